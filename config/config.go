@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -14,9 +16,17 @@ type Config struct {
 	Server      ServerConfig   `mapstructure:"server"`
 	Database    DatabaseConfig `mapstructure:"database"`
 	ERPDatabase DatabaseConfig `mapstructure:"erp_database"`
-	JWT         JWTConfig      `mapstructure:"jwt"`
-	Excel       ExcelConfig    `mapstructure:"excel"`
-	Logger      LoggerConfig   `mapstructure:"logger"`
+	// ERPReportDatabase is the read-only login used to run report queries.
+	// Empty fields fall back to ERPDatabase; without a user reports run on ERPDatabase.
+	ERPReportDatabase DatabaseConfig `mapstructure:"erp_report_database"`
+	JWT               JWTConfig      `mapstructure:"jwt"`
+	Excel             ExcelConfig    `mapstructure:"excel"`
+	Export            ExportConfig   `mapstructure:"export"`
+	Report            ReportConfig   `mapstructure:"report"`
+	SMTP              SMTPConfig     `mapstructure:"smtp"`
+	Logger            LoggerConfig   `mapstructure:"logger"`
+	Security          SecurityConfig `mapstructure:"security"`
+	Auth              AuthConfig     `mapstructure:"auth"`
 }
 
 type ServerConfig struct {
@@ -34,9 +44,28 @@ type DatabaseConfig struct {
 	Timeout  time.Duration `mapstructure:"timeout"`
 }
 
+// JWTConfig holds the token signing keys. When Keys is empty Secret is used
+// as a single HS256 key with the id "default". Otherwise new tokens are signed
+// with SigningKeyID and every key in Keys is accepted for verification.
 type JWTConfig struct {
-	Secret     string `mapstructure:"secret"`
-	ExpiryHour int    `mapstructure:"expiry_hour"`
+	Secret                 string         `mapstructure:"secret"`
+	SigningKeyID           string         `mapstructure:"signing_key_id"`
+	Keys                   []JWTKeyConfig `mapstructure:"keys"`
+	ExpiryHour             int            `mapstructure:"expiry_hour"`
+	AccessExpiryMinutes    int            `mapstructure:"access_expiry_minutes"`
+	RefreshExpiryHour      int            `mapstructure:"refresh_expiry_hour"`
+	CleanupIntervalMinutes int            `mapstructure:"cleanup_interval_minutes"`
+}
+
+// JWTKeyConfig is one key of the key set. HS256 keys use Secret; RS256 and
+// EdDSA keys are read from PEM files. A key with only PublicKeyFile verifies
+// tokens but cannot sign them, which is how a retired key is kept during rotation.
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"`
+	Secret         string `mapstructure:"secret"`
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
 }
 
 type ExcelConfig struct {
@@ -44,6 +73,121 @@ type ExcelConfig struct {
 	MaxSearchMonths int    `mapstructure:"max_search_months"`
 }
 
+// ExportConfig controls background export jobs. Generated files are kept in
+// Directory, which must be shared storage when several instances run.
+type ExportConfig struct {
+	Workers        int       `mapstructure:"workers"`
+	Directory      string    `mapstructure:"directory"`
+	RetentionHours int       `mapstructure:"retention_hours"`
+	PDF            PDFConfig `mapstructure:"pdf"`
+}
+
+// PDFConfig is the company title block printed on every page of PDF exports
+type PDFConfig struct {
+	CompanyName    string `mapstructure:"company_name"`
+	CompanyAddress string `mapstructure:"company_address"`
+	CompanyPhone   string `mapstructure:"company_phone"`
+}
+
+// ReportConfig limits report query execution. Reports can override the timeout.
+type ReportConfig struct {
+	// QueryTimeout is the default execution timeout in seconds
+	QueryTimeout int `mapstructure:"query_timeout"`
+	// MaxRows aborts a query returning more rows than this
+	MaxRows int `mapstructure:"max_rows"`
+	// ScheduleInterval is how often due report schedules are checked, in seconds
+	ScheduleInterval int `mapstructure:"schedule_interval"`
+}
+
+// SMTPConfig is the mail server scheduled reports are sent through. Without
+// a Username the server is used without authentication.
+type SMTPConfig struct {
+	Host               string `mapstructure:"host"`
+	Port               int    `mapstructure:"port"`
+	Username           string `mapstructure:"username"`
+	Password           string `mapstructure:"password"`
+	From               string `mapstructure:"from"`
+	StartTLS           bool   `mapstructure:"start_tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	Timeout            int    `mapstructure:"timeout"`
+}
+
+type SecurityConfig struct {
+	Login     LoginSecurityConfig  `mapstructure:"login"`
+	Password  PasswordPolicyConfig `mapstructure:"password"`
+	TwoFactor TwoFactorConfig      `mapstructure:"two_factor"`
+}
+
+// TwoFactorConfig controls TOTP. Users in RequiredRoles must enrol before
+// they can use any other endpoint.
+type TwoFactorConfig struct {
+	Issuer           string   `mapstructure:"issuer"`
+	RequiredRoles    []string `mapstructure:"required_roles"`
+	ChallengeMinutes int      `mapstructure:"challenge_minutes"`
+	MaxAttempts      int      `mapstructure:"max_attempts"`
+	RecoveryCodes    int      `mapstructure:"recovery_codes"`
+}
+
+// PasswordPolicyConfig is applied to every password set for a local user.
+// HistorySize previous passwords cannot be reused; temporary passwords issued
+// by an administrator expire after TempPasswordHours.
+type PasswordPolicyConfig struct {
+	MinLength         int  `mapstructure:"min_length"`
+	RequireUpper      bool `mapstructure:"require_upper"`
+	RequireLower      bool `mapstructure:"require_lower"`
+	RequireDigit      bool `mapstructure:"require_digit"`
+	RequireSpecial    bool `mapstructure:"require_special"`
+	HistorySize       int  `mapstructure:"history_size"`
+	TempPasswordHours int  `mapstructure:"temp_password_hours"`
+}
+
+// LoginSecurityConfig controls brute-force protection on the login endpoint.
+// An account or IP is locked for LockoutMinutes once it reaches its failure
+// limit within WindowMinutes. A limit of 0 disables that check.
+type LoginSecurityConfig struct {
+	MaxFailuresPerAccount int `mapstructure:"max_failures_per_account"`
+	MaxFailuresPerIP      int `mapstructure:"max_failures_per_ip"`
+	WindowMinutes         int `mapstructure:"window_minutes"`
+	LockoutMinutes        int `mapstructure:"lockout_minutes"`
+}
+
+// AuthConfig lists the authentication providers tried in order on login.
+// Known providers are "local" and "ldap"; an empty list means local only.
+type AuthConfig struct {
+	Providers []string   `mapstructure:"providers"`
+	LDAP      LDAPConfig `mapstructure:"ldap"`
+}
+
+type LDAPConfig struct {
+	URL                string `mapstructure:"url"`
+	StartTLS           bool   `mapstructure:"start_tls"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+	BindDN             string `mapstructure:"bind_dn"`
+	BindPassword       string `mapstructure:"bind_password"`
+	BaseDN             string `mapstructure:"base_dn"`
+	// UserFilter is a search filter with a single %s for the escaped username
+	UserFilter  string         `mapstructure:"user_filter"`
+	Timeout     int            `mapstructure:"timeout"`
+	DefaultRole string         `mapstructure:"default_role"`
+	Attributes  LDAPAttributes `mapstructure:"attributes"`
+	// LinkLocalUsers lets a directory account sign in as the local user of
+	// the same username, turning it into an LDAP user. Off by default, so a
+	// directory entry cannot take over a local account such as admin.
+	LinkLocalUsers bool `mapstructure:"link_local_users"`
+	// DepartmentMap maps a value of the department attribute to a department
+	// code. Values not listed are matched against department codes directly.
+	DepartmentMap map[string]string `mapstructure:"department_map"`
+}
+
+type LDAPAttributes struct {
+	FullName   string `mapstructure:"full_name"`
+	Email      string `mapstructure:"email"`
+	Department string `mapstructure:"department"`
+}
+
+// PlaceholderJWTSecret is the secret shipped in config.yaml; it must be replaced in production.
+const PlaceholderJWTSecret = "your_jwt_secret_key"
+
 type LoggerConfig struct {
 	Level string `mapstructure:"level"`
 	Path  string `mapstructure:"path"`
@@ -61,6 +205,8 @@ func LoadConfig() (*Config, error) {
 
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("KANBAN")
+	// Nested keys are read from e.g. KANBAN_JWT_SECRET
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config: %w", err)
@@ -71,9 +217,40 @@ func LoadConfig() (*Config, error) {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
 	return config, nil
 }
 
+// IsProduction reports whether the server runs in the production environment
+func (c *Config) IsProduction() bool {
+	return strings.EqualFold(c.Server.Env, "production") || strings.EqualFold(c.Server.Env, "prod")
+}
+
+// Validate rejects settings that are unsafe for the current environment
+func (c *Config) Validate() error {
+	if !c.IsProduction() {
+		return nil
+	}
+	if !c.HasERPReportDatabase() {
+		log.Printf("Warning: erp_report_database is not set, report queries run with the main ERP login")
+	}
+	if len(c.JWT.Keys) == 0 {
+		if c.JWT.Secret == "" || c.JWT.Secret == PlaceholderJWTSecret {
+			return fmt.Errorf("jwt.secret must be set to a real secret in production")
+		}
+		return nil
+	}
+	for _, key := range c.JWT.Keys {
+		if strings.EqualFold(key.Algorithm, "HS256") && (key.Secret == "" || key.Secret == PlaceholderJWTSecret) {
+			return fmt.Errorf("jwt key %q must use a real secret in production", key.ID)
+		}
+	}
+	return nil
+}
+
 func MustConfig() *Config {
 	cfg, err := LoadConfig()
 	if err != nil {
@@ -94,27 +271,174 @@ func (c *Config) GetDSN() string {
 }
 
 func (c *Config) GetERPDatabaseDSN() string {
+	return erpDSN(c.ERPDatabase)
+}
+
+// HasERPReportDatabase reports whether a separate read-only login is configured for report queries
+func (c *Config) HasERPReportDatabase() bool {
+	return c.ERPReportDatabase.User != ""
+}
+
+// GetERPReportDatabaseDSN returns the DSN of the read-only report login
+func (c *Config) GetERPReportDatabaseDSN() string {
+	db := c.ERPReportDatabase
+	if db.Host == "" {
+		db.Host = c.ERPDatabase.Host
+	}
+	if db.Port == 0 {
+		db.Port = c.ERPDatabase.Port
+	}
+	if db.DBName == "" {
+		db.DBName = c.ERPDatabase.DBName
+	}
+	if db.Timeout == 0 {
+		db.Timeout = c.ERPDatabase.Timeout
+	}
+	return erpDSN(db)
+}
+
+func erpDSN(db DatabaseConfig) string {
 	// Sử dụng url.URL để xây dựng connection string an toàn
 	u := &url.URL{
 		Scheme: "sqlserver",
-		User:   url.UserPassword(c.ERPDatabase.User, c.ERPDatabase.Password),
-		Host:   fmt.Sprintf("%s:%d", c.ERPDatabase.Host, c.ERPDatabase.Port),
+		User:   url.UserPassword(db.User, db.Password),
+		Host:   fmt.Sprintf("%s:%d", db.Host, db.Port),
 	}
 
 	query := u.Query()
-	query.Add("database", c.ERPDatabase.DBName)
+	query.Add("database", db.DBName)
 	query.Add("encrypt", "disable")
 	query.Add("trustServerCertificate", "true")
 	// Chuyển timeout từ duration sang mili-giây hoặc giây tùy driver,
 	// driver mssql thường tính bằng giây trong connection string nhưng int64
-	query.Add("connection timeout", fmt.Sprintf("%d", int(c.ERPDatabase.Timeout.Seconds())))
+	query.Add("connection timeout", fmt.Sprintf("%d", int(db.Timeout.Seconds())))
 
 	u.RawQuery = query.Encode()
 
 	return u.String()
 }
 
-// GetJWTExpiry returns JWT expiry duration
+// GetJWTExpiry returns the access token lifetime. access_expiry_minutes takes
+// precedence over the legacy expiry_hour setting.
 func (c *Config) GetJWTExpiry() time.Duration {
+	if c.JWT.AccessExpiryMinutes > 0 {
+		return time.Duration(c.JWT.AccessExpiryMinutes) * time.Minute
+	}
 	return time.Duration(c.JWT.ExpiryHour) * time.Hour
 }
+
+// GetRefreshTokenExpiry returns the refresh token lifetime
+func (c *Config) GetRefreshTokenExpiry() time.Duration {
+	if c.JWT.RefreshExpiryHour <= 0 {
+		return 7 * 24 * time.Hour
+	}
+	return time.Duration(c.JWT.RefreshExpiryHour) * time.Hour
+}
+
+// GetTokenCleanupInterval returns how often expired revoked tokens are purged
+func (c *Config) GetTokenCleanupInterval() time.Duration {
+	if c.JWT.CleanupIntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.JWT.CleanupIntervalMinutes) * time.Minute
+}
+
+// GetLoginFailureWindow returns the window in which failed logins are counted
+func (c *Config) GetLoginFailureWindow() time.Duration {
+	if c.Security.Login.WindowMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.Security.Login.WindowMinutes) * time.Minute
+}
+
+// GetLoginLockoutDuration returns how long an account or IP stays locked
+func (c *Config) GetLoginLockoutDuration() time.Duration {
+	if c.Security.Login.LockoutMinutes <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.Security.Login.LockoutMinutes) * time.Minute
+}
+
+// GetLDAPTimeout returns the timeout for LDAP dial and operations
+func (c *Config) GetLDAPTimeout() time.Duration {
+	if c.Auth.LDAP.Timeout <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.Auth.LDAP.Timeout) * time.Second
+}
+
+// GetReportQueryTimeout returns the default execution timeout of report queries
+func (c *Config) GetReportQueryTimeout() time.Duration {
+	if c.Report.QueryTimeout <= 0 {
+		return 2 * time.Minute
+	}
+	return time.Duration(c.Report.QueryTimeout) * time.Second
+}
+
+// GetReportMaxRows returns the row count after which a report query is aborted
+func (c *Config) GetReportMaxRows() int {
+	if c.Report.MaxRows <= 0 {
+		return 100000
+	}
+	return c.Report.MaxRows
+}
+
+// GetReportScheduleInterval returns how often due report schedules are checked
+func (c *Config) GetReportScheduleInterval() time.Duration {
+	if c.Report.ScheduleInterval <= 0 {
+		return time.Minute
+	}
+	return time.Duration(c.Report.ScheduleInterval) * time.Second
+}
+
+// GetExportWorkers returns how many export jobs run at the same time
+func (c *Config) GetExportWorkers() int {
+	if c.Export.Workers <= 0 {
+		return 2
+	}
+	return c.Export.Workers
+}
+
+// GetExportDirectory returns where export job files are written
+func (c *Config) GetExportDirectory() string {
+	if c.Export.Directory != "" {
+		return c.Export.Directory
+	}
+	base := c.Excel.DownloadPath
+	if base == "" {
+		base = "public/downloads"
+	}
+	return filepath.Join(base, "exports")
+}
+
+// GetExportRetention returns how long finished export jobs and their files are kept
+func (c *Config) GetExportRetention() time.Duration {
+	if c.Export.RetentionHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.Export.RetentionHours) * time.Hour
+}
+
+// GetSMTPTimeout returns the timeout for delivering one mail
+func (c *Config) GetSMTPTimeout() time.Duration {
+	if c.SMTP.Timeout <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.SMTP.Timeout) * time.Second
+}
+
+// GetTempPasswordExpiry returns how long an administrator-issued temporary password is valid
+func (c *Config) GetTempPasswordExpiry() time.Duration {
+	if c.Security.Password.TempPasswordHours <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.Security.Password.TempPasswordHours) * time.Hour
+}
+
+// GetTwoFactorChallengeExpiry returns how long a login challenge waits for the second factor
+func (c *Config) GetTwoFactorChallengeExpiry() time.Duration {
+	if c.Security.TwoFactor.ChallengeMinutes <= 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(c.Security.TwoFactor.ChallengeMinutes) * time.Minute
+}
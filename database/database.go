@@ -5,6 +5,7 @@ import (
 	"cqs-kanban/config"
 	"cqs-kanban/internal/models"
 	"cqs-kanban/logger"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -17,13 +18,16 @@ import (
 type Database interface {
 	DB() *gorm.DB
 	ERPDB() *gorm.DB
+	// ReportDB is the read-only ERP connection for report queries
+	ReportDB() *gorm.DB
 	Close() error
 	Ping() error
 }
 
 type database struct {
-	db    *gorm.DB
-	erpDB *gorm.DB
+	db       *gorm.DB
+	erpDB    *gorm.DB
+	reportDB *gorm.DB
 }
 
 func NewDatabase(cfg *config.Config, log *logger.AppLogger) (Database, error) {
@@ -36,6 +40,14 @@ func NewDatabase(cfg *config.Config, log *logger.AppLogger) (Database, error) {
 		panic(fmt.Sprintf("connect to erp database with err: [%v]", err))
 	}
 
+	reportDB := erpDB
+	if cfg.HasERPReportDatabase() {
+		reportDB, err = newERPDatabase(cfg.GetERPReportDatabaseDSN(), logger)
+		if err != nil {
+			panic(fmt.Sprintf("connect to erp report database with err: [%v]", err))
+		}
+	}
+
 	// Main Database
 	db, err := newDatabase(cfg.GetDSN(), logger)
 	if err != nil {
@@ -43,8 +55,9 @@ func NewDatabase(cfg *config.Config, log *logger.AppLogger) (Database, error) {
 	}
 
 	return &database{
-		db:    db,
-		erpDB: erpDB,
+		db:       db,
+		erpDB:    erpDB,
+		reportDB: reportDB,
 	}, nil
 }
 
@@ -61,8 +74,14 @@ func newDatabase(dns string, logger gormlogger.Interface) (*gorm.DB, error) {
 	db, err := gorm.Open(postgres.Open(dns), &gorm.Config{
 		Logger: logger,
 	})
-	db.AutoMigrate(&models.Report{}, &models.ReportColumn{}, &models.Department{}, &models.Menu{},
-		&models.User{}, &models.Operation{}, &models.AccessLog{})
+	db.AutoMigrate(&models.Report{}, &models.ReportColumn{}, &models.ReportShare{}, &models.ReportParameter{}, &models.ReportRevision{}, &models.ReportSchedule{}, &models.ReportScheduleRun{}, &models.ExportJob{}, &models.Department{}, &models.Menu{},
+		&models.User{}, &models.Operation{}, &models.AccessLog{},
+		&models.Permission{}, &models.RolePermission{}, &models.RevokedToken{},
+		&models.RefreshToken{},
+		&models.APIKey{}, &models.LoginAttempt{}, &models.Lockout{},
+		&models.PasswordHistory{}, &models.UserTOTP{}, &models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.Session{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
@@ -96,12 +115,28 @@ func (d *database) ERPDB() *gorm.DB {
 	return d.erpDB
 }
 
+func (d *database) ReportDB() *gorm.DB {
+	return d.reportDB
+}
+
+// Close closes the main and ERP pools, and the report pool when it is separate
 func (d *database) Close() error {
-	sqlDB, err := d.db.DB()
-	if err != nil {
-		return err
+	pools := []*gorm.DB{d.db, d.erpDB}
+	if d.reportDB != d.erpDB {
+		pools = append(pools, d.reportDB)
+	}
+	var errs []error
+	for _, pool := range pools {
+		sqlDB, err := pool.DB()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := sqlDB.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return sqlDB.Close()
+	return errors.Join(errs...)
 }
 
 func (d *database) Ping() error {
@@ -15,6 +15,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	fiber "github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
@@ -34,6 +35,11 @@ type App struct {
 	operationService service.OperationService
 	menuService      service.MenuService
 	forecastService  service.ForecastService
+
+	permissionService service.PermissionService
+	apiKeyService     service.APIKeyService
+	scheduleService   service.ReportScheduleService
+	exportJobService  service.ExportJobService
 }
 
 // New creates a new application instance
@@ -61,15 +67,15 @@ func New(cfg *config.Config, db database.Database) *App {
 
 	// Report
 	ctx := context.Background()
-	baseErpRepo := repository.NewBaseERPRepository(ctx, app.db.ERPDB())
+	baseErpRepo := repository.NewBaseERPRepository(ctx, app.db.ReportDB())
 	reportRepo := repository.NewReportRepo(app.db.DB())
 	operationRepo := repository.NewOperationRepo(app.db.DB())
 	logger := logger.NewConsoleLogger()
-	reportService := service.NewReportService(reportRepo, baseErpRepo, operationRepo, logger)
-	reportHandler := handler.NewReportHandler(reportService)
+	copmaRepo := repository.NewCopmaRepo(app.db.ERPDB())
+	reportService := service.NewReportService(reportRepo, baseErpRepo, operationRepo, copmaRepo, logger, app.config)
 	// Menu
 	menuRepo := repository.NewMenuRepo(app.db.DB())
-	menuService := service.NewMenuService(menuRepo)
+	menuService := service.NewMenuService(menuRepo, reportRepo, operationRepo, logger)
 	menuHandler := handler.NewMenuHandler(menuService)
 
 	// Department
@@ -78,28 +84,69 @@ func New(cfg *config.Config, db database.Database) *App {
 	departmentHandler := handler.NewDepartmentHandler(departmentService)
 	// Users
 	userRepo := repository.NewUserRepo(app.db.DB())
-	userService := service.NewUserService(userRepo)
+	passwordHistoryRepo := repository.NewPasswordHistoryRepo(app.db.DB())
+	passwordService := service.NewPasswordService(userRepo, passwordHistoryRepo, app.config, logger)
+	userService := service.NewUserService(userRepo, passwordService)
 	userHandler := handler.NewUserHandler(userService)
 	// SalesCopi04
 
 	saleCopi04Repo := repository.NewSaleCopi04(app.db.ERPDB())
-	saleCopi04Service := service.NewSaleCopi04Service(saleCopi04Repo)
+	saleCopi04Service := service.NewSaleCopi04Service(saleCopi04Repo, app.config)
 	saleCopi04Handler := handler.NewSaleCopi04Handler(saleCopi04Service)
 	// Copma
-	copmaRepo := repository.NewCopmaRepo(app.db.ERPDB())
 	copmaService := service.NewCopmaService(copmaRepo)
 	copmaHandler := handler.NewCopmaHandler(copmaService)
 	// Forecast
-		forecasrRepo := repository.NewForecastRepo(app.db.ERPDB())
-	forecastService := service.NewForecastService(forecasrRepo, operationRepo, logger)
-	forecastHandler := handler.NewForecastHandler(forecastService)
-	authService := service.NewAuthService(userRepo, app.config)
-	authHandler := handler.NewAuthHandler(authService)
-	adminService := service.NewAdminService(operationRepo, userRepo, departmentRepo, reportRepo, logger)
+	forecasrRepo := repository.NewForecastRepo(app.db.ERPDB())
+	forecastService := service.NewForecastService(forecasrRepo, operationRepo, logger, app.config)
+	// Permissions
+	permissionRepo := repository.NewPermissionRepo(app.db.DB())
+	permissionService := service.NewPermissionService(permissionRepo, logger)
+	if err := permissionService.SeedDefaults(ctx); err != nil {
+		log.Fatalf("Error seeding default permissions: %v", err)
+	}
+	permissionHandler := handler.NewPermissionHandler(permissionService)
+	// Export jobs run with their owner's current permissions
+	exportJobRepo := repository.NewExportJobRepo(app.db.DB())
+	exportJobService := service.NewExportJobService(exportJobRepo, reportService, forecastService, userRepo, permissionService, logger, app.config)
+	exportJobHandler := handler.NewExportJobHandler(exportJobService)
+	reportHandler := handler.NewReportHandler(reportService, exportJobService)
+	forecastHandler := handler.NewForecastHandler(forecastService, exportJobService)
+	tokenRepo := repository.NewTokenRepo(app.db.DB())
+	loginAttemptRepo := repository.NewLoginAttemptRepo(app.db.DB())
+	loginGuard := service.NewLoginGuard(loginAttemptRepo, app.config, logger)
+	authProviders, err := service.NewAuthProviders(app.config, userRepo, departmentRepo, logger)
+	if err != nil {
+		log.Fatalf("Error configuring auth providers: %v", err)
+	}
+	twoFactorRepo := repository.NewTwoFactorRepo(app.db.DB())
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, app.config, logger)
+	sessionRepo := repository.NewSessionRepo(app.db.DB())
+	sessionService := service.NewSessionService(sessionRepo, tokenRepo, app.config, logger)
+	jwtKeySet, err := service.NewJWTKeySet(app.config)
+	if err != nil {
+		log.Fatalf("Error loading JWT keys: %v", err)
+	}
+	authService := service.NewAuthService(userRepo, tokenRepo, loginGuard, twoFactorService, sessionService, authProviders, jwtKeySet, app.config)
+	authHandler := handler.NewAuthHandler(authService, passwordService, twoFactorService, sessionService)
+	adminService := service.NewAdminService(operationRepo, userRepo, departmentRepo, reportRepo, loginAttemptRepo, logger)
 	adminHandler := handler.NewAdminHandler(adminService)
+	// Report schedules run with their creator's current permissions
+	reportScheduleRepo := repository.NewReportScheduleRepo(app.db.DB())
+	scheduleService := service.NewReportScheduleService(reportScheduleRepo, reportService, userRepo, permissionService, service.NewSMTPMailer(app.config), logger)
+	reportScheduleHandler := handler.NewReportScheduleHandler(scheduleService)
+	// API keys
+	apiKeyRepo := repository.NewAPIKeyRepo(app.db.DB())
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo, userRepo, logger)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
 	app.authService = authService
+	app.permissionService = permissionService
+	app.apiKeyService = apiKeyService
+	app.scheduleService = scheduleService
+	app.exportJobService = exportJobService
 	app.handlers = []handler.BaseHandler{
 		reportHandler,
+		reportScheduleHandler,
 		menuHandler,
 		departmentHandler,
 		userHandler,
@@ -108,6 +155,9 @@ func New(cfg *config.Config, db database.Database) *App {
 		saleCopi04Handler,
 		copmaHandler,
 		forecastHandler,
+		exportJobHandler,
+		permissionHandler,
+		apiKeyHandler,
 	}
 
 	return app
@@ -129,13 +179,31 @@ func (a *App) SetupRoutes() {
 	// White list routes
 	whitelist := []string{
 		"/api/auth/login",
-		"api/forecasts",
-		"/api/admin/dashboard",
-		"/api/admin/dashboard/access-trend",
+		"/api/auth/refresh",
+		"/api/auth/2fa/verify",
+		"/api/auth/jwks",
 	}
 
 	// Protected routes
-	protected := api.Group("/", middleware.JWTMiddleware(a.authService, whitelist))
+	protected := api.Group("/",
+		middleware.JWTMiddleware(a.authService, a.apiKeyService, whitelist),
+		middleware.PasswordChangeMiddleware([]string{
+			"/api/auth/password",
+			"/api/auth/profile",
+			"/api/auth/logout",
+			"/api/auth/logout-all",
+		}),
+		middleware.TwoFactorEnrollmentMiddleware([]string{
+			"/api/auth/2fa",
+			"/api/auth/2fa/enroll",
+			"/api/auth/2fa/confirm",
+			"/api/auth/profile",
+			"/api/auth/password",
+			"/api/auth/logout",
+			"/api/auth/logout-all",
+		}),
+		middleware.PermissionMiddleware(a.permissionService),
+	)
 
 	// Setup all handler routes
 	for _, handler := range a.handlers {
@@ -155,6 +223,12 @@ func (a *App) Start() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.purgeRevokedTokens(ctx)
+	go a.runReportSchedules(ctx)
+	go a.exportJobService.Run(ctx)
+
 	go func() {
 		addr := fmt.Sprintf(":%s", a.config.Server.Port)
 		if err := a.fiber.Listen(addr); err != nil {
@@ -165,6 +239,7 @@ func (a *App) Start() {
 	log.Printf("Server started on port %s", a.config.Server.Port)
 	<-sigChan
 	log.Println("Shutting down server...")
+	cancel()
 
 	if err := a.db.Close(); err != nil {
 		log.Printf("Error closing database connection: %v", err)
@@ -177,6 +252,50 @@ func (a *App) Start() {
 	log.Println("Server gracefully stopped")
 }
 
+// purgeRevokedTokens periodically deletes revoked token entries whose tokens have expired
+func (a *App) purgeRevokedTokens(ctx context.Context) {
+	ticker := time.NewTicker(a.config.GetTokenCleanupInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := a.authService.PurgeRevokedTokens(ctx)
+			if err != nil {
+				log.Printf("Error purging revoked tokens: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Purged %d expired revoked tokens", count)
+			}
+		}
+	}
+}
+
+// runReportSchedules periodically runs the report schedules that are due
+func (a *App) runReportSchedules(ctx context.Context) {
+	ticker := time.NewTicker(a.config.GetReportScheduleInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			count, err := a.scheduleService.RunDue(ctx)
+			if err != nil {
+				log.Printf("Error running report schedules: %v", err)
+				continue
+			}
+			if count > 0 {
+				log.Printf("Ran %d scheduled reports", count)
+			}
+		}
+	}
+}
+
 func errorHandler(c fiber.Ctx, err error) error {
 	code := fiber.StatusInternalServerError
 	message := "Internal Server Error"
@@ -106,3 +106,19 @@ type SecurityAlertResponse struct {
 	LastFailedAt time.Time `json:"last_failed_at"`
 	Description  string    `json:"description"`
 }
+
+type LoginAttemptQueryReq struct {
+	Username  string `query:"username"`
+	IPAddress string `query:"ip"`
+	Hours     int    `query:"hours"`
+	Limit     int    `query:"limit"`
+}
+
+type LockoutResponse struct {
+	ID          int64     `json:"id"`
+	Scope       string    `json:"scope"`
+	Target      string    `json:"target"`
+	FailedCount int64     `json:"failed_count"`
+	LockedAt    time.Time `json:"locked_at"`
+	LockedUntil time.Time `json:"locked_until"`
+}
@@ -0,0 +1,30 @@
+package dto
+
+import "time"
+
+type APIKeyCreateReq struct {
+	Name      string     `json:"name" validate:"required"`
+	OwnerID   int64      `json:"owner_id"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyRes struct {
+	ID            int64      `json:"id"`
+	Name          string     `json:"name"`
+	Prefix        string     `json:"prefix"`
+	OwnerID       int64      `json:"owner_id"`
+	OwnerUsername string     `json:"owner_username,omitempty"`
+	Scopes        []string   `json:"scopes"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt    *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy     int64      `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// APIKeySecretRes is returned on create and rotate; Key is shown only once.
+type APIKeySecretRes struct {
+	APIKeyRes
+	Key string `json:"key"`
+}
@@ -1,18 +1,70 @@
 package dto
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type LoginRequest struct {
 	Username string `json:"username" validate:"required"`
 	Password string `json:"password" validate:"required"`
+	// Filled by the handler from the request, not the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
 }
 
 type LoginResponse struct {
-	Token        string `json:"token"`
-	UserID       int64  `json:"user_id"`
-	Username     string `json:"username"`
-	DepartmentID int64  `json:"department_id"`
-	Role         string `json:"role"`
+	Token            string    `json:"token"`
+	ExpiresIn        int64     `json:"expires_in"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	UserID           int64     `json:"user_id"`
+	Username         string    `json:"username"`
+	DepartmentID     int64     `json:"department_id"`
+	Role             string    `json:"role"`
+
+	MustChangePassword bool `json:"must_change_password"`
+
+	// Set instead of the tokens when a second factor is needed
+	TwoFactorRequired  bool       `json:"two_factor_required,omitempty"`
+	ChallengeToken     string     `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt *time.Time `json:"challenge_expires_at,omitempty"`
+	// Set when the role requires 2FA and the user has not enrolled yet
+	TwoFactorEnrollRequired bool `json:"two_factor_enroll_required,omitempty"`
+}
+
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code" validate:"required"`
+	// Filled by the handler from the request, not the body
+	IPAddress string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type TwoFactorStatusResponse struct {
+	Enabled                bool  `json:"enabled"`
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type ResetPasswordResponse struct {
+	TemporaryPassword string    `json:"temporary_password"`
+	ExpiresAt         time.Time `json:"expires_at"`
 }
 
 type ValidateTokenRequest struct {
@@ -24,7 +76,16 @@ type ValidateTokenResponse struct {
 }
 
 type LogoutRequest struct {
-	Token string `json:"token" `
+	Token        string `json:"token" `
+	RefreshToken string `json:"refresh_token"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type RevokeTokensRequest struct {
+	Reason string `json:"reason"`
 }
 
 type LogoutResponse struct {
@@ -37,5 +98,25 @@ type TokenClaims struct {
 	DepartmentID int64  `json:"department_id"`
 	Exp          int64  `json:"exp,omitempty"`
 	Role         string `json:"role,omitempty"`
+	SessionID    string `json:"sid,omitempty"`
+
+	MustChangePassword      bool `json:"must_change_password,omitempty"`
+	TwoFactorEnrollRequired bool `json:"two_factor_enroll_required,omitempty"`
 	jwt.RegisteredClaims
 }
+
+// JWK is a public key in JSON Web Key format (RFC 7517)
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSResponse struct {
+	Keys []JWK `json:"keys"`
+}
@@ -6,8 +6,44 @@ type BaseERPReq struct {
 	SqlQuery string
 	FromDate time.Time
 	ToDate   time.Time
+	// Params are bound as named parameters next to @FromDate and @ToDate
+	Params map[string]any
+	// Sort, Filters and paging are applied around the query by SQL Server.
+	// Rows are ordered by the GroupBy columns first, keeping groups together.
+	GroupBy  []string
+	Sort     string
+	SortDesc bool
+	Filters  []BaseERPFilter
+	Offset   int
+	// Limit of 0 returns every row
+	Limit int
+	// MaxRows aborts the query when it returns more rows; 0 or less means no limit
+	MaxRows int
+}
+
+// BaseERPFilter matches a result column: strings by substring, dates by
+// ISO 8601 prefix and other types by equality.
+type BaseERPFilter struct {
+	Column string
+	Type   string
+	Value  any
 }
 
 type BaseERP struct {
 	Data []map[string]any
+	// Total is the filtered row count, set when the query is paged
+	Total int64
+}
+
+// BaseERPColumn describes a column of an ERP result set
+type BaseERPColumn struct {
+	Name         string
+	DatabaseType string
+}
+
+// BaseERPPreview holds the first rows of a query and its result set columns
+type BaseERPPreview struct {
+	Columns   []BaseERPColumn
+	Data      []map[string]any
+	Truncated bool
 }
@@ -0,0 +1,36 @@
+package dto
+
+import "time"
+
+// ExportJobRequest is the export request kept with a job, so that it can run
+// after a restart.
+type ExportJobRequest struct {
+	FromDate *time.Time          `json:"from_date,omitempty"`
+	ToDate   *time.Time          `json:"to_date,omitempty"`
+	Params   map[string][]string `json:"params,omitempty"`
+	Filters  map[string]string   `json:"filters,omitempty"`
+	Sort     string              `json:"sort,omitempty"`
+	// Format, Delimiter, DecimalSeparator, BOM and Orientation are as in ReportReq
+	Format           string `json:"format,omitempty"`
+	Delimiter        string `json:"delimiter,omitempty"`
+	DecimalSeparator string `json:"decimal_separator,omitempty"`
+	BOM              bool   `json:"bom,omitempty"`
+	Orientation      string `json:"orientation,omitempty"`
+}
+
+type ExportJobRes struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	ReportID int64  `json:"report_id,omitempty"`
+	Status   string `json:"status"`
+	// Progress is a percentage
+	Progress    int        `json:"progress"`
+	Error       string     `json:"error,omitempty"`
+	FileName    string     `json:"file_name,omitempty"`
+	FileSize    int64      `json:"file_size,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+type RolePermissionCreateReq struct {
+	Role           string `json:"role" validate:"required"`
+	PermissionCode string `json:"permission_code" validate:"required"`
+	DepartmentID   int64  `json:"department_id"`
+}
+
+type RolePermissionRes struct {
+	ID             int64     `json:"id"`
+	Role           string    `json:"role"`
+	PermissionCode string    `json:"permission_code"`
+	DepartmentID   int64     `json:"department_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type PermissionRes struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
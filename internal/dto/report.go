@@ -10,6 +10,27 @@ type ReportReq struct {
 	FromDate *time.Time `json:"from_date,omitempty"`
 	ToDate   *time.Time `json:"to_date,omitempty"`
 	Period   *string    `json:"period,omitempty"`
+	// Params holds the report parameters from the query string, by name
+	Params map[string][]string `json:"-" query:"-"`
+	Page   int                 `json:"page,omitempty" query:"page"`
+	// Without Page and PageSize every row is returned; with either one the
+	// other defaults to the first page or the default page size. Reports with
+	// group-by columns are paged by their grouped rows.
+	PageSize int `json:"page_size,omitempty" query:"page_size"`
+	// Sort is a column code, prefixed with "-" for descending order
+	Sort string `json:"sort,omitempty" query:"sort"`
+	// Filters holds filter[code]=value query parameters, by column code
+	Filters map[string]string `json:"-" query:"-"`
+	// Refresh bypasses the result cache and stores the fresh result
+	Refresh bool `json:"refresh,omitempty" query:"refresh"`
+	// Format is the export file format: xlsx (default), csv or tsv
+	Format string `json:"format,omitempty" query:"format"`
+	// Delimiter, DecimalSeparator and BOM apply to csv and tsv exports
+	Delimiter        string `json:"delimiter,omitempty" query:"delimiter"`
+	DecimalSeparator string `json:"decimal_separator,omitempty" query:"decimal_separator"`
+	BOM              bool   `json:"bom,omitempty" query:"bom"`
+	// Orientation of pdf exports: landscape (default) or portrait
+	Orientation string `json:"orientation,omitempty" query:"orientation"`
 }
 
 type ReportRes struct {
@@ -17,23 +38,67 @@ type ReportRes struct {
 	ReportType string                `json:"report_type"`
 	ReportName string                `json:"report_name"`
 	Columns    []models.ReportColumn `json:"columns"`
-	Data       []map[string]any      `json:"data"`
+	// Data holds the rows of reports without group-by columns
+	Data []map[string]any `json:"data"`
+	// Rows lays out the result of reports with group-by columns, with group
+	// headers, subtotals and the grand total, in place of Data. Aggregates
+	// cover the whole result; with paging, Rows holds a page of the layout.
+	Rows       []ReportRow       `json:"rows,omitempty"`
+	Pagination *ReportPagination `json:"pagination,omitempty"`
+	FromCache  bool              `json:"from_cache"`
+	// CachedAt and CacheAgeSeconds describe the cached result when FromCache is set
+	CachedAt        *time.Time `json:"cached_at,omitempty"`
+	CacheAgeSeconds int64      `json:"cache_age_seconds,omitempty"`
+}
+
+// ReportRow is a row of a grouped report: a group header holding the group
+// values, a detail row, a subtotal holding the group values and aggregates,
+// or the grand total. Level is 1 for the outermost group, one more for each
+// group inside it and one more again for detail rows; the total is level 0.
+// Count is the number of detail rows below a header, subtotal or total.
+type ReportRow struct {
+	Type   string         `json:"type"`
+	Level  int            `json:"level"`
+	Count  int            `json:"count,omitempty"`
+	Values map[string]any `json:"values"`
+}
+
+// ReportPagination pages Data, or Rows for grouped reports
+type ReportPagination struct {
+	Page       int   `json:"page"`
+	PageSize   int   `json:"page_size"`
+	TotalCount int64 `json:"total_count"`
+	TotalPages int   `json:"total_pages"`
 }
 
 type ReportCreateReq struct {
-	ReportType     string          `json:"report_type"`
-	ReportName     string          `json:"report_name"`
-	DepartmentID   string          `json:"department_id"`
-	QueryStatement string          `json:"query_statement"`
-	Columns        []*ReportColumn `json:"columns"`
+	ReportType          string             `json:"report_type"`
+	ReportName          string             `json:"report_name"`
+	DepartmentID        string             `json:"department_id"`
+	QueryStatement      string             `json:"query_statement"`
+	Columns             []*ReportColumn    `json:"columns"`
+	Parameters          []*ReportParameter `json:"parameters"`
+	CacheTTLSeconds     int                `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds int                `json:"query_timeout_seconds"`
+	// Comment is stored on the revision recorded for this change
+	Comment string `json:"comment"`
+	// CreatedBy is set from the caller, not the request body
+	CreatedBy int64 `json:"-"`
 }
 
 type ReportCreateModel struct {
-	ReportType     string          `json:"report_type"`
-	ReportName     string          `json:"report_name"`
-	DepartmentID   string          `json:"department_id"`
-	QueryStatement string          `json:"sql_query"`
-	Columns        []*ReportColumn `json:"columns"`
+	ReportType          string             `json:"report_type"`
+	ReportName          string             `json:"report_name"`
+	DepartmentID        string             `json:"department_id"`
+	QueryStatement      string             `json:"sql_query"`
+	Columns             []*ReportColumn    `json:"columns"`
+	Parameters          []*ReportParameter `json:"parameters"`
+	CacheTTLSeconds     int                `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds int                `json:"query_timeout_seconds"`
+	// Comment is stored on the revision recorded for this change
+	Comment string `json:"comment"`
+	// CreatedBy is set from the caller, not the request body
+	CreatedBy int64 `json:"-"`
 }
 
 type ReportUpdateModel struct {
@@ -42,31 +107,186 @@ type ReportUpdateModel struct {
 	DepartmentID   *string         `json:"department_id"`
 	QueryStatement *string         `json:"query_statement"`
 	Columns        []*ReportColumn `json:"columns"`
+	// Parameters replaces the report parameters when not nil
+	Parameters          []*ReportParameter `json:"parameters"`
+	CacheTTLSeconds     *int               `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds *int               `json:"query_timeout_seconds"`
+	// Comment is stored on the revision recorded for this change
+	Comment string `json:"comment"`
+	// UpdatedBy is set from the caller, not the request body
+	UpdatedBy int64 `json:"-"`
 }
 
 type ReportColumn struct {
-	ID       int64  `json:"id"`
-	ReportID int64  `json:"report_id"`
-	Title    string `json:"title"`
-	Code     string `json:"code"`
-	Type     string `json:"type"`
-	Num      int64  `json:"num"`
+	ID           int64  `json:"id"`
+	ReportID     int64  `json:"report_id"`
+	Title        string `json:"title"`
+	Code         string `json:"code"`
+	Type         string `json:"type"`
+	Num          int64  `json:"num"`
+	Decimals     *int   `json:"decimals"`
+	Width        int    `json:"width"`
+	Align        string `json:"align"`
+	Hidden       bool   `json:"hidden"`
+	NumberFormat string `json:"number_format"`
+	GroupLevel   int    `json:"group_level"`
+	Aggregate    string `json:"aggregate"`
 }
 
 type ReportFileResponse struct {
-	ReportName  string    `json:"report_name"`
-	FileName    string    `json:"file_name"`
-	FileDetal   any       `json:"filed_detail"`
+	ReportName string `json:"report_name"`
+	FileName   string `json:"file_name"`
+	FileDetal  any    `json:"filed_detail"`
+	// FilePath is set instead of FileDetal for exports written to a temporary
+	// file; whoever receives the response removes the file.
+	FilePath    string    `json:"-"`
+	ContentType string    `json:"content_type"`
 	GeneratedAt time.Time `json:"generated_at"`
 }
 
 type ReportDetail struct {
-	ID             int64           `json:"id"`
-	ReportType     string          `json:"report_type"`
-	ReportName     string          `json:"report_name"`
-	DepartmentID   string          `json:"department_id"`
-	QueryStatement string          `json:"query_statement"`
-	Columns        []*ReportColumn `json:"columns"`
-	CreatedAt      time.Time       `json:"created_at"`
-	UpdatedAt      time.Time       `json:"updated_at"`
+	ID                  int64              `json:"id"`
+	ReportType          string             `json:"report_type"`
+	ReportName          string             `json:"report_name"`
+	DepartmentID        string             `json:"department_id"`
+	QueryStatement      string             `json:"query_statement"`
+	Columns             []*ReportColumn    `json:"columns"`
+	Parameters          []*ReportParameter `json:"parameters"`
+	CacheTTLSeconds     int                `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds int                `json:"query_timeout_seconds"`
+	CreatedAt           time.Time          `json:"created_at"`
+	UpdatedAt           time.Time          `json:"updated_at"`
+}
+
+type ReportShareReq struct {
+	DepartmentID int64 `json:"department_id" validate:"required"`
+}
+
+type ReportShareRes struct {
+	ReportID     int64     `json:"report_id"`
+	DepartmentID int64     `json:"department_id"`
+	CreatedBy    int64     `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ReportParameter struct {
+	ID            int64    `json:"id"`
+	ReportID      int64    `json:"report_id"`
+	Name          string   `json:"name"`
+	Label         string   `json:"label"`
+	Type          string   `json:"type"`
+	Required      bool     `json:"required"`
+	Multiple      bool     `json:"multiple"`
+	DefaultValue  string   `json:"default_value"`
+	AllowedValues []string `json:"allowed_values,omitempty"`
+	LookupSource  string   `json:"lookup_source,omitempty"`
+	Num           int64    `json:"num"`
+}
+
+type LookupOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// ReportPreviewReq runs a draft report query. Dates default to the usual report
+// range and Params are resolved against the draft Parameters.
+type ReportPreviewReq struct {
+	QueryStatement string              `json:"query_statement"`
+	FromDate       *time.Time          `json:"from_date,omitempty"`
+	ToDate         *time.Time          `json:"to_date,omitempty"`
+	Parameters     []*ReportParameter  `json:"parameters"`
+	Params         map[string][]string `json:"params"`
+	MaxRows        int                 `json:"max_rows"`
+}
+
+type ReportPreviewColumn struct {
+	Name         string `json:"name"`
+	DatabaseType string `json:"database_type"`
+	Type         string `json:"type"`
+}
+
+type ReportPreviewRes struct {
+	Columns          []ReportPreviewColumn `json:"columns"`
+	SuggestedColumns []*ReportColumn       `json:"suggested_columns"`
+	Data             []map[string]any      `json:"data"`
+	Truncated        bool                  `json:"truncated"`
+}
+
+// ReportSaveRes carries non-blocking problems found while saving a report
+type ReportSaveRes struct {
+	Warnings []string `json:"warnings"`
+}
+
+// RunningReportQuery is a report query currently executing on the ERP
+type RunningReportQuery struct {
+	ID             string    `json:"id"`
+	ReportID       int64     `json:"report_id"`
+	ReportName     string    `json:"report_name"`
+	UserID         int64     `json:"user_id"`
+	StartedAt      time.Time `json:"started_at"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+}
+
+type ReportRevisionSummary struct {
+	Revision   int       `json:"revision"`
+	ReportName string    `json:"report_name"`
+	Comment    string    `json:"comment"`
+	CreatedBy  int64     `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ReportRevisionRes struct {
+	ReportID            int64              `json:"report_id"`
+	Revision            int                `json:"revision"`
+	ReportType          string             `json:"report_type"`
+	ReportName          string             `json:"report_name"`
+	DepartmentID        string             `json:"department_id"`
+	QueryStatement      string             `json:"query_statement"`
+	Columns             []*ReportColumn    `json:"columns"`
+	Parameters          []*ReportParameter `json:"parameters"`
+	CacheTTLSeconds     int                `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds int                `json:"query_timeout_seconds"`
+	Comment             string             `json:"comment"`
+	CreatedBy           int64              `json:"created_by"`
+	CreatedAt           time.Time          `json:"created_at"`
+}
+
+type ReportRevisionDiffReq struct {
+	From int `query:"from" validate:"required"`
+	To   int `query:"to" validate:"required"`
+}
+
+// ReportRevisionDiff lists what changed from one revision to another
+type ReportRevisionDiff struct {
+	ReportID   int64               `json:"report_id"`
+	From       int                 `json:"from"`
+	To         int                 `json:"to"`
+	Fields     []ReportFieldChange `json:"fields"`
+	Query      []ReportDiffLine    `json:"query"`
+	Columns    []ReportItemChange  `json:"columns"`
+	Parameters []ReportItemChange  `json:"parameters"`
+}
+
+type ReportFieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// ReportDiffLine is a line of the SQL diff; Op is "equal", "delete" or "insert"
+type ReportDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// ReportItemChange is a column (by code) or parameter (by name) that was added, removed or changed
+type ReportItemChange struct {
+	Key    string `json:"key"`
+	Change string `json:"change"`
+	From   any    `json:"from,omitempty"`
+	To     any    `json:"to,omitempty"`
+}
+
+type ReportRestoreReq struct {
+	Comment string `json:"comment"`
 }
@@ -0,0 +1,50 @@
+package dto
+
+import "time"
+
+// ReportScheduleReq creates or replaces a report schedule. Params holds the
+// report parameters by name, as they would appear in the query string.
+type ReportScheduleReq struct {
+	ReportID   int64               `json:"report_id"`
+	Name       string              `json:"name"`
+	Cron       string              `json:"cron"`
+	Timezone   string              `json:"timezone"`
+	Preset     string              `json:"preset"`
+	Params     map[string][]string `json:"params"`
+	Format     string              `json:"format"`
+	Recipients []string            `json:"recipients"`
+	Enabled    *bool               `json:"enabled"`
+}
+
+type ReportScheduleRes struct {
+	ID         int64               `json:"id"`
+	ReportID   int64               `json:"report_id"`
+	Name       string              `json:"name"`
+	Cron       string              `json:"cron"`
+	Timezone   string              `json:"timezone"`
+	Preset     string              `json:"preset"`
+	Params     map[string][]string `json:"params"`
+	Format     string              `json:"format"`
+	Recipients []string            `json:"recipients"`
+	Enabled    bool                `json:"enabled"`
+	NextRunAt  *time.Time          `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time          `json:"last_run_at,omitempty"`
+	CreatedBy  int64               `json:"created_by"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+}
+
+type ReportScheduleRunRes struct {
+	ID         int64      `json:"id"`
+	ScheduleID int64      `json:"schedule_id"`
+	ReportID   int64      `json:"report_id"`
+	Status     string     `json:"status"`
+	Manual     bool       `json:"manual"`
+	FromDate   time.Time  `json:"from_date"`
+	ToDate     time.Time  `json:"to_date"`
+	FileName   string     `json:"file_name,omitempty"`
+	Recipients []string   `json:"recipients"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -6,6 +6,7 @@ type UserCreateReq struct {
 	Password     string `json:"password"`
 	Email        string `json:"email"`
 	DepartmentID int64  `json:"department_id,omitempty"`
+	Role         string `json:"role,omitempty"`
 }
 
 type UserUpdateReq struct {
@@ -14,6 +15,7 @@ type UserUpdateReq struct {
 	Password     *string `json:"password"`
 	Email        *string `json:"email"`
 	DepartmentID *int64  `json:"department_id"`
+	Role         *string `json:"role"`
 }
 
 type UserDetailReq struct {
@@ -26,6 +28,7 @@ type UserDetailRes struct {
 	FullName     string `json:"full_name"`
 	Email        string `json:"email"`
 	DepartmentID int64  `json:"department_id"`
+	Role         string `json:"role"`
 	CreatedAt    string `json:"created_at"`
 	UpdatedAt    string `json:"updated_at"`
 }
@@ -2,8 +2,12 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
+	"errors"
 	"strconv"
 	"time"
 
@@ -272,6 +276,46 @@ func (h *AdminHandler) GetFailedAccessByIP(c fiber.Ctx) error {
 	})
 }
 
+func (h *AdminHandler) GetLoginAttempts(c fiber.Ctx) error {
+	var req dto.LoginAttemptQueryReq
+	if err := c.Bind().Query(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid query parameters", err.Error())
+	}
+
+	attempts, err := h.adminService.GetLoginAttempts(c.RequestCtx(), req)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get login attempts", err)
+	}
+
+	return utils.SuccessResponse(c, "Login attempts retrieved successfully", attempts)
+}
+
+func (h *AdminHandler) GetLockouts(c fiber.Ctx) error {
+	lockouts, err := h.adminService.GetLockouts(c.RequestCtx())
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get lockouts", err)
+	}
+
+	return utils.SuccessResponse(c, "Lockouts retrieved successfully", lockouts)
+}
+
+func (h *AdminHandler) Unlock(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid lockout ID", err.Error())
+	}
+	adminID, _ := c.Locals("user_id").(int64)
+
+	if err := h.adminService.Unlock(c.RequestCtx(), id, adminID); err != nil {
+		if errors.Is(err, repository.ErrLockoutNotFound) {
+			return utils.NotFoundResponse(c, "Lockout not found or already unlocked")
+		}
+		return utils.InternalErrorResponse(c, "Failed to unlock", err)
+	}
+
+	return utils.SuccessResponse(c, "Unlocked successfully", nil)
+}
+
 // ============================================================================
 // Setup Routes
 // ============================================================================
@@ -286,31 +330,36 @@ func (h *AdminHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 		}
 	}
 
+	guard := middleware.Guard(admin)
+
 	// Dashboard
-	admin.Get("/dashboard", h.GetDashboard)
-	admin.Get("/dashboard/top-operations", h.GetTopOperations)
-	admin.Get("/dashboard/top-users", h.GetTopUsers)
-	admin.Get("/dashboard/access-trend", h.GetAccessTrend)
+	guard.Get("/dashboard", models.PermissionAdminDashboard, h.GetDashboard)
+	guard.Get("/dashboard/top-operations", models.PermissionAdminDashboard, h.GetTopOperations)
+	guard.Get("/dashboard/top-users", models.PermissionAdminDashboard, h.GetTopUsers)
+	guard.Get("/dashboard/access-trend", models.PermissionAdminDashboard, h.GetAccessTrend)
 
 	// Operations Management
-	operations := admin.Group("/operations")
-	operations.Post("/", h.CreateOperation)
-	operations.Get("/", h.GetAllOperations)
-	operations.Get("/:id", h.GetOperation)
-	operations.Put("/:id", h.UpdateOperation)
-	operations.Delete("/:id", h.DeleteOperation)
+	operations := middleware.Guard(admin.Group("/operations"))
+	operations.Post("/", models.PermissionAdminOperations, h.CreateOperation)
+	operations.Get("/", models.PermissionAdminOperations, h.GetAllOperations)
+	operations.Get("/:id", models.PermissionAdminOperations, h.GetOperation)
+	operations.Put("/:id", models.PermissionAdminOperations, h.UpdateOperation)
+	operations.Delete("/:id", models.PermissionAdminOperations, h.DeleteOperation)
 
 	// Access Logs
-	logs := admin.Group("/logs")
-	logs.Get("/", h.GetAccessLogs)
-	logs.Get("/:id", h.GetAccessLog)
-	logs.Delete("/cleanup", h.DeleteOldLogs)
+	logs := middleware.Guard(admin.Group("/logs"))
+	logs.Get("/", models.PermissionAdminLogs, h.GetAccessLogs)
+	logs.Get("/:id", models.PermissionAdminLogs, h.GetAccessLog)
+	logs.Delete("/cleanup", models.PermissionAdminLogs, h.DeleteOldLogs)
 
 	// User Activity
-	admin.Get("/user-activity", h.GetUserActivityReport)
+	guard.Get("/user-activity", models.PermissionAdminLogs, h.GetUserActivityReport)
 
 	// Security
-	security := admin.Group("/security")
-	security.Get("/alerts", h.GetSecurityAlerts)
-	security.Get("/failed-access", h.GetFailedAccessByIP)
+	security := middleware.Guard(admin.Group("/security"))
+	security.Get("/alerts", models.PermissionAdminSecurity, h.GetSecurityAlerts)
+	security.Get("/failed-access", models.PermissionAdminSecurity, h.GetFailedAccessByIP)
+	security.Get("/login-attempts", models.PermissionAdminSecurity, h.GetLoginAttempts)
+	security.Get("/lockouts", models.PermissionAdminSecurity, h.GetLockouts)
+	security.Post("/lockouts/:id/unlock", models.PermissionAdminSecurity, h.Unlock)
 }
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/service"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type APIKeyHandler struct {
+	BaseHandler
+	apiKeyService service.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+func (h *APIKeyHandler) GetAPIKeys(c fiber.Ctx) error {
+	keys, err := h.apiKeyService.List(c.RequestCtx())
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get API keys", err)
+	}
+	return utils.SuccessResponse(c, "API keys retrieved successfully", keys)
+}
+
+func (h *APIKeyHandler) CreateAPIKey(c fiber.Ctx) error {
+	var req dto.APIKeyCreateReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+	createdBy, _ := c.Locals("user_id").(int64)
+
+	res, err := h.apiKeyService.Create(c.RequestCtx(), req, createdBy)
+	if err != nil {
+		if errors.Is(err, service.ErrAPIKeyNoName) || errors.Is(err, service.ErrAPIKeyNoScopes) || errors.Is(err, service.ErrUnknownPermission) {
+			return utils.BadRequestResponse(c, "Invalid API key", err)
+		}
+		return utils.InternalErrorResponse(c, "Failed to create API key", err)
+	}
+	return utils.CreatedResponse(c, "API key created successfully", res)
+}
+
+func (h *APIKeyHandler) RotateAPIKey(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid API key ID", err.Error())
+	}
+
+	res, err := h.apiKeyService.Rotate(c.RequestCtx(), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return utils.NotFoundResponse(c, "API key not found or revoked")
+		}
+		return utils.InternalErrorResponse(c, "Failed to rotate API key", err)
+	}
+	return utils.SuccessResponse(c, "API key rotated successfully", res)
+}
+
+func (h *APIKeyHandler) RevokeAPIKey(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid API key ID", err.Error())
+	}
+
+	if err := h.apiKeyService.Revoke(c.RequestCtx(), id); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return utils.NotFoundResponse(c, "API key not found or already revoked")
+		}
+		return utils.InternalErrorResponse(c, "Failed to revoke API key", err)
+	}
+	return utils.SuccessResponse(c, "API key revoked successfully", nil)
+}
+
+func (h *APIKeyHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
+	apiKeys := router.Group("/admin/api-keys")
+	for _, m := range ms {
+		apiKeys.Use(m)
+	}
+
+	guard := middleware.Guard(apiKeys)
+	guard.Get("/", models.PermissionAdminAPIKeys, h.GetAPIKeys)
+	guard.Post("/", models.PermissionAdminAPIKeys, h.CreateAPIKey)
+	guard.Post("/:id/rotate", models.PermissionAdminAPIKeys, h.RotateAPIKey)
+	guard.Delete("/:id", models.PermissionAdminAPIKeys, h.RevokeAPIKey)
+}
@@ -2,20 +2,32 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
+	"errors"
+	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
 type AuthHandler struct {
 	BaseHandler
-	authService service.AuthService
+	authService      service.AuthService
+	passwordService  service.PasswordService
+	twoFactorService service.TwoFactorService
+	sessionService   service.SessionService
 }
 
-func NewAuthHandler(authService service.AuthService) *AuthHandler {
+func NewAuthHandler(authService service.AuthService, passwordService service.PasswordService, twoFactorService service.TwoFactorService, sessionService service.SessionService) *AuthHandler {
 	return &AuthHandler{
-		authService: authService,
+		authService:      authService,
+		passwordService:  passwordService,
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
 	}
 }
 
@@ -24,21 +36,326 @@ func (h *AuthHandler) Login(c fiber.Ctx) error {
 	if err := c.Bind().Body(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+
 	res, err := h.authService.Login(c.RequestCtx(), req)
 	if err != nil {
+		if errors.Is(err, service.ErrAccountLocked) || errors.Is(err, service.ErrIPLocked) {
+			return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Login failed", err)
+		}
+		if errors.Is(err, service.ErrPasswordExpired) {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Login failed", err)
+		}
 		return utils.InternalErrorResponse(c, "Login failed", err)
 	}
 	return utils.SuccessResponse(c, "Login successful", res)
 }
 
+func (h *AuthHandler) Refresh(c fiber.Ctx) error {
+	var req dto.RefreshTokenRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
+	}
+	res, err := h.authService.Refresh(c.RequestCtx(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Refresh failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Refresh failed", err)
+	}
+	return utils.SuccessResponse(c, "Token refreshed", res)
+}
+
 func (h *AuthHandler) Logout(c fiber.Ctx) error {
 	var req dto.LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return utils.BadRequestResponse(c, "Invalid request body", err)
+		}
+	}
+	if req.Token == "" {
+		req.Token = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	}
+	if err := h.authService.Logout(c.RequestCtx(), req.Token, req.RefreshToken); err != nil {
+		return utils.BadRequestResponse(c, "Logout failed", err)
+	}
+
+	return utils.SuccessResponse(c, "Logout successful", nil)
+}
+
+func (h *AuthHandler) LogoutAll(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	if err := h.authService.LogoutAll(c.RequestCtx(), userID); err != nil {
+		return utils.InternalErrorResponse(c, "Logout all sessions failed", err)
+	}
+	return utils.SuccessResponse(c, "All sessions logged out", nil)
+}
+
+func (h *AuthHandler) RevokeUserTokens(c fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID", err)
+	}
+	var req dto.RevokeTokensRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return utils.BadRequestResponse(c, "Invalid request body", err)
+		}
+	}
+	if req.Reason == "" {
+		req.Reason = "revoked by administrator"
+	}
+	adminID, _ := c.Locals("user_id").(int64)
+
+	if err := h.authService.RevokeUserTokens(c.RequestCtx(), userID, adminID, req.Reason); err != nil {
+		return utils.InternalErrorResponse(c, "Failed to revoke user tokens", err)
+	}
+	return utils.SuccessResponse(c, "User tokens revoked", nil)
+}
+
+// ChangePassword lets the authenticated user change their own password. Tokens
+// issued before the change still carry must_change_password; call /auth/refresh.
+func (h *AuthHandler) ChangePassword(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	var req dto.UpdatePasswordRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
+	}
+
+	if err := h.passwordService.ChangePassword(c.RequestCtx(), userID, req); err != nil {
+		if isPasswordError(err) {
+			return utils.BadRequestResponse(c, "Password change failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Password change failed", err)
+	}
+	return utils.SuccessResponse(c, "Password changed successfully", nil)
+}
+
+// ResetPassword issues a one-time temporary password and signs the user out everywhere
+func (h *AuthHandler) ResetPassword(c fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID", err)
+	}
+	adminID, _ := c.Locals("user_id").(int64)
+
+	res, err := h.passwordService.ResetPassword(c.RequestCtx(), userID)
+	if err != nil {
+		if errors.Is(err, service.ErrExternalAccount) {
+			return utils.BadRequestResponse(c, "Password reset failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Password reset failed", err)
+	}
+	if err := h.authService.RevokeUserTokens(c.RequestCtx(), userID, adminID, "password reset"); err != nil {
+		return utils.InternalErrorResponse(c, "Failed to revoke user tokens", err)
+	}
+	return utils.SuccessResponse(c, "Temporary password issued", res)
+}
+
+// VerifyTwoFactor exchanges a login challenge and a TOTP or recovery code for tokens
+func (h *AuthHandler) VerifyTwoFactor(c fiber.Ctx) error {
+	var req dto.TwoFactorVerifyRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
+	}
+	req.IPAddress = c.IP()
+	req.UserAgent = c.Get(fiber.HeaderUserAgent)
+
+	res, err := h.authService.VerifyTwoFactor(c.RequestCtx(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrAccountLocked) || errors.Is(err, service.ErrIPLocked) {
+			return utils.ErrorResponse(c, fiber.StatusTooManyRequests, "Verification failed", err)
+		}
+		if errors.Is(err, service.ErrInvalidChallenge) || errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			return utils.ErrorResponse(c, fiber.StatusUnauthorized, "Verification failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Verification failed", err)
+	}
+	return utils.SuccessResponse(c, "Login successful", res)
+}
+
+func (h *AuthHandler) GetTwoFactorStatus(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	role, _ := c.Locals("role").(string)
+
+	res, err := h.twoFactorService.Status(c.RequestCtx(), userID, role)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get two-factor status", err)
+	}
+	return utils.SuccessResponse(c, "Two-factor status retrieved", res)
+}
+
+func (h *AuthHandler) EnrollTwoFactor(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	username, _ := c.Locals("username").(string)
+
+	res, err := h.twoFactorService.Enroll(c.RequestCtx(), userID, username)
+	if err != nil {
+		if errors.Is(err, service.ErrTwoFactorAlreadyEnabled) {
+			return utils.BadRequestResponse(c, "Enrolment failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Enrolment failed", err)
+	}
+	return utils.SuccessResponse(c, "Scan the provisioning URI and confirm with a code", res)
+}
+
+// ConfirmTwoFactor enables 2FA. Tokens issued before still carry
+// two_factor_enroll_required; call /auth/refresh.
+func (h *AuthHandler) ConfirmTwoFactor(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	var req dto.TwoFactorCodeRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
+	}
+
+	res, err := h.twoFactorService.Confirm(c.RequestCtx(), userID, req.Code)
+	if err != nil {
+		if isTwoFactorError(err) {
+			return utils.BadRequestResponse(c, "Confirmation failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Confirmation failed", err)
+	}
+	return utils.SuccessResponse(c, "Two-factor authentication enabled; store the recovery codes safely", res)
+}
+
+func (h *AuthHandler) DisableTwoFactor(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	role, _ := c.Locals("role").(string)
+	var req dto.TwoFactorCodeRequest
 	if err := c.Bind().Body(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
-	res := h.authService.Logout(c.RequestCtx(), req.Token)
 
-	return utils.SuccessResponse(c, "Logout successful", res)
+	if err := h.twoFactorService.Disable(c.RequestCtx(), userID, role, req.Code); err != nil {
+		if isTwoFactorError(err) {
+			return utils.BadRequestResponse(c, "Disable failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Disable failed", err)
+	}
+	return utils.SuccessResponse(c, "Two-factor authentication disabled", nil)
+}
+
+func (h *AuthHandler) RegenerateRecoveryCodes(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	var req dto.TwoFactorCodeRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
+	}
+
+	res, err := h.twoFactorService.RegenerateRecoveryCodes(c.RequestCtx(), userID, req.Code)
+	if err != nil {
+		if isTwoFactorError(err) {
+			return utils.BadRequestResponse(c, "Regenerate recovery codes failed", err)
+		}
+		return utils.InternalErrorResponse(c, "Regenerate recovery codes failed", err)
+	}
+	return utils.SuccessResponse(c, "Recovery codes regenerated", res)
+}
+
+// ResetTwoFactor removes a user's 2FA, e.g. after a lost device
+func (h *AuthHandler) ResetTwoFactor(c fiber.Ctx) error {
+	userID, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid user ID", err)
+	}
+	adminID, _ := c.Locals("user_id").(int64)
+
+	if err := h.twoFactorService.Reset(c.RequestCtx(), userID); err != nil {
+		return utils.InternalErrorResponse(c, "Failed to reset two-factor authentication", err)
+	}
+	if err := h.authService.RevokeUserTokens(c.RequestCtx(), userID, adminID, "two-factor reset"); err != nil {
+		return utils.InternalErrorResponse(c, "Failed to revoke user tokens", err)
+	}
+	return utils.SuccessResponse(c, "Two-factor authentication reset", nil)
+}
+
+// GetSessions lists the signed-in devices of the authenticated user
+func (h *AuthHandler) GetSessions(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	sessionID, _ := c.Locals("session_id").(string)
+
+	sessions, err := h.sessionService.ListUserSessions(c.RequestCtx(), userID, sessionID)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get sessions", err)
+	}
+	return utils.SuccessResponse(c, "Sessions retrieved successfully", sessions)
+}
+
+// RevokeSession signs out one of the authenticated user's own sessions
+func (h *AuthHandler) RevokeSession(c fiber.Ctx) error {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid session ID", err)
+	}
+
+	if err := h.sessionService.Revoke(c.RequestCtx(), id, userID, userID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return utils.NotFoundResponse(c, "Session not found")
+		}
+		return utils.InternalErrorResponse(c, "Failed to revoke session", err)
+	}
+	return utils.SuccessResponse(c, "Session revoked", nil)
+}
+
+// GetActiveSessions lists every active session for administrators
+func (h *AuthHandler) GetActiveSessions(c fiber.Ctx) error {
+	sessions, err := h.sessionService.ListActive(c.RequestCtx())
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get sessions", err)
+	}
+	return utils.SuccessResponse(c, "Sessions retrieved successfully", sessions)
+}
+
+// AdminRevokeSession signs out any user's session
+func (h *AuthHandler) AdminRevokeSession(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid session ID", err)
+	}
+	adminID, _ := c.Locals("user_id").(int64)
+
+	if err := h.sessionService.Revoke(c.RequestCtx(), id, 0, adminID); err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			return utils.NotFoundResponse(c, "Session not found")
+		}
+		return utils.InternalErrorResponse(c, "Failed to revoke session", err)
+	}
+	return utils.SuccessResponse(c, "Session revoked", nil)
+}
+
+// JWKS publishes the public token verification keys in the standard key set format
+func (h *AuthHandler) JWKS(c fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(h.authService.JWKS())
 }
 
 func (h *AuthHandler) GetProfile(c fiber.Ctx) error {
@@ -65,6 +382,35 @@ func (h *AuthHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 	}
 
 	auth.Post("/login", h.Login)
+	auth.Post("/refresh", h.Refresh)
+	auth.Get("/jwks", h.JWKS)
 	auth.Post("/logout", h.Logout)
+	auth.Post("/logout-all", h.LogoutAll)
 	auth.Get("/profile", h.GetProfile)
+	auth.Put("/password", h.ChangePassword)
+	auth.Post("/2fa/verify", h.VerifyTwoFactor)
+	auth.Get("/2fa", h.GetTwoFactorStatus)
+	auth.Post("/2fa/enroll", h.EnrollTwoFactor)
+	auth.Post("/2fa/confirm", h.ConfirmTwoFactor)
+	auth.Post("/2fa/disable", h.DisableTwoFactor)
+	auth.Post("/2fa/recovery-codes", h.RegenerateRecoveryCodes)
+	auth.Get("/sessions", h.GetSessions)
+	auth.Delete("/sessions/:id", h.RevokeSession)
+
+	users := middleware.Guard(router.Group("/admin/users"))
+	users.Post("/:id/revoke-tokens", models.PermissionUserManage, h.RevokeUserTokens)
+	users.Post("/:id/reset-password", models.PermissionUserManage, h.ResetPassword)
+	users.Post("/:id/reset-2fa", models.PermissionUserManage, h.ResetTwoFactor)
+
+	sessions := middleware.Guard(router.Group("/admin/sessions"))
+	sessions.Get("/", models.PermissionAdminSecurity, h.GetActiveSessions)
+	sessions.Delete("/:id", models.PermissionAdminSecurity, h.AdminRevokeSession)
+}
+
+// isTwoFactorError reports whether err is a rejected 2FA request rather than a server error
+func isTwoFactorError(err error) bool {
+	return errors.Is(err, service.ErrInvalidTwoFactorCode) ||
+		errors.Is(err, service.ErrTwoFactorNotEnabled) ||
+		errors.Is(err, service.ErrTwoFactorAlreadyEnabled) ||
+		errors.Is(err, service.ErrTwoFactorRequired)
 }
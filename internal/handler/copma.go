@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
 
@@ -112,16 +114,17 @@ func (h *CopmaHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 	for _, m := range ms {
 		r.Use(m)
 	}
-	r.Get("/", h.GetCopma)
-	r.Get("/channel", h.GetChannel)
-	r.Get("/types", h.GetTypes)
-	r.Get("/region", h.GetRegion)
-	r.Get("/country", h.GetCountry)
-	r.Get("/route", h.GetRoute)
-	r.Get("/saledept", h.GetSaleDept)
-	r.Get("/saleworkshop", h.GetSaleWorkshop)
-	r.Get("/saleitem", h.GetSaleItem)
-	r.Get("/salewarehouse", h.GetSaleWarehouse)
-	r.Get("/salemoney", h.GetSaleMoney)
-	r.Get("/search", h.SearchCopma)
+	guard := middleware.Guard(r)
+	guard.Get("/", models.PermissionCopmaView, h.GetCopma)
+	guard.Get("/channel", models.PermissionCopmaView, h.GetChannel)
+	guard.Get("/types", models.PermissionCopmaView, h.GetTypes)
+	guard.Get("/region", models.PermissionCopmaView, h.GetRegion)
+	guard.Get("/country", models.PermissionCopmaView, h.GetCountry)
+	guard.Get("/route", models.PermissionCopmaView, h.GetRoute)
+	guard.Get("/saledept", models.PermissionCopmaView, h.GetSaleDept)
+	guard.Get("/saleworkshop", models.PermissionCopmaView, h.GetSaleWorkshop)
+	guard.Get("/saleitem", models.PermissionCopmaView, h.GetSaleItem)
+	guard.Get("/salewarehouse", models.PermissionCopmaView, h.GetSaleWarehouse)
+	guard.Get("/salemoney", models.PermissionCopmaView, h.GetSaleMoney)
+	guard.Get("/search", models.PermissionCopmaView, h.SearchCopma)
 }
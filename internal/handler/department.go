@@ -2,6 +2,8 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
 	"strconv"
@@ -88,9 +90,10 @@ func (d *DepartmentHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler
 			department.Use(m)
 		}
 	}
-	department.Post("/", d.Create)
-	department.Get("/", d.GetAll)
-	department.Get("/:id", d.GetByID)
-	department.Put("/:id", d.Update)
-	department.Delete("/:id", d.Delete)
+	guard := middleware.Guard(department)
+	guard.Post("/", models.PermissionDepartmentManage, d.Create)
+	guard.Get("/", models.PermissionDepartmentView, d.GetAll)
+	guard.Get("/:id", models.PermissionDepartmentView, d.GetByID)
+	guard.Put("/:id", models.PermissionDepartmentManage, d.Update)
+	guard.Delete("/:id", models.PermissionDepartmentManage, d.Delete)
 }
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/service"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// exportJobsPath is where the export job routes are mounted, for download URLs
+const exportJobsPath = "/api/export-jobs"
+
+// ExportJobHandler lets users follow, cancel and download their own export
+// jobs. The jobs are queued by the report and forecast handlers.
+type ExportJobHandler struct {
+	BaseHandler
+	exportJobService service.ExportJobService
+}
+
+func NewExportJobHandler(exportJobService service.ExportJobService) *ExportJobHandler {
+	return &ExportJobHandler{
+		exportJobService: exportJobService,
+	}
+}
+
+func (h *ExportJobHandler) GetJobs(c fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int64)
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	jobs, err := h.exportJobService.List(c.RequestCtx(), userID, limit)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get export jobs", err)
+	}
+	for i := range jobs {
+		withDownloadURL(&jobs[i])
+	}
+	return utils.SuccessResponse(c, "Export jobs retrieved successfully", jobs)
+}
+
+func (h *ExportJobHandler) GetJob(c fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int64)
+
+	job, err := h.exportJobService.Get(c.RequestCtx(), c.Params("id"), userID)
+	if err != nil {
+		return exportJobErrorResponse(c, "Failed to get export job", err)
+	}
+	return utils.SuccessResponse(c, "Export job retrieved successfully", withDownloadURL(job))
+}
+
+func (h *ExportJobHandler) CancelJob(c fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int64)
+
+	job, err := h.exportJobService.Cancel(c.RequestCtx(), c.Params("id"), userID)
+	if err != nil {
+		return exportJobErrorResponse(c, "Failed to cancel export job", err)
+	}
+	return utils.SuccessResponse(c, "Export job cancelled", job)
+}
+
+func (h *ExportJobHandler) DownloadJob(c fiber.Ctx) error {
+	userID, _ := c.Locals("user_id").(int64)
+
+	job, err := h.exportJobService.GetFile(c.RequestCtx(), c.Params("id"), userID)
+	if err != nil {
+		return exportJobErrorResponse(c, "Failed to download export", err)
+	}
+	return c.Download(job.FilePath, job.FileName)
+}
+
+// withDownloadURL sets the download URL of a job that has a file
+func withDownloadURL(job *dto.ExportJobRes) *dto.ExportJobRes {
+	if job.Status == models.ExportJobSucceeded {
+		job.DownloadURL = exportJobsPath + "/" + job.ID + "/download"
+	}
+	return job
+}
+
+func exportJobErrorResponse(c fiber.Ctx, message string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrExportJobNotFound):
+		return utils.NotFoundResponse(c, "Export job not found")
+	case errors.Is(err, service.ErrExportJobFinished), errors.Is(err, service.ErrExportJobNotReady):
+		return utils.ErrorResponse(c, fiber.StatusConflict, err.Error(), err)
+	case errors.Is(err, service.ErrReportNotFound):
+		return utils.NotFoundResponse(c, "Report not found")
+	case errors.Is(err, service.ErrDepartmentAccessDenied):
+		return utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidReportID), errors.Is(err, service.ErrInvalidDateRange),
+		errors.Is(err, service.ErrInvalidExportFormat):
+		return utils.BadRequestResponse(c, "Invalid export request", err.Error())
+	default:
+		return utils.InternalErrorResponse(c, message, err)
+	}
+}
+
+// SetupRoutes registers routes open to every authenticated user; each user
+// only sees their own jobs.
+func (h *ExportJobHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
+	jobs := router.Group("/export-jobs")
+	for _, m := range ms {
+		jobs.Use(m)
+	}
+
+	jobs.Get("/", h.GetJobs)
+	jobs.Get("/:id", h.GetJob)
+	jobs.Get("/:id/download", h.DownloadJob)
+	jobs.Post("/:id/cancel", h.CancelJob)
+}
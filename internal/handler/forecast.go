@@ -2,6 +2,8 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
 	"errors"
@@ -14,18 +16,19 @@ import (
 
 type ForecastHandler struct {
 	BaseHandler
-	forecastService service.ForecastService
+	forecastService  service.ForecastService
+	exportJobService service.ExportJobService
 }
 
-func NewForecastHandler(forecastService service.ForecastService) *ForecastHandler {
+func NewForecastHandler(forecastService service.ForecastService, exportJobService service.ExportJobService) *ForecastHandler {
 	return &ForecastHandler{
-		forecastService: forecastService,
+		forecastService:  forecastService,
+		exportJobService: exportJobService,
 	}
 }
 
-
 func (h *ForecastHandler) GetForecast(c fiber.Ctx) error {
-	
+
 	fromDateStr := c.Query("FromDate")
 	toDateStr := c.Query("ToDate")
 
@@ -82,8 +85,13 @@ func (h *ForecastHandler) ExportReport(c fiber.Ctx) error {
 	}
 
 	req := dto.ReportReq{
-		FromDate: &fromDate,
-		ToDate:   &toDate,
+		FromDate:         &fromDate,
+		ToDate:           &toDate,
+		Format:           c.Query("format"),
+		Delimiter:        c.Query("delimiter"),
+		DecimalSeparator: c.Query("decimal_separator"),
+		BOM:              fiber.Query[bool](c, "bom"),
+		Orientation:      c.Query("orientation"),
 	}
 
 	reqCtx, err := h.extractRequestContext(c)
@@ -93,12 +101,23 @@ func (h *ForecastHandler) ExportReport(c fiber.Ctx) error {
 
 	reportData, err := h.forecastService.ExportReport(c.RequestCtx(), reqCtx, &req, c)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidExportFormat) {
+			return utils.BadRequestResponse(c, "Invalid export format", err.Error())
+		}
 		return utils.InternalErrorResponse(c, "Failed to export report", err)
 	}
 
+	c.Set("Content-Type", reportData.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, reportData.FileName))
+	if reportData.FilePath != "" {
+		// The file is removed once the response body has been sent
+		file, size, err := utils.OpenTempFile(reportData.FilePath)
+		if err != nil {
+			return utils.InternalErrorResponse(c, "Failed to export report", err)
+		}
+		return c.SendStream(file, int(size))
+	}
 	if fileBytes, ok := reportData.FileDetal.([]byte); ok {
-		c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
-		c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, reportData.FileName))
 		c.Set("Content-Length", strconv.Itoa(len(fileBytes)))
 		return c.Send(fileBytes)
 	}
@@ -106,6 +125,37 @@ func (h *ForecastHandler) ExportReport(c fiber.Ctx) error {
 	return utils.InternalErrorResponse(c, "Invalid file data", "reportData.FileDetal is not []byte")
 }
 
+// EnqueueExport queues a forecast export for FromDate and ToDate (YYYYMMDD)
+// and returns the job to poll.
+func (h *ForecastHandler) EnqueueExport(c fiber.Ctx) error {
+	fromDate, err := parseCompactDate(c.Query("FromDate"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "invalid FromDate format, expected YYYYMMDD", err.Error())
+	}
+	toDate, err := parseCompactDate(c.Query("ToDate"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "invalid ToDate format, expected YYYYMMDD", err.Error())
+	}
+	reqCtx, err := h.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	job, err := h.exportJobService.Enqueue(c.RequestCtx(), reqCtx, models.ExportKindForecast, &dto.ReportReq{
+		FromDate:         &fromDate,
+		ToDate:           &toDate,
+		Format:           c.Query("format"),
+		Delimiter:        c.Query("delimiter"),
+		DecimalSeparator: c.Query("decimal_separator"),
+		BOM:              fiber.Query[bool](c, "bom"),
+		Orientation:      c.Query("orientation"),
+	})
+	if err != nil {
+		return exportJobErrorResponse(c, "Failed to queue export", err)
+	}
+	return utils.AcceptedResponse(c, "Export queued", withDownloadURL(job))
+}
+
 func parseCompactDate(dateStr string) (time.Time, error) {
 	if len(dateStr) != 8 {
 		return time.Time{}, fmt.Errorf("invalid date format: expected 8 characters (YYYYMMDD), got %d", len(dateStr))
@@ -119,7 +169,6 @@ func parseCompactDate(dateStr string) (time.Time, error) {
 	return parsedDate, nil
 }
 
-
 func (h *ForecastHandler) extractRequestContext(c fiber.Ctx) (service.RequestContext, error) {
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
@@ -155,6 +204,8 @@ func (h *ForecastHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler)
 			forecast.Use(m)
 		}
 	}
-	forecast.Get("", h.GetForecast)        
-	forecast.Get("/export", h.ExportReport) 
+	guard := middleware.Guard(forecast)
+	guard.Get("", models.PermissionForecastView, h.GetForecast)
+	guard.Get("/export", models.PermissionForecastExport, h.ExportReport)
+	guard.Post("/export", models.PermissionForecastExport, h.EnqueueExport)
 }
@@ -2,8 +2,11 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -62,8 +65,15 @@ func (m *MenuHandler) GetMenu(c fiber.Ctx) error {
 	if err := c.Bind().Query(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid query body", err)
 	}
-	data, err := m.menuService.GetMenu(c.RequestCtx(), req)
+	reqCtx, err := m.extractRequestContext(c)
 	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	data, err := m.menuService.GetMenu(c.RequestCtx(), reqCtx, req)
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
 		return utils.InternalErrorResponse(c, "failed to get menu", err)
 	}
 
@@ -75,8 +85,15 @@ func (m *MenuHandler) GetByID(c fiber.Ctx) error {
 	if err != nil {
 		return fmt.Errorf("invalid ID params")
 	}
-	data, err := m.menuService.GetByID(c.RequestCtx(), id)
+	reqCtx, err := m.extractRequestContext(c)
 	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	data, err := m.menuService.GetByID(c.RequestCtx(), reqCtx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
 		return utils.InternalErrorResponse(c, "failed to get menu %w", err)
 	}
 	return utils.SuccessResponse(c, "get menu success", data)
@@ -86,12 +103,32 @@ func (m *MenuHandler) GetAllMenu(c fiber.Ctx) error {
 	if err := c.Bind().Query(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid query body", err)
 	}
-	data, err := m.menuService.GetAll(c.RequestCtx())
+	reqCtx, err := m.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	data, err := m.menuService.GetAll(c.RequestCtx(), reqCtx)
 	if err != nil {
 		return utils.InternalErrorResponse(c, "failed to get menu %w", err)
 	}
 	return utils.SuccessResponse(c, "get menu success", data)
 }
+func (m *MenuHandler) extractRequestContext(c fiber.Ctx) (service.RequestContext, error) {
+	userID, ok := c.Locals("user_id").(int64)
+	if !ok || userID <= 0 {
+		return service.RequestContext{}, errors.New("user_id not found or invalid type")
+	}
+
+	departmentID, _ := c.Locals("department_id").(int64)
+
+	return service.RequestContext{
+		UserID:         userID,
+		DepartmentID:   departmentID,
+		IPAddress:      c.IP(),
+		AllDepartments: middleware.HasPermission(c, models.PermissionAllDepartments),
+	}, nil
+}
+
 func (m *MenuHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 	menus := router.Group("/menus")
 	if len(ms) > 0 {
@@ -99,10 +136,11 @@ func (m *MenuHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 			menus.Use(m)
 		}
 	}
-	menus.Post("/", m.CreateMenu)
-	menus.Get("/:id", m.GetMenu)
-	menus.Get("/by-id/:id", m.GetByID)
-	menus.Put("/:id", m.UpdateMenu)
-	menus.Delete("/:id", m.DeleteMenu)
-	menus.Get("/", m.GetAllMenu)
+	guard := middleware.Guard(menus)
+	guard.Post("/", models.PermissionMenuManage, m.CreateMenu)
+	guard.Get("/:id", models.PermissionMenuView, m.GetMenu)
+	guard.Get("/by-id/:id", models.PermissionMenuView, m.GetByID)
+	guard.Put("/:id", models.PermissionMenuManage, m.UpdateMenu)
+	guard.Delete("/:id", models.PermissionMenuManage, m.DeleteMenu)
+	guard.Get("/", models.PermissionMenuView, m.GetAllMenu)
 }
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/service"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type PermissionHandler struct {
+	BaseHandler
+	permissionService service.PermissionService
+}
+
+func NewPermissionHandler(permissionService service.PermissionService) *PermissionHandler {
+	return &PermissionHandler{
+		permissionService: permissionService,
+	}
+}
+
+func (h *PermissionHandler) GetPermissions(c fiber.Ctx) error {
+	permissions, err := h.permissionService.ListPermissions(c.RequestCtx())
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get permissions", err)
+	}
+	return utils.SuccessResponse(c, "Permissions retrieved successfully", permissions)
+}
+
+func (h *PermissionHandler) GetRolePermissions(c fiber.Ctx) error {
+	rolePermissions, err := h.permissionService.ListRolePermissions(c.RequestCtx(), c.Query("role"))
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get role permissions", err)
+	}
+	return utils.SuccessResponse(c, "Role permissions retrieved successfully", rolePermissions)
+}
+
+func (h *PermissionHandler) GrantRolePermission(c fiber.Ctx) error {
+	var req dto.RolePermissionCreateReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+
+	res, err := h.permissionService.GrantRolePermission(c.RequestCtx(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrEmptyRole) || errors.Is(err, service.ErrUnknownPermission) || errors.Is(err, service.ErrDuplicatePermission) {
+			return utils.BadRequestResponse(c, "Invalid role permission", err)
+		}
+		return utils.InternalErrorResponse(c, "Failed to grant role permission", err)
+	}
+	return utils.CreatedResponse(c, "Role permission granted successfully", res)
+}
+
+func (h *PermissionHandler) RevokeRolePermission(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid role permission ID", err.Error())
+	}
+
+	if err := h.permissionService.RevokeRolePermission(c.RequestCtx(), id); err != nil {
+		return utils.InternalErrorResponse(c, "Failed to revoke role permission", err)
+	}
+	return utils.SuccessResponse(c, "Role permission revoked successfully", nil)
+}
+
+func (h *PermissionHandler) GetRoutePermissions(c fiber.Ctx) error {
+	return utils.SuccessResponse(c, "Route permissions retrieved successfully", middleware.Permissions.Routes())
+}
+
+func (h *PermissionHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
+	permissions := router.Group("/admin/permissions")
+	for _, m := range ms {
+		permissions.Use(m)
+	}
+
+	guard := middleware.Guard(permissions)
+	guard.Get("/", models.PermissionAdminPermission, h.GetPermissions)
+	guard.Get("/routes", models.PermissionAdminPermission, h.GetRoutePermissions)
+	guard.Get("/roles", models.PermissionAdminPermission, h.GetRolePermissions)
+	guard.Post("/roles", models.PermissionAdminPermission, h.GrantRolePermission)
+	guard.Delete("/roles/:id", models.PermissionAdminPermission, h.RevokeRolePermission)
+}
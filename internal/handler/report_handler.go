@@ -2,23 +2,28 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v3"
 )
 
 type ReportHandler struct {
 	BaseHandler
-	reportService service.ReportService
+	reportService    service.ReportService
+	exportJobService service.ExportJobService
 }
 
-func NewReportHandler(reportService service.ReportService) *ReportHandler {
+func NewReportHandler(reportService service.ReportService, exportJobService service.ExportJobService) *ReportHandler {
 	return &ReportHandler{
-		reportService: reportService,
+		reportService:    reportService,
+		exportJobService: exportJobService,
 	}
 }
 
@@ -27,11 +32,27 @@ func (r *ReportHandler) CreateReport(c fiber.Ctx) error {
 	if err := c.Bind().Body(&report); err != nil {
 		return utils.BadRequestResponse(c, "invalid body parser", err.Error())
 	}
-	err := r.reportService.CreateReport(c.RequestCtx(), report)
+	report.CreatedBy, _ = c.Locals("user_id").(int64)
+	res, err := r.reportService.CreateReport(c.RequestCtx(), report)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidParamDefinition) {
+			return utils.BadRequestResponse(c, "invalid report parameters", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidColumnDefinition) {
+			return utils.BadRequestResponse(c, "invalid report columns", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidCacheTTL) {
+			return utils.BadRequestResponse(c, "invalid report cache ttl", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidQueryTimeout) {
+			return utils.BadRequestResponse(c, "invalid report query timeout", err.Error())
+		}
+		if errors.Is(err, utils.ErrUnsafeSQL) {
+			return utils.BadRequestResponse(c, "invalid report query", err.Error())
+		}
 		return utils.InternalErrorResponse(c, "failed to create report", err)
 	}
-	return utils.SuccessResponse(c, "create report success", nil)
+	return utils.SuccessResponse(c, "create report success", res)
 }
 func (r *ReportHandler) UpdateReport(c fiber.Ctx) error {
 	idStr := c.Params("id")
@@ -43,11 +64,53 @@ func (r *ReportHandler) UpdateReport(c fiber.Ctx) error {
 	if err := c.Bind().Body(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
-	if err := r.reportService.UpdateReport(c.RequestCtx(), int64(id), req); err != nil {
+	req.UpdatedBy, _ = c.Locals("user_id").(int64)
+	res, err := r.reportService.UpdateReport(c.RequestCtx(), int64(id), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidParamDefinition) {
+			return utils.BadRequestResponse(c, "invalid report parameters", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidColumnDefinition) {
+			return utils.BadRequestResponse(c, "invalid report columns", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidCacheTTL) {
+			return utils.BadRequestResponse(c, "invalid report cache ttl", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidQueryTimeout) {
+			return utils.BadRequestResponse(c, "invalid report query timeout", err.Error())
+		}
+		if errors.Is(err, utils.ErrUnsafeSQL) {
+			return utils.BadRequestResponse(c, "invalid report query", err.Error())
+		}
 		return utils.InternalErrorResponse(c, "failed to update report", err)
 	}
-	return utils.SuccessResponse(c, "Update to report success", nil)
+	return utils.SuccessResponse(c, "Update to report success", res)
 }
+func (r *ReportHandler) PreviewReport(c fiber.Ctx) error {
+	var req dto.ReportPreviewReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+	res, err := r.reportService.PreviewReport(c.RequestCtx(), req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrEmptySQLQuery),
+			errors.Is(err, service.ErrInvalidDateRange),
+			errors.Is(err, service.ErrDateRangeTooLarge),
+			errors.Is(err, service.ErrInvalidParamDefinition),
+			errors.Is(err, service.ErrInvalidReportParam),
+			errors.Is(err, service.ErrReportPreviewFailed):
+			return utils.BadRequestResponse(c, "Failed to preview report", err.Error())
+		case errors.Is(err, utils.ErrUnsafeSQL):
+			return utils.BadRequestResponse(c, "invalid report query", err.Error())
+		case isReportQueryError(err):
+			return reportQueryErrorResponse(c, err)
+		}
+		return utils.InternalErrorResponse(c, "failed to preview report", err)
+	}
+	return utils.SuccessResponse(c, "preview report success", res)
+}
+
 func (r *ReportHandler) DeleteReport(c fiber.Ctx) error {
 	idStr := c.Params("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -67,12 +130,32 @@ func (r *ReportHandler) GetReport(c fiber.Ctx) error {
 	if err := c.Bind().URI(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid params", err)
 	}
+	req.Params = reportQueryParams(c)
+	req.Filters = reportQueryFilters(c)
 	reqCtx, err := r.extractRequestContext(c)
 	if err != nil {
 		return utils.UnauthorizedResponse(c, "Invalid user context")
 	}
-	report, err := r.reportService.GetReport(c.RequestCtx(), reqCtx, &req, c)
+	// The query is cancelled if the client goes away before it finishes
+	ctx, cancel := utils.ClientContext(c)
+	defer cancel()
+	report, err := r.reportService.GetReport(ctx, reqCtx, &req, c)
 	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidReportParam) {
+			return utils.BadRequestResponse(c, "Invalid report parameters", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidResultView) {
+			return utils.BadRequestResponse(c, "Invalid sort or filter", err.Error())
+		}
+		if errors.Is(err, utils.ErrUnsafeSQL) {
+			return utils.BadRequestResponse(c, "Report query is not read-only", err.Error())
+		}
+		if isReportQueryError(err) {
+			return reportQueryErrorResponse(c, err)
+		}
 		return utils.InternalErrorResponse(c, "failed get report columns", err)
 	}
 	return utils.SuccessResponse(c, "get report success", report)
@@ -87,23 +170,68 @@ func (r *ReportHandler) ExportReport(c fiber.Ctx) error {
 	if err := c.Bind().URI(&req); err != nil {
 		return utils.BadRequestResponse(c, "Invalid params", err)
 	}
+	req.Params = reportQueryParams(c)
+	req.Filters = reportQueryFilters(c)
 	reqCtx, err := r.extractRequestContext(c)
 	if err != nil {
 		return utils.UnauthorizedResponse(c, "Invalid user context")
 	}
-	reportData, err := r.reportService.ExportReport(c.RequestCtx(), reqCtx, &req, c)
+	ctx, cancel := utils.ClientContext(c)
+	defer cancel()
+	reportData, err := r.reportService.ExportReport(ctx, reqCtx, &req, c)
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidReportParam) {
+			return utils.BadRequestResponse(c, "Invalid report parameters", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidResultView) {
+			return utils.BadRequestResponse(c, "Invalid sort or filter", err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidExportFormat) {
+			return utils.BadRequestResponse(c, "Invalid export format", err.Error())
+		}
+		if errors.Is(err, utils.ErrUnsafeSQL) {
+			return utils.BadRequestResponse(c, "Report query is not read-only", err.Error())
+		}
+		if isReportQueryError(err) {
+			return reportQueryErrorResponse(c, err)
+		}
+		return utils.InternalErrorResponse(c, "failed export report", err)
+	}
+	// The file is removed once the response body has been sent
+	file, size, err := utils.OpenTempFile(reportData.FilePath)
 	if err != nil {
 		return utils.InternalErrorResponse(c, "failed export report", err)
 	}
-	c.Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Set("Content-Type", reportData.ContentType)
 	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, reportData.FileName))
-	if fileBytes, ok := reportData.FileDetal.([]byte); ok {
-		c.Set("Content-Length", strconv.Itoa(len(fileBytes)))
+	return c.SendStream(file, int(size))
+}
 
-		return c.Send(fileBytes)
-	} else {
-		return utils.InternalErrorResponse(c, "Invalid file data", "reportData.FileDetal is not []byte")
+// EnqueueExport queues an export with the same query parameters as
+// ExportReport and returns the job to poll.
+func (r *ReportHandler) EnqueueExport(c fiber.Ctx) error {
+	var req dto.ReportReq
+	if err := c.Bind().Query(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
+	if err := c.Bind().URI(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid params", err)
+	}
+	req.Params = reportQueryParams(c)
+	req.Filters = reportQueryFilters(c)
+	reqCtx, err := r.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	job, err := r.exportJobService.Enqueue(c.RequestCtx(), reqCtx, models.ExportKindReport, &req)
+	if err != nil {
+		return exportJobErrorResponse(c, "failed to queue export", err)
+	}
+	return utils.AcceptedResponse(c, "export queued", withDownloadURL(job))
 }
 
 func (r *ReportHandler) GetReportByID(c fiber.Ctx) error {
@@ -112,21 +240,271 @@ func (r *ReportHandler) GetReportByID(c fiber.Ctx) error {
 	if err != nil {
 		return fmt.Errorf("Invalid ID params")
 	}
-	report, err := r.reportService.GetReportByID(c.RequestCtx(), id)
+	reqCtx, err := r.extractRequestContext(c)
 	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	report, err := r.reportService.GetReportByID(c.RequestCtx(), reqCtx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
 		return utils.InternalErrorResponse(c, "failed get report by id", err)
 	}
 	return utils.SuccessResponse(c, "get report success", report)
 }
 func (r *ReportHandler) GetAllReport(c fiber.Ctx) error {
-	reports, err := r.reportService.GetAllReport(c.RequestCtx())
+	reqCtx, err := r.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	reports, err := r.reportService.GetAllReport(c.RequestCtx(), reqCtx)
 	if err != nil {
 		return utils.InternalErrorResponse(c, "failed get all report", err)
 	}
 	return utils.SuccessResponse(c, "get all report success", reports)
 }
 
+func (r *ReportHandler) GetParameters(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	reqCtx, err := r.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	params, err := r.reportService.GetParameters(c.RequestCtx(), reqCtx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
+		return utils.InternalErrorResponse(c, "failed get report parameters", err)
+	}
+	return utils.SuccessResponse(c, "get report parameters success", params)
+}
+
+func (r *ReportHandler) GetParameterOptions(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	reqCtx, err := r.extractRequestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	options, err := r.reportService.GetParameterOptions(c.RequestCtx(), reqCtx, id, c.Params("name"))
+	if err != nil {
+		if errors.Is(err, service.ErrDepartmentAccessDenied) {
+			return utils.ForbiddenResponse(c, err.Error())
+		}
+		if errors.Is(err, service.ErrReportParamNotFound) {
+			return utils.NotFoundResponse(c, "Report parameter not found")
+		}
+		return utils.InternalErrorResponse(c, "failed get report parameter options", err)
+	}
+	return utils.SuccessResponse(c, "get report parameter options success", options)
+}
+
+func (r *ReportHandler) GetShares(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	shares, err := r.reportService.GetShares(c.RequestCtx(), id)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "failed get report shares", err)
+	}
+	return utils.SuccessResponse(c, "get report shares success", shares)
+}
+
+func (r *ReportHandler) GetRevisions(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	revisions, err := r.reportService.GetRevisions(c.RequestCtx(), id)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "failed get report revisions", err)
+	}
+	return utils.SuccessResponse(c, "get report revisions success", revisions)
+}
+
+func (r *ReportHandler) GetRevision(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	revision, err := strconv.Atoi(c.Params("revision"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid revision", err.Error())
+	}
+	res, err := r.reportService.GetRevision(c.RequestCtx(), id, revision)
+	if err != nil {
+		if errors.Is(err, service.ErrReportRevisionNotFound) {
+			return utils.NotFoundResponse(c, err.Error())
+		}
+		return utils.InternalErrorResponse(c, "failed get report revision", err)
+	}
+	return utils.SuccessResponse(c, "get report revision success", res)
+}
+
+func (r *ReportHandler) DiffRevisions(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	var req dto.ReportRevisionDiffReq
+	if err := c.Bind().Query(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid query", err.Error())
+	}
+	if req.From <= 0 || req.To <= 0 {
+		return utils.BadRequestResponse(c, "Invalid query", "from and to revisions are required")
+	}
+	res, err := r.reportService.DiffRevisions(c.RequestCtx(), id, req.From, req.To)
+	if err != nil {
+		if errors.Is(err, service.ErrReportRevisionNotFound) {
+			return utils.NotFoundResponse(c, err.Error())
+		}
+		return utils.InternalErrorResponse(c, "failed to diff report revisions", err)
+	}
+	return utils.SuccessResponse(c, "diff report revisions success", res)
+}
+
+func (r *ReportHandler) RestoreRevision(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	revision, err := strconv.Atoi(c.Params("revision"))
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid revision", err.Error())
+	}
+	var req dto.ReportRestoreReq
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+		}
+	}
+	userID, _ := c.Locals("user_id").(int64)
+
+	res, err := r.reportService.RestoreRevision(c.RequestCtx(), id, revision, userID, req.Comment)
+	if err != nil {
+		if errors.Is(err, service.ErrReportRevisionNotFound) {
+			return utils.NotFoundResponse(c, err.Error())
+		}
+		return utils.InternalErrorResponse(c, "failed to restore report revision", err)
+	}
+	return utils.SuccessResponse(c, "restore report revision success", res)
+}
+
+func (r *ReportHandler) ShareReport(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	var req dto.ReportShareReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+	userID, _ := c.Locals("user_id").(int64)
+
+	if err := r.reportService.ShareReport(c.RequestCtx(), id, req, userID); err != nil {
+		if errors.Is(err, service.ErrInvalidDepartment) {
+			return utils.BadRequestResponse(c, "Invalid department", err.Error())
+		}
+		if errors.Is(err, service.ErrReportNotFound) {
+			return utils.NotFoundResponse(c, "Report not found")
+		}
+		return utils.InternalErrorResponse(c, "failed to share report", err)
+	}
+	return utils.SuccessResponse(c, "share report success", nil)
+}
+
+func (r *ReportHandler) UnshareReport(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid report ID", err.Error())
+	}
+	departmentID, err := strconv.ParseInt(c.Params("departmentId"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid department ID", err.Error())
+	}
+	if err := r.reportService.UnshareReport(c.RequestCtx(), id, departmentID); err != nil {
+		return utils.InternalErrorResponse(c, "failed to unshare report", err)
+	}
+	return utils.SuccessResponse(c, "unshare report success", nil)
+}
+
+// reportQueryParams collects the query string except the fixed report fields.
+// A parameter may be repeated or given as a comma-separated list.
+func reportQueryParams(c fiber.Ctx) map[string][]string {
+	params := make(map[string][]string)
+	for key, value := range c.RequestCtx().QueryArgs().All() {
+		name := string(key)
+		switch name {
+		case "from_date", "to_date", "period", "page", "page_size", "sort", "refresh":
+			continue
+		}
+		if strings.HasPrefix(name, "filter[") {
+			continue
+		}
+		params[name] = append(params[name], string(value))
+	}
+	return params
+}
+
+// reportQueryFilters collects filter[code]=value query parameters
+func reportQueryFilters(c fiber.Ctx) map[string]string {
+	filters := make(map[string]string)
+	for key, value := range c.RequestCtx().QueryArgs().All() {
+		name := string(key)
+		if strings.HasPrefix(name, "filter[") && strings.HasSuffix(name, "]") {
+			filters[name[len("filter["):len(name)-1]] = string(value)
+		}
+	}
+	return filters
+}
+
+func (r *ReportHandler) GetRunningQueries(c fiber.Ctx) error {
+	return utils.SuccessResponse(c, "get running queries success", r.reportService.ListRunningQueries(c.RequestCtx()))
+}
+
+func (r *ReportHandler) KillQuery(c fiber.Ctx) error {
+	adminID, _ := c.Locals("user_id").(int64)
+	if err := r.reportService.KillQuery(c.RequestCtx(), c.Params("id"), adminID); err != nil {
+		if errors.Is(err, service.ErrRunningQueryNotFound) {
+			return utils.NotFoundResponse(c, err.Error())
+		}
+		return utils.InternalErrorResponse(c, "failed to kill query", err)
+	}
+	return utils.SuccessResponse(c, "query killed", nil)
+}
+
+// isReportQueryError reports whether err is a report query stopped by its limits or an administrator
+func isReportQueryError(err error) bool {
+	return errors.Is(err, service.ErrReportQueryTimeout) ||
+		errors.Is(err, service.ErrReportTooManyRows) ||
+		errors.Is(err, service.ErrReportQueryKilled)
+}
+
+func reportQueryErrorResponse(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrReportQueryTimeout):
+		return utils.ErrorResponse(c, fiber.StatusGatewayTimeout, "Report query timed out", err)
+	case errors.Is(err, service.ErrReportTooManyRows):
+		return utils.ErrorResponse(c, fiber.StatusUnprocessableEntity, "Report returned too many rows", err)
+	default:
+		return utils.ErrorResponse(c, fiber.StatusConflict, "Report query was cancelled", err)
+	}
+}
+
 func (h *ReportHandler) extractRequestContext(c fiber.Ctx) (service.RequestContext, error) {
+	return requestContext(c)
+}
+
+// requestContext builds the caller's report access context from the authenticated request
+func requestContext(c fiber.Ctx) (service.RequestContext, error) {
 	userID, ok := c.Locals("user_id").(int64)
 	if !ok {
 		if uid, ok := c.Locals("user_id").(int); ok {
@@ -148,9 +526,11 @@ func (h *ReportHandler) extractRequestContext(c fiber.Ctx) (service.RequestConte
 	}
 
 	return service.RequestContext{
-		UserID:       userID,
-		DepartmentID: departmentID,
-		IPAddress:    c.IP(),
+		UserID:         userID,
+		DepartmentID:   departmentID,
+		IPAddress:      c.IP(),
+		AllDepartments: middleware.HasPermission(c, models.PermissionAllDepartments),
+		Admin:          middleware.HasPermission(c, models.PermissionAll),
 	}, nil
 }
 
@@ -161,11 +541,27 @@ func (r *ReportHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 			report.Use(m)
 		}
 	}
-	report.Post("/", r.CreateReport)
-	report.Get("/", r.GetAllReport)
-	report.Get("/:id", r.GetReport)
-	report.Put("/:id", r.UpdateReport)
-	report.Get("/by-id/:id", r.GetReportByID)
-	report.Delete("/:id", r.DeleteReport)
-	report.Get("/export/:id", r.ExportReport)
+	guard := middleware.Guard(report)
+	guard.Post("/", models.PermissionReportManage, r.CreateReport)
+	guard.Post("/preview", models.PermissionReportManage, r.PreviewReport)
+	guard.Get("/", models.PermissionReportView, r.GetAllReport)
+	guard.Get("/:id", models.PermissionReportView, r.GetReport)
+	guard.Put("/:id", models.PermissionReportManage, r.UpdateReport)
+	guard.Get("/by-id/:id", models.PermissionReportView, r.GetReportByID)
+	guard.Delete("/:id", models.PermissionReportManage, r.DeleteReport)
+	guard.Get("/export/:id", models.PermissionReportExport, r.ExportReport)
+	guard.Post("/export/:id", models.PermissionReportExport, r.EnqueueExport)
+	guard.Get("/:id/parameters", models.PermissionReportView, r.GetParameters)
+	guard.Get("/:id/parameters/:name/options", models.PermissionReportView, r.GetParameterOptions)
+	guard.Get("/:id/shares", models.PermissionReportManage, r.GetShares)
+	guard.Post("/:id/shares", models.PermissionReportManage, r.ShareReport)
+	guard.Delete("/:id/shares/:departmentId", models.PermissionReportManage, r.UnshareReport)
+	guard.Get("/:id/revisions", models.PermissionReportManage, r.GetRevisions)
+	guard.Get("/:id/revisions/diff", models.PermissionReportManage, r.DiffRevisions)
+	guard.Get("/:id/revisions/:revision", models.PermissionReportManage, r.GetRevision)
+	guard.Post("/:id/revisions/:revision/restore", models.PermissionReportManage, r.RestoreRevision)
+
+	queries := middleware.Guard(router.Group("/admin/report-queries"))
+	queries.Get("/", models.PermissionAdminQueries, r.GetRunningQueries)
+	queries.Delete("/:id", models.PermissionAdminQueries, r.KillQuery)
 }
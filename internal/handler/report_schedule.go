@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/service"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"strconv"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type ReportScheduleHandler struct {
+	BaseHandler
+	scheduleService service.ReportScheduleService
+}
+
+func NewReportScheduleHandler(scheduleService service.ReportScheduleService) *ReportScheduleHandler {
+	return &ReportScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+func (h *ReportScheduleHandler) GetSchedules(c fiber.Ctx) error {
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	schedules, err := h.scheduleService.List(c.RequestCtx(), reqCtx)
+	if err != nil {
+		return utils.InternalErrorResponse(c, "Failed to get report schedules", err)
+	}
+	return utils.SuccessResponse(c, "Report schedules retrieved successfully", schedules)
+}
+
+func (h *ReportScheduleHandler) GetSchedule(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid schedule ID", err.Error())
+	}
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	schedule, err := h.scheduleService.Get(c.RequestCtx(), reqCtx, id)
+	if err != nil {
+		return scheduleErrorResponse(c, "Failed to get report schedule", err)
+	}
+	return utils.SuccessResponse(c, "Report schedule retrieved successfully", schedule)
+}
+
+func (h *ReportScheduleHandler) CreateSchedule(c fiber.Ctx) error {
+	var req dto.ReportScheduleReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	schedule, err := h.scheduleService.Create(c.RequestCtx(), reqCtx, req)
+	if err != nil {
+		return scheduleErrorResponse(c, "Failed to create report schedule", err)
+	}
+	return utils.CreatedResponse(c, "Report schedule created successfully", schedule)
+}
+
+func (h *ReportScheduleHandler) UpdateSchedule(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid schedule ID", err.Error())
+	}
+	var req dto.ReportScheduleReq
+	if err := c.Bind().Body(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid request body", err.Error())
+	}
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	schedule, err := h.scheduleService.Update(c.RequestCtx(), reqCtx, id, req)
+	if err != nil {
+		return scheduleErrorResponse(c, "Failed to update report schedule", err)
+	}
+	return utils.SuccessResponse(c, "Report schedule updated successfully", schedule)
+}
+
+func (h *ReportScheduleHandler) DeleteSchedule(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid schedule ID", err.Error())
+	}
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+	if err := h.scheduleService.Delete(c.RequestCtx(), reqCtx, id); err != nil {
+		return scheduleErrorResponse(c, "Failed to delete report schedule", err)
+	}
+	return utils.SuccessResponse(c, "Report schedule deleted successfully", nil)
+}
+
+func (h *ReportScheduleHandler) GetRuns(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid schedule ID", err.Error())
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	runs, err := h.scheduleService.GetRuns(c.RequestCtx(), reqCtx, id, limit)
+	if err != nil {
+		return scheduleErrorResponse(c, "Failed to get report schedule runs", err)
+	}
+	return utils.SuccessResponse(c, "Report schedule runs retrieved successfully", runs)
+}
+
+// RunSchedule runs a schedule now; the response holds the recorded run,
+// including its error when the export or the mail failed.
+func (h *ReportScheduleHandler) RunSchedule(c fiber.Ctx) error {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil {
+		return utils.BadRequestResponse(c, "Invalid schedule ID", err.Error())
+	}
+	reqCtx, err := requestContext(c)
+	if err != nil {
+		return utils.UnauthorizedResponse(c, "Invalid user context")
+	}
+
+	run, err := h.scheduleService.RunNow(c.RequestCtx(), reqCtx, id)
+	if err != nil {
+		return scheduleErrorResponse(c, "Failed to run report schedule", err)
+	}
+	return utils.SuccessResponse(c, "Report schedule run finished", run)
+}
+
+func scheduleErrorResponse(c fiber.Ctx, message string, err error) error {
+	switch {
+	case errors.Is(err, repository.ErrReportScheduleNotFound):
+		return utils.NotFoundResponse(c, "Report schedule not found")
+	case errors.Is(err, service.ErrReportNotFound):
+		return utils.NotFoundResponse(c, "Report not found")
+	case errors.Is(err, service.ErrDepartmentAccessDenied):
+		return utils.ForbiddenResponse(c, err.Error())
+	case errors.Is(err, service.ErrInvalidReportSchedule),
+		errors.Is(err, service.ErrInvalidReportParam),
+		errors.Is(err, service.ErrInvalidReportID):
+		return utils.BadRequestResponse(c, "Invalid report schedule", err.Error())
+	default:
+		return utils.InternalErrorResponse(c, message, err)
+	}
+}
+
+func (h *ReportScheduleHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
+	schedules := router.Group("/report-schedules")
+	for _, m := range ms {
+		schedules.Use(m)
+	}
+
+	guard := middleware.Guard(schedules)
+	guard.Get("/", models.PermissionReportManage, h.GetSchedules)
+	guard.Post("/", models.PermissionReportManage, h.CreateSchedule)
+	guard.Get("/:id", models.PermissionReportManage, h.GetSchedule)
+	guard.Put("/:id", models.PermissionReportManage, h.UpdateSchedule)
+	guard.Delete("/:id", models.PermissionReportManage, h.DeleteSchedule)
+	guard.Get("/:id/runs", models.PermissionReportManage, h.GetRuns)
+	guard.Post("/:id/run", models.PermissionReportManage, h.RunSchedule)
+}
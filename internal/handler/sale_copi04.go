@@ -4,8 +4,12 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/gofiber/fiber/v3"
@@ -58,7 +62,6 @@ func (h *SaleCopi04Handler) Create(c fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "ME001 is required", nil)
 	}
 
-
 	creator := c.Locals("username").(string)
 	company := "CQS_VN_2025"
 
@@ -121,12 +124,38 @@ func (h *SaleCopi04Handler) Delete(c fiber.Ctx) error {
 	return utils.SuccessResponse(c, "Successfully deleted COPI04 data", nil)
 }
 
+// ExportPDF prints a single COPI04 document; ?orientation=portrait|landscape
+func (h *SaleCopi04Handler) ExportPDF(c fiber.Ctx) error {
+	var req dto.SaleCopi04Req
+	if err := c.Bind().URI(&req); err != nil {
+		return utils.BadRequestResponse(c, "Invalid URI params", err)
+	}
+
+	file, err := h.SaleCopi04Service.ExportPDF(c.RequestCtx(), req, c.Query("orientation"))
+	if err != nil {
+		if errors.Is(err, service.ErrSaleCopi04NotFound) {
+			return utils.NotFoundResponse(c, err.Error())
+		}
+		if errors.Is(err, service.ErrInvalidExportFormat) {
+			return utils.BadRequestResponse(c, "Invalid export format", err.Error())
+		}
+		return utils.InternalErrorResponse(c, "Failed to export COPI04 document", err)
+	}
+
+	data, _ := file.FileDetal.([]byte)
+	c.Set("Content-Type", file.ContentType)
+	c.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, file.FileName))
+	return c.Send(data)
+}
+
 // ✅ Setup routes
 func (h *SaleCopi04Handler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 	saleCopi04 := router.Group("/sale-copi04")
-	saleCopi04.Get("/", h.GetAllCopi04) // List all
-	saleCopi04.Get("/:id", h.GetCopi04) // Get by ID
-	saleCopi04.Post("/", h.Create)      // Create
-	saleCopi04.Put("/:id", h.Update)    // Update
-	saleCopi04.Delete("/:id", h.Delete) // Delete
+	guard := middleware.Guard(saleCopi04)
+	guard.Get("/", models.PermissionSaleCopi04View, h.GetAllCopi04)     // List all
+	guard.Get("/:id", models.PermissionSaleCopi04View, h.GetCopi04)     // Get by ID
+	guard.Get("/:id/pdf", models.PermissionSaleCopi04View, h.ExportPDF) // Print as PDF
+	guard.Post("/", models.PermissionSaleCopi04Manage, h.Create)        // Create
+	guard.Put("/:id", models.PermissionSaleCopi04Manage, h.Update)      // Update
+	guard.Delete("/:id", models.PermissionSaleCopi04Manage, h.Delete)   // Delete
 }
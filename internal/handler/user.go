@@ -2,8 +2,11 @@ package handler
 
 import (
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/middleware"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/service"
 	"cqs-kanban/internal/utils"
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v3"
@@ -26,6 +29,9 @@ func (u *UserHandler) Create(c fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
 	if err := u.userService.Create(c.RequestCtx(), req); err != nil {
+		if isPasswordError(err) {
+			return utils.BadRequestResponse(c, "failed to create users", err)
+		}
 		return utils.InternalErrorResponse(c, "failed to create users", err)
 	}
 	return utils.SuccessResponse(c, "create user success", nil)
@@ -50,6 +56,9 @@ func (u *UserHandler) Update(c fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "Invalid request body", err)
 	}
 	if err := u.userService.Update(c.RequestCtx(), id, req); err != nil {
+		if isPasswordError(err) {
+			return utils.BadRequestResponse(c, "failed to update user", err)
+		}
 		return utils.InternalErrorResponse(c, "failed to update user", err)
 	}
 	return utils.SuccessResponse(c, "update user success", nil)
@@ -68,6 +77,9 @@ func (u *UserHandler) UpdatePassword(c fiber.Ctx) error {
 		return utils.BadRequestResponse(c, "New password cannot be empty", nil)
 	}
 	if err := u.userService.UpdatePassword(c.RequestCtx(), int64(id), req); err != nil {
+		if isPasswordError(err) {
+			return utils.BadRequestResponse(c, "failed to update user password", err)
+		}
 		return utils.InternalErrorResponse(c, "failed to update user password", err)
 	}
 	return utils.SuccessResponse(c, "update user password success", nil)
@@ -105,10 +117,20 @@ func (u *UserHandler) SetupRoutes(router fiber.Router, ms ...fiber.Handler) {
 			users.Use(m)
 		}
 	}
-	users.Post("/", u.Create)
-	users.Get("/", u.GetAll)
-	users.Put("/:id", u.Update)
-	users.Delete("/:id", u.Delete)
-	users.Put("/:id/password", u.UpdatePassword)
-	users.Get("/:id", u.GetByID)
+	guard := middleware.Guard(users)
+	guard.Post("/", models.PermissionUserManage, u.Create)
+	guard.Get("/", models.PermissionUserView, u.GetAll)
+	guard.Put("/:id", models.PermissionUserManage, u.Update)
+	guard.Delete("/:id", models.PermissionUserManage, u.Delete)
+	guard.Put("/:id/password", models.PermissionUserManage, u.UpdatePassword)
+	guard.Get("/:id", models.PermissionUserView, u.GetByID)
+}
+
+// isPasswordError reports whether err is a rejected password rather than a server error
+func isPasswordError(err error) bool {
+	return errors.Is(err, service.ErrPasswordPolicy) ||
+		errors.Is(err, service.ErrPasswordReused) ||
+		errors.Is(err, service.ErrPasswordMismatch) ||
+		errors.Is(err, service.ErrCurrentPasswordIncorrect) ||
+		errors.Is(err, service.ErrExternalAccount)
 }
@@ -8,15 +8,43 @@ import (
 	"github.com/gofiber/fiber/v3"
 )
 
-func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Handler {
+// APIKeyHeader carries a managed API key as an alternative to a Bearer token.
+const APIKeyHeader = "X-API-Key"
+
+func JWTMiddleware(authService service.AuthService, apiKeyService service.APIKeyService, whiteList []string) fiber.Handler {
 	return func(c fiber.Ctx) error {
 		// Skip middleware for whitelisted routes
 		for _, route := range whiteList {
 			if c.Path() == route {
+				c.Locals("whitelisted", true)
 				return c.Next()
 			}
 		}
 
+		// Machine clients authenticate with an API key
+		if apiKey := c.Get(APIKeyHeader); apiKey != "" {
+			key, err := apiKeyService.Authenticate(c.RequestCtx(), apiKey)
+			if err != nil {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+					"error":   "Invalid API key",
+					"message": err.Error(),
+				})
+			}
+
+			scopes := make(map[string]bool)
+			for _, scope := range key.ScopeList() {
+				scopes[scope] = true
+			}
+
+			c.Locals("user_id", key.Owner.ID)
+			c.Locals("username", key.Owner.Username)
+			c.Locals("department_id", key.Owner.DepartmentID)
+			c.Locals("role", key.Owner.Role)
+			c.Locals("api_key_id", key.ID)
+			c.Locals("scopes", scopes)
+			return c.Next()
+		}
+
 		// Get the JWT token from the request
 		authHeader := c.Get("Authorization")
 
@@ -28,16 +56,6 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 			})
 		}
 
-		// Bypass if auth token is super admin
-		if authHeader == "Basic 17c4520f6cfd1ab53d8745e84681eb49" {
-			c.Locals("user_id", 0)
-			c.Locals("username", "super_admin")
-			c.Locals("is_admin", true)
-			c.Locals("department_id")
-			fmt.Println("Super Admin Access Granted")
-			return c.Next()
-		}
-
 		// Check if auth header format is valid
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
@@ -68,6 +86,10 @@ func JWTMiddleware(authService service.AuthService, whiteList []string) fiber.Ha
 		c.Locals("user_id", claims.UserID)
 		c.Locals("username", claims.Username)
 		c.Locals("department_id", claims.DepartmentID)
+		c.Locals("role", claims.Role)
+		c.Locals("session_id", claims.SessionID)
+		c.Locals("must_change_password", claims.MustChangePassword)
+		c.Locals("two_factor_enroll_required", claims.TwoFactorEnrollRequired)
 		fmt.Println("Authenticated user ID:", claims.UserID, "Username:", claims.Username)
 
 		// Continue to next handler
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v3"
+)
+
+// PasswordChangeMiddleware rejects requests from users whose password must be
+// changed, except for the paths in allowed (the password change itself,
+// profile, refresh and logout).
+func PasswordChangeMiddleware(allowed []string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if mustChange, _ := c.Locals("must_change_password").(bool); !mustChange {
+			return c.Next()
+		}
+
+		for _, path := range allowed {
+			if c.Path() == path {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   "Password change required",
+			"message": "You must change your password before using other endpoints",
+		})
+	}
+}
+
+// TwoFactorEnrollmentMiddleware rejects requests from users whose role requires
+// 2FA but who have not enrolled yet, except for the paths in allowed.
+func TwoFactorEnrollmentMiddleware(allowed []string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if required, _ := c.Locals("two_factor_enroll_required").(bool); !required {
+			return c.Next()
+		}
+
+		for _, path := range allowed {
+			if c.Path() == path {
+				return c.Next()
+			}
+		}
+
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"error":   "Two-factor enrolment required",
+			"message": "Your role requires two-factor authentication; enrol before using other endpoints",
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/service"
+	"cqs-kanban/internal/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// RoutePermission describes the permission a single route requires.
+type RoutePermission struct {
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Permission string `json:"permission"`
+}
+
+// PermissionRegistry keeps the route-to-permission table declared by the handlers.
+type PermissionRegistry struct {
+	mu     sync.RWMutex
+	routes map[string]RoutePermission
+}
+
+func NewPermissionRegistry() *PermissionRegistry {
+	return &PermissionRegistry{
+		routes: make(map[string]RoutePermission),
+	}
+}
+
+// Permissions is the registry populated by every handler's SetupRoutes.
+var Permissions = NewPermissionRegistry()
+
+func (r *PermissionRegistry) Register(method, path, permission string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[method+" "+path] = RoutePermission{
+		Method:     method,
+		Path:       path,
+		Permission: permission,
+	}
+}
+
+func (r *PermissionRegistry) Routes() []RoutePermission {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	routes := make([]RoutePermission, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path == routes[j].Path {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+// GuardedRouter registers routes together with the permission they require.
+type GuardedRouter struct {
+	router   fiber.Router
+	prefix   string
+	registry *PermissionRegistry
+}
+
+// Guard wraps router so that every route added through it is recorded in the
+// registry and rejected with 403 when the caller lacks the permission.
+func Guard(router fiber.Router) *GuardedRouter {
+	prefix := ""
+	if group, ok := router.(*fiber.Group); ok {
+		prefix = group.Prefix
+	}
+	return &GuardedRouter{
+		router:   router,
+		prefix:   prefix,
+		registry: Permissions,
+	}
+}
+
+func (g *GuardedRouter) Get(path, permission string, handler fiber.Handler) {
+	g.add(fiber.MethodGet, path, permission, handler)
+}
+
+func (g *GuardedRouter) Post(path, permission string, handler fiber.Handler) {
+	g.add(fiber.MethodPost, path, permission, handler)
+}
+
+func (g *GuardedRouter) Put(path, permission string, handler fiber.Handler) {
+	g.add(fiber.MethodPut, path, permission, handler)
+}
+
+func (g *GuardedRouter) Delete(path, permission string, handler fiber.Handler) {
+	g.add(fiber.MethodDelete, path, permission, handler)
+}
+
+func (g *GuardedRouter) add(method, path, permission string, handler fiber.Handler) {
+	fullPath := strings.TrimRight(g.prefix+path, "/")
+	if fullPath == "" {
+		fullPath = "/"
+	}
+	g.registry.Register(method, fullPath, permission)
+	g.router.Add([]string{method}, path, RequirePermission(permission), handler)
+}
+
+// PermissionMiddleware resolves the permissions of the authenticated user and
+// stores them in the "permissions" local for RequirePermission. Requests made
+// with an API key are further limited to the key's scopes.
+func PermissionMiddleware(permissionService service.PermissionService) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		if role == "" {
+			return c.Next()
+		}
+		departmentID, _ := c.Locals("department_id").(int64)
+
+		permissions, err := permissionService.GetPermissions(c.RequestCtx(), role, departmentID)
+		if err != nil {
+			return utils.InternalErrorResponse(c, "Failed to resolve permissions", err)
+		}
+		if scopes, ok := c.Locals("scopes").(map[string]bool); ok {
+			permissions = restrictToScopes(permissions, scopes)
+		}
+		c.Locals("permissions", permissions)
+
+		return c.Next()
+	}
+}
+
+// restrictToScopes returns the permissions present both in permissions and scopes.
+func restrictToScopes(permissions, scopes map[string]bool) map[string]bool {
+	if scopes[models.PermissionAll] {
+		return permissions
+	}
+	restricted := make(map[string]bool, len(scopes))
+	for scope := range scopes {
+		if permissions[models.PermissionAll] || permissions[scope] {
+			restricted[scope] = true
+		}
+	}
+	return restricted
+}
+
+// HasPermission reports whether the caller's resolved permissions include permission.
+func HasPermission(c fiber.Ctx, permission string) bool {
+	permissions, _ := c.Locals("permissions").(map[string]bool)
+	return permissions[models.PermissionAll] || permissions[permission]
+}
+
+// RequirePermission rejects the request with 403 unless the caller holds
+// permission. Routes that declare a permission are never reachable without
+// authentication, even when they match the JWT whitelist.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if HasPermission(c, permission) {
+			return c.Next()
+		}
+
+		return utils.ErrorResponse(c, fiber.StatusForbidden, "Forbidden",
+			fmt.Sprintf("missing permission %s for %s %s", permission, c.Method(), c.Route().Path))
+	}
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix is prepended to every generated key so keys are easy to
+// recognise in logs and secret scanners.
+const APIKeyPrefix = "ekb_"
+
+// APIKey is a machine credential owned by a user. Only the SHA-256 hash of
+// the key is stored; Prefix keeps the first characters for identification.
+// Scopes is a comma separated list of permission codes that further limits
+// the owner's permissions; "*" keeps all of them.
+type APIKey struct {
+	ID         int64      `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(20);not null"`
+	KeyHash    string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	OwnerID    int64      `json:"owner_id" gorm:"not null;index"`
+	Owner      *User      `json:"owner,omitempty" gorm:"foreignKey:OwnerID"`
+	Scopes     string     `json:"scopes" gorm:"type:text"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedBy  int64      `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func (APIKey) Table() string {
+	return "api_keys"
+}
+
+// ScopeList returns the scopes as a slice, skipping empty entries.
+func (k *APIKey) ScopeList() []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(k.Scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// IsActive reports whether the key can still be used at now.
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
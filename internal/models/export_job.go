@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// Export job kinds
+const (
+	ExportKindReport   = "report"
+	ExportKindForecast = "forecast"
+)
+
+// Export job statuses
+const (
+	ExportJobQueued    = "queued"
+	ExportJobRunning   = "running"
+	ExportJobSucceeded = "succeeded"
+	ExportJobFailed    = "failed"
+	ExportJobCancelled = "cancelled"
+)
+
+// ExportJob is an export generated in the background. Request holds the JSON
+// of the export request. DepartmentID and AllDepartments record the owner's
+// scope when the job was queued; the job runs with the owner's current
+// account and permissions. A running job refreshes HeartbeatAt so that jobs
+// left behind by a stopped server can be queued again; Attempts counts the
+// claims of the job, so that one that keeps taking the server down fails.
+type ExportJob struct {
+	ID             string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	Kind           string     `json:"kind" gorm:"type:varchar(20);not null"`
+	ReportID       int64      `json:"report_id"`
+	Request        string     `json:"request" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"type:varchar(20);not null;index"`
+	Progress       int        `json:"progress" gorm:"not null;default:0"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	Error          string     `json:"error" gorm:"type:text"`
+	FileName       string     `json:"file_name" gorm:"type:varchar(255)"`
+	FilePath       string     `json:"-" gorm:"type:varchar(500)"`
+	FileSize       int64      `json:"file_size"`
+	UserID         int64      `json:"user_id" gorm:"not null;index"`
+	DepartmentID   int64      `json:"department_id"`
+	AllDepartments bool       `json:"all_departments"`
+	IPAddress      string     `json:"ip_address" gorm:"type:varchar(64)"`
+	CreatedAt      time.Time  `json:"created_at"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`
+	HeartbeatAt    *time.Time `json:"heartbeat_at,omitempty"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ExportJob) Table() string {
+	return "export_jobs"
+}
+
+// IsFinished reports whether the job reached a final status.
+func (j *ExportJob) IsFinished() bool {
+	return j.Status == ExportJobSucceeded || j.Status == ExportJobFailed || j.Status == ExportJobCancelled
+}
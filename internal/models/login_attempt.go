@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// Login attempt results recorded in LoginAttempt.Reason
+const (
+	LoginReasonSuccess         = "success"
+	LoginReasonUnknownUser     = "unknown_user"
+	LoginReasonInvalidPassword = "invalid_password"
+	LoginReasonInactive        = "inactive"
+	LoginReasonPasswordExpired = "password_expired"
+	LoginReasonOTPRequired     = "otp_required"
+	LoginReasonInvalidOTP      = "invalid_otp"
+	LoginReasonLocked          = "locked"
+	// LoginReasonProviderError is a failure of the identity provider, such as
+	// an unreachable directory; LoginReasonError any other internal failure
+	LoginReasonProviderError = "provider_error"
+	LoginReasonError         = "error"
+)
+
+// Lockout scopes
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIP      = "ip"
+)
+
+// LoginAttempt records every call to the login endpoint.
+type LoginAttempt struct {
+	ID          int64     `json:"id" gorm:"primaryKey"`
+	Username    string    `json:"username" gorm:"type:varchar(100);index"`
+	UserID      int64     `json:"user_id,omitempty"`
+	IPAddress   string    `json:"ip_address" gorm:"type:varchar(64);index"`
+	UserAgent   string    `json:"user_agent,omitempty" gorm:"type:varchar(255)"`
+	Success     bool      `json:"success"`
+	Reason      string    `json:"reason" gorm:"type:varchar(50)"`
+	AttemptedAt time.Time `json:"attempted_at" gorm:"not null;index"`
+}
+
+func (LoginAttempt) Table() string {
+	return "login_attempts"
+}
+
+// Lockout blocks logins for an account (Target is the username) or an IP address
+// until LockedUntil, unless an administrator unlocks it earlier.
+type Lockout struct {
+	ID          int64      `json:"id" gorm:"primaryKey"`
+	Scope       string     `json:"scope" gorm:"type:varchar(20);not null;index:idx_lockout_scope_target"`
+	Target      string     `json:"target" gorm:"type:varchar(100);not null;index:idx_lockout_scope_target"`
+	FailedCount int64      `json:"failed_count"`
+	LockedAt    time.Time  `json:"locked_at" gorm:"not null"`
+	LockedUntil time.Time  `json:"locked_until" gorm:"not null"`
+	UnlockedAt  *time.Time `json:"unlocked_at,omitempty"`
+	UnlockedBy  int64      `json:"unlocked_by,omitempty"`
+}
+
+func (Lockout) Table() string {
+	return "lockouts"
+}
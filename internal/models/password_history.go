@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// PasswordHistory keeps previous password hashes of a user to prevent reuse.
+type PasswordHistory struct {
+	ID           int64     `json:"id" gorm:"primaryKey"`
+	UserID       int64     `json:"user_id" gorm:"not null;index"`
+	PasswordHash string    `json:"-" gorm:"not null"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (PasswordHistory) Table() string {
+	return "password_histories"
+}
@@ -0,0 +1,115 @@
+package models
+
+import "time"
+
+// Roles
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+)
+
+// Permission codes. PermissionAll grants every permission.
+const (
+	PermissionAll = "*"
+
+	PermissionReportView   = "report:view"
+	PermissionReportExport = "report:export"
+	PermissionReportManage = "report:manage"
+
+	// PermissionAllDepartments lifts the department scope on reports and menus
+	PermissionAllDepartments = "data:all_departments"
+
+	PermissionMenuView   = "menu:view"
+	PermissionMenuManage = "menu:manage"
+
+	PermissionDepartmentView   = "department:view"
+	PermissionDepartmentManage = "department:manage"
+
+	PermissionUserView   = "user:view"
+	PermissionUserManage = "user:manage"
+
+	PermissionForecastView   = "forecast:view"
+	PermissionForecastExport = "forecast:export"
+
+	PermissionCopmaView = "copma:view"
+
+	PermissionSaleCopi04View   = "sale_copi04:view"
+	PermissionSaleCopi04Manage = "sale_copi04:manage"
+
+	PermissionAdminDashboard  = "admin:dashboard"
+	PermissionAdminOperations = "admin:operations"
+	PermissionAdminLogs       = "admin:logs"
+	PermissionAdminSecurity   = "admin:security"
+	PermissionAdminPermission = "admin:permissions"
+	PermissionAdminAPIKeys    = "admin:api_keys"
+	PermissionAdminQueries    = "admin:queries"
+)
+
+// PermissionDescriptions is the catalogue of known permissions seeded into the permissions table.
+var PermissionDescriptions = map[string]string{
+	PermissionAll:              "Full access to every endpoint",
+	PermissionReportView:       "View report data",
+	PermissionReportExport:     "Export report data",
+	PermissionReportManage:     "Create, update and delete report definitions",
+	PermissionAllDepartments:   "Access reports and menus of every department",
+	PermissionMenuView:         "View menus",
+	PermissionMenuManage:       "Create, update and delete menus",
+	PermissionDepartmentView:   "View departments",
+	PermissionDepartmentManage: "Create, update and delete departments",
+	PermissionUserView:         "View users",
+	PermissionUserManage:       "Create, update and delete users",
+	PermissionForecastView:     "View forecasts",
+	PermissionForecastExport:   "Export forecasts",
+	PermissionCopmaView:        "View COPMA master data",
+	PermissionSaleCopi04View:   "View COPI04 documents",
+	PermissionSaleCopi04Manage: "Create, update and delete COPI04 documents",
+	PermissionAdminDashboard:   "View admin dashboard statistics",
+	PermissionAdminOperations:  "Manage operations",
+	PermissionAdminLogs:        "View and purge access logs",
+	PermissionAdminSecurity:    "View security alerts",
+	PermissionAdminPermission:  "Manage role permissions",
+	PermissionAdminAPIKeys:     "Create, rotate and revoke API keys",
+	PermissionAdminQueries:     "View and kill running report queries",
+}
+
+// DefaultRolePermissions is seeded into role_permissions when a role has no grants yet.
+var DefaultRolePermissions = map[string][]string{
+	RoleAdmin: {PermissionAll},
+	RoleUser: {
+		PermissionReportView,
+		PermissionReportExport,
+		PermissionMenuView,
+		PermissionDepartmentView,
+		PermissionForecastView,
+		PermissionForecastExport,
+		PermissionCopmaView,
+		PermissionSaleCopi04View,
+	},
+}
+
+type Permission struct {
+	ID          int64     `json:"id" gorm:"primaryKey"`
+	Code        string    `json:"code" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description string    `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (Permission) Table() string {
+	return "permissions"
+}
+
+// RolePermission grants a permission to a role. A zero DepartmentID applies the
+// grant to every department, otherwise only to users of that department.
+type RolePermission struct {
+	ID             int64     `json:"id" gorm:"primaryKey"`
+	Role           string    `json:"role" gorm:"type:varchar(50);not null;index"`
+	PermissionCode string    `json:"permission_code" gorm:"type:varchar(100);not null"`
+	DepartmentID   int64     `json:"department_id" gorm:"not null;default:0"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func (RolePermission) Table() string {
+	return "role_permissions"
+}
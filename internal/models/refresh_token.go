@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// RefreshToken is an opaque, single-use token that can be exchanged for a new
+// access token. Only the SHA-256 hash of the token is stored. Every rotation
+// stays in the same FamilyID so a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID        int64      `json:"id" gorm:"primaryKey"`
+	UserID    int64      `json:"user_id" gorm:"not null;index"`
+	FamilyID  string     `json:"family_id" gorm:"type:varchar(64);not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) Table() string {
+	return "refresh_tokens"
+}
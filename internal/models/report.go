@@ -3,28 +3,151 @@ package models
 import "time"
 
 type Report struct {
-	ID             int64     `json:"id" gorm:"primaryKey"`
-	ReportType     string    `gorm:"type:varchar(100);not null" json:"report_type"`
-	ReportName     string    `gorm:"type:varchar(255);not null" json:"report_name"`
-	DepartmentID   string    `josn:"department_id" gorm:"not null"`
-	QueryStatement string    `gorm:"type:text;not null" json:"query_statement"`
-	CreatedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
-	UpdatedAt      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	ID             int64  `json:"id" gorm:"primaryKey"`
+	ReportType     string `gorm:"type:varchar(100);not null" json:"report_type"`
+	ReportName     string `gorm:"type:varchar(255);not null" json:"report_name"`
+	DepartmentID   string `josn:"department_id" gorm:"not null"`
+	QueryStatement string `gorm:"type:text;not null" json:"query_statement"`
+	// CacheTTLSeconds keeps query results in memory for that long; 0 disables caching
+	CacheTTLSeconds int `gorm:"not null;default:0" json:"cache_ttl_seconds"`
+	// QueryTimeoutSeconds overrides the configured query timeout when set
+	QueryTimeoutSeconds int       `gorm:"not null;default:0" json:"query_timeout_seconds"`
+	CreatedAt           time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	UpdatedAt           time.Time `gorm:"default:CURRENT_TIMESTAMP"`
 }
 
 func (Report) Table() string {
 	return "reports"
 }
 
+// ReportColumn defines a report column and how its values are displayed and
+// exported. Decimals falls back to the type's default when nil, Width (in
+// characters) to the exporter's when 0, and Align to the type's when empty.
+// NumberFormat is an Excel number format code that overrides the one derived
+// from the type and decimals. GroupLevel groups report rows by the column, 1
+// being the outermost group and 0 not grouped; Aggregate is computed for the
+// group subtotals and the grand total.
 type ReportColumn struct {
-	ID       int64  `json:"id" gorm:"primaryKey" `
-	ReportID int64  `json:"report_id" gorm:"not null"`
-	Title    string `json:"title" gorm:"not null"`
-	Code     string `json:"code" gorm:"not null"`
-	Type     string `json:"type" gorm:"not null"`
-	Num      int64  `json:"num" gorm:"not null"`
+	ID           int64  `json:"id" gorm:"primaryKey" `
+	ReportID     int64  `json:"report_id" gorm:"not null"`
+	Title        string `json:"title" gorm:"not null"`
+	Code         string `json:"code" gorm:"not null"`
+	Type         string `json:"type" gorm:"not null"`
+	Num          int64  `json:"num" gorm:"not null"`
+	Decimals     *int   `json:"decimals"`
+	Width        int    `json:"width" gorm:"not null;default:0"`
+	Align        string `json:"align" gorm:"type:varchar(10)"`
+	Hidden       bool   `json:"hidden" gorm:"not null;default:false"`
+	NumberFormat string `json:"number_format" gorm:"type:varchar(100)"`
+	GroupLevel   int    `json:"group_level" gorm:"not null;default:0"`
+	Aggregate    string `json:"aggregate" gorm:"type:varchar(10)"`
 }
 
 func (ReportColumn) Table() string {
 	return "report_columns"
 }
+
+// Report column types. All but currency and percent are suggested from the
+// SQL type of a result set column; percent values are fractions (0.15 is 15%).
+const (
+	ReportColumnString   = "string"
+	ReportColumnInt      = "int"
+	ReportColumnDecimal  = "decimal"
+	ReportColumnDate     = "date"
+	ReportColumnBool     = "bool"
+	ReportColumnCurrency = "currency"
+	ReportColumnPercent  = "percent"
+)
+
+// Report column aggregates
+const (
+	ReportAggregateSum   = "sum"
+	ReportAggregateCount = "count"
+	ReportAggregateAvg   = "avg"
+	ReportAggregateMin   = "min"
+	ReportAggregateMax   = "max"
+)
+
+// Report column alignments
+const (
+	ReportAlignLeft   = "left"
+	ReportAlignCenter = "center"
+	ReportAlignRight  = "right"
+)
+
+// ReportShare grants a department access to a report owned by another department.
+type ReportShare struct {
+	ID           int64     `json:"id" gorm:"primaryKey"`
+	ReportID     int64     `json:"report_id" gorm:"not null;uniqueIndex:idx_report_share"`
+	DepartmentID int64     `json:"department_id" gorm:"not null;uniqueIndex:idx_report_share;index"`
+	CreatedBy    int64     `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (ReportShare) Table() string {
+	return "report_shares"
+}
+
+// ReportRevision is an immutable snapshot of a report definition, written on
+// every change. Columns and Parameters hold the JSON of the report's
+// ReportColumn and ReportParameter rows at that time.
+type ReportRevision struct {
+	ID                  int64     `json:"id" gorm:"primaryKey"`
+	ReportID            int64     `json:"report_id" gorm:"not null;uniqueIndex:idx_report_revision"`
+	Revision            int       `json:"revision" gorm:"not null;uniqueIndex:idx_report_revision"`
+	ReportType          string    `json:"report_type" gorm:"type:varchar(100)"`
+	ReportName          string    `json:"report_name" gorm:"type:varchar(255)"`
+	DepartmentID        string    `json:"department_id"`
+	QueryStatement      string    `json:"query_statement" gorm:"type:text"`
+	Columns             string    `json:"columns" gorm:"type:text"`
+	Parameters          string    `json:"parameters" gorm:"type:text"`
+	CacheTTLSeconds     int       `json:"cache_ttl_seconds"`
+	QueryTimeoutSeconds int       `json:"query_timeout_seconds"`
+	Comment             string    `json:"comment" gorm:"type:varchar(500)"`
+	CreatedBy           int64     `json:"created_by"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+func (ReportRevision) Table() string {
+	return "report_revisions"
+}
+
+// Report parameter types
+const (
+	ReportParamString  = "string"
+	ReportParamInt     = "int"
+	ReportParamDecimal = "decimal"
+	ReportParamDate    = "date"
+	ReportParamBool    = "bool"
+)
+
+// Report parameter lookup sources, resolved against ERP master tables
+const (
+	ReportLookupCustomer       = "customer"
+	ReportLookupItem           = "item"
+	ReportLookupWarehouse      = "warehouse"
+	ReportLookupSaleDepartment = "sale_department"
+	ReportLookupCurrency       = "currency"
+)
+
+// ReportParameter declares a named SQL parameter (@Name) of a report query.
+// AllowedValues is a comma-separated list; LookupSource restricts values to
+// the codes of an ERP master table instead. Multiple parameters bind a list
+// for use as "col IN @Name".
+type ReportParameter struct {
+	ID            int64  `json:"id" gorm:"primaryKey"`
+	ReportID      int64  `json:"report_id" gorm:"not null;index"`
+	Name          string `json:"name" gorm:"type:varchar(64);not null"`
+	Label         string `json:"label" gorm:"type:varchar(255)"`
+	Type          string `json:"type" gorm:"type:varchar(20);not null"`
+	Required      bool   `json:"required"`
+	Multiple      bool   `json:"multiple"`
+	DefaultValue  string `json:"default_value"`
+	AllowedValues string `json:"allowed_values"`
+	LookupSource  string `json:"lookup_source" gorm:"type:varchar(50)"`
+	Num           int64  `json:"num"`
+}
+
+func (ReportParameter) Table() string {
+	return "report_parameters"
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Report export formats
+const (
+	ReportFormatXLSX = "xlsx"
+	ReportFormatCSV  = "csv"
+	ReportFormatTSV  = "tsv"
+	ReportFormatPDF  = "pdf"
+)
+
+// Report schedule run statuses
+const (
+	ReportRunRunning = "running"
+	ReportRunSuccess = "success"
+	ReportRunFailed  = "failed"
+)
+
+// ReportSchedule mails a report export to Recipients, a comma separated list
+// of addresses, whenever Cron matches in Timezone. Preset names the date range
+// computed at run time; Params holds the JSON of the report parameters by
+// name. Each run uses the creator's current account: it fails when the
+// creator is inactive or can no longer export, and takes the creator's
+// current department scope. DepartmentID and AllDepartments record the scope
+// at creation.
+type ReportSchedule struct {
+	ID             int64      `json:"id" gorm:"primaryKey"`
+	ReportID       int64      `json:"report_id" gorm:"not null;index"`
+	Name           string     `json:"name" gorm:"type:varchar(255);not null"`
+	Cron           string     `json:"cron" gorm:"type:varchar(100);not null"`
+	Timezone       string     `json:"timezone" gorm:"type:varchar(64);not null"`
+	Preset         string     `json:"preset" gorm:"type:varchar(50);not null"`
+	Params         string     `json:"params" gorm:"type:text"`
+	Format         string     `json:"format" gorm:"type:varchar(10);not null"`
+	Recipients     string     `json:"recipients" gorm:"type:text;not null"`
+	Enabled        bool       `json:"enabled" gorm:"not null;default:true"`
+	DepartmentID   int64      `json:"department_id"`
+	AllDepartments bool       `json:"all_departments"`
+	NextRunAt      *time.Time `json:"next_run_at,omitempty" gorm:"index"`
+	LastRunAt      *time.Time `json:"last_run_at,omitempty"`
+	CreatedBy      int64      `json:"created_by"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (ReportSchedule) Table() string {
+	return "report_schedules"
+}
+
+// RecipientList returns the recipients as a slice, skipping empty entries.
+func (s *ReportSchedule) RecipientList() []string {
+	return splitRecipients(s.Recipients)
+}
+
+// ReportScheduleRun records one execution of a schedule, whether started by
+// the scheduler or by hand.
+type ReportScheduleRun struct {
+	ID         int64      `json:"id" gorm:"primaryKey"`
+	ScheduleID int64      `json:"schedule_id" gorm:"not null;index"`
+	ReportID   int64      `json:"report_id" gorm:"not null"`
+	Status     string     `json:"status" gorm:"type:varchar(20);not null"`
+	Manual     bool       `json:"manual"`
+	FromDate   time.Time  `json:"from_date"`
+	ToDate     time.Time  `json:"to_date"`
+	FileName   string     `json:"file_name" gorm:"type:varchar(255)"`
+	Recipients string     `json:"recipients" gorm:"type:text"`
+	Error      string     `json:"error" gorm:"type:text"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+func (ReportScheduleRun) Table() string {
+	return "report_schedule_runs"
+}
+
+// RecipientList returns the addresses the run was sent to.
+func (r *ReportScheduleRun) RecipientList() []string {
+	return splitRecipients(r.Recipients)
+}
+
+func splitRecipients(list string) []string {
+	recipients := make([]string, 0)
+	for _, recipient := range strings.Split(list, ",") {
+		if recipient = strings.TrimSpace(recipient); recipient != "" {
+			recipients = append(recipients, recipient)
+		}
+	}
+	return recipients
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	RevocationScopeToken   = "token"
+	RevocationScopeUser    = "user"
+	RevocationScopeSession = "session"
+)
+
+// RevokedToken blacklists either a single token (Scope "token", matched by JTI),
+// every token of a user issued at or before RevokedAt (Scope "user"), or every
+// token of one session (Scope "session", matched by SessionID).
+// Rows can be purged once ExpiresAt has passed because the affected tokens
+// are expired by then.
+type RevokedToken struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	Scope     string    `json:"scope" gorm:"type:varchar(20);not null;default:'token'"`
+	JTI       string    `json:"jti" gorm:"type:varchar(64);index"`
+	SessionID string    `json:"session_id,omitempty" gorm:"type:varchar(64);index"`
+	UserID    int64     `json:"user_id" gorm:"not null;index"`
+	Reason    string    `json:"reason" gorm:"type:varchar(255)"`
+	RevokedBy int64     `json:"revoked_by"`
+	RevokedAt time.Time `json:"revoked_at" gorm:"not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (RevokedToken) Table() string {
+	return "revoked_tokens"
+}
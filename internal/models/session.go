@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Session is one signed-in device. SessionID equals the refresh token family
+// and is carried in access tokens as the "sid" claim. ExpiresAt follows the
+// latest refresh token of the family.
+type Session struct {
+	ID         int64      `json:"id" gorm:"primaryKey"`
+	SessionID  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UserID     int64      `json:"user_id" gorm:"not null;index"`
+	User       *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	IPAddress  string     `json:"ip_address" gorm:"type:varchar(64)"`
+	UserAgent  string     `json:"user_agent" gorm:"type:varchar(255)"`
+	IssuedAt   time.Time  `json:"issued_at" gorm:"not null"`
+	ExpiresAt  time.Time  `json:"expires_at" gorm:"not null;index"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  int64      `json:"revoked_by,omitempty"`
+}
+
+func (Session) Table() string {
+	return "sessions"
+}
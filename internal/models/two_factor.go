@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// UserTOTP holds the TOTP secret of a user. The secret is stored when
+// enrolment starts and only takes effect once confirmed with a valid code.
+// LastUsedStep prevents the same code from being accepted twice.
+type UserTOTP struct {
+	ID           int64      `json:"id" gorm:"primaryKey"`
+	UserID       int64      `json:"user_id" gorm:"not null;uniqueIndex"`
+	Secret       string     `json:"-" gorm:"type:varchar(64);not null"`
+	Enabled      bool       `json:"enabled" gorm:"default:false"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (UserTOTP) Table() string {
+	return "user_totps"
+}
+
+// RecoveryCode is a single-use code that replaces a TOTP code. Only its hash is stored.
+type RecoveryCode struct {
+	ID        int64      `json:"id" gorm:"primaryKey"`
+	UserID    int64      `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) Table() string {
+	return "recovery_codes"
+}
+
+// LoginChallenge is issued after a correct password when the user has 2FA
+// enabled; it is exchanged for tokens together with a TOTP or recovery code.
+type LoginChallenge struct {
+	ID        int64      `json:"id" gorm:"primaryKey"`
+	UserID    int64      `json:"user_id" gorm:"not null;index"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	IPAddress string     `json:"ip_address" gorm:"type:varchar(64)"`
+	UserAgent string     `json:"user_agent,omitempty" gorm:"type:varchar(255)"`
+	Attempts  int        `json:"attempts"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (LoginChallenge) Table() string {
+	return "login_challenges"
+}
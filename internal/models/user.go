@@ -2,19 +2,29 @@ package models
 
 import "time"
 
+// Authentication sources recorded in User.AuthSource
+const (
+	AuthSourceLocal = "local"
+	AuthSourceLDAP  = "ldap"
+)
+
 type User struct {
-	ID           int64       `json:"id"`
-	Username     string      `json:"username"`
-	Password     string      `json:"password"`
-	FullName     string      `json:"full_name"`
-	Email        string      `json:"email,omitempty"`
-	DepartmentID int64       `json:"department_id,omitempty"`
-	Department   *Department `json:"department,omitempty"`
-	IsActive     bool        `json:"is_active" gorm:"default:true"`
-	Role         string      `json:"role" gorm:"default:'user'"`
-	LastLogin    time.Time   `json:"last_login,omitempty"`
-	CreatedAt    time.Time   `json:"created_at"`
-	UpdatedAt    time.Time   `json:"updated_at"`
+	ID                 int64       `json:"id"`
+	Username           string      `json:"username"`
+	Password           string      `json:"password"`
+	FullName           string      `json:"full_name"`
+	Email              string      `json:"email,omitempty"`
+	DepartmentID       int64       `json:"department_id,omitempty"`
+	Department         *Department `json:"department,omitempty"`
+	IsActive           bool        `json:"is_active" gorm:"default:true"`
+	Role               string      `json:"role" gorm:"default:'user'"`
+	AuthSource         string      `json:"auth_source" gorm:"type:varchar(20);default:'local'"`
+	MustChangePassword bool        `json:"must_change_password" gorm:"default:false"`
+	PasswordExpiresAt  *time.Time  `json:"password_expires_at,omitempty"`
+	PasswordChangedAt  *time.Time  `json:"password_changed_at,omitempty"`
+	LastLogin          time.Time   `json:"last_login,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
 }
 
 func (User) Table() string {
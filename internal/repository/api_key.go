@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+type (
+	apiKeyRepo struct {
+		db *gorm.DB
+	}
+	APIKeyRepo interface {
+		Create(ctx context.Context, key *models.APIKey) error
+		GetByID(ctx context.Context, id int64) (*models.APIKey, error)
+		GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+		GetAll(ctx context.Context) ([]models.APIKey, error)
+		UpdateHash(ctx context.Context, id int64, keyHash, prefix string) error
+		Revoke(ctx context.Context, id int64) error
+		TouchLastUsed(ctx context.Context, id int64, usedAt time.Time) error
+	}
+)
+
+func NewAPIKeyRepo(db *gorm.DB) APIKeyRepo {
+	return &apiKeyRepo{
+		db: db,
+	}
+}
+
+func (r *apiKeyRepo) Create(ctx context.Context, key *models.APIKey) error {
+	if err := r.db.WithContext(ctx).Create(key).Error; err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+func (r *apiKeyRepo) GetByID(ctx context.Context, id int64) (*models.APIKey, error) {
+	keys, err := gorm.G[models.APIKey](r.db).Preload("Owner", nil).Where("id = ?", id).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &keys[0], nil
+}
+
+func (r *apiKeyRepo) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	keys, err := gorm.G[models.APIKey](r.db).Preload("Owner", nil).Where("key_hash = ?", keyHash).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+	return &keys[0], nil
+}
+
+func (r *apiKeyRepo) GetAll(ctx context.Context) ([]models.APIKey, error) {
+	keys, err := gorm.G[models.APIKey](r.db).Preload("Owner", nil).Order("created_at DESC").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// UpdateHash replaces the key material of an active key, invalidating the old key.
+func (r *apiKeyRepo) UpdateHash(ctx context.Context, id int64, keyHash, prefix string) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"key_hash":     keyHash,
+			"prefix":       prefix,
+			"last_used_at": nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to rotate api key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepo) Revoke(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke api key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *apiKeyRepo) TouchLastUsed(ctx context.Context, id int64, usedAt time.Time) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id = ?", id).
+		UpdateColumn("last_used_at", usedAt).Error; err != nil {
+		return fmt.Errorf("failed to update api key last used: %w", err)
+	}
+	return nil
+}
@@ -3,10 +3,19 @@ package repository
 import (
 	"context"
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
+var ErrRowLimitExceeded = errors.New("row limit exceeded")
+
 type (
 	baseERPRepository struct {
 		db *gorm.DB
@@ -14,6 +23,11 @@ type (
 
 	BaseERPRepository interface {
 		GetBaseERP(ctx context.Context, input dto.BaseERPReq) (*dto.BaseERP, error)
+		// EachBaseERP runs the query like GetBaseERP but hands each row to fn as
+		// it is read instead of collecting them, stopping at fn's first error
+		EachBaseERP(ctx context.Context, input dto.BaseERPReq, fn func(row map[string]any) error) error
+		// PreviewBaseERP reads at most maxRows rows along with the result set columns
+		PreviewBaseERP(ctx context.Context, input dto.BaseERPReq, maxRows int) (*dto.BaseERPPreview, error)
 	}
 )
 
@@ -24,37 +38,250 @@ func NewBaseERPRepository(ctx context.Context, db *gorm.DB) BaseERPRepository {
 }
 
 func (r *baseERPRepository) GetBaseERP(ctx context.Context, input dto.BaseERPReq) (*dto.BaseERP, error) {
-	args := map[string]any{
-		"FromDate": input.FromDate,
-		"ToDate":   input.ToDate,
+	res := &dto.BaseERP{Data: []map[string]any{}}
+	total, err := r.query(ctx, input, func(row map[string]any) error {
+		res.Data = append(res.Data, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Total = total
+	if input.Limit <= 0 {
+		res.Total = int64(len(res.Data))
 	}
+	return res, nil
+}
 
-	data, err := gorm.G[map[string]any](r.db).Raw(input.SqlQuery, args).Find(ctx)
+func (r *baseERPRepository) EachBaseERP(ctx context.Context, input dto.BaseERPReq, fn func(row map[string]any) error) error {
+	_, err := r.query(ctx, input, fn)
+	return err
+}
+
+// query runs the report query with the sort, filters and paging of input and
+// calls fn with each row as it is read. With paging it also returns the
+// number of rows of the whole result.
+func (r *baseERPRepository) query(ctx context.Context, input dto.BaseERPReq, fn func(row map[string]any) error) (int64, error) {
+	args := baseERPArgs(input)
+	query, countQuery, err := baseERPQuery(input, args)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+
+	// Cancelling the context stops the query on the server when the row limit is hit
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The driver has no read-only transactions, so the query runs in one that
+	// is always rolled back; the read-only login is the primary safeguard.
+	tx := r.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+	defer tx.Rollback()
+
+	var total int64
+	if countQuery != "" {
+		if err := tx.Raw(countQuery, args).Scan(&total).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	rows, err := tx.Raw(query, args).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	count := 0
+	_, err = scanRows(rows, func(row map[string]any) error {
+		if input.MaxRows > 0 && count >= input.MaxRows {
+			cancel()
+			return fmt.Errorf("%w: more than %d rows", ErrRowLimitExceeded, input.MaxRows)
+		}
+		count++
+		return fn(row)
+	})
+	return total, err
+}
+
+// baseERPQuery wraps the report query as a derived table to apply the sort,
+// filters and paging of input, binding their values into args. With paging it
+// also returns the query counting the rows of the whole result.
+func baseERPQuery(input dto.BaseERPReq, args map[string]any) (query string, countQuery string, err error) {
+	if input.Sort == "" && len(input.GroupBy) == 0 && len(input.Filters) == 0 && input.Limit <= 0 {
+		return input.SqlQuery, "", nil
+	}
+	with, body, err := utils.SplitReportSQL(input.SqlQuery)
+	if err != nil {
+		return "", "", err
+	}
+	source := fmt.Sprintf("%s\nSELECT %%s FROM (\n%s\n) AS report_source%s", with, body, baseERPWhere(input.Filters, args))
+
+	order := baseERPOrder(input)
+	query = fmt.Sprintf(source, "*")
+	if order != "" || input.Limit > 0 {
+		if order == "" {
+			order = "(SELECT NULL)"
+		}
+		query += "\nORDER BY " + order
+	}
+	if input.Limit > 0 {
+		query += "\nOFFSET @__offset ROWS FETCH NEXT @__limit ROWS ONLY"
+		args["__offset"] = input.Offset
+		args["__limit"] = input.Limit
+		countQuery = fmt.Sprintf(source, "COUNT_BIG(*)")
+	}
+	return query, countQuery, nil
+}
+
+// baseERPOrder orders by the group columns and then the sort column. A sort
+// on a group column sets that column's direction instead, as SQL Server does
+// not allow a column twice in ORDER BY.
+func baseERPOrder(input dto.BaseERPReq) string {
+	direction := func(column string) string {
+		if column == input.Sort && input.SortDesc {
+			return " DESC"
+		}
+		return " ASC"
+	}
+	order := make([]string, 0, len(input.GroupBy)+1)
+	for _, column := range input.GroupBy {
+		order = append(order, utils.QuoteSQLIdentifier(column)+direction(column))
 	}
+	if input.Sort != "" && !slices.Contains(input.GroupBy, input.Sort) {
+		order = append(order, utils.QuoteSQLIdentifier(input.Sort)+direction(input.Sort))
+	}
+	return strings.Join(order, ", ")
+}
 
-	convertedData := convertBytesToString(data)
+// baseERPWhere builds the WHERE clause for the result filters and binds their values into args
+func baseERPWhere(filters []dto.BaseERPFilter, args map[string]any) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	conditions := make([]string, len(filters))
+	for i, filter := range filters {
+		name := fmt.Sprintf("__filter%d", i)
+		column := utils.QuoteSQLIdentifier(filter.Column)
+		switch filter.Type {
+		case models.ReportColumnInt, models.ReportColumnDecimal, models.ReportColumnBool,
+			models.ReportColumnCurrency, models.ReportColumnPercent:
+			conditions[i] = fmt.Sprintf("%s = @%s", column, name)
+			args[name] = filter.Value
+		case models.ReportColumnDate:
+			conditions[i] = fmt.Sprintf("CONVERT(NVARCHAR(33), %s, 126) LIKE @%s ESCAPE '\\'", column, name)
+			args[name] = escapeLike(fmt.Sprint(filter.Value)) + "%"
+		default:
+			conditions[i] = fmt.Sprintf("CAST(%s AS NVARCHAR(4000)) LIKE @%s ESCAPE '\\'", column, name)
+			args[name] = "%" + escapeLike(fmt.Sprint(filter.Value)) + "%"
+		}
+	}
+	return "\nWHERE " + strings.Join(conditions, " AND ")
+}
 
-	return &dto.BaseERP{
-		Data: convertedData,
-	}, nil
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`, "[", `\[`).Replace(value)
 }
 
-func convertBytesToString(data []map[string]any) []map[string]any {
-	if data == nil {
+func (r *baseERPRepository) PreviewBaseERP(ctx context.Context, input dto.BaseERPReq, maxRows int) (*dto.BaseERPPreview, error) {
+	// Cancelling the context stops the query on the server once enough rows are read
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tx := r.db.WithContext(ctx).Begin()
+	if tx.Error != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Raw(input.SqlQuery, baseERPArgs(input)).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return readRows(rows, maxRows, true, cancel)
+}
+
+// readRows scans a result set. Past maxRows it either truncates the result or
+// fails with ErrRowLimitExceeded; cancel stops the rest of the query. A
+// negative maxRows reads every row.
+func readRows(rows *sql.Rows, maxRows int, truncate bool, cancel context.CancelFunc) (*dto.BaseERPPreview, error) {
+	result := &dto.BaseERPPreview{Data: []map[string]any{}}
+	columns, err := scanRows(rows, func(row map[string]any) error {
+		if maxRows >= 0 && len(result.Data) >= maxRows {
+			cancel()
+			if !truncate {
+				return fmt.Errorf("%w: more than %d rows", ErrRowLimitExceeded, maxRows)
+			}
+			result.Truncated = true
+			return errStopRows
+		}
+		result.Data = append(result.Data, row)
 		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	result.Columns = columns
+	return result, nil
+}
 
-	convertedData := make([]map[string]any, len(data))
-	for i, row := range data {
-		convertedRow := make(map[string]any)
-		for key, value := range row {
-			convertedRow[key] = convertValue(value)
+// errStopRows ends scanRows early without an error
+var errStopRows = errors.New("stop reading rows")
+
+// scanRows calls fn with each row of a result set, keyed by column name, and
+// returns the result set columns. Rows are not kept; fn may hold on to them.
+// When fn returns errStopRows the remaining rows are skipped.
+func scanRows(rows *sql.Rows, fn func(row map[string]any) error) ([]dto.BaseERPColumn, error) {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+	columns := make([]dto.BaseERPColumn, len(columnTypes))
+	for i, columnType := range columnTypes {
+		columns[i] = dto.BaseERPColumn{
+			Name:         columnType.Name(),
+			DatabaseType: columnType.DatabaseTypeName(),
+		}
+	}
+
+	values := make([]any, len(columnTypes))
+	dest := make([]any, len(columnTypes))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		row := make(map[string]any, len(values))
+		for i, column := range columns {
+			row[column.Name] = convertValue(values[i])
+		}
+		if err := fn(row); err != nil {
+			if errors.Is(err, errStopRows) {
+				return columns, nil
+			}
+			return nil, err
 		}
-		convertedData[i] = convertedRow
 	}
-	return convertedData
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+func baseERPArgs(input dto.BaseERPReq) map[string]any {
+	args := map[string]any{
+		"FromDate": input.FromDate,
+		"ToDate":   input.ToDate,
+	}
+	for name, value := range input.Params {
+		args[name] = value
+	}
+	return args
 }
 
 func convertValue(value any) any {
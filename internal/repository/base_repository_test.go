@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"fmt"
+	"testing"
+)
+
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "abc", want: "abc"},
+		{value: "50%", want: `50\%`},
+		{value: "a_b", want: `a\_b`},
+		{value: "[x]", want: `\[x]`},
+		{value: `C:\temp`, want: `C:\\temp`},
+		{value: `\%_[`, want: `\\\%\_\[`},
+	}
+	for _, tt := range tests {
+		if got := escapeLike(tt.value); got != tt.want {
+			t.Errorf("escapeLike(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBaseERPWhere(t *testing.T) {
+	args := map[string]any{}
+	where := baseERPWhere([]dto.BaseERPFilter{
+		{Column: "amount", Type: models.ReportColumnDecimal, Value: 12.5},
+		{Column: "order_date", Type: models.ReportColumnDate, Value: "2026-01"},
+		{Column: "customer]x", Type: models.ReportColumnString, Value: "10%_off"},
+	}, args)
+
+	want := "\nWHERE [amount] = @__filter0" +
+		" AND CONVERT(NVARCHAR(33), [order_date], 126) LIKE @__filter1 ESCAPE '\\'" +
+		" AND CAST([customer]]x] AS NVARCHAR(4000)) LIKE @__filter2 ESCAPE '\\'"
+	if where != want {
+		t.Errorf("where =\n%q\nwant\n%q", where, want)
+	}
+	wantArgs := map[string]any{"__filter0": 12.5, "__filter1": "2026-01%", "__filter2": `%10\%\_off%`}
+	for name, value := range wantArgs {
+		if args[name] != value {
+			t.Errorf("args[%s] = %v, want %v", name, args[name], value)
+		}
+	}
+
+	if where := baseERPWhere(nil, args); where != "" {
+		t.Errorf("where without filters = %q, want empty", where)
+	}
+}
+
+func TestBaseERPOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input dto.BaseERPReq
+		want  string
+	}{
+		{name: "none", input: dto.BaseERPReq{}, want: ""},
+		{name: "sort", input: dto.BaseERPReq{Sort: "amount", SortDesc: true}, want: "[amount] DESC"},
+		{name: "groups then sort", input: dto.BaseERPReq{GroupBy: []string{"region", "customer"}, Sort: "amount"}, want: "[region] ASC, [customer] ASC, [amount] ASC"},
+		// A column may appear once in ORDER BY; the sort sets the group's direction
+		{name: "sort on a group column", input: dto.BaseERPReq{GroupBy: []string{"region", "customer"}, Sort: "customer", SortDesc: true}, want: "[region] ASC, [customer] DESC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := baseERPOrder(tt.input); got != tt.want {
+				t.Errorf("baseERPOrder = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBaseERPQuery(t *testing.T) {
+	const report = "WITH s AS (SELECT id, amount FROM sales) SELECT id, amount FROM s ORDER BY id;"
+
+	t.Run("unchanged", func(t *testing.T) {
+		query, countQuery, err := baseERPQuery(dto.BaseERPReq{SqlQuery: report}, map[string]any{})
+		if err != nil {
+			t.Fatalf("baseERPQuery: %v", err)
+		}
+		if query != report || countQuery != "" {
+			t.Errorf("query = %q, count = %q, want the report query as is", query, countQuery)
+		}
+	})
+
+	t.Run("paged", func(t *testing.T) {
+		args := map[string]any{}
+		input := dto.BaseERPReq{
+			SqlQuery: report,
+			Sort:     "amount",
+			SortDesc: true,
+			Filters:  []dto.BaseERPFilter{{Column: "amount", Type: models.ReportColumnInt, Value: int64(5)}},
+			Offset:   40,
+			Limit:    20,
+		}
+		query, countQuery, err := baseERPQuery(input, args)
+		if err != nil {
+			t.Fatalf("baseERPQuery: %v", err)
+		}
+		source := "WITH s AS (SELECT id, amount FROM sales)\nSELECT %s FROM (\nSELECT id, amount FROM s ORDER BY id\nOFFSET 0 ROWS\n) AS report_source\nWHERE [amount] = @__filter0"
+		wantQuery := fmt.Sprintf(source, "*") + "\nORDER BY [amount] DESC\nOFFSET @__offset ROWS FETCH NEXT @__limit ROWS ONLY"
+		if query != wantQuery {
+			t.Errorf("query =\n%s\nwant\n%s", query, wantQuery)
+		}
+		if want := fmt.Sprintf(source, "COUNT_BIG(*)"); countQuery != want {
+			t.Errorf("count query =\n%s\nwant\n%s", countQuery, want)
+		}
+		if args["__offset"] != 40 || args["__limit"] != 20 || args["__filter0"] != int64(5) {
+			t.Errorf("args = %v", args)
+		}
+	})
+
+	t.Run("paged without sort", func(t *testing.T) {
+		query, _, err := baseERPQuery(dto.BaseERPReq{SqlQuery: "SELECT id FROM t", Limit: 10}, map[string]any{})
+		if err != nil {
+			t.Fatalf("baseERPQuery: %v", err)
+		}
+		// OFFSET needs an ORDER BY
+		want := "\nSELECT * FROM (\nSELECT id FROM t\n) AS report_source\nORDER BY (SELECT NULL)\nOFFSET @__offset ROWS FETCH NEXT @__limit ROWS ONLY"
+		if query != want {
+			t.Errorf("query =\n%q\nwant\n%q", query, want)
+		}
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		query, countQuery, err := baseERPQuery(dto.BaseERPReq{SqlQuery: "SELECT region, amount FROM t", GroupBy: []string{"region"}}, map[string]any{})
+		if err != nil {
+			t.Fatalf("baseERPQuery: %v", err)
+		}
+		want := "\nSELECT * FROM (\nSELECT region, amount FROM t\n) AS report_source\nORDER BY [region] ASC"
+		if query != want || countQuery != "" {
+			t.Errorf("query =\n%q\ncount = %q\nwant\n%q", query, countQuery, want)
+		}
+	})
+
+	t.Run("query hint", func(t *testing.T) {
+		if _, _, err := baseERPQuery(dto.BaseERPReq{SqlQuery: "SELECT id FROM t OPTION (RECOMPILE)", Limit: 10}, map[string]any{}); err == nil {
+			t.Errorf("want an error for a query hint")
+		}
+	})
+}
@@ -3,6 +3,10 @@ package repository
 import (
 	"context"
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"strings"
 
 	"gorm.io/gorm"
 )
@@ -24,9 +28,34 @@ type (
 		GetSaleWarehouse(ctx context.Context) ([]dto.SaleWarehouse, error)
 		GetSaleMoney(ctx context.Context) ([]dto.SaleMoney, error)
 		SearchCopma(ctx context.Context, search string) ([]dto.SaleCOPMA, error)
+		GetLookupOptions(ctx context.Context, source string) ([]dto.LookupOption, error)
+		FilterLookupCodes(ctx context.Context, source string, codes []string) ([]string, error)
 	}
 )
 
+// lookupTable is an ERP master table usable as a report parameter lookup source
+type lookupTable struct {
+	table       string
+	codeColumn  string
+	labelColumn string
+}
+
+var lookupTables = map[string]lookupTable{
+	models.ReportLookupCustomer:       {table: "COPMA", codeColumn: "MA001", labelColumn: "MA002"},
+	models.ReportLookupItem:           {table: "INVMB", codeColumn: "MB001", labelColumn: "MB002"},
+	models.ReportLookupWarehouse:      {table: "CMSMC", codeColumn: "MC001", labelColumn: "MC002"},
+	models.ReportLookupSaleDepartment: {table: "CMSME", codeColumn: "ME001", labelColumn: "ME002"},
+	models.ReportLookupCurrency:       {table: "CMSMF", codeColumn: "MF001", labelColumn: "MF002"},
+}
+
+var ErrUnknownLookupSource = errors.New("unknown lookup source")
+
+// IsLookupSource reports whether source names a known lookup table
+func IsLookupSource(source string) bool {
+	_, ok := lookupTables[source]
+	return ok
+}
+
 func NewCopmaRepo(db *gorm.DB) CopmaRepo {
 	return &copmaRepo{
 		db: db,
@@ -224,3 +253,41 @@ func (s *copmaRepo) SearchCopma(ctx context.Context, search string) ([]dto.SaleC
 	}
 	return result, nil
 }
+
+// GetLookupOptions lists the codes and names of a lookup source
+func (s *copmaRepo) GetLookupOptions(ctx context.Context, source string) ([]dto.LookupOption, error) {
+	lookup, ok := lookupTables[source]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLookupSource, source)
+	}
+	var result []dto.LookupOption
+	query := fmt.Sprintf(`SELECT
+  RTRIM(%[1]s) AS value,
+  ISNULL(%[2]s, '') AS label
+  FROM %[3]s
+  ORDER BY %[1]s`, lookup.codeColumn, lookup.labelColumn, lookup.table)
+	if err := s.db.WithContext(ctx).Raw(query).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FilterLookupCodes returns the subset of codes that exist in the lookup source
+func (s *copmaRepo) FilterLookupCodes(ctx context.Context, source string, codes []string) ([]string, error) {
+	lookup, ok := lookupTables[source]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownLookupSource, source)
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	var result []string
+	query := fmt.Sprintf(`SELECT RTRIM(%[1]s) FROM %[2]s WHERE %[1]s IN ?`, lookup.codeColumn, lookup.table)
+	if err := s.db.WithContext(ctx).Raw(query, codes).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+	for i := range result {
+		result[i] = strings.TrimSpace(result[i])
+	}
+	return result, nil
+}
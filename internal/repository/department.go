@@ -15,6 +15,7 @@ type (
 	DepartmentRepo interface {
 		Create(ctx context.Context, input models.Department) error
 		GetByID(ctx context.Context, id uint) (*models.Department, error)
+		GetByCode(ctx context.Context, code string) (*models.Department, error)
 		GetAll(ctx context.Context) ([]models.Department, error)
 		Update(ctx context.Context, id uint, input models.Department) error
 		Delete(ctx context.Context, id uint) error
@@ -35,6 +36,14 @@ func (d *departmentRepo) Create(ctx context.Context, input models.Department) er
 	return nil
 }
 
+func (d *departmentRepo) GetByCode(ctx context.Context, code string) (*models.Department, error) {
+	var department models.Department
+	if err := d.db.WithContext(ctx).Where("code = ?", code).First(&department).Error; err != nil {
+		return nil, fmt.Errorf("failed to get department by code %w", err)
+	}
+	return &department, nil
+}
+
 func (d *departmentRepo) GetByID(ctx context.Context, id uint) (*models.Department, error) {
 	var department models.Department
 	if err := d.db.WithContext(ctx).First(&department, id).Error; err != nil {
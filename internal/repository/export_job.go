@@ -0,0 +1,230 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrExportJobNotFound   = errors.New("export job not found")
+	ErrExportJobNotRunning = errors.New("export job is no longer running")
+)
+
+type (
+	exportJobRepo struct {
+		db *gorm.DB
+	}
+	ExportJobRepo interface {
+		Create(ctx context.Context, job *models.ExportJob) error
+		GetByID(ctx context.Context, id string) (*models.ExportJob, error)
+		GetByUser(ctx context.Context, userID int64, limit int) ([]models.ExportJob, error)
+		ClaimNext(ctx context.Context) (*models.ExportJob, error)
+		Heartbeat(ctx context.Context, id string, progress int) error
+		Finish(ctx context.Context, job *models.ExportJob) (bool, error)
+		Cancel(ctx context.Context, id string, userID int64) (bool, error)
+		Requeue(ctx context.Context, id string) error
+		RequeueStale(ctx context.Context, before time.Time, maxAttempts int) (requeued int64, failed int64, err error)
+		DeleteFinished(ctx context.Context, before time.Time) ([]models.ExportJob, error)
+	}
+)
+
+func NewExportJobRepo(db *gorm.DB) ExportJobRepo {
+	return &exportJobRepo{
+		db: db,
+	}
+}
+
+func (r *exportJobRepo) Create(ctx context.Context, job *models.ExportJob) error {
+	if err := r.db.WithContext(ctx).Create(job).Error; err != nil {
+		return fmt.Errorf("failed to create export job: %w", err)
+	}
+	return nil
+}
+
+func (r *exportJobRepo) GetByID(ctx context.Context, id string) (*models.ExportJob, error) {
+	jobs, err := gorm.G[models.ExportJob](r.db).Where("id = ?", id).Limit(1).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export job: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, ErrExportJobNotFound
+	}
+	return &jobs[0], nil
+}
+
+func (r *exportJobRepo) GetByUser(ctx context.Context, userID int64, limit int) ([]models.ExportJob, error) {
+	jobs, err := gorm.G[models.ExportJob](r.db).Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get export jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// ClaimNext marks the oldest queued job as running and returns it, or nil
+// when the queue is empty. The status check in the update keeps two workers,
+// possibly on different instances, from taking the same job.
+func (r *exportJobRepo) ClaimNext(ctx context.Context) (*models.ExportJob, error) {
+	for {
+		jobs, err := gorm.G[models.ExportJob](r.db).Where("status = ?", models.ExportJobQueued).Order("created_at").Limit(1).Find(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queued export job: %w", err)
+		}
+		if len(jobs) == 0 {
+			return nil, nil
+		}
+		job := &jobs[0]
+
+		now := time.Now()
+		result := r.db.WithContext(ctx).
+			Model(&models.ExportJob{}).
+			Where("id = ? AND status = ?", job.ID, models.ExportJobQueued).
+			Updates(map[string]interface{}{
+				"status":       models.ExportJobRunning,
+				"progress":     0,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"started_at":   now,
+				"heartbeat_at": now,
+			})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to claim export job: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			// Another worker took it first
+			continue
+		}
+		job.Status = models.ExportJobRunning
+		job.Progress = 0
+		job.Attempts++
+		job.StartedAt = &now
+		job.HeartbeatAt = &now
+		return job, nil
+	}
+}
+
+// Heartbeat records the progress of a running job. It returns
+// ErrExportJobNotRunning once the job was cancelled or requeued.
+func (r *exportJobRepo) Heartbeat(ctx context.Context, id string, progress int) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", id, models.ExportJobRunning).
+		Updates(map[string]interface{}{
+			"progress":     progress,
+			"heartbeat_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update export job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrExportJobNotRunning
+	}
+	return nil
+}
+
+// Finish stores the outcome of a running job. It returns false when the job
+// stopped running in the meantime, for example because it was cancelled.
+func (r *exportJobRepo) Finish(ctx context.Context, job *models.ExportJob) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", job.ID, models.ExportJobRunning).
+		Updates(map[string]interface{}{
+			"status":      job.Status,
+			"progress":    job.Progress,
+			"error":       job.Error,
+			"file_name":   job.FileName,
+			"file_path":   job.FilePath,
+			"file_size":   job.FileSize,
+			"finished_at": job.FinishedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to finish export job: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Cancel cancels a queued or running job of the user
+func (r *exportJobRepo) Cancel(ctx context.Context, id string, userID int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("id = ? AND user_id = ? AND status IN ?", id, userID, []string{models.ExportJobQueued, models.ExportJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ExportJobCancelled,
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to cancel export job: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// Requeue puts a running job back in the queue. The interrupted attempt is
+// not counted, as the job did not cause the interruption.
+func (r *exportJobRepo) Requeue(ctx context.Context, id string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("id = ? AND status = ?", id, models.ExportJobRunning).
+		Updates(map[string]interface{}{
+			"status":   models.ExportJobQueued,
+			"progress": 0,
+			"attempts": gorm.Expr("GREATEST(attempts - 1, 0)"),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to requeue export job: %w", err)
+	}
+	return nil
+}
+
+// RequeueStale queues running jobs whose last heartbeat is before the given
+// time again. Jobs that were already claimed maxAttempts times fail instead,
+// so that an export that takes the server down is not retried forever.
+func (r *exportJobRepo) RequeueStale(ctx context.Context, before time.Time, maxAttempts int) (int64, int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("status = ? AND heartbeat_at < ? AND attempts >= ?", models.ExportJobRunning, before, maxAttempts).
+		Updates(map[string]interface{}{
+			"status":      models.ExportJobFailed,
+			"error":       fmt.Sprintf("export stopped responding %d times and was abandoned", maxAttempts),
+			"finished_at": time.Now(),
+		})
+	if result.Error != nil {
+		return 0, 0, fmt.Errorf("failed to fail stale export jobs: %w", result.Error)
+	}
+	failed := result.RowsAffected
+
+	result = r.db.WithContext(ctx).
+		Model(&models.ExportJob{}).
+		Where("status = ? AND heartbeat_at < ?", models.ExportJobRunning, before).
+		Updates(map[string]interface{}{
+			"status":   models.ExportJobQueued,
+			"progress": 0,
+		})
+	if result.Error != nil {
+		return 0, failed, fmt.Errorf("failed to requeue stale export jobs: %w", result.Error)
+	}
+	return result.RowsAffected, failed, nil
+}
+
+// DeleteFinished deletes the jobs that finished before the given time and
+// returns them, so that their files can be removed.
+func (r *exportJobRepo) DeleteFinished(ctx context.Context, before time.Time) ([]models.ExportJob, error) {
+	jobs, err := gorm.G[models.ExportJob](r.db).
+		Where("status IN ? AND finished_at < ?", []string{models.ExportJobSucceeded, models.ExportJobFailed, models.ExportJobCancelled}, before).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired export jobs: %w", err)
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&models.ExportJob{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to delete expired export jobs: %w", err)
+	}
+	return jobs, nil
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"context"
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/utils"
 	"fmt"
+	"io"
 	"time"
 
 	"github.com/xuri/excelize/v2"
@@ -17,6 +19,8 @@ type forecastRepo struct {
 type ForecastRepo interface {
 	GetForecast(ctx context.Context, req *dto.ReportReq) ([]dto.CombinedForecast, error)
 	ExportGroupedForecastToExcel(ctx context.Context, data []dto.CombinedForecast, fromDate, toDate time.Time) ([]byte, error)
+	ExportGroupedForecastToDelimited(ctx context.Context, w io.Writer, data []dto.CombinedForecast, options utils.DelimitedOptions) error
+	ExportGroupedForecastToPDF(ctx context.Context, w io.Writer, data []dto.CombinedForecast, fromDate, toDate time.Time, options utils.PDFOptions) error
 }
 
 func NewForecastRepo(db *gorm.DB) ForecastRepo {
@@ -367,4 +371,107 @@ func (r *forecastRepo) ExportGroupedForecastToExcel(ctx context.Context, data []
 	}  
 
 	return buffer.Bytes(), nil  
-}  
\ No newline at end of file
+}
+
+// forecastColumns are the columns of a delimited forecast export; order and
+// forecast schedule lines share them, with a Loại column telling them apart
+var forecastColumns = []utils.ExportColumn{
+	{Title: "Nhóm", Format: utils.FormatInteger},
+	{Title: "Loại"},
+	{Title: "Mã chứng từ"},
+	{Title: "Mã KH"},
+	{Title: "Tên KH"},
+	{Title: "Mã Sản Phẩm"},
+	{Title: "Tên Sản Phẩm"},
+	{Title: "Quy cách"},
+	{Title: "Số lượng đặt", Format: utils.FormatDecimal},
+	{Title: "Số lượng đã giao", Format: utils.FormatDecimal},
+	{Title: "Đơn giá", Format: utils.FormatDecimal},
+	{Title: "Ngày"},
+}
+
+// ExportGroupedForecastToDelimited writes the forecast as CSV or TSV to w,
+// one line per order followed by one per forecast schedule entry of each group.
+// Unlike report exports it does not stream: GetForecast matches the orders to
+// their schedule entries in memory, so data holds the whole forecast.
+func (r *forecastRepo) ExportGroupedForecastToDelimited(ctx context.Context, w io.Writer, data []dto.CombinedForecast, options utils.DelimitedOptions) error {
+	values := make([]any, len(forecastColumns))
+	table := utils.ExportTable{
+		Columns: forecastColumns,
+		Rows: func(fn func(row utils.ExportRow) error) error {
+			for groupIndex, group := range data {
+				if len(group.Columns) == 0 {
+					continue
+				}
+				for _, col := range group.Columns {
+					values = append(values[:0], groupIndex+1, "Đơn hàng", col.TD01, col.MKH, col.KH01, col.TD02, col.TD03, col.TD04, col.TD05, col.TD06, col.TD07, col.TD08)
+					if err := fn(utils.ExportRow{Values: values}); err != nil {
+						return err
+					}
+				}
+				for _, detail := range group.Details {
+					values = append(values[:0], groupIndex+1, "Dự đoán", detail.TD09, "", detail.KH02, detail.TD10, detail.TD11, detail.TD12, detail.TD13, "", detail.TD14, detail.TD15)
+					if err := fn(utils.ExportRow{Values: values}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	}
+	if err := utils.WriteDelimitedTable(w, table, options); err != nil {
+		return fmt.Errorf("failed to export to delimited text: %w", err)
+	}
+	return nil
+}
+
+// ExportGroupedForecastToPDF writes the forecast as a printable PDF document
+// to w, laid out by group like ExportGroupedForecastToExcel
+func (r *forecastRepo) ExportGroupedForecastToPDF(ctx context.Context, w io.Writer, data []dto.CombinedForecast, fromDate, toDate time.Time, options utils.PDFOptions) error {
+	options.Title = fmt.Sprintf("FORECAST REPORT (%s - %s)", fromDate.Format("2006-01-02"), toDate.Format("2006-01-02"))
+	d := utils.NewPDFDocument(options)
+
+	orderColumns := []utils.ExportColumn{
+		{Title: "Đơn hàng"}, {Title: "Mã KH"}, {Title: "Tên KH"}, {Title: "Mã Sản Phẩm"}, {Title: "Tên Sản Phẩm"},
+		{Title: "Quy cách"}, {Title: "Số lượng đặt", Format: utils.FormatDecimal},
+		{Title: "Số lượng đã giao", Format: utils.FormatDecimal}, {Title: "Đơn giá", Format: utils.FormatDecimal},
+		{Title: "Ngày dự định giao", Format: utils.FormatDate},
+	}
+	orderWidths := []float64{20, 14, 30, 18, 30, 22, 13, 13, 13, 14}
+	forecastColumns := []utils.ExportColumn{
+		{Title: "Mã dự đoán"}, {Title: "Tên KH"}, {Title: "Mã Sản Phẩm"}, {Title: "Tên Sản Phẩm"}, {Title: "Quy cách"},
+		{Title: "Số lượng đặt", Format: utils.FormatDecimal}, {Title: "Đơn giá", Format: utils.FormatDecimal},
+		{Title: "Ngày", Format: utils.FormatDate},
+	}
+	forecastWidths := []float64{20, 30, 18, 30, 22, 13, 13, 14}
+
+	for groupIndex, group := range data {
+		if len(group.Columns) == 0 {
+			continue
+		}
+		d.Heading(fmt.Sprintf("Group %d - Order: %s", groupIndex+1, group.Columns[0].TD02))
+
+		d.Caption("ORDER DETAILS")
+		d.Table(orderColumns, orderWidths)
+		for _, col := range group.Columns {
+			d.Row([]any{col.TD01, col.MKH, col.KH01, col.TD02, col.TD03, col.TD04, col.TD05, col.TD06, col.TD07, col.TD08})
+		}
+		d.EndTable()
+		d.Space(3)
+
+		if len(group.Details) > 0 {
+			d.Caption(fmt.Sprintf("FORECAST SCHEDULE (%d records)", len(group.Details)))
+			d.Table(forecastColumns, forecastWidths)
+			for _, detail := range group.Details {
+				d.Row([]any{detail.TD09, detail.KH02, detail.TD10, detail.TD11, detail.TD12, detail.TD13, detail.TD14, detail.TD15})
+			}
+			d.EndTable()
+			d.Space(5)
+		}
+	}
+
+	if err := d.Write(w); err != nil {
+		return fmt.Errorf("failed to export to pdf: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrLockoutNotFound = errors.New("lockout not found")
+
+// uncountedReasons are unsuccessful attempts that do not count toward a lockout:
+// rejected while already locked, waiting for the second factor, or failed on
+// the server's side.
+var uncountedReasons = []string{
+	models.LoginReasonLocked,
+	models.LoginReasonOTPRequired,
+	models.LoginReasonProviderError,
+	models.LoginReasonError,
+}
+
+type (
+	loginAttemptRepo struct {
+		db *gorm.DB
+	}
+	LoginAttemptRepo interface {
+		Create(ctx context.Context, attempt *models.LoginAttempt) error
+		CountFailuresByUsername(ctx context.Context, username string, since time.Time) (int64, error)
+		CountFailuresByIP(ctx context.Context, ipAddress string, since time.Time) (int64, error)
+		GetLastSuccessAt(ctx context.Context, username string) (*time.Time, error)
+		GetFailures(ctx context.Context, since time.Time, limit int) ([]models.LoginAttempt, error)
+		GetAttempts(ctx context.Context, username, ipAddress string, since time.Time, limit int) ([]models.LoginAttempt, error)
+
+		CreateLockout(ctx context.Context, lockout *models.Lockout) error
+		GetActiveLockout(ctx context.Context, scope, target string, now time.Time) (*models.Lockout, error)
+		GetActiveLockouts(ctx context.Context, now time.Time) ([]models.Lockout, error)
+		GetLatestLockout(ctx context.Context, scope, target string) (*models.Lockout, error)
+		Unlock(ctx context.Context, id int64, unlockedBy int64) error
+	}
+)
+
+func NewLoginAttemptRepo(db *gorm.DB) LoginAttemptRepo {
+	return &loginAttemptRepo{
+		db: db,
+	}
+}
+
+func (r *loginAttemptRepo) Create(ctx context.Context, attempt *models.LoginAttempt) error {
+	if err := r.db.WithContext(ctx).Create(attempt).Error; err != nil {
+		return fmt.Errorf("failed to record login attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepo) CountFailuresByUsername(ctx context.Context, username string, since time.Time) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.LoginAttempt{}).
+		Where("username = ? AND success = ? AND reason NOT IN ? AND attempted_at > ?", username, false, uncountedReasons, since).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return count, nil
+}
+
+func (r *loginAttemptRepo) CountFailuresByIP(ctx context.Context, ipAddress string, since time.Time) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.LoginAttempt{}).
+		Where("ip_address = ? AND success = ? AND reason NOT IN ? AND attempted_at > ?", ipAddress, false, uncountedReasons, since).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return count, nil
+}
+
+func (r *loginAttemptRepo) GetLastSuccessAt(ctx context.Context, username string) (*time.Time, error) {
+	attempts, err := gorm.G[models.LoginAttempt](r.db).
+		Where("username = ? AND success = ?", username, true).
+		Order("attempted_at DESC").
+		Limit(1).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last successful login: %w", err)
+	}
+	if len(attempts) == 0 {
+		return nil, nil
+	}
+	return &attempts[0].AttemptedAt, nil
+}
+
+func (r *loginAttemptRepo) GetFailures(ctx context.Context, since time.Time, limit int) ([]models.LoginAttempt, error) {
+	attempts, err := gorm.G[models.LoginAttempt](r.db).
+		Where("success = ? AND attempted_at > ?", false, since).
+		Order("attempted_at DESC").
+		Limit(limit).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login failures: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *loginAttemptRepo) GetAttempts(ctx context.Context, username, ipAddress string, since time.Time, limit int) ([]models.LoginAttempt, error) {
+	query := r.db.WithContext(ctx).Where("attempted_at > ?", since)
+	if username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ipAddress != "" {
+		query = query.Where("ip_address = ?", ipAddress)
+	}
+
+	var attempts []models.LoginAttempt
+	if err := query.Order("attempted_at DESC").Limit(limit).Find(&attempts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+	return attempts, nil
+}
+
+func (r *loginAttemptRepo) CreateLockout(ctx context.Context, lockout *models.Lockout) error {
+	if err := r.db.WithContext(ctx).Create(lockout).Error; err != nil {
+		return fmt.Errorf("failed to create lockout: %w", err)
+	}
+	return nil
+}
+
+func (r *loginAttemptRepo) GetActiveLockout(ctx context.Context, scope, target string, now time.Time) (*models.Lockout, error) {
+	lockouts, err := gorm.G[models.Lockout](r.db).
+		Where("scope = ? AND target = ? AND unlocked_at IS NULL AND locked_until > ?", scope, target, now).
+		Order("locked_until DESC").
+		Limit(1).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	if len(lockouts) == 0 {
+		return nil, nil
+	}
+	return &lockouts[0], nil
+}
+
+func (r *loginAttemptRepo) GetActiveLockouts(ctx context.Context, now time.Time) ([]models.Lockout, error) {
+	lockouts, err := gorm.G[models.Lockout](r.db).
+		Where("unlocked_at IS NULL AND locked_until > ?", now).
+		Order("locked_at DESC").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lockouts: %w", err)
+	}
+	return lockouts, nil
+}
+
+func (r *loginAttemptRepo) GetLatestLockout(ctx context.Context, scope, target string) (*models.Lockout, error) {
+	lockouts, err := gorm.G[models.Lockout](r.db).
+		Where("scope = ? AND target = ?", scope, target).
+		Order("locked_at DESC").
+		Limit(1).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest lockout: %w", err)
+	}
+	if len(lockouts) == 0 {
+		return nil, nil
+	}
+	return &lockouts[0], nil
+}
+
+func (r *loginAttemptRepo) Unlock(ctx context.Context, id int64, unlockedBy int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.Lockout{}).
+		Where("id = ? AND unlocked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"unlocked_at": time.Now(),
+			"unlocked_by": unlockedBy,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unlock: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLockoutNotFound
+	}
+	return nil
+}
@@ -18,6 +18,8 @@ type (
 		GetByDepartment(ctx context.Context, departmentID int64) ([]models.Menu, error)
 		GetByDepartments(ctx context.Context, departmentIDs []int64) ([]models.Menu, error)
 		GetByID(ctx context.Context, id int64) (*models.Menu, error)
+		GetByIDs(ctx context.Context, ids []int64) ([]models.Menu, error)
+		GetByReportIDs(ctx context.Context, reportIDs []int64) ([]models.Menu, error)
 		Update(ctx context.Context, id int64, input dto.MenuUpdateReq) error
 		Delete(ctx context.Context, id int64) error
 		GetAll(ctx context.Context) ([]models.Menu, error)
@@ -103,6 +105,22 @@ func (m *menuRepo) GetByDepartments(ctx context.Context, departmentIDs []int64)
 	}
 	return detail, nil
 }
+
+func (m *menuRepo) GetByIDs(ctx context.Context, ids []int64) ([]models.Menu, error) {
+	menus, err := gorm.G[models.Menu](m.db).Where("id in ?", ids).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menus %w", err)
+	}
+	return menus, nil
+}
+
+func (m *menuRepo) GetByReportIDs(ctx context.Context, reportIDs []int64) ([]models.Menu, error) {
+	menus, err := gorm.G[models.Menu](m.db).Where("report_id in ?", reportIDs).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get menus by report %w", err)
+	}
+	return menus, nil
+}
 func (m *menuRepo) GetItemsMenuIDs(ctx context.Context, id int64) ([]dto.MenuDetailItem, error) {
 	menus, err := gorm.G[models.Menu](m.db).Where("parent_id = ?", id).Find(ctx)
 	if err != nil {
@@ -152,6 +170,11 @@ func (m *menuRepo) Update(ctx context.Context, id int64, input dto.MenuUpdateReq
 	if err := m.db.WithContext(ctx).Save(menu).Error; err != nil {
 		return fmt.Errorf("failed to update menu %w", err)
 	}
+	if input.DepartmentID != nil {
+		if err := m.db.WithContext(ctx).Model(&models.Menu{}).Where("parent_id = ?", id).Update("department_id", menu.DepartmentID).Error; err != nil {
+			return fmt.Errorf("failed to update sub-menu department %w", err)
+		}
+	}
 
 	if input.List != nil {
 		if err := m.db.Transaction(func(tx *gorm.DB) error {
@@ -167,12 +190,13 @@ func (m *menuRepo) Update(ctx context.Context, id int64, input dto.MenuUpdateReq
 				}
 
 				menuList = append(menuList, models.Menu{
-					Title:    *item.Title,
-					Code:     *item.Code,
-					Route:    *item.Route,
-					ReportID: *item.ReportID,
-					Level:    2,
-					ParentID: menu.ID,
+					Title:        *item.Title,
+					Code:         *item.Code,
+					Route:        *item.Route,
+					ReportID:     *item.ReportID,
+					Level:        2,
+					ParentID:     menu.ID,
+					DepartmentID: menu.DepartmentID,
 				})
 			}
 
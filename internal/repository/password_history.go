@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+type (
+	passwordHistoryRepo struct {
+		db *gorm.DB
+	}
+	PasswordHistoryRepo interface {
+		Create(ctx context.Context, userID int64, passwordHash string) error
+		GetRecent(ctx context.Context, userID int64, limit int) ([]models.PasswordHistory, error)
+		Prune(ctx context.Context, userID int64, keep int) error
+	}
+)
+
+func NewPasswordHistoryRepo(db *gorm.DB) PasswordHistoryRepo {
+	return &passwordHistoryRepo{
+		db: db,
+	}
+}
+
+func (r *passwordHistoryRepo) Create(ctx context.Context, userID int64, passwordHash string) error {
+	if err := r.db.WithContext(ctx).Create(&models.PasswordHistory{
+		UserID:       userID,
+		PasswordHash: passwordHash,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to save password history: %w", err)
+	}
+	return nil
+}
+
+func (r *passwordHistoryRepo) GetRecent(ctx context.Context, userID int64, limit int) ([]models.PasswordHistory, error) {
+	history, err := gorm.G[models.PasswordHistory](r.db).
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(limit).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get password history: %w", err)
+	}
+	return history, nil
+}
+
+// Prune deletes all but the keep most recent entries of userID
+func (r *passwordHistoryRepo) Prune(ctx context.Context, userID int64, keep int) error {
+	recent := r.db.Model(&models.PasswordHistory{}).
+		Select("id").
+		Where("user_id = ?", userID).
+		Order("created_at DESC, id DESC").
+		Limit(keep)
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND id NOT IN (?)", userID, recent).
+		Delete(&models.PasswordHistory{}).Error; err != nil {
+		return fmt.Errorf("failed to prune password history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+var ErrRolePermissionNotFound = errors.New("role permission not found")
+
+type (
+	permissionRepo struct {
+		db *gorm.DB
+	}
+	PermissionRepo interface {
+		GetAll(ctx context.Context) ([]models.Permission, error)
+		UpsertPermission(ctx context.Context, permission *models.Permission) error
+		GetRolePermissions(ctx context.Context, role string) ([]models.RolePermission, error)
+		GetAllRolePermissions(ctx context.Context) ([]models.RolePermission, error)
+		GetPermissionCodes(ctx context.Context, role string, departmentID int64) ([]string, error)
+		CountRolePermissions(ctx context.Context, role string) (int64, error)
+		CreateRolePermission(ctx context.Context, rolePermission *models.RolePermission) error
+		DeleteRolePermission(ctx context.Context, id int64) error
+	}
+)
+
+func NewPermissionRepo(db *gorm.DB) PermissionRepo {
+	return &permissionRepo{
+		db: db,
+	}
+}
+
+func (r *permissionRepo) GetAll(ctx context.Context) ([]models.Permission, error) {
+	permissions, err := gorm.G[models.Permission](r.db).Order("code ASC").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions: %w", err)
+	}
+	return permissions, nil
+}
+
+func (r *permissionRepo) UpsertPermission(ctx context.Context, permission *models.Permission) error {
+	var existing models.Permission
+	err := r.db.WithContext(ctx).Where("code = ?", permission.Code).First(&existing).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to find permission %s: %w", permission.Code, err)
+	}
+	if err := r.db.WithContext(ctx).Create(permission).Error; err != nil {
+		return fmt.Errorf("failed to create permission %s: %w", permission.Code, err)
+	}
+	return nil
+}
+
+func (r *permissionRepo) GetRolePermissions(ctx context.Context, role string) ([]models.RolePermission, error) {
+	rolePermissions, err := gorm.G[models.RolePermission](r.db).
+		Where("role = ?", role).
+		Order("permission_code ASC").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	return rolePermissions, nil
+}
+
+func (r *permissionRepo) GetAllRolePermissions(ctx context.Context) ([]models.RolePermission, error) {
+	rolePermissions, err := gorm.G[models.RolePermission](r.db).
+		Order("role ASC, permission_code ASC").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role permissions: %w", err)
+	}
+	return rolePermissions, nil
+}
+
+// GetPermissionCodes returns the permissions granted to role, either globally or for departmentID.
+func (r *permissionRepo) GetPermissionCodes(ctx context.Context, role string, departmentID int64) ([]string, error) {
+	var codes []string
+	err := r.db.WithContext(ctx).
+		Model(&models.RolePermission{}).
+		Where("role = ?", role).
+		Where("department_id = 0 OR department_id = ?", departmentID).
+		Distinct().
+		Pluck("permission_code", &codes).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission codes: %w", err)
+	}
+	return codes, nil
+}
+
+func (r *permissionRepo) CountRolePermissions(ctx context.Context, role string) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RolePermission{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count role permissions: %w", err)
+	}
+	return count, nil
+}
+
+func (r *permissionRepo) CreateRolePermission(ctx context.Context, rolePermission *models.RolePermission) error {
+	if err := r.db.WithContext(ctx).Create(rolePermission).Error; err != nil {
+		return fmt.Errorf("failed to create role permission: %w", err)
+	}
+	return nil
+}
+
+func (r *permissionRepo) DeleteRolePermission(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.RolePermission{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete role permission: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRolePermissionNotFound
+	}
+	return nil
+}
@@ -6,7 +6,10 @@ import (
 	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/utils"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -23,9 +26,21 @@ type (
 		GetReport(ctx context.Context, id int64) (*models.Report, error)
 		GetReportByID(ctx context.Context, id int64) (*models.Report, error)
 		GetAllReport(ctx context.Context) ([]models.Report, error)
+		GetReportsForDepartment(ctx context.Context, departmentID int64) ([]models.Report, error)
+		IsSharedWith(ctx context.Context, reportID int64, departmentID int64) (bool, error)
+		GetSharedReportIDs(ctx context.Context, departmentID int64) ([]int64, error)
+		GetShares(ctx context.Context, reportID int64) ([]models.ReportShare, error)
+		CreateShare(ctx context.Context, share *models.ReportShare) error
+		DeleteShare(ctx context.Context, reportID int64, departmentID int64) error
 		GetColumn(ctx context.Context, reportID int64) ([]models.ReportColumn, error)
+		GetParameters(ctx context.Context, reportID int64) ([]models.ReportParameter, error)
+		GetRevisions(ctx context.Context, reportID int64) ([]models.ReportRevision, error)
+		GetRevision(ctx context.Context, reportID int64, revision int) (*models.ReportRevision, error)
+		RestoreRevision(ctx context.Context, reportID int64, revision int, restoredBy int64, comment string) (*models.ReportRevision, error)
 		Count(ctx context.Context) (int64, error)
-		ExportReportToExcel(ctx context.Context, reportName string, columns []models.ReportColumn, data []map[string]interface{}, fromDate, toDate time.Time) ([]byte, error)
+		ExportReportToExcel(ctx context.Context, w io.Writer, reportName string, columns []models.ReportColumn, rows ReportRows, fromDate, toDate time.Time) error
+		ExportReportToDelimited(ctx context.Context, w io.Writer, columns []models.ReportColumn, rows ReportRows, options utils.DelimitedOptions) error
+		ExportReportToPDF(ctx context.Context, w io.Writer, reportName string, columns []models.ReportColumn, rows ReportRows, fromDate, toDate time.Time, options utils.PDFOptions) error
 	}
 )
 
@@ -38,10 +53,12 @@ func NewReportRepo(db *gorm.DB) ReportRepo {
 func (r *reportRepo) CreateReport(ctx context.Context, input dto.ReportCreateModel) error {
 	if err := r.db.Transaction(func(tx *gorm.DB) error {
 		report := models.Report{
-			ReportType:     input.ReportType,
-			ReportName:     input.ReportName,
-			QueryStatement: input.QueryStatement,
-			DepartmentID:   input.DepartmentID,
+			ReportType:          input.ReportType,
+			ReportName:          input.ReportName,
+			QueryStatement:      input.QueryStatement,
+			DepartmentID:        input.DepartmentID,
+			CacheTTLSeconds:     input.CacheTTLSeconds,
+			QueryTimeoutSeconds: input.QueryTimeoutSeconds,
 		}
 		if err := tx.WithContext(ctx).Create(&report).Error; err != nil {
 			return fmt.Errorf("failed to create report")
@@ -52,6 +69,14 @@ func (r *reportRepo) CreateReport(ctx context.Context, input dto.ReportCreateMod
 		if err := tx.WithContext(ctx).Create(&input.Columns).Error; err != nil {
 			return fmt.Errorf("failed to created column report")
 		}
+		if params := ToParameterModels(report.ID, input.Parameters); len(params) > 0 {
+			if err := tx.WithContext(ctx).Create(&params).Error; err != nil {
+				return fmt.Errorf("failed to create report parameters %w", err)
+			}
+		}
+		if _, err := recordRevision(ctx, tx, report.ID, input.CreatedBy, input.Comment); err != nil {
+			return err
+		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("error transaction create report")
@@ -73,6 +98,13 @@ func (r *reportRepo) GetColumn(ctx context.Context, reportID int64) ([]models.Re
 	}
 	return column, nil
 }
+func (r *reportRepo) GetParameters(ctx context.Context, reportID int64) ([]models.ReportParameter, error) {
+	params, err := gorm.G[models.ReportParameter](r.db).Where("report_id = ?", reportID).Order("num, id").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get report parameters error: %w", err)
+	}
+	return params, nil
+}
 func (r *reportRepo) GetReportByID(ctx context.Context, id int64) (*models.Report, error) {
 	report, err := gorm.G[models.Report](r.db).Where("id = ?", id).First(ctx)
 	if err != nil {
@@ -87,20 +119,90 @@ func (r *reportRepo) GetAllReport(ctx context.Context) ([]models.Report, error)
 	}
 	return reports, nil
 }
+
+// GetReportsForDepartment returns the reports owned by or shared with departmentID
+func (r *reportRepo) GetReportsForDepartment(ctx context.Context, departmentID int64) ([]models.Report, error) {
+	reports, err := gorm.G[models.Report](r.db).
+		Where("department_id = ? OR id IN (?)",
+			strconv.FormatInt(departmentID, 10),
+			r.db.Model(&models.ReportShare{}).Select("report_id").Where("department_id = ?", departmentID),
+		).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get department reports error: %w", err)
+	}
+	return reports, nil
+}
+
+func (r *reportRepo) IsSharedWith(ctx context.Context, reportID int64, departmentID int64) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.ReportShare{}).
+		Where("report_id = ? AND department_id = ?", reportID, departmentID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check report share: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *reportRepo) GetSharedReportIDs(ctx context.Context, departmentID int64) ([]int64, error) {
+	var ids []int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.ReportShare{}).
+		Where("department_id = ?", departmentID).
+		Pluck("report_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to get shared reports: %w", err)
+	}
+	return ids, nil
+}
+
+func (r *reportRepo) GetShares(ctx context.Context, reportID int64) ([]models.ReportShare, error) {
+	shares, err := gorm.G[models.ReportShare](r.db).Where("report_id = ?", reportID).Order("department_id").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report shares: %w", err)
+	}
+	return shares, nil
+}
+
+func (r *reportRepo) CreateShare(ctx context.Context, share *models.ReportShare) error {
+	if err := r.db.WithContext(ctx).Create(share).Error; err != nil {
+		return fmt.Errorf("failed to share report: %w", err)
+	}
+	return nil
+}
+
+func (r *reportRepo) DeleteShare(ctx context.Context, reportID int64, departmentID int64) error {
+	if err := r.db.WithContext(ctx).
+		Where("report_id = ? AND department_id = ?", reportID, departmentID).
+		Delete(&models.ReportShare{}).Error; err != nil {
+		return fmt.Errorf("failed to unshare report: %w", err)
+	}
+	return nil
+}
+
 func (r *reportRepo) UpdateReport(ctx context.Context, id int64, input dto.ReportUpdateModel) error {
 	report, err := r.GetReportByID(ctx, id)
 	if err != nil {
 		return fmt.Errorf("report not found")
 	}
 	if err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseRevision(ctx, tx, id); err != nil {
+			return err
+		}
 		report.ReportType = *input.ReportType
 		report.ReportName = *input.ReportName
 		report.QueryStatement = *input.QueryStatement
 		report.DepartmentID = *input.DepartmentID
+		if input.CacheTTLSeconds != nil {
+			report.CacheTTLSeconds = *input.CacheTTLSeconds
+		}
+		if input.QueryTimeoutSeconds != nil {
+			report.QueryTimeoutSeconds = *input.QueryTimeoutSeconds
+		}
 		if err := tx.WithContext(ctx).Save(&report).Error; err != nil {
 			return fmt.Errorf("failed to update report %w", err)
 		}
-		_, err := gorm.G[models.ReportColumn](r.db).Where("report_id = ?", id).Delete(ctx)
+		_, err := gorm.G[models.ReportColumn](tx).Where("report_id = ?", id).Delete(ctx)
 		if err != nil {
 			return fmt.Errorf("failed to delete report column")
 		}
@@ -110,6 +212,19 @@ func (r *reportRepo) UpdateReport(ctx context.Context, id int64, input dto.Repor
 		if err := tx.WithContext(ctx).Create(&input.Columns).Error; err != nil {
 			return fmt.Errorf("failed to created column report")
 		}
+		if input.Parameters != nil {
+			if err := tx.WithContext(ctx).Where("report_id = ?", id).Delete(&models.ReportParameter{}).Error; err != nil {
+				return fmt.Errorf("failed to delete report parameters %w", err)
+			}
+			if params := ToParameterModels(report.ID, input.Parameters); len(params) > 0 {
+				if err := tx.WithContext(ctx).Create(&params).Error; err != nil {
+					return fmt.Errorf("failed to create report parameters %w", err)
+				}
+			}
+		}
+		if _, err := recordRevision(ctx, tx, id, input.UpdatedBy, input.Comment); err != nil {
+			return err
+		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("failed to update report %w", err)
@@ -126,46 +241,215 @@ func (r *reportRepo) DeleteReport(ctx context.Context, id int64) error {
 		if err != nil {
 			return fmt.Errorf("failed to delete report column")
 		}
+		if err := tx.WithContext(ctx).Where("report_id = ?", id).Delete(&models.ReportShare{}).Error; err != nil {
+			return fmt.Errorf("failed to delete report shares %w", err)
+		}
+		if err := tx.WithContext(ctx).Where("report_id = ?", id).Delete(&models.ReportParameter{}).Error; err != nil {
+			return fmt.Errorf("failed to delete report parameters %w", err)
+		}
 		return nil
 	}); err != nil {
 		return fmt.Errorf("failed to delete report %w", err)
 	}
 	return nil
 }
-func (r *reportRepo) ExportReportToExcel(ctx context.Context, reportName string, columns []models.ReportColumn, data []map[string]interface{}, fromDate, toDate time.Time) ([]byte, error) {
-	// Prepare headers từ columns
-	headers := make([]string, len(columns))
-	for i, col := range columns {
-		headers[i] = col.Title
-	}
 
-	processedData := make([]map[string]interface{}, len(data))
-	for i, row := range data {
-		newRow := make(map[string]interface{})
-		for _, col := range columns {
-			if val, exists := row[col.Code]; exists {
-				newRow[col.Title] = val
+// ReportRows calls fn with each report row in order and returns the first
+// error of fn or of reading the rows. Exports read the rows once, as they are
+// written, so they can come straight from the database.
+type ReportRows func(fn func(row map[string]any) error) error
+
+// SliceRows returns rows held in memory as ReportRows
+func SliceRows(data []map[string]any) ReportRows {
+	return func(fn func(row map[string]any) error) error {
+		for _, row := range data {
+			if err := fn(row); err != nil {
+				return err
 			}
 		}
-		processedData[i] = newRow
+		return nil
 	}
-	title := fmt.Sprintf("%s (%s to %s)",
+}
+
+// ExportReportToExcel writes the report rows as an xlsx workbook to w. Reports
+// with group-by columns get outlined groups with subtotals and a grand total.
+func (r *reportRepo) ExportReportToExcel(ctx context.Context, w io.Writer, reportName string, columns []models.ReportColumn, rows ReportRows, fromDate, toDate time.Time) error {
+	table := reportTable(columns, rows)
+	if groupBy, aggregates := ReportGroups(columns); len(groupBy) > 0 {
+		table = groupedReportTable(columns, groupBy, aggregates, rows)
+	}
+	table.Title = fmt.Sprintf("%s (%s to %s)",
 		reportName,
 		fromDate.Format("2006-01-02"),
 		toDate.Format("2006-01-02"))
+	if err := utils.WriteExcelTable(w, table); err != nil {
+		return fmt.Errorf("failed to export to excel: %w", err)
+	}
+	return nil
+}
 
-	filePath, err := utils.ExportToExcel(processedData, headers, title)
-	if err != nil {
-		return nil, fmt.Errorf("failed to export to excel: %w", err)
+// ExportReportToDelimited writes the report rows as CSV or TSV to w, with a
+// header line of column titles in column order
+func (r *reportRepo) ExportReportToDelimited(ctx context.Context, w io.Writer, columns []models.ReportColumn, rows ReportRows, options utils.DelimitedOptions) error {
+	table := reportTable(columns, rows)
+	if err := utils.WriteDelimitedTable(w, table, options); err != nil {
+		return fmt.Errorf("failed to export to delimited text: %w", err)
 	}
+	return nil
+}
 
-	fileBytes, err := ioutil.ReadFile(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read excel file: %w", err)
+// ExportReportToPDF writes the report rows as a printable PDF document to w
+func (r *reportRepo) ExportReportToPDF(ctx context.Context, w io.Writer, reportName string, columns []models.ReportColumn, rows ReportRows, fromDate, toDate time.Time, options utils.PDFOptions) error {
+	table := reportTable(columns, rows)
+	options.Title = fmt.Sprintf("%s (%s - %s)",
+		reportName,
+		fromDate.Format("2006-01-02"),
+		toDate.Format("2006-01-02"))
+	if err := utils.WritePDFTable(w, table, options); err != nil {
+		return fmt.Errorf("failed to export to pdf: %w", err)
 	}
+	return nil
+}
 
-	return fileBytes, nil
+// reportTable lays out the report rows by column definition, leaving out
+// hidden columns; values are passed on as the database returned them.
+func reportTable(columns []models.ReportColumn, rows ReportRows) utils.ExportTable {
+	visible := VisibleColumns(columns)
+	exportColumns := make([]utils.ExportColumn, len(visible))
+	for i, col := range visible {
+		exportColumns[i] = ReportExportColumn(col)
+	}
+	values := make([]any, len(visible))
+	return utils.ExportTable{
+		Columns: exportColumns,
+		Rows: func(fn func(row utils.ExportRow) error) error {
+			return rows(func(row map[string]any) error {
+				for j, col := range visible {
+					values[j] = row[col.Code]
+				}
+				return fn(utils.ExportRow{Values: values})
+			})
+		},
+	}
 }
+
+// groupedReportTable groups the report rows, which come sorted by the groupBy
+// columns, and lays them out by column definition. Subtotals are labelled in
+// their group's column and the grand total in the first column without an
+// aggregate.
+func groupedReportTable(columns []models.ReportColumn, groupBy []string, aggregates []utils.GroupAggregate, rows ReportRows) utils.ExportTable {
+	visible := VisibleColumns(columns)
+	exportColumns := make([]utils.ExportColumn, len(visible))
+	totalLabel := -1
+	for i, col := range visible {
+		exportColumns[i] = ReportExportColumn(col)
+		exportColumns[i].SummaryCount = col.Aggregate == models.ReportAggregateCount
+		if totalLabel < 0 && col.Aggregate == "" {
+			totalLabel = i
+		}
+	}
+	values := make([]any, len(visible))
+	return utils.ExportTable{
+		Columns: exportColumns,
+		Rows: func(fn func(row utils.ExportRow) error) error {
+			grouper := utils.NewGrouper(groupBy, aggregates, func(row utils.GroupedRow) error {
+				for j, col := range visible {
+					values[j] = row.Values[col.Code]
+					switch {
+					case row.Kind == utils.RowSubtotal && col.Code == groupBy[row.Level-1]:
+						values[j] = "Cộng " + groupLabel(values[j])
+					case row.Kind == utils.RowTotal && j == totalLabel:
+						values[j] = "Tổng cộng"
+					}
+				}
+				return fn(utils.ExportRow{Values: values, Kind: row.Kind, Level: row.Level})
+			})
+			if err := rows(grouper.Add); err != nil {
+				return err
+			}
+			return grouper.Close()
+		},
+	}
+}
+
+func groupLabel(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case time.Time:
+		return v.Format("2006-01-02")
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// ReportGroups returns the group-by column codes of a report, outermost
+// first, and the aggregates of its columns
+func ReportGroups(columns []models.ReportColumn) ([]string, []utils.GroupAggregate) {
+	grouped := make([]models.ReportColumn, 0, len(columns))
+	var aggregates []utils.GroupAggregate
+	for _, col := range columns {
+		if col.GroupLevel > 0 {
+			grouped = append(grouped, col)
+		}
+		if col.Aggregate != "" {
+			aggregates = append(aggregates, utils.GroupAggregate{
+				Code:      col.Code,
+				Aggregate: utils.Aggregate(col.Aggregate),
+				Format:    columnFormat(col.Type),
+			})
+		}
+	}
+	sort.SliceStable(grouped, func(i, j int) bool { return grouped[i].GroupLevel < grouped[j].GroupLevel })
+	groupBy := make([]string, len(grouped))
+	for i, col := range grouped {
+		groupBy[i] = col.Code
+	}
+	return groupBy, aggregates
+}
+
+// VisibleColumns returns the columns that are not hidden, in order
+func VisibleColumns(columns []models.ReportColumn) []models.ReportColumn {
+	visible := make([]models.ReportColumn, 0, len(columns))
+	for _, col := range columns {
+		if !col.Hidden {
+			visible = append(visible, col)
+		}
+	}
+	return visible
+}
+
+// ReportExportColumn is the export layout of a report column definition
+func ReportExportColumn(col models.ReportColumn) utils.ExportColumn {
+	return utils.ExportColumn{
+		Title:        col.Title,
+		Format:       columnFormat(col.Type),
+		Decimals:     col.Decimals,
+		Width:        col.Width,
+		Align:        col.Align,
+		NumberFormat: col.NumberFormat,
+	}
+}
+
+func columnFormat(columnType string) utils.ColumnFormat {
+	switch columnType {
+	case models.ReportColumnInt:
+		return utils.FormatInteger
+	case models.ReportColumnDecimal:
+		return utils.FormatDecimal
+	case models.ReportColumnCurrency:
+		return utils.FormatCurrency
+	case models.ReportColumnPercent:
+		return utils.FormatPercent
+	case models.ReportColumnDate:
+		return utils.FormatDate
+	default:
+		return utils.FormatText
+	}
+}
+
 func (r *reportRepo) Count(ctx context.Context) (int64, error) {
 	var count int64
 	if err := r.db.WithContext(ctx).Model(&models.Report{}).Count(&count).Error; err != nil {
@@ -173,3 +457,27 @@ func (r *reportRepo) Count(ctx context.Context) (int64, error) {
 	}
 	return count, nil
 }
+
+// ToParameterModels converts parameter definitions into report parameter rows
+func ToParameterModels(reportID int64, params []*dto.ReportParameter) []models.ReportParameter {
+	res := make([]models.ReportParameter, 0, len(params))
+	for i, param := range params {
+		num := param.Num
+		if num == 0 {
+			num = int64(i + 1)
+		}
+		res = append(res, models.ReportParameter{
+			ReportID:      reportID,
+			Name:          param.Name,
+			Label:         param.Label,
+			Type:          param.Type,
+			Required:      param.Required,
+			Multiple:      param.Multiple,
+			DefaultValue:  param.DefaultValue,
+			AllowedValues: strings.Join(param.AllowedValues, ","),
+			LookupSource:  param.LookupSource,
+			Num:           num,
+		})
+	}
+	return res
+}
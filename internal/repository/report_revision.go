@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRevisionNotFound = errors.New("report revision not found")
+
+// untrackedRevisionComment marks the snapshot taken of a report that was last
+// changed before revisions were recorded.
+const untrackedRevisionComment = "State before revision history"
+
+func (r *reportRepo) GetRevisions(ctx context.Context, reportID int64) ([]models.ReportRevision, error) {
+	revisions, err := gorm.G[models.ReportRevision](r.db).Where("report_id = ?", reportID).Order("revision DESC").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get report revisions error: %w", err)
+	}
+	return revisions, nil
+}
+
+func (r *reportRepo) GetRevision(ctx context.Context, reportID int64, revision int) (*models.ReportRevision, error) {
+	revisions, err := gorm.G[models.ReportRevision](r.db).Where("report_id = ? AND revision = ?", reportID, revision).Limit(1).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get report revision error: %w", err)
+	}
+	if len(revisions) == 0 {
+		return nil, ErrRevisionNotFound
+	}
+	return &revisions[0], nil
+}
+
+// RestoreRevision makes an old revision the current definition and records
+// the result as a new revision.
+func (r *reportRepo) RestoreRevision(ctx context.Context, reportID int64, revision int, restoredBy int64, comment string) (*models.ReportRevision, error) {
+	old, err := r.GetRevision(ctx, reportID, revision)
+	if err != nil {
+		return nil, err
+	}
+	var columns []models.ReportColumn
+	if err := json.Unmarshal([]byte(old.Columns), &columns); err != nil {
+		return nil, fmt.Errorf("failed to decode revision columns: %w", err)
+	}
+	var params []models.ReportParameter
+	if err := json.Unmarshal([]byte(old.Parameters), &params); err != nil {
+		return nil, fmt.Errorf("failed to decode revision parameters: %w", err)
+	}
+
+	var restored *models.ReportRevision
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseRevision(ctx, tx, reportID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Report{}).Where("id = ?", reportID).Updates(map[string]any{
+			"report_type":           old.ReportType,
+			"report_name":           old.ReportName,
+			"department_id":         old.DepartmentID,
+			"query_statement":       old.QueryStatement,
+			"cache_ttl_seconds":     old.CacheTTLSeconds,
+			"query_timeout_seconds": old.QueryTimeoutSeconds,
+			"updated_at":            time.Now(),
+		}).Error; err != nil {
+			return fmt.Errorf("failed to update report %w", err)
+		}
+
+		if err := tx.Where("report_id = ?", reportID).Delete(&models.ReportColumn{}).Error; err != nil {
+			return fmt.Errorf("failed to delete report columns %w", err)
+		}
+		for i := range columns {
+			columns[i].ReportID = reportID
+		}
+		if len(columns) > 0 {
+			if err := tx.Create(&columns).Error; err != nil {
+				return fmt.Errorf("failed to create report columns %w", err)
+			}
+		}
+
+		if err := tx.Where("report_id = ?", reportID).Delete(&models.ReportParameter{}).Error; err != nil {
+			return fmt.Errorf("failed to delete report parameters %w", err)
+		}
+		for i := range params {
+			params[i].ReportID = reportID
+		}
+		if len(params) > 0 {
+			if err := tx.Create(&params).Error; err != nil {
+				return fmt.Errorf("failed to create report parameters %w", err)
+			}
+		}
+
+		restored, err = recordRevision(ctx, tx, reportID, restoredBy, comment)
+		return err
+	}); err != nil {
+		return nil, fmt.Errorf("failed to restore report revision %w", err)
+	}
+	return restored, nil
+}
+
+// ensureBaseRevision snapshots a report that has no revisions yet, so the
+// definition about to be overwritten is kept.
+func ensureBaseRevision(ctx context.Context, tx *gorm.DB, reportID int64) error {
+	var count int64
+	if err := tx.WithContext(ctx).Model(&models.ReportRevision{}).Where("report_id = ?", reportID).Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to count report revisions %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	_, err := recordRevision(ctx, tx, reportID, 0, untrackedRevisionComment)
+	return err
+}
+
+// recordRevision snapshots the report as stored in tx as its next revision
+func recordRevision(ctx context.Context, tx *gorm.DB, reportID int64, createdBy int64, comment string) (*models.ReportRevision, error) {
+	tx = tx.WithContext(ctx)
+	var report models.Report
+	if err := tx.Where("id = ?", reportID).First(&report).Error; err != nil {
+		return nil, fmt.Errorf("failed to get report %w", err)
+	}
+	var columns []models.ReportColumn
+	if err := tx.Where("report_id = ?", reportID).Order("num, id").Find(&columns).Error; err != nil {
+		return nil, fmt.Errorf("failed to get report columns %w", err)
+	}
+	var params []models.ReportParameter
+	if err := tx.Where("report_id = ?", reportID).Order("num, id").Find(&params).Error; err != nil {
+		return nil, fmt.Errorf("failed to get report parameters %w", err)
+	}
+	// Row ids change on every save, so they are not part of a revision
+	for i := range columns {
+		columns[i].ID, columns[i].ReportID = 0, 0
+	}
+	for i := range params {
+		params[i].ID, params[i].ReportID = 0, 0
+	}
+	columnsJSON, err := json.Marshal(columns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report columns %w", err)
+	}
+	paramsJSON, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode report parameters %w", err)
+	}
+
+	var last int
+	if err := tx.Model(&models.ReportRevision{}).Where("report_id = ?", reportID).Select("COALESCE(MAX(revision), 0)").Scan(&last).Error; err != nil {
+		return nil, fmt.Errorf("failed to get last report revision %w", err)
+	}
+	revision := &models.ReportRevision{
+		ReportID:            reportID,
+		Revision:            last + 1,
+		ReportType:          report.ReportType,
+		ReportName:          report.ReportName,
+		DepartmentID:        report.DepartmentID,
+		QueryStatement:      report.QueryStatement,
+		Columns:             string(columnsJSON),
+		Parameters:          string(paramsJSON),
+		CacheTTLSeconds:     report.CacheTTLSeconds,
+		QueryTimeoutSeconds: report.QueryTimeoutSeconds,
+		Comment:             comment,
+		CreatedBy:           createdBy,
+		CreatedAt:           time.Now(),
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, fmt.Errorf("failed to create report revision %w", err)
+	}
+	return revision, nil
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrReportScheduleNotFound = errors.New("report schedule not found")
+
+type (
+	reportScheduleRepo struct {
+		db *gorm.DB
+	}
+	ReportScheduleRepo interface {
+		Create(ctx context.Context, schedule *models.ReportSchedule) error
+		Update(ctx context.Context, schedule *models.ReportSchedule) error
+		Delete(ctx context.Context, id int64) error
+		GetByID(ctx context.Context, id int64) (*models.ReportSchedule, error)
+		GetAll(ctx context.Context) ([]models.ReportSchedule, error)
+		GetByCreator(ctx context.Context, userID int64) ([]models.ReportSchedule, error)
+		GetDue(ctx context.Context, now time.Time) ([]models.ReportSchedule, error)
+		Claim(ctx context.Context, id int64, dueAt time.Time, nextRunAt *time.Time) (bool, error)
+		CreateRun(ctx context.Context, run *models.ReportScheduleRun) error
+		FinishRun(ctx context.Context, run *models.ReportScheduleRun) error
+		GetRuns(ctx context.Context, scheduleID int64, limit int) ([]models.ReportScheduleRun, error)
+	}
+)
+
+func NewReportScheduleRepo(db *gorm.DB) ReportScheduleRepo {
+	return &reportScheduleRepo{
+		db: db,
+	}
+}
+
+func (r *reportScheduleRepo) Create(ctx context.Context, schedule *models.ReportSchedule) error {
+	if err := r.db.WithContext(ctx).Create(schedule).Error; err != nil {
+		return fmt.Errorf("failed to create report schedule: %w", err)
+	}
+	return nil
+}
+
+func (r *reportScheduleRepo) Update(ctx context.Context, schedule *models.ReportSchedule) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.ReportSchedule{}).
+		Where("id = ?", schedule.ID).
+		Updates(map[string]interface{}{
+			"report_id":   schedule.ReportID,
+			"name":        schedule.Name,
+			"cron":        schedule.Cron,
+			"timezone":    schedule.Timezone,
+			"preset":      schedule.Preset,
+			"params":      schedule.Params,
+			"format":      schedule.Format,
+			"recipients":  schedule.Recipients,
+			"enabled":     schedule.Enabled,
+			"next_run_at": schedule.NextRunAt,
+			"updated_at":  time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update report schedule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrReportScheduleNotFound
+	}
+	return nil
+}
+
+// Delete removes the schedule together with its run history
+func (r *reportScheduleRepo) Delete(ctx context.Context, id int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.ReportSchedule{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete report schedule: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrReportScheduleNotFound
+		}
+		if err := tx.Where("schedule_id = ?", id).Delete(&models.ReportScheduleRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete report schedule runs: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *reportScheduleRepo) GetByID(ctx context.Context, id int64) (*models.ReportSchedule, error) {
+	schedules, err := gorm.G[models.ReportSchedule](r.db).Where("id = ?", id).Limit(1).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedule: %w", err)
+	}
+	if len(schedules) == 0 {
+		return nil, ErrReportScheduleNotFound
+	}
+	return &schedules[0], nil
+}
+
+func (r *reportScheduleRepo) GetAll(ctx context.Context) ([]models.ReportSchedule, error) {
+	schedules, err := gorm.G[models.ReportSchedule](r.db).Order("id").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (r *reportScheduleRepo) GetByCreator(ctx context.Context, userID int64) ([]models.ReportSchedule, error) {
+	schedules, err := gorm.G[models.ReportSchedule](r.db).Where("created_by = ?", userID).Order("id").Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// GetDue returns the enabled schedules whose next run is at or before now
+func (r *reportScheduleRepo) GetDue(ctx context.Context, now time.Time) ([]models.ReportSchedule, error) {
+	schedules, err := gorm.G[models.ReportSchedule](r.db).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get due report schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// Claim moves a due schedule to its next run. It only succeeds while the
+// schedule is still due at dueAt, so with several instances running the
+// scheduler exactly one of them runs it.
+func (r *reportScheduleRepo) Claim(ctx context.Context, id int64, dueAt time.Time, nextRunAt *time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.ReportSchedule{}).
+		Where("id = ? AND next_run_at = ?", id, dueAt).
+		Updates(map[string]interface{}{
+			"next_run_at": nextRunAt,
+			"last_run_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim report schedule: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *reportScheduleRepo) CreateRun(ctx context.Context, run *models.ReportScheduleRun) error {
+	if err := r.db.WithContext(ctx).Create(run).Error; err != nil {
+		return fmt.Errorf("failed to create report schedule run: %w", err)
+	}
+	return nil
+}
+
+func (r *reportScheduleRepo) FinishRun(ctx context.Context, run *models.ReportScheduleRun) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.ReportScheduleRun{}).
+		Where("id = ?", run.ID).
+		Updates(map[string]interface{}{
+			"status":      run.Status,
+			"file_name":   run.FileName,
+			"error":       run.Error,
+			"finished_at": run.FinishedAt,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to finish report schedule run: %w", err)
+	}
+	return nil
+}
+
+// GetRuns returns the latest runs of a schedule, newest first
+func (r *reportScheduleRepo) GetRuns(ctx context.Context, scheduleID int64, limit int) ([]models.ReportScheduleRun, error) {
+	runs, err := gorm.G[models.ReportScheduleRun](r.db).
+		Where("schedule_id = ?", scheduleID).
+		Order("started_at DESC").
+		Limit(limit).
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report schedule runs: %w", err)
+	}
+	return runs, nil
+}
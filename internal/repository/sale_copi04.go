@@ -5,8 +5,10 @@ package repository
 import (
 	"context"
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/utils"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time"
@@ -25,6 +27,8 @@ type (
 		Create(ctx context.Context, input dto.SaleCopi04Create, creator string, company string) error
 		Update(ctx context.Context, id string, input dto.SaleCopi04Update, modifier string, company string) error
 		Delete(ctx context.Context, id string) error
+		// ExportCopi04ToPDF prints a COPI04 document, its header fields followed by its lines
+		ExportCopi04ToPDF(ctx context.Context, w io.Writer, doc *dto.SaleCopi04Res, options utils.PDFOptions) error
 	}
 )
 
@@ -312,3 +316,40 @@ func (s *saleCopi04) Delete(ctx context.Context, id string) error {
 	})
 }
 
+// copi04LineColumns are the COPMF columns read by GetCopi04, labelled by field
+var copi04LineColumns = []utils.ExportColumn{
+	{Title: "MF002"}, {Title: "MF003"}, {Title: "MF004"}, {Title: "MF005"},
+	{Title: "MF006", Format: utils.FormatDate}, {Title: "MF007"},
+	{Title: "MF008", Format: utils.FormatDecimal}, {Title: "MF009", Format: utils.FormatDecimal},
+	{Title: "MF010"}, {Title: "MF011"}, {Title: "MF012", Format: utils.FormatDecimal},
+	{Title: "MF013"}, {Title: "MF014", Format: utils.FormatDecimal},
+	{Title: "MF015", Format: utils.FormatInteger}, {Title: "MF020"},
+}
+
+func (s *saleCopi04) ExportCopi04ToPDF(ctx context.Context, w io.Writer, doc *dto.SaleCopi04Res, options utils.PDFOptions) error {
+	header := doc.Header
+	d := utils.NewPDFDocument(options)
+	d.Fields([]utils.PDFField{
+		{Label: "ME001", Value: header.ME001}, {Label: "ME002", Value: header.ME002},
+		{Label: "ME003", Value: header.ME003}, {Label: "ME004", Value: header.ME004},
+		{Label: "ME005", Value: header.ME005}, {Label: "ME006", Value: header.ME006},
+		{Label: "ME007", Value: header.ME007}, {Label: "ME008", Value: header.ME008},
+		{Label: "ME009", Value: header.ME009}, {Label: "ME010", Value: header.ME010},
+		{Label: "ME011", Value: header.ME011}, {Label: "ME012", Value: header.ME012},
+		{Label: "ME013", Value: header.ME013}, {Label: "ME014", Value: header.ME014},
+	})
+	d.Space(5)
+
+	d.Caption(fmt.Sprintf("COPMF (%d)", len(doc.Detail)))
+	d.Table(copi04LineColumns, nil)
+	for _, line := range doc.Detail {
+		d.Row([]any{line.MF002, line.MF003, line.MF004, line.MF005, line.MF006, line.MF007, line.MF008, line.MF009,
+			line.MF010, line.MF011, line.MF012, line.MF013, line.MF014, line.MF015, line.MF020})
+	}
+	d.EndTable()
+
+	if err := d.Write(w); err != nil {
+		return fmt.Errorf("failed to export to pdf: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+type (
+	sessionRepo struct {
+		db *gorm.DB
+	}
+	SessionRepo interface {
+		Create(ctx context.Context, session *models.Session) error
+		GetByID(ctx context.Context, id int64) (*models.Session, error)
+		GetBySessionID(ctx context.Context, sessionID string) (*models.Session, error)
+		GetActiveByUser(ctx context.Context, userID int64, now time.Time) ([]models.Session, error)
+		GetActive(ctx context.Context, now time.Time) ([]models.Session, error)
+		Extend(ctx context.Context, sessionID string, expiresAt time.Time) error
+		Touch(ctx context.Context, sessionID string, seenAt time.Time) error
+		Revoke(ctx context.Context, sessionID string, revokedBy int64) error
+		RevokeByUser(ctx context.Context, userID int64, revokedBy int64) error
+		DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+	}
+)
+
+func NewSessionRepo(db *gorm.DB) SessionRepo {
+	return &sessionRepo{
+		db: db,
+	}
+}
+
+func (r *sessionRepo) Create(ctx context.Context, session *models.Session) error {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepo) GetByID(ctx context.Context, id int64) (*models.Session, error) {
+	sessions, err := gorm.G[models.Session](r.db).Where("id = ?", id).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &sessions[0], nil
+}
+
+func (r *sessionRepo) GetBySessionID(ctx context.Context, sessionID string) (*models.Session, error) {
+	sessions, err := gorm.G[models.Session](r.db).Where("session_id = ?", sessionID).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	if len(sessions) == 0 {
+		return nil, ErrSessionNotFound
+	}
+	return &sessions[0], nil
+}
+
+func (r *sessionRepo) GetActiveByUser(ctx context.Context, userID int64, now time.Time) ([]models.Session, error) {
+	sessions, err := gorm.G[models.Session](r.db).
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepo) GetActive(ctx context.Context, now time.Time) ([]models.Session, error) {
+	sessions, err := gorm.G[models.Session](r.db).
+		Preload("User", nil).
+		Where("revoked_at IS NULL AND expires_at > ?", now).
+		Order("last_seen_at DESC").
+		Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+func (r *sessionRepo) Extend(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"expires_at":   expiresAt,
+			"last_seen_at": time.Now(),
+		}).Error; err != nil {
+		return fmt.Errorf("failed to extend session: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepo) Touch(ctx context.Context, sessionID string, seenAt time.Time) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("session_id = ?", sessionID).
+		UpdateColumn("last_seen_at", seenAt).Error; err != nil {
+		return fmt.Errorf("failed to update session last seen: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepo) Revoke(ctx context.Context, sessionID string, revokedBy int64) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now(),
+			"revoked_by": revokedBy,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to revoke session: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *sessionRepo) RevokeByUser(ctx context.Context, userID int64, revokedBy int64) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at": time.Now(),
+			"revoked_by": revokedBy,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to revoke user sessions: %w", err)
+	}
+	return nil
+}
+
+func (r *sessionRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.Session{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired sessions: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
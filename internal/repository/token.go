@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrRefreshTokenNotFound = errors.New("refresh token not found")
+
+type (
+	tokenRepo struct {
+		db *gorm.DB
+	}
+	TokenRepo interface {
+		Revoke(ctx context.Context, token *models.RevokedToken) error
+		IsRevoked(ctx context.Context, jti string, userID int64, sessionID string, issuedAt time.Time) (bool, error)
+		DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+
+		CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error
+		GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+		MarkRefreshTokenUsed(ctx context.Context, id int64) (bool, error)
+		RevokeRefreshFamily(ctx context.Context, familyID string) error
+		RevokeUserRefreshTokens(ctx context.Context, userID int64) error
+		DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error)
+	}
+)
+
+func NewTokenRepo(db *gorm.DB) TokenRepo {
+	return &tokenRepo{
+		db: db,
+	}
+}
+
+func (r *tokenRepo) Revoke(ctx context.Context, token *models.RevokedToken) error {
+	if token == nil {
+		return errors.New("revoked token cannot be nil")
+	}
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// IsRevoked reports whether the token identified by jti, its session, or every
+// token of userID issued at issuedAt, has been revoked.
+func (r *tokenRepo) IsRevoked(ctx context.Context, jti string, userID int64, sessionID string, issuedAt time.Time) (bool, error) {
+	revoked := r.db.Where("scope = ? AND jti = ?", models.RevocationScopeToken, jti).
+		Or("scope = ? AND user_id = ? AND revoked_at >= ?", models.RevocationScopeUser, userID, issuedAt)
+	if sessionID != "" {
+		revoked = revoked.Or("scope = ? AND session_id = ?", models.RevocationScopeSession, sessionID)
+	}
+
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.RevokedToken{}).
+		Where("expires_at > ?", time.Now()).
+		Where(revoked).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check revoked token: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r *tokenRepo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RevokedToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired revoked tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *tokenRepo) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if token == nil {
+		return errors.New("refresh token cannot be nil")
+	}
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepo) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed consumes the token. It returns false when the token was
+// already used or revoked, so two concurrent rotations cannot both succeed.
+func (r *tokenRepo) MarkRefreshTokenUsed(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark refresh token used: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *tokenRepo) RevokeRefreshFamily(ctx context.Context, familyID string) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepo) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	err := r.db.WithContext(ctx).
+		Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke user refresh tokens: %w", err)
+	}
+	return nil
+}
+
+func (r *tokenRepo) DeleteExpiredRefreshTokens(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.RefreshToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired refresh tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTOTPNotFound      = errors.New("totp not configured")
+	ErrChallengeNotFound = errors.New("login challenge not found")
+)
+
+type (
+	twoFactorRepo struct {
+		db *gorm.DB
+	}
+	TwoFactorRepo interface {
+		GetTOTP(ctx context.Context, userID int64) (*models.UserTOTP, error)
+		SaveTOTP(ctx context.Context, totp *models.UserTOTP) error
+		DeleteTOTP(ctx context.Context, userID int64) error
+		UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error)
+
+		ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error
+		UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error)
+		CountRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+
+		CreateChallenge(ctx context.Context, challenge *models.LoginChallenge) error
+		GetChallengeByHash(ctx context.Context, tokenHash string) (*models.LoginChallenge, error)
+		IncrementChallengeAttempts(ctx context.Context, id int64) error
+		UseChallenge(ctx context.Context, id int64) (bool, error)
+		DeleteExpiredChallenges(ctx context.Context, now time.Time) (int64, error)
+	}
+)
+
+func NewTwoFactorRepo(db *gorm.DB) TwoFactorRepo {
+	return &twoFactorRepo{
+		db: db,
+	}
+}
+
+func (r *twoFactorRepo) GetTOTP(ctx context.Context, userID int64) (*models.UserTOTP, error) {
+	totps, err := gorm.G[models.UserTOTP](r.db).Where("user_id = ?", userID).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totp: %w", err)
+	}
+	if len(totps) == 0 {
+		return nil, ErrTOTPNotFound
+	}
+	return &totps[0], nil
+}
+
+func (r *twoFactorRepo) SaveTOTP(ctx context.Context, totp *models.UserTOTP) error {
+	if err := r.db.WithContext(ctx).Save(totp).Error; err != nil {
+		return fmt.Errorf("failed to save totp: %w", err)
+	}
+	return nil
+}
+
+func (r *twoFactorRepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserTOTP{}).Error; err != nil {
+			return fmt.Errorf("failed to delete totp: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep records step as used; it fails when the step or a later one was already used
+func (r *twoFactorRepo) UseTOTPStep(ctx context.Context, userID int64, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.UserTOTP{}).
+		Where("user_id = ? AND last_used_step < ?", userID, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update totp step: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *twoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete recovery codes: %w", err)
+		}
+		codes := make([]models.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if err := tx.Create(&codes).Error; err != nil {
+			return fmt.Errorf("failed to create recovery codes: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *twoFactorRepo) UseRecoveryCode(ctx context.Context, userID int64, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *twoFactorRepo) CountRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+func (r *twoFactorRepo) CreateChallenge(ctx context.Context, challenge *models.LoginChallenge) error {
+	if err := r.db.WithContext(ctx).Create(challenge).Error; err != nil {
+		return fmt.Errorf("failed to create login challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *twoFactorRepo) GetChallengeByHash(ctx context.Context, tokenHash string) (*models.LoginChallenge, error) {
+	challenges, err := gorm.G[models.LoginChallenge](r.db).Where("token_hash = ?", tokenHash).Find(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login challenge: %w", err)
+	}
+	if len(challenges) == 0 {
+		return nil, ErrChallengeNotFound
+	}
+	return &challenges[0], nil
+}
+
+func (r *twoFactorRepo) IncrementChallengeAttempts(ctx context.Context, id int64) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.LoginChallenge{}).
+		Where("id = ?", id).
+		UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+		return fmt.Errorf("failed to update login challenge: %w", err)
+	}
+	return nil
+}
+
+func (r *twoFactorRepo) UseChallenge(ctx context.Context, id int64) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to use login challenge: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *twoFactorRepo) DeleteExpiredChallenges(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&models.LoginChallenge{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired login challenges: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
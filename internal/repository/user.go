@@ -5,11 +5,15 @@ import (
 	"cqs-kanban/internal/dto"
 	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/utils"
+	"errors"
 	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+var ErrUserNotFound = errors.New("not found user")
+
 type (
 	userRepo struct {
 		db *gorm.DB
@@ -18,8 +22,11 @@ type (
 		Create(ctx context.Context, input dto.UserCreateReq) error
 		GetByID(ctx context.Context, id int64) (*models.User, error)
 		GetByUsername(ctx context.Context, username string) (*models.User, error)
+		Save(ctx context.Context, user *models.User) error
 		Update(ctx context.Context, id int64, input dto.UserUpdateReq) error
-		UpdatePassword(ctx context.Context, id int64, password string) error
+		UpdatePassword(ctx context.Context, id int64, passwordHash string, mustChange bool, expiresAt *time.Time) error
+		ExpirePassword(ctx context.Context, id int64) error
+		UpdateLastLogin(ctx context.Context, id int64, loginAt time.Time) error
 		Delete(ctx context.Context, id int64) error
 		GetAll(ctx context.Context) ([]models.User, error)
 		GetUser(ctx context.Context, req dto.UserDetailReq) (*dto.UserDetailRes, error)
@@ -44,8 +51,12 @@ func (u *userRepo) Create(ctx context.Context, input dto.UserCreateReq) error {
 		FullName:     input.FullName,
 		Email:        input.Email,
 		DepartmentID: input.DepartmentID,
+		Role:         input.Role,
 		Password:     hashedPassword,
 	}
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
 
 	if err := u.db.Create(user).Error; err != nil {
 		return fmt.Errorf("failed to create user %w", err)
@@ -72,11 +83,19 @@ func (u *userRepo) GetByUsername(ctx context.Context, username string) (*models.
 	}
 
 	if len(users) < 1 {
-		return nil, fmt.Errorf("not found user")
+		return nil, ErrUserNotFound
 	}
 	return &users[0], nil
 }
 
+// Save creates user, or updates every field of it when it already has an ID
+func (u *userRepo) Save(ctx context.Context, user *models.User) error {
+	if err := u.db.WithContext(ctx).Save(user).Error; err != nil {
+		return fmt.Errorf("failed to save user %w", err)
+	}
+	return nil
+}
+
 func (u *userRepo) Update(ctx context.Context, id int64, input dto.UserUpdateReq) error {
 	updates := make(map[string]interface{})
 	if input.Username != nil {
@@ -91,6 +110,9 @@ func (u *userRepo) Update(ctx context.Context, id int64, input dto.UserUpdateReq
 	if input.DepartmentID != nil {
 		updates["department_id"] = *input.DepartmentID
 	}
+	if input.Role != nil {
+		updates["role"] = *input.Role
+	}
 	if input.Password != nil {
 		hashedPassword, err := utils.HashPassword(*input.Password)
 		if err != nil {
@@ -108,16 +130,36 @@ func (u *userRepo) Update(ctx context.Context, id int64, input dto.UserUpdateReq
 	}
 	return nil
 }
-func (u *userRepo) UpdatePassword(ctx context.Context, id int64, password string) error {
-	hashedPassword, err := utils.HashPassword(password)
-	if err != nil {
-		return fmt.Errorf("failed to hash password %w", err)
-	}
-	if err := u.db.Model(&models.User{}).Where("id = ?", id).Update("password", hashedPassword).Error; err != nil {
+
+// UpdatePassword stores an already hashed password. mustChange and expiresAt
+// are set for administrator-issued temporary passwords.
+func (u *userRepo) UpdatePassword(ctx context.Context, id int64, passwordHash string, mustChange bool, expiresAt *time.Time) error {
+	if err := u.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":             passwordHash,
+		"must_change_password": mustChange,
+		"password_expires_at":  expiresAt,
+		"password_changed_at":  time.Now(),
+	}).Error; err != nil {
 		return fmt.Errorf("failed to update user password %w", err)
 	}
 	return nil
 }
+
+// ExpirePassword makes the current password unusable for further logins
+func (u *userRepo) ExpirePassword(ctx context.Context, id int64) error {
+	if err := u.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).Update("password_expires_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("failed to expire user password %w", err)
+	}
+	return nil
+}
+
+func (u *userRepo) UpdateLastLogin(ctx context.Context, id int64, loginAt time.Time) error {
+	if err := u.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", id).UpdateColumn("last_login", loginAt).Error; err != nil {
+		return fmt.Errorf("failed to update user last login %w", err)
+	}
+	return nil
+}
+
 func (u *userRepo) Delete(ctx context.Context, id int64) error {
 	if err := u.db.Delete(&models.User{}, id).Error; err != nil {
 		return fmt.Errorf("failed to delete user %w", err)
@@ -145,6 +187,7 @@ func (u *userRepo) GetUser(ctx context.Context, req dto.UserDetailReq) (*dto.Use
 		FullName:     user.FullName,
 		Email:        user.Email,
 		DepartmentID: user.DepartmentID,
+		Role:         user.Role,
 		CreatedAt:    user.CreatedAt.Format("2006-01-02"),
 		UpdatedAt:    user.UpdatedAt.Format("2006-01-02"),
 	}
@@ -35,14 +35,18 @@ type AdminService interface {
 	// Security
 	GetSecurityAlerts(ctx context.Context, hours int) ([]*dto.SecurityAlertResponse, error)
 	GetFailedAccessByIP(ctx context.Context, ipAddress string, hours int) (int64, error)
+	GetLoginAttempts(ctx context.Context, req dto.LoginAttemptQueryReq) ([]models.LoginAttempt, error)
+	GetLockouts(ctx context.Context) ([]*dto.LockoutResponse, error)
+	Unlock(ctx context.Context, lockoutID int64, unlockedBy int64) error
 }
 
 type adminService struct {
-	operationRepo  repository.OperationRepository
-	userRepo       repository.UserRepo
-	departmentRepo repository.DepartmentRepo
-	reportRepo     repository.ReportRepo
-	logger         Logger
+	operationRepo    repository.OperationRepository
+	userRepo         repository.UserRepo
+	departmentRepo   repository.DepartmentRepo
+	reportRepo       repository.ReportRepo
+	loginAttemptRepo repository.LoginAttemptRepo
+	logger           Logger
 }
 
 func NewAdminService(
@@ -50,14 +54,16 @@ func NewAdminService(
 	userRepo repository.UserRepo,
 	departmentRepo repository.DepartmentRepo,
 	reportRepo repository.ReportRepo,
+	loginAttemptRepo repository.LoginAttemptRepo,
 	logger Logger,
 ) AdminService {
 	return &adminService{
-		operationRepo:  operationRepo,
-		userRepo:       userRepo,
-		departmentRepo: departmentRepo,
-		reportRepo:     reportRepo,
-		logger:         logger,
+		operationRepo:    operationRepo,
+		userRepo:         userRepo,
+		departmentRepo:   departmentRepo,
+		reportRepo:       reportRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		logger:           logger,
 	}
 }
 
@@ -540,6 +546,31 @@ func (s *adminService) GetSecurityAlerts(ctx context.Context, hours int) ([]*dto
 		}
 	}
 
+	// Failed logins, grouped the same way
+	failedLogins, err := s.loginAttemptRepo.GetFailures(ctx, fromTime, 1000)
+	if err != nil {
+		return nil, err
+	}
+	for _, attempt := range failedLogins {
+		key := fmt.Sprintf("login_%s_%s", attempt.Username, attempt.IPAddress)
+		if alert, exists := alertMap[key]; exists {
+			alert.FailedCount++
+			if attempt.AttemptedAt.After(alert.LastFailedAt) {
+				alert.LastFailedAt = attempt.AttemptedAt
+			}
+			continue
+		}
+		alertMap[key] = &dto.SecurityAlertResponse{
+			AlertType:    "multiple_failed_logins",
+			UserID:       attempt.UserID,
+			Username:     attempt.Username,
+			IPAddress:    attempt.IPAddress,
+			FailedCount:  1,
+			LastFailedAt: attempt.AttemptedAt,
+			Description:  fmt.Sprintf("Multiple failed logins for %s from IP %s", attempt.Username, attempt.IPAddress),
+		}
+	}
+
 	// Convert to slice and filter (only alerts with 3+ failures)
 	alerts := make([]*dto.SecurityAlertResponse, 0)
 	for _, alert := range alertMap {
@@ -548,6 +579,26 @@ func (s *adminService) GetSecurityAlerts(ctx context.Context, hours int) ([]*dto
 		}
 	}
 
+	// Active lockouts are always reported
+	lockouts, err := s.loginAttemptRepo.GetActiveLockouts(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	for _, lockout := range lockouts {
+		alert := &dto.SecurityAlertResponse{
+			AlertType:    lockout.Scope + "_locked",
+			FailedCount:  lockout.FailedCount,
+			LastFailedAt: lockout.LockedAt,
+			Description:  fmt.Sprintf("Logins for %s %s locked until %s", lockout.Scope, lockout.Target, lockout.LockedUntil.Format(time.RFC3339)),
+		}
+		if lockout.Scope == models.LockoutScopeIP {
+			alert.IPAddress = lockout.Target
+		} else {
+			alert.Username = lockout.Target
+		}
+		alerts = append(alerts, alert)
+	}
+
 	return alerts, nil
 }
 
@@ -568,6 +619,49 @@ func (s *adminService) GetFailedAccessByIP(ctx context.Context, ipAddress string
 	return count, nil
 }
 
+func (s *adminService) GetLoginAttempts(ctx context.Context, req dto.LoginAttemptQueryReq) ([]models.LoginAttempt, error) {
+	if req.Hours <= 0 {
+		req.Hours = 24
+	}
+	if req.Limit <= 0 || req.Limit > 1000 {
+		req.Limit = 100
+	}
+	fromTime := time.Now().Add(-time.Duration(req.Hours) * time.Hour)
+	return s.loginAttemptRepo.GetAttempts(ctx, req.Username, req.IPAddress, fromTime, req.Limit)
+}
+
+func (s *adminService) GetLockouts(ctx context.Context) ([]*dto.LockoutResponse, error) {
+	lockouts, err := s.loginAttemptRepo.GetActiveLockouts(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]*dto.LockoutResponse, 0, len(lockouts))
+	for _, lockout := range lockouts {
+		res = append(res, &dto.LockoutResponse{
+			ID:          lockout.ID,
+			Scope:       lockout.Scope,
+			Target:      lockout.Target,
+			FailedCount: lockout.FailedCount,
+			LockedAt:    lockout.LockedAt,
+			LockedUntil: lockout.LockedUntil,
+		})
+	}
+	return res, nil
+}
+
+func (s *adminService) Unlock(ctx context.Context, lockoutID int64, unlockedBy int64) error {
+	if err := s.loginAttemptRepo.Unlock(ctx, lockoutID, unlockedBy); err != nil {
+		return err
+	}
+
+	s.logger.Info(ctx, "Login lockout removed", map[string]interface{}{
+		"lockout_id":  lockoutID,
+		"unlocked_by": unlockedBy,
+	})
+	return nil
+}
+
 // ============================================================================
 // Helper Methods
 // ============================================================================
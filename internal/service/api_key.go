@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid or expired api key")
+	ErrAPIKeyNoName   = errors.New("api key name cannot be empty")
+	ErrAPIKeyNoScopes = errors.New("api key needs at least one scope")
+)
+
+const (
+	apiKeyBytes     = 32
+	apiKeyPrefixLen = len(models.APIKeyPrefix) + 8
+	// apiKeyTouchInterval limits how often last_used_at is written for a busy key
+	apiKeyTouchInterval = time.Minute
+)
+
+type (
+	apiKeyService struct {
+		apiKeyRepo repository.APIKeyRepo
+		userRepo   repository.UserRepo
+		logger     Logger
+	}
+	APIKeyService interface {
+		Create(ctx context.Context, req dto.APIKeyCreateReq, createdBy int64) (*dto.APIKeySecretRes, error)
+		List(ctx context.Context) ([]dto.APIKeyRes, error)
+		Rotate(ctx context.Context, id int64) (*dto.APIKeySecretRes, error)
+		Revoke(ctx context.Context, id int64) error
+		Authenticate(ctx context.Context, key string) (*models.APIKey, error)
+	}
+)
+
+func NewAPIKeyService(apiKeyRepo repository.APIKeyRepo, userRepo repository.UserRepo, logger Logger) APIKeyService {
+	return &apiKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+		logger:     logger,
+	}
+}
+
+func (s *apiKeyService) Create(ctx context.Context, req dto.APIKeyCreateReq, createdBy int64) (*dto.APIKeySecretRes, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrAPIKeyNoName
+	}
+	if len(req.Scopes) == 0 {
+		return nil, ErrAPIKeyNoScopes
+	}
+	for _, scope := range req.Scopes {
+		if _, known := models.PermissionDescriptions[scope]; !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, scope)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expires_at must be in the future")
+	}
+
+	ownerID := req.OwnerID
+	if ownerID == 0 {
+		ownerID = createdBy
+	}
+	owner, err := s.userRepo.GetByID(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner: %w", err)
+	}
+
+	key, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := &models.APIKey{
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   hash,
+		OwnerID:   owner.ID,
+		Owner:     owner,
+		Scopes:    strings.Join(req.Scopes, ","),
+		ExpiresAt: req.ExpiresAt,
+		CreatedBy: createdBy,
+	}
+	if err := s.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "API key created", map[string]interface{}{
+		"api_key_id": apiKey.ID,
+		"owner_id":   owner.ID,
+		"created_by": createdBy,
+	})
+
+	return &dto.APIKeySecretRes{APIKeyRes: toAPIKeyRes(apiKey), Key: key}, nil
+}
+
+func (s *apiKeyService) List(ctx context.Context) ([]dto.APIKeyRes, error) {
+	keys, err := s.apiKeyRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.APIKeyRes, 0, len(keys))
+	for _, key := range keys {
+		res = append(res, toAPIKeyRes(&key))
+	}
+	return res, nil
+}
+
+// Rotate issues new key material for id; the previous key stops working immediately.
+func (s *apiKeyService) Rotate(ctx context.Context, id int64) (*dto.APIKeySecretRes, error) {
+	key, prefix, hash, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apiKeyRepo.UpdateHash(ctx, id, hash, prefix); err != nil {
+		return nil, err
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "API key rotated", map[string]interface{}{
+		"api_key_id": id,
+	})
+
+	return &dto.APIKeySecretRes{APIKeyRes: toAPIKeyRes(apiKey), Key: key}, nil
+}
+
+func (s *apiKeyService) Revoke(ctx context.Context, id int64) error {
+	if err := s.apiKeyRepo.Revoke(ctx, id); err != nil {
+		return err
+	}
+
+	s.logger.Info(ctx, "API key revoked", map[string]interface{}{
+		"api_key_id": id,
+	})
+	return nil
+}
+
+// Authenticate resolves key to an active API key whose owner is still active.
+func (s *apiKeyService) Authenticate(ctx context.Context, key string) (*models.APIKey, error) {
+	if !strings.HasPrefix(key, models.APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	apiKey, err := s.apiKeyRepo.GetByHash(ctx, utils.HashToken(key))
+	if err != nil {
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !apiKey.IsActive(now) || apiKey.Owner == nil || !apiKey.Owner.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.apiKeyRepo.TouchLastUsed(ctx, apiKey.ID, now); err != nil {
+			s.logger.Warn(ctx, "Failed to update API key last used", map[string]interface{}{
+				"api_key_id": apiKey.ID,
+				"error":      err.Error(),
+			})
+		}
+		apiKey.LastUsedAt = &now
+	}
+
+	return apiKey, nil
+}
+
+func generateAPIKey() (key, prefix, hash string, err error) {
+	random, err := utils.GenerateRandomToken(apiKeyBytes)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	key = models.APIKeyPrefix + random
+	return key, key[:apiKeyPrefixLen], utils.HashToken(key), nil
+}
+
+func toAPIKeyRes(key *models.APIKey) dto.APIKeyRes {
+	res := dto.APIKeyRes{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		OwnerID:    key.OwnerID,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		RevokedAt:  key.RevokedAt,
+		CreatedBy:  key.CreatedBy,
+		CreatedAt:  key.CreatedAt,
+	}
+	if key.Owner != nil {
+		res.OwnerUsername = key.Owner.Username
+	}
+	return res
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnknownUser is returned by a provider that does not know the username,
+// so the next configured provider is tried.
+var ErrUnknownUser = errors.New("user not known to provider")
+
+// AuthProvider verifies a username and password and returns the matching
+// local user, creating or updating it when the identity lives elsewhere.
+type AuthProvider interface {
+	Name() string
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// NewAuthProviders builds the providers listed in auth.providers, in order.
+func NewAuthProviders(cfg *config.Config, userRepo repository.UserRepo, departmentRepo repository.DepartmentRepo, logger Logger) ([]AuthProvider, error) {
+	names := cfg.Auth.Providers
+	if len(names) == 0 {
+		names = []string{models.AuthSourceLocal}
+	}
+
+	providers := make([]AuthProvider, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case models.AuthSourceLocal:
+			providers = append(providers, NewLocalAuthProvider(userRepo))
+		case models.AuthSourceLDAP:
+			providers = append(providers, NewLDAPAuthProvider(cfg, userRepo, departmentRepo, logger))
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+type localAuthProvider struct {
+	userRepo repository.UserRepo
+}
+
+// NewLocalAuthProvider checks passwords against the bcrypt hashes in the users table.
+func NewLocalAuthProvider(userRepo repository.UserRepo) AuthProvider {
+	return &localAuthProvider{
+		userRepo: userRepo,
+	}
+}
+
+func (p *localAuthProvider) Name() string {
+	return models.AuthSourceLocal
+}
+
+func (p *localAuthProvider) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := p.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return nil, ErrUnknownUser
+		}
+		return nil, err
+	}
+	// Users provisioned by another provider have no usable local password
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		return nil, ErrUnknownUser
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return user, ErrInvalidCredentials
+	}
+	if user.PasswordExpiresAt != nil && time.Now().After(*user.PasswordExpiresAt) {
+		return user, ErrPasswordExpired
+	}
+	return user, nil
+}
@@ -13,65 +13,336 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid username or password")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+	ErrTokenNoID           = errors.New("token has no id")
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+const refreshTokenBytes = 32
+
 type AuthService interface {
 	Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error)
 	ValidateToken(ctx context.Context, token string) (*dto.TokenClaims, error)
 	GenerateToken(user *models.User) (string, error)
-	Logout(ctx context.Context, token string) error
+	VerifyTwoFactor(ctx context.Context, req dto.TwoFactorVerifyRequest) (*dto.LoginResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*dto.LoginResponse, error)
+	Logout(ctx context.Context, token string, refreshToken string) error
+	LogoutAll(ctx context.Context, userID int64) error
+	RevokeUserTokens(ctx context.Context, userID int64, revokedBy int64, reason string) error
+	PurgeRevokedTokens(ctx context.Context) (int64, error)
 	GetProfile(ctx context.Context, userID int64) (*dto.UserDetailRes, error)
+	JWKS() dto.JWKSResponse
 }
 
 type authService struct {
-	userRepo repository.UserRepo
-	config   *config.Config
+	userRepo         repository.UserRepo
+	tokenRepo        repository.TokenRepo
+	loginGuard       LoginGuard
+	twoFactorService TwoFactorService
+	sessionService   SessionService
+	providers        []AuthProvider
+	keySet           *JWTKeySet
+	config           *config.Config
 }
 
-func NewAuthService(userRepo repository.UserRepo, config *config.Config) AuthService {
+func NewAuthService(userRepo repository.UserRepo, tokenRepo repository.TokenRepo, loginGuard LoginGuard, twoFactorService TwoFactorService, sessionService SessionService, providers []AuthProvider, keySet *JWTKeySet, config *config.Config) AuthService {
 	return &authService{
-		userRepo: userRepo,
-		config:   config,
+		userRepo:         userRepo,
+		tokenRepo:        tokenRepo,
+		loginGuard:       loginGuard,
+		twoFactorService: twoFactorService,
+		sessionService:   sessionService,
+		providers:        providers,
+		keySet:           keySet,
+		config:           config,
 	}
 }
 
 func (s *authService) Login(ctx context.Context, req dto.LoginRequest) (*dto.LoginResponse, error) {
-	user, err := s.userRepo.GetByUsername(ctx, req.Username)
+	attempt := &models.LoginAttempt{
+		Username:  req.Username,
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	}
+	// Every exit records the attempt; one without a reason failed on our side
+	defer func() {
+		if attempt.Reason == "" {
+			attempt.Reason = models.LoginReasonError
+		}
+		s.recordAttempt(ctx, attempt)
+	}()
+
+	if err := s.loginGuard.Check(ctx, req.Username, req.IPAddress); err != nil {
+		attempt.Reason = models.LoginReasonLocked
+		return nil, err
+	}
+
+	user, err := s.authenticate(ctx, req.Username, req.Password)
+	if user != nil {
+		attempt.UserID = user.ID
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUnknownUser):
+			attempt.Reason = models.LoginReasonUnknownUser
+		case errors.Is(err, ErrInvalidCredentials):
+			log.Printf("Invalid credentials for user: %s", req.Username)
+			attempt.Reason = models.LoginReasonInvalidPassword
+		case errors.Is(err, ErrPasswordExpired):
+			attempt.Reason = models.LoginReasonPasswordExpired
+			return nil, ErrPasswordExpired
+		default:
+			// Provider failure (e.g. directory unreachable), not the user's fault
+			attempt.Reason = models.LoginReasonProviderError
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.IsActive {
+		attempt.Reason = models.LoginReasonInactive
+		return nil, errors.New("user is inactive")
+	}
+
+	// A temporary password is good for this one login only
+	if user.MustChangePassword && user.PasswordExpiresAt != nil {
+		if err := s.userRepo.ExpirePassword(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	twoFactorEnabled, err := s.twoFactorService.IsEnabled(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorEnabled {
+		challenge, expiresAt, err := s.twoFactorService.CreateChallenge(ctx, user.ID, req.IPAddress, req.UserAgent)
+		if err != nil {
+			return nil, err
+		}
+		attempt.Reason = models.LoginReasonOTPRequired
+		return &dto.LoginResponse{
+			UserID:             user.ID,
+			Username:           user.Username,
+			TwoFactorRequired:  true,
+			ChallengeToken:     challenge,
+			ChallengeExpiresAt: &expiresAt,
+		}, nil
+	}
+
+	res, err := s.startSession(ctx, user, req.IPAddress, req.UserAgent)
+	if err != nil {
+		return nil, err
+	}
+	attempt.Success = true
+	attempt.Reason = models.LoginReasonSuccess
+	return res, nil
+}
+
+// VerifyTwoFactor completes a login that returned a challenge
+func (s *authService) VerifyTwoFactor(ctx context.Context, req dto.TwoFactorVerifyRequest) (*dto.LoginResponse, error) {
+	attempt := &models.LoginAttempt{
+		IPAddress: req.IPAddress,
+		UserAgent: req.UserAgent,
+	}
+
+	userID, err := s.twoFactorService.ChallengeUser(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+	attempt.UserID = user.ID
+	attempt.Username = user.Username
+
+	// A locked out user must not use up the challenge or its code
+	if lockErr := s.loginGuard.Check(ctx, user.Username, req.IPAddress); lockErr != nil {
+		attempt.Reason = models.LoginReasonLocked
+		s.recordAttempt(ctx, attempt)
+		return nil, lockErr
+	}
+
+	consumedID, err := s.twoFactorService.ConsumeChallenge(ctx, req.ChallengeToken, req.Code)
+	if err == nil && consumedID != user.ID {
+		err = ErrInvalidChallenge
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			attempt.Reason = models.LoginReasonInvalidOTP
+			s.recordAttempt(ctx, attempt)
+		}
+		return nil, err
+	}
+	if !user.IsActive {
+		attempt.Reason = models.LoginReasonInactive
+		s.recordAttempt(ctx, attempt)
+		return nil, errors.New("user is inactive")
+	}
+
+	attempt.Success = true
+	attempt.Reason = models.LoginReasonSuccess
+	s.recordAttempt(ctx, attempt)
+
+	return s.startSession(ctx, user, req.IPAddress, req.UserAgent)
+}
+
+// startSession issues the first tokens of a new session and records the device
+func (s *authService) startSession(ctx context.Context, user *models.User, ipAddress, userAgent string) (*dto.LoginResponse, error) {
+	sessionID := uuid.NewString()
+	res, err := s.issueTokens(ctx, user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.Start(ctx, user.ID, sessionID, ipAddress, userAgent, res.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateLastLogin(ctx, user.ID, time.Now()); err != nil {
+		log.Printf("Error updating last login for user %d: %v", user.ID, err)
+	}
+	return res, nil
+}
+
+// authenticate tries each provider in order until one knows the user
+func (s *authService) authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	for _, provider := range s.providers {
+		user, err := provider.Authenticate(ctx, username, password)
+		if errors.Is(err, ErrUnknownUser) {
+			continue
+		}
+		if err != nil {
+			return user, fmt.Errorf("%s: %w", provider.Name(), err)
+		}
+		return user, nil
+	}
+	return nil, ErrUnknownUser
+}
+
+// recordAttempt stores a login attempt; failing to record must not block the login itself
+func (s *authService) recordAttempt(ctx context.Context, attempt *models.LoginAttempt) {
+	if err := s.loginGuard.Record(ctx, attempt); err != nil {
+		log.Printf("Error recording login attempt for %s: %v", attempt.Username, err)
+	}
+}
+
+// Refresh exchanges a refresh token for a new access token and rotates the
+// refresh token. Presenting a token that was already used or revoked revokes
+// its whole family, since that means the token has leaked.
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*dto.LoginResponse, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, repository.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.UsedAt != nil || stored.RevokedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored)
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	consumed, err := s.tokenRepo.MarkRefreshTokenUsed(ctx, stored.ID)
 	if err != nil {
-		return nil, errors.New("invalid username or password")
+		return nil, err
+	}
+	if !consumed {
+		return nil, s.revokeReusedFamily(ctx, stored)
 	}
 
+	user, err := s.userRepo.GetByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
 	if !user.IsActive {
+		if err := s.sessionService.End(ctx, stored.FamilyID, 0, "user inactive"); err != nil {
+			return nil, err
+		}
 		return nil, errors.New("user is inactive")
 	}
 
-	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		log.Printf("Password hash mismatch for user: %s", req.Username)
-		return nil, errors.New("invalid username or password")
+	res, err := s.issueTokens(ctx, user, stored.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionService.Extend(ctx, stored.FamilyID, res.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *authService) revokeReusedFamily(ctx context.Context, stored *models.RefreshToken) error {
+	log.Printf("Refresh token reuse detected for user %d, revoking family %s", stored.UserID, stored.FamilyID)
+	if err := s.sessionService.End(ctx, stored.FamilyID, 0, "refresh token reuse"); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// issueTokens creates an access token and a new refresh token in familyID,
+// which doubles as the session id
+func (s *authService) issueTokens(ctx context.Context, user *models.User, familyID string) (*dto.LoginResponse, error) {
+	enrollRequired := false
+	if s.twoFactorService.IsRequired(user.Role) {
+		enabled, err := s.twoFactorService.IsEnabled(ctx, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		enrollRequired = !enabled
 	}
 
-	token, err := s.GenerateToken(user)
+	token, err := s.generateToken(user, familyID, enrollRequired)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate token: %w", err)
 	}
 
+	refreshToken, err := utils.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	refreshExpiresAt := time.Now().Add(s.config.GetRefreshTokenExpiry())
+	if err := s.tokenRepo.CreateRefreshToken(ctx, &models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: refreshExpiresAt,
+	}); err != nil {
+		return nil, err
+	}
+
 	return &dto.LoginResponse{
-		Token:        token,
-		UserID:       user.ID,
-		Username:     user.Username,
-		DepartmentID: user.DepartmentID,
-		Role:         user.Role,
+		Token:            token,
+		ExpiresIn:        int64(s.config.GetJWTExpiry().Seconds()),
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refreshExpiresAt,
+		UserID:           user.ID,
+		Username:         user.Username,
+		DepartmentID:     user.DepartmentID,
+		Role:             user.Role,
+
+		MustChangePassword:      user.MustChangePassword,
+		TwoFactorEnrollRequired: enrollRequired,
 	}, nil
 }
+
 func (s *authService) ValidateToken(ctx context.Context, tokenString string) (*dto.TokenClaims, error) {
 	claims := &dto.TokenClaims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.config.JWT.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keySet.Keyfunc, jwt.WithValidMethods(s.keySet.ValidMethods()))
 
 	if err != nil {
 		return nil, err
@@ -81,10 +352,31 @@ func (s *authService) ValidateToken(ctx context.Context, tokenString string) (*d
 		return nil, errors.New("invalid token")
 	}
 
+	if claims.ID == "" {
+		return nil, ErrTokenNoID
+	}
+
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := s.tokenRepo.IsRevoked(ctx, claims.ID, claims.UserID, claims.SessionID, issuedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	s.sessionService.Touch(ctx, claims.SessionID)
+
 	return claims, nil
 }
 func (s *authService) GenerateToken(user *models.User) (string, error) {
+	return s.generateToken(user, "", false)
+}
 
+// generateToken signs an access token for sessionID; enrollRequired limits it to 2FA enrolment
+func (s *authService) generateToken(user *models.User, sessionID string, enrollRequired bool) (string, error) {
 	expirationTime := time.Now().Add(s.config.GetJWTExpiry())
 	claims := dto.TokenClaims{
 		UserID:       user.ID,
@@ -92,17 +384,19 @@ func (s *authService) GenerateToken(user *models.User) (string, error) {
 		DepartmentID: user.DepartmentID,
 		Role:         user.Role,
 		Exp:          expirationTime.Unix(),
+		SessionID:    sessionID,
+
+		MustChangePassword:      user.MustChangePassword,
+		TwoFactorEnrollRequired: enrollRequired,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	// Create token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign token with secret
-	tokenString, err := token.SignedString([]byte(s.config.JWT.Secret))
+	// Sign token with the active key
+	tokenString, err := s.keySet.Sign(claims)
 	if err != nil {
 		return "", err
 	}
@@ -110,19 +404,100 @@ func (s *authService) GenerateToken(user *models.User) (string, error) {
 	return tokenString, nil
 }
 
-func (s *authService) Logout(ctx context.Context, token string) error {
-	_, err := s.ValidateToken(ctx, token)
+func (s *authService) Logout(ctx context.Context, token string, refreshToken string) error {
+	claims, err := s.ValidateToken(ctx, token)
 	if err != nil {
 		return errors.New("invalid token")
 	}
 
-	// TODO: Implement token blacklisting or other logout logic
-	// Ví dụ: Lưu token vào danh sách bị chặn
-	// return s.tokenBlacklistRepo.Add(ctx, token)
+	sessionID := claims.SessionID
+	if sessionID == "" && refreshToken != "" {
+		stored, err := s.tokenRepo.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
+		if err == nil && stored.UserID == claims.UserID {
+			sessionID = stored.FamilyID
+		}
+	}
+	if err := s.sessionService.End(ctx, sessionID, claims.UserID, "logout"); err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(s.config.GetJWTExpiry())
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
 
-	return nil
+	return s.tokenRepo.Revoke(ctx, &models.RevokedToken{
+		Scope:     models.RevocationScopeToken,
+		JTI:       claims.ID,
+		UserID:    claims.UserID,
+		Reason:    "logout",
+		RevokedBy: claims.UserID,
+		RevokedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// LogoutAll revokes every token the user currently holds.
+func (s *authService) LogoutAll(ctx context.Context, userID int64) error {
+	return s.RevokeUserTokens(ctx, userID, userID, "logout all sessions")
+}
+
+// RevokeUserTokens revokes every token of userID issued up to now. The entry
+// only has to outlive the longest possible token lifetime.
+func (s *authService) RevokeUserTokens(ctx context.Context, userID int64, revokedBy int64, reason string) error {
+	if userID <= 0 {
+		return ErrInvalidUserID
+	}
+	if _, err := s.userRepo.GetByID(ctx, userID); err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	if err := s.tokenRepo.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.sessionService.RevokeAllForUser(ctx, userID, revokedBy); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return s.tokenRepo.Revoke(ctx, &models.RevokedToken{
+		Scope:     models.RevocationScopeUser,
+		UserID:    userID,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		RevokedAt: now,
+		ExpiresAt: now.Add(s.config.GetJWTExpiry()),
+	})
+}
+
+// PurgeRevokedTokens deletes expired revocation entries, refresh tokens,
+// login challenges and sessions
+func (s *authService) PurgeRevokedTokens(ctx context.Context) (int64, error) {
+	now := time.Now()
+	revoked, err := s.tokenRepo.DeleteExpired(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	refreshed, err := s.tokenRepo.DeleteExpiredRefreshTokens(ctx, now)
+	if err != nil {
+		return revoked, err
+	}
+	challenges, err := s.twoFactorService.PurgeExpiredChallenges(ctx)
+	if err != nil {
+		return revoked + refreshed, err
+	}
+	sessions, err := s.sessionService.Purge(ctx)
+	if err != nil {
+		return revoked + refreshed + challenges, err
+	}
+	return revoked + refreshed + challenges + sessions, nil
 }
 
 func (s *authService) GetProfile(ctx context.Context, userID int64) (*dto.UserDetailRes, error) {
 	return s.userRepo.GetUser(ctx, dto.UserDetailReq{UserID: userID})
 }
+
+// JWKS returns the public verification keys for other services
+func (s *authService) JWKS() dto.JWKSResponse {
+	return s.keySet.JWKS()
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrDepartmentAccessDenied = errors.New("access denied: resource belongs to another department")
+
+// departmentScope limits reports and menus to the caller's department and the
+// reports shared with it. Callers holding PermissionAllDepartments bypass it.
+type departmentScope struct {
+	reportRepo    repository.ReportRepo
+	operationRepo repository.OperationRepository
+	logger        Logger
+}
+
+// canAccessReport reports whether the report is owned by or shared with the caller's department
+func (d *departmentScope) canAccessReport(ctx context.Context, reqCtx RequestContext, report *models.Report) (bool, error) {
+	if reqCtx.AllDepartments {
+		return true, nil
+	}
+	if reqCtx.DepartmentID <= 0 {
+		return false, nil
+	}
+	if strings.TrimSpace(report.DepartmentID) == strconv.FormatInt(reqCtx.DepartmentID, 10) {
+		return true, nil
+	}
+	return d.reportRepo.IsSharedWith(ctx, report.ID, reqCtx.DepartmentID)
+}
+
+// sharedReportIDs returns the set of reports shared with departmentID
+func (d *departmentScope) sharedReportIDs(ctx context.Context, departmentID int64) (map[int64]bool, error) {
+	ids, err := d.reportRepo.GetSharedReportIDs(ctx, departmentID)
+	if err != nil {
+		return nil, err
+	}
+	shared := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		shared[id] = true
+	}
+	return shared, nil
+}
+
+// deny records the refused request as a failed access and returns ErrDepartmentAccessDenied
+func (d *departmentScope) deny(ctx context.Context, reqCtx RequestContext, reportID int64, operationType int, resource string) error {
+	if _, err := d.operationRepo.LogAccess(ctx, &models.AccessLog{
+		UserID:       reqCtx.UserID,
+		DepartmentID: reqCtx.DepartmentID,
+		OperationID:  operationType,
+		AccessTime:   time.Now().UTC(),
+		IPAddress:    reqCtx.IPAddress,
+		ReportID:     reportID,
+		Status:       "failed",
+	}); err != nil {
+		d.logger.Error(ctx, "Failed to log denied access", err, map[string]interface{}{
+			"user_id":   reqCtx.UserID,
+			"report_id": reportID,
+		})
+	}
+
+	d.logger.Warn(ctx, "Department access denied", map[string]interface{}{
+		"user_id":       reqCtx.UserID,
+		"department_id": reqCtx.DepartmentID,
+		"resource":      resource,
+		"report_id":     reportID,
+	})
+	return ErrDepartmentAccessDenied
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"fmt"
+)
+
+// currentExportContext builds the access context of an export made on behalf
+// of userID outside a request, from the user's current account, so that a
+// deactivated user, a revoked permission or a department change takes effect
+// on exports that were set up earlier. Refusals wrap notAllowed.
+func currentExportContext(ctx context.Context, userRepo repository.UserRepo, permissionService PermissionService, userID int64, permission string, notAllowed error) (RequestContext, error) {
+	user, err := userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return RequestContext{}, fmt.Errorf("%w: user %d no longer exists", notAllowed, userID)
+		}
+		return RequestContext{}, err
+	}
+	if !user.IsActive {
+		return RequestContext{}, fmt.Errorf("%w: user %s is inactive", notAllowed, user.Username)
+	}
+
+	permissions, err := permissionService.GetPermissions(ctx, user.Role, user.DepartmentID)
+	if err != nil {
+		return RequestContext{}, err
+	}
+	all := permissions[models.PermissionAll]
+	if !all && !permissions[permission] {
+		return RequestContext{}, fmt.Errorf("%w: user %s lacks %s", notAllowed, user.Username, permission)
+	}
+	return RequestContext{
+		UserID:         user.ID,
+		DepartmentID:   user.DepartmentID,
+		AllDepartments: all || permissions[models.PermissionAllDepartments],
+	}, nil
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidExportFormat = errors.New("invalid export format")
+
+var reportFormatContentTypes = map[string]string{
+	models.ReportFormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	models.ReportFormatCSV:  "text/csv; charset=utf-8",
+	models.ReportFormatTSV:  "text/tab-separated-values; charset=utf-8",
+	models.ReportFormatPDF:  "application/pdf",
+}
+
+// exportOptions are the format specific settings of an export
+type exportOptions struct {
+	delimited utils.DelimitedOptions
+	landscape bool
+}
+
+// exportFormat resolves the requested export format, xlsx by default, and its
+// options. For csv and tsv the delimiter defaults to "," and a tab; "tab" also
+// selects a tab. PDF pages are landscape unless portrait is asked for.
+func exportFormat(req *dto.ReportReq) (string, exportOptions, error) {
+	var options exportOptions
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = models.ReportFormatXLSX
+	}
+	if _, ok := reportFormatContentTypes[format]; !ok {
+		return "", options, fmt.Errorf("%w: unsupported format %q", ErrInvalidExportFormat, req.Format)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(req.Orientation)) {
+	case "", "landscape":
+		options.landscape = true
+	case "portrait":
+	default:
+		return "", options, fmt.Errorf("%w: orientation must be landscape or portrait", ErrInvalidExportFormat)
+	}
+
+	delimited, err := delimitedOptions(format, req)
+	if err != nil {
+		return "", options, err
+	}
+	options.delimited = delimited
+	return format, options, nil
+}
+
+func delimitedOptions(format string, req *dto.ReportReq) (utils.DelimitedOptions, error) {
+	options := utils.DelimitedOptions{Delimiter: ',', DecimalSeparator: ".", BOM: req.BOM}
+	if format == models.ReportFormatTSV {
+		options.Delimiter = '\t'
+	}
+
+	switch req.Delimiter {
+	case "":
+	case "tab", `\t`:
+		options.Delimiter = '\t'
+	default:
+		delimiter, size := utf8.DecodeRuneInString(req.Delimiter)
+		if size != len(req.Delimiter) || delimiter == utf8.RuneError || strings.ContainsRune("\"\r\n", delimiter) {
+			return options, fmt.Errorf("%w: invalid delimiter %q", ErrInvalidExportFormat, req.Delimiter)
+		}
+		options.Delimiter = delimiter
+	}
+	if req.DecimalSeparator != "" {
+		if req.DecimalSeparator != "." && req.DecimalSeparator != "," {
+			return options, fmt.Errorf("%w: decimal separator must be \".\" or \",\"", ErrInvalidExportFormat)
+		}
+		options.DecimalSeparator = req.DecimalSeparator
+	}
+	if (format == models.ReportFormatCSV || format == models.ReportFormatTSV) && options.DecimalSeparator == string(options.Delimiter) {
+		return options, fmt.Errorf("%w: decimal separator must differ from the delimiter", ErrInvalidExportFormat)
+	}
+	return options, nil
+}
+
+// pdfCompany is the configured title block of PDF exports
+func pdfCompany(cfg *config.Config) utils.PDFCompany {
+	return utils.PDFCompany{
+		Name:    cfg.Export.PDF.CompanyName,
+		Address: cfg.Export.PDF.CompanyAddress,
+		Phone:   cfg.Export.PDF.CompanyPhone,
+	}
+}
@@ -0,0 +1,558 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrExportJobCancelled = errors.New("export job was cancelled")
+	ErrExportJobFinished  = errors.New("export job has already finished")
+	ErrExportJobNotReady  = errors.New("export job has no file to download")
+	ErrInvalidExportKind  = errors.New("invalid export kind")
+	// ErrExportOwnerNotAllowed fails jobs whose owner can no longer export
+	ErrExportOwnerNotAllowed = errors.New("export job owner is not allowed to export")
+)
+
+const (
+	DefaultExportJobList = 50
+	MaxExportJobList     = 200
+	// MaxExportJobAttempts is how many times a job that stops responding is
+	// run before it fails
+	MaxExportJobAttempts = 3
+
+	// exportJobHeartbeat is how often a running job reports that it is alive;
+	// jobs silent for exportJobStaleAfter are queued again, up to
+	// MaxExportJobAttempts runs.
+	exportJobHeartbeat  = 15 * time.Second
+	exportJobStaleAfter = 2 * time.Minute
+	exportJobPoll       = 5 * time.Second
+	exportJobSweep      = time.Minute
+)
+
+type exportProgressKey struct{}
+
+// withExportProgress makes report exports run with ctx report their progress to fn
+func withExportProgress(ctx context.Context, fn func(percent int)) context.Context {
+	return context.WithValue(ctx, exportProgressKey{}, fn)
+}
+
+// reportExportProgress tells a background export job how far its export got.
+// Exports served within a request have no listener.
+func reportExportProgress(ctx context.Context, percent int) {
+	if fn, ok := ctx.Value(exportProgressKey{}).(func(int)); ok {
+		fn(percent)
+	}
+}
+
+type (
+	exportJobService struct {
+		jobRepo           repository.ExportJobRepo
+		reportService     ReportService
+		forecastService   ForecastService
+		userRepo          repository.UserRepo
+		permissionService PermissionService
+		logger            Logger
+		workers           int
+		directory         string
+		retention         time.Duration
+		// wake signals idle workers that a job was queued on this instance
+		wake chan struct{}
+
+		mu      sync.Mutex
+		running map[string]context.CancelCauseFunc
+	}
+	ExportJobService interface {
+		Enqueue(ctx context.Context, reqCtx RequestContext, kind string, req *dto.ReportReq) (*dto.ExportJobRes, error)
+		Get(ctx context.Context, id string, userID int64) (*dto.ExportJobRes, error)
+		List(ctx context.Context, userID int64, limit int) ([]dto.ExportJobRes, error)
+		Cancel(ctx context.Context, id string, userID int64) (*dto.ExportJobRes, error)
+		GetFile(ctx context.Context, id string, userID int64) (*models.ExportJob, error)
+		Run(ctx context.Context)
+	}
+)
+
+func NewExportJobService(jobRepo repository.ExportJobRepo, reportService ReportService, forecastService ForecastService, userRepo repository.UserRepo, permissionService PermissionService, logger Logger, config *config.Config) ExportJobService {
+	workers := config.GetExportWorkers()
+	return &exportJobService{
+		jobRepo:           jobRepo,
+		reportService:     reportService,
+		forecastService:   forecastService,
+		userRepo:          userRepo,
+		permissionService: permissionService,
+		logger:            logger,
+		workers:           workers,
+		directory:         config.GetExportDirectory(),
+		retention:         config.GetExportRetention(),
+		wake:              make(chan struct{}, workers),
+		running:           make(map[string]context.CancelCauseFunc),
+	}
+}
+
+// Enqueue stores an export request for the worker pool. Report access is
+// checked now so that the caller gets the error right away.
+func (s *exportJobService) Enqueue(ctx context.Context, reqCtx RequestContext, kind string, req *dto.ReportReq) (*dto.ExportJobRes, error) {
+	switch kind {
+	case models.ExportKindReport:
+		if req.ReportID <= 0 {
+			return nil, ErrInvalidReportID
+		}
+		if _, err := s.reportService.GetParameters(ctx, reqCtx, req.ReportID); err != nil {
+			return nil, err
+		}
+	case models.ExportKindForecast:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExportKind, kind)
+	}
+	if req.FromDate != nil && req.ToDate != nil && req.FromDate.After(*req.ToDate) {
+		return nil, ErrInvalidDateRange
+	}
+	if _, _, err := exportFormat(req); err != nil {
+		return nil, err
+	}
+
+	request, err := json.Marshal(dto.ExportJobRequest{
+		FromDate: req.FromDate,
+		ToDate:   req.ToDate,
+		Params:   req.Params,
+		Filters:  req.Filters,
+		Sort:     req.Sort,
+
+		Format:           req.Format,
+		Delimiter:        req.Delimiter,
+		DecimalSeparator: req.DecimalSeparator,
+		BOM:              req.BOM,
+		Orientation:      req.Orientation,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode export request: %w", err)
+	}
+	job := &models.ExportJob{
+		ID:             uuid.NewString(),
+		Kind:           kind,
+		ReportID:       req.ReportID,
+		Request:        string(request),
+		Status:         models.ExportJobQueued,
+		UserID:         reqCtx.UserID,
+		DepartmentID:   reqCtx.DepartmentID,
+		AllDepartments: reqCtx.AllDepartments,
+		IPAddress:      reqCtx.IPAddress,
+	}
+	if err := s.jobRepo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	s.logger.Info(ctx, "Export job queued", map[string]interface{}{
+		"job_id":    job.ID,
+		"kind":      kind,
+		"report_id": req.ReportID,
+		"user_id":   reqCtx.UserID,
+	})
+	res := s.toExportJobRes(job)
+	return &res, nil
+}
+
+func (s *exportJobService) Get(ctx context.Context, id string, userID int64) (*dto.ExportJobRes, error) {
+	job, err := s.getOwned(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := s.toExportJobRes(job)
+	return &res, nil
+}
+
+func (s *exportJobService) List(ctx context.Context, userID int64, limit int) ([]dto.ExportJobRes, error) {
+	if limit <= 0 {
+		limit = DefaultExportJobList
+	}
+	if limit > MaxExportJobList {
+		limit = MaxExportJobList
+	}
+	jobs, err := s.jobRepo.GetByUser(ctx, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ExportJobRes, 0, len(jobs))
+	for _, job := range jobs {
+		res = append(res, s.toExportJobRes(&job))
+	}
+	return res, nil
+}
+
+// Cancel stops a queued or running job. A job running on another instance
+// stops at its next heartbeat.
+func (s *exportJobService) Cancel(ctx context.Context, id string, userID int64) (*dto.ExportJobRes, error) {
+	cancelled, err := s.jobRepo.Cancel(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		if _, err := s.getOwned(ctx, id, userID); err != nil {
+			return nil, err
+		}
+		return nil, ErrExportJobFinished
+	}
+
+	s.mu.Lock()
+	if cancel, ok := s.running[id]; ok {
+		cancel(ErrExportJobCancelled)
+	}
+	s.mu.Unlock()
+
+	s.logger.Info(ctx, "Export job cancelled", map[string]interface{}{
+		"job_id":  id,
+		"user_id": userID,
+	})
+	return s.Get(ctx, id, userID)
+}
+
+// GetFile returns a finished job whose file can be downloaded
+func (s *exportJobService) GetFile(ctx context.Context, id string, userID int64) (*models.ExportJob, error) {
+	job, err := s.getOwned(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.ExportJobSucceeded || job.FilePath == "" {
+		return nil, ErrExportJobNotReady
+	}
+	return job, nil
+}
+
+// getOwned returns the job when it belongs to userID; other users' jobs are not found
+func (s *exportJobService) getOwned(ctx context.Context, id string, userID int64) (*models.ExportJob, error) {
+	job, err := s.jobRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.UserID != userID {
+		return nil, repository.ErrExportJobNotFound
+	}
+	return job, nil
+}
+
+// Run starts the worker pool and the sweeper that requeues abandoned jobs
+// and removes expired ones. It returns when ctx is done.
+func (s *exportJobService) Run(ctx context.Context) {
+	if err := os.MkdirAll(s.directory, 0o755); err != nil {
+		s.logger.Error(ctx, "Failed to create export directory", err, map[string]interface{}{
+			"directory": s.directory,
+		})
+	}
+	// Jobs interrupted by the previous shutdown become stale right away
+	s.sweep(ctx)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.work(ctx)
+		}()
+	}
+
+	ticker := time.NewTicker(exportJobSweep)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *exportJobService) work(ctx context.Context) {
+	for {
+		job, err := s.jobRepo.ClaimNext(ctx)
+		if err != nil && ctx.Err() == nil {
+			s.logger.Error(ctx, "Failed to claim export job", err, nil)
+		}
+		if job != nil {
+			s.process(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-time.After(exportJobPoll):
+		}
+	}
+}
+
+func (s *exportJobService) sweep(ctx context.Context) {
+	requeued, failed, err := s.jobRepo.RequeueStale(ctx, time.Now().Add(-exportJobStaleAfter), MaxExportJobAttempts)
+	if err != nil {
+		s.logger.Error(ctx, "Failed to requeue stale export jobs", err, nil)
+	}
+	if requeued > 0 {
+		s.logger.Warn(ctx, "Requeued abandoned export jobs", map[string]interface{}{
+			"count": requeued,
+		})
+	}
+	if failed > 0 {
+		s.logger.Warn(ctx, "Failed export jobs abandoned too often", map[string]interface{}{
+			"count":        failed,
+			"max_attempts": MaxExportJobAttempts,
+		})
+	}
+
+	expired, err := s.jobRepo.DeleteFinished(ctx, time.Now().Add(-s.retention))
+	if err != nil {
+		s.logger.Error(ctx, "Failed to purge export jobs", err, nil)
+		return
+	}
+	for _, job := range expired {
+		s.removeFile(ctx, job.FilePath)
+	}
+	if len(expired) > 0 {
+		s.logger.Info(ctx, "Purged expired export jobs", map[string]interface{}{
+			"count": len(expired),
+		})
+	}
+}
+
+// process runs a claimed job and stores its outcome
+func (s *exportJobService) process(ctx context.Context, job *models.ExportJob) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	s.mu.Lock()
+	s.running[job.ID] = cancel
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+
+	var progress atomic.Int32
+	heartbeat := func() {
+		err := s.jobRepo.Heartbeat(jobCtx, job.ID, int(progress.Load()))
+		if errors.Is(err, repository.ErrExportJobNotRunning) {
+			cancel(ErrExportJobCancelled)
+		}
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(exportJobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				heartbeat()
+			}
+		}
+	}()
+
+	exportCtx := withExportProgress(jobCtx, func(percent int) {
+		progress.Store(int32(percent))
+		heartbeat()
+	})
+	file, err := s.export(exportCtx, job)
+	if err == nil {
+		err = s.writeFile(job, file)
+	}
+
+	// Store the outcome even when the server is shutting down
+	storeCtx := context.WithoutCancel(ctx)
+	switch {
+	case errors.Is(context.Cause(jobCtx), ErrExportJobCancelled):
+		s.removeFile(storeCtx, job.FilePath)
+		s.logger.Info(storeCtx, "Export job stopped after cancellation", map[string]interface{}{
+			"job_id": job.ID,
+		})
+		return
+	case err != nil && ctx.Err() != nil:
+		// Interrupted by a shutdown: the next start picks the job up again
+		s.removeFile(storeCtx, job.FilePath)
+		if err := s.jobRepo.Requeue(storeCtx, job.ID); err != nil {
+			s.logger.Error(storeCtx, "Failed to requeue export job", err, map[string]interface{}{
+				"job_id": job.ID,
+			})
+		}
+		return
+	}
+
+	finishedAt := time.Now()
+	job.FinishedAt = &finishedAt
+	if err != nil {
+		job.Status = models.ExportJobFailed
+		job.Error = err.Error()
+		job.FilePath, job.FileName, job.FileSize = "", "", 0
+	} else {
+		job.Status = models.ExportJobSucceeded
+		job.Progress = 100
+	}
+	stored, storeErr := s.jobRepo.Finish(storeCtx, job)
+	if storeErr != nil {
+		s.logger.Error(storeCtx, "Failed to store export job result", storeErr, map[string]interface{}{
+			"job_id": job.ID,
+		})
+	}
+	if !stored {
+		s.removeFile(storeCtx, job.FilePath)
+		return
+	}
+
+	s.logger.Info(storeCtx, "Export job finished", map[string]interface{}{
+		"job_id":    job.ID,
+		"kind":      job.Kind,
+		"report_id": job.ReportID,
+		"status":    job.Status,
+		"file_size": job.FileSize,
+	})
+}
+
+func (s *exportJobService) export(ctx context.Context, job *models.ExportJob) (*dto.ReportFileResponse, error) {
+	var request dto.ExportJobRequest
+	if job.Request != "" {
+		if err := json.Unmarshal([]byte(job.Request), &request); err != nil {
+			return nil, fmt.Errorf("failed to decode export request: %w", err)
+		}
+	}
+	permission := models.PermissionReportExport
+	if job.Kind == models.ExportKindForecast {
+		permission = models.PermissionForecastExport
+	}
+	// Jobs can wait in the queue for long; the owner's access is checked again
+	reqCtx, err := currentExportContext(ctx, s.userRepo, s.permissionService, job.UserID, permission, ErrExportOwnerNotAllowed)
+	if err != nil {
+		return nil, err
+	}
+	reqCtx.IPAddress = job.IPAddress
+	req := &dto.ReportReq{
+		ReportID: job.ReportID,
+		FromDate: request.FromDate,
+		ToDate:   request.ToDate,
+		Params:   request.Params,
+		Filters:  request.Filters,
+		Sort:     request.Sort,
+
+		Format:           request.Format,
+		Delimiter:        request.Delimiter,
+		DecimalSeparator: request.DecimalSeparator,
+		BOM:              request.BOM,
+		Orientation:      request.Orientation,
+	}
+	switch job.Kind {
+	case models.ExportKindReport:
+		return s.reportService.ExportReport(ctx, reqCtx, req, nil)
+	case models.ExportKindForecast:
+		return s.forecastService.ExportReport(ctx, reqCtx, req, nil)
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExportKind, job.Kind)
+	}
+}
+
+// writeFile stores the generated file under the export directory, named by job id
+func (s *exportJobService) writeFile(job *models.ExportJob, file *dto.ReportFileResponse) error {
+	path := filepath.Join(s.directory, job.ID+filepath.Ext(file.FileName))
+	var size int64
+	if file.FilePath != "" {
+		// Gone after a successful move; removes the temporary file otherwise
+		defer os.Remove(file.FilePath)
+		info, err := os.Stat(file.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to read export file: %w", err)
+		}
+		if err := moveFile(file.FilePath, path); err != nil {
+			return fmt.Errorf("failed to store export file: %w", err)
+		}
+		size = info.Size()
+	} else {
+		data, ok := file.FileDetal.([]byte)
+		if !ok {
+			return fmt.Errorf("export returned no file data")
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write export file: %w", err)
+		}
+		size = int64(len(data))
+	}
+	job.FilePath = path
+	job.FileName = file.FileName
+	job.FileSize = size
+	return nil
+}
+
+// moveFile renames src to dst, copying when they are on different file systems
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+func (s *exportJobService) removeFile(ctx context.Context, path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.Warn(ctx, "Failed to remove export file", map[string]interface{}{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+}
+
+func (s *exportJobService) toExportJobRes(job *models.ExportJob) dto.ExportJobRes {
+	res := dto.ExportJobRes{
+		ID:         job.ID,
+		Kind:       job.Kind,
+		ReportID:   job.ReportID,
+		Status:     job.Status,
+		Progress:   job.Progress,
+		Error:      job.Error,
+		FileName:   job.FileName,
+		FileSize:   job.FileSize,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	if job.FinishedAt != nil {
+		expiresAt := job.FinishedAt.Add(s.retention)
+		res.ExpiresAt = &expiresAt
+	}
+	return res
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"errors"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type fakeForecastExportService struct {
+	ForecastService
+	exports []RequestContext
+}
+
+func (s *fakeForecastExportService) ExportReport(_ context.Context, reqCtx RequestContext, _ *dto.ReportReq, _ fiber.Ctx) (*dto.ReportFileResponse, error) {
+	s.exports = append(s.exports, reqCtx)
+	return &dto.ReportFileResponse{FileName: "forecast.csv", FileDetal: []byte("forecast")}, nil
+}
+
+func TestExportJobUsesOwnersCurrentAccess(t *testing.T) {
+	permissions := &fakePermissionService{roles: map[string][]string{
+		models.RoleUser: {models.PermissionReportExport},
+		"planner":       {models.PermissionForecastExport},
+		"viewer":        {models.PermissionReportView},
+	}}
+	// Queued by the owner while in department 1 with every department in scope
+	queued := func(kind string) *models.ExportJob {
+		return &models.ExportJob{ID: "job", Kind: kind, ReportID: 10, UserID: 5, DepartmentID: 1, AllDepartments: true, IPAddress: "10.0.0.1"}
+	}
+
+	tests := []struct {
+		name    string
+		kind    string
+		user    *models.User
+		wantErr bool
+		reqCtx  RequestContext
+	}{
+		{name: "deleted", kind: models.ExportKindReport, wantErr: true},
+		{name: "inactive", kind: models.ExportKindReport, user: &models.User{ID: 5, Username: "jdoe", Role: models.RoleUser}, wantErr: true},
+		{name: "export revoked", kind: models.ExportKindReport, user: &models.User{ID: 5, Username: "jdoe", Role: "viewer", IsActive: true}, wantErr: true},
+		{name: "forecast needs its own permission", kind: models.ExportKindForecast, user: &models.User{ID: 5, Username: "jdoe", Role: models.RoleUser, IsActive: true}, wantErr: true},
+		{
+			name:   "moved department",
+			kind:   models.ExportKindReport,
+			user:   &models.User{ID: 5, Username: "jdoe", Role: models.RoleUser, IsActive: true, DepartmentID: 8},
+			reqCtx: RequestContext{UserID: 5, DepartmentID: 8, IPAddress: "10.0.0.1"},
+		},
+		{
+			name:   "forecast",
+			kind:   models.ExportKindForecast,
+			user:   &models.User{ID: 5, Username: "jdoe", Role: "planner", IsActive: true, DepartmentID: 3},
+			reqCtx: RequestContext{UserID: 5, DepartmentID: 3, IPAddress: "10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := map[string]*models.User{}
+			if tt.user != nil {
+				users[tt.user.Username] = tt.user
+			}
+			reports := &fakeExportService{dir: t.TempDir()}
+			forecasts := &fakeForecastExportService{}
+			s := NewExportJobService(nil, reports, forecasts, &fakeUserRepo{users: users}, permissions, nopLogger{}, &config.Config{}).(*exportJobService)
+
+			_, err := s.export(context.Background(), queued(tt.kind))
+			exported := append([]RequestContext{}, forecasts.exports...)
+			for _, export := range reports.exports {
+				exported = append(exported, export.reqCtx)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrExportOwnerNotAllowed) {
+					t.Errorf("err = %v, want ErrExportOwnerNotAllowed", err)
+				}
+				if len(exported) != 0 {
+					t.Errorf("exported %d times, want none", len(exported))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("export: %v", err)
+			}
+			if len(exported) != 1 || exported[0] != tt.reqCtx {
+				t.Errorf("export contexts = %+v, want %+v", exported, tt.reqCtx)
+			}
+		})
+	}
+}
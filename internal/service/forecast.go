@@ -2,10 +2,13 @@ package service
 
 import (
 	"context"
+	"cqs-kanban/config"
 	"cqs-kanban/internal/dto"
 	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/gofiber/fiber/v3"
@@ -16,6 +19,7 @@ type (
 		forecastRepo  repository.ForecastRepo
 		operationRepo repository.OperationRepository
 		logger        Logger
+		pdfCompany    utils.PDFCompany
 	}
 	ForecastService interface {
 		GetForecast(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) ([]dto.CombinedForecast, error)
@@ -23,8 +27,8 @@ type (
 	}
 )
 
-func NewForecastService(forecastRepo repository.ForecastRepo, operationRepo repository.OperationRepository, logger Logger) ForecastService {
-	return &forecastService{forecastRepo: forecastRepo, operationRepo: operationRepo, logger: logger}
+func NewForecastService(forecastRepo repository.ForecastRepo, operationRepo repository.OperationRepository, logger Logger, config *config.Config) ForecastService {
+	return &forecastService{forecastRepo: forecastRepo, operationRepo: operationRepo, logger: logger, pdfCompany: pdfCompany(config)}
 }
 
 func (s *forecastService) GetForecast(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) ([]dto.CombinedForecast, error) {
@@ -44,6 +48,10 @@ func (s *forecastService) GetForecast(ctx context.Context, reqCtx RequestContext
 }
 
 func (s *forecastService) ExportReport(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) (*dto.ReportFileResponse, error) {
+	format, options, err := exportFormat(req)
+	if err != nil {
+		return nil, err
+	}
 	logID, err := s.logAccess(ctx, reqCtx, req, OperationTypeExport, c)
 	if err != nil {
 		s.logger.Warn(ctx, "Failed to log access", map[string]interface{}{
@@ -62,25 +70,55 @@ func (s *forecastService) ExportReport(ctx context.Context, reqCtx RequestContex
 		s.updateLogStatus(ctx, logID, "failed")
 		return nil, fmt.Errorf("failed to fetch forecast data: %w", err)
 	}
+	reportExportProgress(ctx, 60)
 
 	fromDate := *req.FromDate
 	toDate := *req.ToDate
 
-	fileBytes, err := s.forecastRepo.ExportGroupedForecastToExcel(ctx, forecastData, fromDate, toDate)
+	reportName := "Forecast_Report"
+	res := &dto.ReportFileResponse{
+		ReportName:  reportName,
+		FileName:    fmt.Sprintf("%s_%s_to_%s.%s", reportName, fromDate.Format("2006-01-02"), toDate.Format("2006-01-02"), format),
+		ContentType: reportFormatContentTypes[format],
+	}
+	if format == models.ReportFormatXLSX {
+		res.FileDetal, err = s.forecastRepo.ExportGroupedForecastToExcel(ctx, forecastData, fromDate, toDate)
+	} else {
+		res.FilePath, err = s.writeExportFile(ctx, format, options, forecastData, fromDate, toDate)
+	}
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "failed")
-		return nil, fmt.Errorf("failed to export to excel: %w", err)
+		return nil, fmt.Errorf("failed to export to %s: %w", format, err)
 	}
+	reportExportProgress(ctx, 90)
 
 	s.updateLogStatus(ctx, logID, "success")
 
-	reportName := "Forecast_Report"
-	return &dto.ReportFileResponse{
-		ReportName:  reportName,
-		FileName:    fmt.Sprintf("%s_%s_to_%s.xlsx", reportName, fromDate.Format("2006-01-02"), toDate.Format("2006-01-02")),
-		FileDetal:   fileBytes,
-		GeneratedAt: time.Now(),
-	}, nil
+	res.GeneratedAt = time.Now()
+	return res, nil
+}
+
+// writeExportFile writes the forecast, which GetForecast has loaded in full,
+// into a temporary file and returns its path
+func (s *forecastService) writeExportFile(ctx context.Context, format string, options exportOptions, data []dto.CombinedForecast, fromDate, toDate time.Time) (string, error) {
+	file, err := os.CreateTemp("", "forecast-*."+format)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	if format == models.ReportFormatPDF {
+		err = s.forecastRepo.ExportGroupedForecastToPDF(ctx, file, data, fromDate, toDate,
+			utils.PDFOptions{Landscape: options.landscape, Company: s.pdfCompany})
+	} else {
+		err = s.forecastRepo.ExportGroupedForecastToDelimited(ctx, file, data, options.delimited)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
 }
 func (s *forecastService) normalizeDateRange(req *dto.ReportReq) error {
 	now := time.Now().UTC()
@@ -104,8 +142,12 @@ func (s *forecastService) normalizeDateRange(req *dto.ReportReq) error {
 	return nil
 }
 
+// logAccess records the request; c is nil for background export jobs
 func (s *forecastService) logAccess(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, operationType int, c fiber.Ctx) (int, error) {
-	ipAddress := c.IP()
+	ipAddress := reqCtx.IPAddress
+	if c != nil {
+		ipAddress = c.IP()
+	}
 	accessLog := &models.AccessLog{
 		UserID:       reqCtx.UserID,
 		DepartmentID: reqCtx.DepartmentID,
@@ -136,5 +178,3 @@ func (s *forecastService) updateLogStatus(ctx context.Context, logID int, status
 		})
 	}
 }
-
-
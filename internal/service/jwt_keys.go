@@ -0,0 +1,228 @@
+package service
+
+import (
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// legacyKeyID is the id of the key built from jwt.secret when no key set is configured
+const legacyKeyID = "default"
+
+var (
+	ErrUnknownSigningKey = errors.New("unknown signing key")
+	ErrNoSigningKey      = errors.New("no signing key configured")
+)
+
+type jwtKey struct {
+	id     string
+	method jwt.SigningMethod
+	// signKey is nil for keys that only verify tokens
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// JWTKeySet signs tokens with the active key and verifies tokens signed by
+// any configured key, selected by the kid header.
+type JWTKeySet struct {
+	keys    map[string]*jwtKey
+	signing *jwtKey
+}
+
+func NewJWTKeySet(cfg *config.Config) (*JWTKeySet, error) {
+	set := &JWTKeySet{keys: make(map[string]*jwtKey)}
+
+	if len(cfg.JWT.Keys) == 0 {
+		if cfg.JWT.Secret == "" {
+			return nil, ErrNoSigningKey
+		}
+		key := &jwtKey{
+			id:        legacyKeyID,
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(cfg.JWT.Secret),
+			verifyKey: []byte(cfg.JWT.Secret),
+		}
+		set.keys[key.id] = key
+		set.signing = key
+		return set, nil
+	}
+
+	for _, keyCfg := range cfg.JWT.Keys {
+		key, err := loadJWTKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyCfg.ID, err)
+		}
+		if _, exists := set.keys[key.id]; exists {
+			return nil, fmt.Errorf("duplicate jwt key id %q", key.id)
+		}
+		set.keys[key.id] = key
+	}
+
+	signingID := cfg.JWT.SigningKeyID
+	if signingID == "" && len(cfg.JWT.Keys) == 1 {
+		signingID = cfg.JWT.Keys[0].ID
+	}
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("%w: signing_key_id %q", ErrUnknownSigningKey, signingID)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("jwt key %q has no private key and cannot sign", signingID)
+	}
+	set.signing = signing
+	return set, nil
+}
+
+func loadJWTKey(cfg config.JWTKeyConfig) (*jwtKey, error) {
+	if strings.TrimSpace(cfg.ID) == "" {
+		return nil, errors.New("id is required")
+	}
+	key := &jwtKey{id: cfg.ID}
+
+	switch strings.ToUpper(cfg.Algorithm) {
+	case "HS256":
+		if cfg.Secret == "" {
+			return nil, errors.New("secret is required for HS256")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(cfg.Secret)
+		key.verifyKey = []byte(cfg.Secret)
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key: %w", err)
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		}
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key: %w", err)
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+			key.verifyKey = public
+		}
+	case "EDDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key: %w", err)
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse private key: %w", err)
+			}
+			edPrivate, ok := private.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("private key is not an Ed25519 key")
+			}
+			key.signKey = edPrivate
+			key.verifyKey = edPrivate.Public()
+		}
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key: %w", err)
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+			key.verifyKey = public
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+	return key, nil
+}
+
+// Sign signs claims with the active key and sets the kid header
+func (k *JWTKeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.signing.method, claims)
+	token.Header["kid"] = k.signing.id
+	return token.SignedString(k.signing.signKey)
+}
+
+// Keyfunc picks the verification key by kid and rejects an algorithm that
+// does not match the key. Tokens without kid are checked against the legacy key.
+func (k *JWTKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = legacyKeyID
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSigningKey, kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// ValidMethods lists the algorithms of every configured key
+func (k *JWTKeySet) ValidMethods() []string {
+	seen := make(map[string]bool)
+	methods := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		if alg := key.method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS returns the public keys of the set. HMAC keys are never published.
+func (k *JWTKeySet) JWKS() dto.JWKSResponse {
+	res := dto.JWKSResponse{Keys: make([]dto.JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwk := dto.JWK{
+			Kid: key.id,
+			Use: "sig",
+			Alg: key.method.Alg(),
+		}
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		res.Keys = append(res.Keys, jwk)
+	}
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Kid < res.Keys[j].Kid
+	})
+	return res
+}
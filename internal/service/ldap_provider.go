@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+type ldapAuthProvider struct {
+	config         *config.Config
+	userRepo       repository.UserRepo
+	departmentRepo repository.DepartmentRepo
+	logger         Logger
+}
+
+// NewLDAPAuthProvider authenticates against an LDAP or Active Directory server:
+// it looks the user up with the service account, binds as the user to check
+// the password, then creates or updates the local user from the directory entry.
+func NewLDAPAuthProvider(cfg *config.Config, userRepo repository.UserRepo, departmentRepo repository.DepartmentRepo, logger Logger) AuthProvider {
+	return &ldapAuthProvider{
+		config:         cfg,
+		userRepo:       userRepo,
+		departmentRepo: departmentRepo,
+		logger:         logger,
+	}
+}
+
+func (p *ldapAuthProvider) Name() string {
+	return models.AuthSourceLDAP
+}
+
+func (p *ldapAuthProvider) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which most servers accept
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := p.findUser(conn, username)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap user bind failed: %w", err)
+	}
+
+	return p.provision(ctx, username, entry)
+}
+
+func (p *ldapAuthProvider) dial() (*ldap.Conn, error) {
+	cfg := p.config.Auth.LDAP
+	timeout := p.config.GetLDAPTimeout()
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	conn, err := ldap.DialURL(cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ldap: %w", err)
+	}
+	conn.SetTimeout(timeout)
+
+	if cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap start tls failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (p *ldapAuthProvider) findUser(conn *ldap.Conn, username string) (*ldap.Entry, error) {
+	cfg := p.config.Auth.LDAP
+	if cfg.BindDN != "" {
+		if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, fmt.Errorf("ldap service bind failed: %w", err)
+		}
+	}
+
+	attributes := []string{"dn"}
+	for _, attr := range []string{cfg.Attributes.FullName, cfg.Attributes.Email, cfg.Attributes.Department} {
+		if attr != "" {
+			attributes = append(attributes, attr)
+		}
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(p.config.GetLDAPTimeout().Seconds()), false,
+		fmt.Sprintf(cfg.UserFilter, ldap.EscapeFilter(username)),
+		attributes,
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("ldap search failed: %w", err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, ErrUnknownUser
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("ldap search returned %d entries for %s", len(result.Entries), username)
+	}
+}
+
+// provision creates the local user on first login and refreshes its profile
+// from the directory on every later login. Users of another auth source are
+// left alone, unless linking local users is enabled, and the next provider
+// is tried.
+func (p *ldapAuthProvider) provision(ctx context.Context, username string, entry *ldap.Entry) (*models.User, error) {
+	attrs := p.config.Auth.LDAP.Attributes
+
+	user, err := p.userRepo.GetByUsername(ctx, username)
+	if err != nil {
+		if !errors.Is(err, repository.ErrUserNotFound) {
+			return nil, err
+		}
+
+		// The password is never checked locally; store an unguessable hash
+		random, err := utils.GenerateRandomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		hashed, err := utils.HashPassword(random)
+		if err != nil {
+			return nil, err
+		}
+
+		role := p.config.Auth.LDAP.DefaultRole
+		if role == "" {
+			role = models.RoleUser
+		}
+		user = &models.User{
+			Username: username,
+			Password: hashed,
+			IsActive: true,
+			Role:     role,
+		}
+	} else if user.AuthSource != models.AuthSourceLDAP && !p.config.Auth.LDAP.LinkLocalUsers {
+		// Leave local accounts to their provider rather than take them over
+		p.logger.Warn(ctx, "LDAP login matches a local user; not linking", map[string]interface{}{
+			"username":    username,
+			"auth_source": user.AuthSource,
+		})
+		return nil, ErrUnknownUser
+	}
+
+	user.AuthSource = models.AuthSourceLDAP
+	if attrs.FullName != "" {
+		if fullName := entry.GetAttributeValue(attrs.FullName); fullName != "" {
+			user.FullName = fullName
+		}
+	}
+	if attrs.Email != "" {
+		if email := entry.GetAttributeValue(attrs.Email); email != "" {
+			user.Email = email
+		}
+	}
+	if attrs.Department != "" {
+		if departmentID, ok := p.mapDepartment(ctx, entry.GetAttributeValue(attrs.Department)); ok {
+			user.DepartmentID = departmentID
+		}
+	}
+
+	isNew := user.ID == 0
+	if err := p.userRepo.Save(ctx, user); err != nil {
+		return nil, err
+	}
+	if isNew {
+		p.logger.Info(ctx, "Provisioned user from LDAP", map[string]interface{}{
+			"user_id":  user.ID,
+			"username": username,
+		})
+	}
+	return user, nil
+}
+
+// mapDepartment resolves an LDAP department value to a department ID through
+// auth.ldap.department_map, falling back to the value as a department code.
+func (p *ldapAuthProvider) mapDepartment(ctx context.Context, value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	code := value
+	for ldapValue, departmentCode := range p.config.Auth.LDAP.DepartmentMap {
+		if strings.EqualFold(ldapValue, value) {
+			code = departmentCode
+			break
+		}
+	}
+
+	department, err := p.departmentRepo.GetByCode(ctx, code)
+	if err != nil {
+		p.logger.Warn(ctx, "No department for LDAP value", map[string]interface{}{
+			"value": value,
+			"code":  code,
+		})
+		return 0, false
+	}
+	return department.ID, true
+}
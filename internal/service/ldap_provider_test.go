@@ -0,0 +1,455 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testLDAPBaseDN       = "dc=example,dc=com"
+	testLDAPBindDN       = "cn=svc,dc=example,dc=com"
+	testLDAPBindPassword = "svc-secret"
+)
+
+// ldapStubEntry is a directory entry of ldapStub, found by its uid
+type ldapStubEntry struct {
+	uid        string
+	password   string
+	attributes map[string]string
+}
+
+func (e ldapStubEntry) dn() string {
+	return "uid=" + e.uid + ",ou=people," + testLDAPBaseDN
+}
+
+// ldapStub is an in-process LDAP server answering simple binds and searches
+// with an equality filter on uid. It records the binds and filters it gets.
+type ldapStub struct {
+	listener net.Listener
+	entries  []ldapStubEntry
+
+	mu      sync.Mutex
+	binds   []string
+	filters []string
+}
+
+func newLDAPStub(t *testing.T, entries ...ldapStubEntry) *ldapStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &ldapStub{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+	go stub.serve()
+	return stub
+}
+
+func (s *ldapStub) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStub) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *ldapStub) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := ber.DecodeString(op.Children[1].Data.Bytes())
+			password := ber.DecodeString(op.Children[2].Data.Bytes())
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+			conn.Write(ldapStubResult(id, ldap.ApplicationBindResponse, s.bindResult(dn, password)).Bytes())
+		case ldap.ApplicationSearchRequest:
+			filter := op.Children[6]
+			text, _ := ldap.DecompileFilter(filter)
+			s.mu.Lock()
+			s.filters = append(s.filters, text)
+			s.mu.Unlock()
+			for _, entry := range s.search(filter) {
+				conn.Write(ldapStubEntryPacket(id, entry).Bytes())
+			}
+			conn.Write(ldapStubResult(id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess).Bytes())
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapStub) bindResult(dn, password string) uint16 {
+	if dn == testLDAPBindDN && password == testLDAPBindPassword {
+		return ldap.LDAPResultSuccess
+	}
+	for _, entry := range s.entries {
+		if entry.dn() == dn && entry.password == password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// search matches entries against an equality filter on uid; any other filter,
+// such as the substring filter of an unescaped "*", matches nothing
+func (s *ldapStub) search(filter *ber.Packet) []ldapStubEntry {
+	if filter.Tag != ldap.FilterEqualityMatch || len(filter.Children) != 2 {
+		return nil
+	}
+	if ber.DecodeString(filter.Children[0].Data.Bytes()) != "uid" {
+		return nil
+	}
+	uid := ber.DecodeString(filter.Children[1].Data.Bytes())
+	var res []ldapStubEntry
+	for _, entry := range s.entries {
+		if entry.uid == uid {
+			res = append(res, entry)
+		}
+	}
+	return res
+}
+
+func (s *ldapStub) recorded() (binds, filters []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...), append([]string(nil), s.filters...)
+}
+
+func ldapStubMessage(id int64, op *ber.Packet) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	packet.AppendChild(op)
+	return packet
+}
+
+func ldapStubResult(id int64, tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return ldapStubMessage(id, op)
+}
+
+func ldapStubEntryPacket(id int64, entry ldapStubEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn(), "objectName"))
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, value := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		attribute.AppendChild(values)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return ldapStubMessage(id, op)
+}
+
+type fakeUserRepo struct {
+	repository.UserRepo
+	users map[string]*models.User
+	saved []models.User
+}
+
+func (r *fakeUserRepo) GetByUsername(_ context.Context, username string) (*models.User, error) {
+	user, ok := r.users[username]
+	if !ok {
+		return nil, repository.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+func (r *fakeUserRepo) GetByID(_ context.Context, id int64) (*models.User, error) {
+	for _, user := range r.users {
+		if user.ID == id {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, repository.ErrUserNotFound
+}
+
+func (r *fakeUserRepo) Save(_ context.Context, user *models.User) error {
+	if user.ID == 0 {
+		user.ID = int64(len(r.users) + 100)
+	}
+	if r.users == nil {
+		r.users = make(map[string]*models.User)
+	}
+	copied := *user
+	r.users[user.Username] = &copied
+	r.saved = append(r.saved, copied)
+	return nil
+}
+
+type fakeDepartmentRepo struct {
+	repository.DepartmentRepo
+	departments []models.Department
+}
+
+func (r *fakeDepartmentRepo) GetByCode(_ context.Context, code string) (*models.Department, error) {
+	for _, department := range r.departments {
+		if department.Code == code {
+			return &department, nil
+		}
+	}
+	return nil, errors.New("department not found")
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(context.Context, string, map[string]interface{})        {}
+func (nopLogger) Info(context.Context, string, map[string]interface{})         {}
+func (nopLogger) Error(context.Context, string, error, map[string]interface{}) {}
+func (nopLogger) Warn(context.Context, string, map[string]interface{})         {}
+
+func newTestLDAPProvider(url string, users *fakeUserRepo) AuthProvider {
+	return NewLDAPAuthProvider(testLDAPConfig(url), users, testDepartments(), nopLogger{})
+}
+
+func testLDAPConfig(url string) *config.Config {
+	cfg := &config.Config{}
+	cfg.Auth.LDAP = config.LDAPConfig{
+		URL:          url,
+		BindDN:       testLDAPBindDN,
+		BindPassword: testLDAPBindPassword,
+		BaseDN:       testLDAPBaseDN,
+		UserFilter:   "(uid=%s)",
+		Timeout:      2,
+		DefaultRole:  models.RoleUser,
+		Attributes: config.LDAPAttributes{
+			FullName:   "cn",
+			Email:      "mail",
+			Department: "departmentNumber",
+		},
+		DepartmentMap: map[string]string{"Sales Dept": "SALES"},
+	}
+	return cfg
+}
+
+func testDepartments() *fakeDepartmentRepo {
+	return &fakeDepartmentRepo{departments: []models.Department{{ID: 7, Code: "SALES"}, {ID: 9, Code: "IT"}}}
+}
+
+var testLDAPEntry = ldapStubEntry{
+	uid:      "jdoe",
+	password: "correct horse",
+	attributes: map[string]string{
+		"cn":               "John Doe",
+		"mail":             "jdoe@example.com",
+		"departmentNumber": "sales dept",
+	},
+}
+
+func TestLDAPAuthenticateProvisionsNewUser(t *testing.T) {
+	stub := newLDAPStub(t, testLDAPEntry)
+	users := &fakeUserRepo{}
+	provider := newTestLDAPProvider(stub.url(), users)
+
+	user, err := provider.Authenticate(context.Background(), "jdoe", "correct horse")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if len(users.saved) != 1 {
+		t.Fatalf("saved %d users, want 1", len(users.saved))
+	}
+	saved := users.saved[0]
+	if user.ID == 0 || saved.ID != user.ID {
+		t.Errorf("user ID = %d, saved ID = %d", user.ID, saved.ID)
+	}
+	if saved.Username != "jdoe" || saved.FullName != "John Doe" || saved.Email != "jdoe@example.com" {
+		t.Errorf("saved profile = %q %q %q", saved.Username, saved.FullName, saved.Email)
+	}
+	if saved.DepartmentID != 7 {
+		t.Errorf("department = %d, want 7 from the department map", saved.DepartmentID)
+	}
+	if saved.Role != models.RoleUser || !saved.IsActive || saved.AuthSource != models.AuthSourceLDAP {
+		t.Errorf("role = %q, active = %v, source = %q", saved.Role, saved.IsActive, saved.AuthSource)
+	}
+	if saved.Password == "" || saved.Password == "correct horse" {
+		t.Errorf("password = %q, want a random hash", saved.Password)
+	}
+
+	binds, _ := stub.recorded()
+	if want := []string{testLDAPBindDN, testLDAPEntry.dn()}; strings.Join(binds, "|") != strings.Join(want, "|") {
+		t.Errorf("binds = %q, want %q", binds, want)
+	}
+}
+
+func TestLDAPAuthenticateUpdatesExistingUser(t *testing.T) {
+	stub := newLDAPStub(t, testLDAPEntry)
+	users := &fakeUserRepo{users: map[string]*models.User{
+		"jdoe": {ID: 5, Username: "jdoe", Password: "local-hash", FullName: "J. Doe", Role: models.RoleAdmin, IsActive: true, DepartmentID: 9, AuthSource: models.AuthSourceLDAP},
+	}}
+	provider := newTestLDAPProvider(stub.url(), users)
+
+	user, err := provider.Authenticate(context.Background(), "jdoe", "correct horse")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.ID != 5 || user.Role != models.RoleAdmin || user.Password != "local-hash" {
+		t.Errorf("user = %d %q %q, want the local ID, role and password kept", user.ID, user.Role, user.Password)
+	}
+	if user.FullName != "John Doe" || user.DepartmentID != 7 || user.AuthSource != models.AuthSourceLDAP {
+		t.Errorf("profile = %q %d %q, want it refreshed from the directory", user.FullName, user.DepartmentID, user.AuthSource)
+	}
+}
+
+func TestLDAPAuthenticateLocalUser(t *testing.T) {
+	for _, source := range []string{models.AuthSourceLocal, ""} {
+		t.Run("source "+source, func(t *testing.T) {
+			stub := newLDAPStub(t, testLDAPEntry)
+			users := &fakeUserRepo{users: map[string]*models.User{
+				"jdoe": {ID: 5, Username: "jdoe", Password: "local-hash", FullName: "J. Doe", Role: models.RoleAdmin, IsActive: true, DepartmentID: 9, AuthSource: source},
+			}}
+			provider := newTestLDAPProvider(stub.url(), users)
+
+			// The local provider gets to check the local password instead
+			if _, err := provider.Authenticate(context.Background(), "jdoe", "correct horse"); !errors.Is(err, ErrUnknownUser) {
+				t.Fatalf("err = %v, want ErrUnknownUser", err)
+			}
+			if len(users.saved) != 0 || users.users["jdoe"].AuthSource != source {
+				t.Errorf("local user was changed: saved %d, source %q", len(users.saved), users.users["jdoe"].AuthSource)
+			}
+		})
+	}
+
+	t.Run("linking enabled", func(t *testing.T) {
+		stub := newLDAPStub(t, testLDAPEntry)
+		users := &fakeUserRepo{users: map[string]*models.User{
+			"jdoe": {ID: 5, Username: "jdoe", Password: "local-hash", Role: models.RoleAdmin, IsActive: true, AuthSource: models.AuthSourceLocal},
+		}}
+		cfg := testLDAPConfig(stub.url())
+		cfg.Auth.LDAP.LinkLocalUsers = true
+		provider := NewLDAPAuthProvider(cfg, users, testDepartments(), nopLogger{})
+
+		user, err := provider.Authenticate(context.Background(), "jdoe", "correct horse")
+		if err != nil {
+			t.Fatalf("Authenticate: %v", err)
+		}
+		if user.ID != 5 || user.AuthSource != models.AuthSourceLDAP {
+			t.Errorf("user = %d %q, want the local user linked to LDAP", user.ID, user.AuthSource)
+		}
+	})
+}
+
+func TestLDAPAuthenticateFailures(t *testing.T) {
+	stub := newLDAPStub(t, testLDAPEntry)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     error
+		binds    int
+	}{
+		{name: "wrong password", username: "jdoe", password: "wrong", want: ErrInvalidCredentials, binds: 2},
+		{name: "unknown user", username: "nobody", password: "secret", want: ErrUnknownUser, binds: 1},
+		{name: "empty password", username: "jdoe", password: "", want: ErrInvalidCredentials, binds: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, _ := stub.recorded()
+			users := &fakeUserRepo{}
+			provider := newTestLDAPProvider(stub.url(), users)
+
+			user, err := provider.Authenticate(context.Background(), tt.username, tt.password)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if user != nil || len(users.saved) != 0 {
+				t.Errorf("user = %v, saved = %d, want no user", user, len(users.saved))
+			}
+			if after, _ := stub.recorded(); len(after)-len(before) != tt.binds {
+				t.Errorf("binds = %d, want %d", len(after)-len(before), tt.binds)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateServiceBindFailure(t *testing.T) {
+	stub := newLDAPStub(t, testLDAPEntry)
+	provider := newTestLDAPProvider(stub.url(), &fakeUserRepo{})
+	provider.(*ldapAuthProvider).config.Auth.LDAP.BindPassword = "wrong"
+
+	_, err := provider.Authenticate(context.Background(), "jdoe", "correct horse")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("err = %v, want a service bind error", err)
+	}
+	if !ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		t.Errorf("err = %v, want the server's invalid credentials result", err)
+	}
+}
+
+func TestLDAPAuthenticateEscapesFilter(t *testing.T) {
+	tricky := ldapStubEntry{uid: `a*b(c)\`, password: "secret"}
+	stub := newLDAPStub(t, ldapStubEntry{uid: "ab", password: "secret"}, ldapStubEntry{uid: "axb(c)", password: "secret"}, tricky)
+	users := &fakeUserRepo{}
+	provider := newTestLDAPProvider(stub.url(), users)
+
+	user, err := provider.Authenticate(context.Background(), tricky.uid, "secret")
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if user.Username != tricky.uid {
+		t.Errorf("username = %q, want %q", user.Username, tricky.uid)
+	}
+	_, filters := stub.recorded()
+	if want := `(uid=a\2ab\28c\29\5c)`; len(filters) != 1 || filters[0] != want {
+		t.Errorf("filters = %q, want [%q]", filters, want)
+	}
+	binds, _ := stub.recorded()
+	if binds[len(binds)-1] != tricky.dn() {
+		t.Errorf("bound as %q, want %q", binds[len(binds)-1], tricky.dn())
+	}
+}
+
+func TestLDAPAuthenticateUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	url := "ldap://" + listener.Addr().String()
+	listener.Close()
+
+	users := &fakeUserRepo{}
+	provider := newTestLDAPProvider(url, users)
+
+	user, err := provider.Authenticate(context.Background(), "jdoe", "correct horse")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUnknownUser) {
+		t.Fatalf("err = %v, want a connection error", err)
+	}
+	if !strings.Contains(err.Error(), "failed to connect to ldap") {
+		t.Errorf("err = %v, want it to name the connection failure", err)
+	}
+	if user != nil || len(users.saved) != 0 {
+		t.Errorf("user = %v, saved = %d, want no user", user, len(users.saved))
+	}
+}
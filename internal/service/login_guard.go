@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrAccountLocked = errors.New("account is temporarily locked due to too many failed logins")
+	ErrIPLocked      = errors.New("too many failed logins from this IP address")
+)
+
+type (
+	loginGuard struct {
+		loginAttemptRepo repository.LoginAttemptRepo
+		config           *config.Config
+		logger           Logger
+	}
+	// LoginGuard records login attempts and locks accounts or IP addresses
+	// that exceed the configured number of failures.
+	LoginGuard interface {
+		Check(ctx context.Context, username, ipAddress string) error
+		Record(ctx context.Context, attempt *models.LoginAttempt) error
+	}
+)
+
+func NewLoginGuard(loginAttemptRepo repository.LoginAttemptRepo, config *config.Config, logger Logger) LoginGuard {
+	return &loginGuard{
+		loginAttemptRepo: loginAttemptRepo,
+		config:           config,
+		logger:           logger,
+	}
+}
+
+// Check returns ErrIPLocked or ErrAccountLocked while a lockout is active.
+func (g *loginGuard) Check(ctx context.Context, username, ipAddress string) error {
+	now := time.Now()
+	if ipAddress != "" {
+		lockout, err := g.loginAttemptRepo.GetActiveLockout(ctx, models.LockoutScopeIP, ipAddress, now)
+		if err != nil {
+			return err
+		}
+		if lockout != nil {
+			return fmt.Errorf("%w until %s", ErrIPLocked, lockout.LockedUntil.Format(time.RFC3339))
+		}
+	}
+
+	lockout, err := g.loginAttemptRepo.GetActiveLockout(ctx, models.LockoutScopeAccount, username, now)
+	if err != nil {
+		return err
+	}
+	if lockout != nil {
+		return fmt.Errorf("%w until %s", ErrAccountLocked, lockout.LockedUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// Record stores the attempt and, for a failure, locks the account or IP when
+// it reaches its limit.
+func (g *loginGuard) Record(ctx context.Context, attempt *models.LoginAttempt) error {
+	if attempt.AttemptedAt.IsZero() {
+		attempt.AttemptedAt = time.Now()
+	}
+	if err := g.loginAttemptRepo.Create(ctx, attempt); err != nil {
+		return err
+	}
+	if attempt.Success || attempt.Reason == models.LoginReasonLocked || attempt.Reason == models.LoginReasonOTPRequired {
+		return nil
+	}
+
+	limits := g.config.Security.Login
+	if limits.MaxFailuresPerAccount > 0 && attempt.Username != "" {
+		if err := g.lockIfExceeded(ctx, models.LockoutScopeAccount, attempt.Username, limits.MaxFailuresPerAccount); err != nil {
+			return err
+		}
+	}
+	if limits.MaxFailuresPerIP > 0 && attempt.IPAddress != "" {
+		if err := g.lockIfExceeded(ctx, models.LockoutScopeIP, attempt.IPAddress, limits.MaxFailuresPerIP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *loginGuard) lockIfExceeded(ctx context.Context, scope, target string, limit int) error {
+	now := time.Now()
+	since, err := g.countingSince(ctx, scope, target, now)
+	if err != nil {
+		return err
+	}
+
+	var failures int64
+	if scope == models.LockoutScopeAccount {
+		failures, err = g.loginAttemptRepo.CountFailuresByUsername(ctx, target, since)
+	} else {
+		failures, err = g.loginAttemptRepo.CountFailuresByIP(ctx, target, since)
+	}
+	if err != nil {
+		return err
+	}
+	if failures < int64(limit) {
+		return nil
+	}
+
+	active, err := g.loginAttemptRepo.GetActiveLockout(ctx, scope, target, now)
+	if err != nil {
+		return err
+	}
+	if active != nil {
+		return nil
+	}
+
+	lockout := &models.Lockout{
+		Scope:       scope,
+		Target:      target,
+		FailedCount: failures,
+		LockedAt:    now,
+		LockedUntil: now.Add(g.config.GetLoginLockoutDuration()),
+	}
+	if err := g.loginAttemptRepo.CreateLockout(ctx, lockout); err != nil {
+		return err
+	}
+
+	g.logger.Warn(ctx, "Login lockout applied", map[string]interface{}{
+		"scope":        scope,
+		"target":       target,
+		"failed_count": failures,
+		"locked_until": lockout.LockedUntil,
+	})
+	return nil
+}
+
+// countingSince returns the start of the failure window for target. Failures
+// before the latest lockout (or its manual unlock) and, for accounts, before
+// the last successful login are not counted again.
+func (g *loginGuard) countingSince(ctx context.Context, scope, target string, now time.Time) (time.Time, error) {
+	since := now.Add(-g.config.GetLoginFailureWindow())
+
+	latest, err := g.loginAttemptRepo.GetLatestLockout(ctx, scope, target)
+	if err != nil {
+		return since, err
+	}
+	if latest != nil {
+		if latest.LockedAt.After(since) {
+			since = latest.LockedAt
+		}
+		if latest.UnlockedAt != nil && latest.UnlockedAt.After(since) {
+			since = *latest.UnlockedAt
+		}
+	}
+
+	if scope == models.LockoutScopeAccount {
+		lastSuccess, err := g.loginAttemptRepo.GetLastSuccessAt(ctx, target)
+		if err != nil {
+			return since, err
+		}
+		if lastSuccess != nil && lastSuccess.After(since) {
+			since = *lastSuccess
+		}
+	}
+	return since, nil
+}
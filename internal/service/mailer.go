@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Mail struct {
+	To          []string
+	Subject     string
+	Body        string
+	Attachments []MailAttachment
+}
+
+// MailAttachment is read once, while the mail is sent
+type MailAttachment struct {
+	FileName    string
+	ContentType string
+	Content     io.Reader
+}
+
+type (
+	smtpMailer struct {
+		config  config.SMTPConfig
+		timeout time.Duration
+	}
+	Mailer interface {
+		Send(ctx context.Context, mail Mail) error
+	}
+)
+
+func NewSMTPMailer(config *config.Config) Mailer {
+	return &smtpMailer{
+		config:  config.SMTP,
+		timeout: config.GetSMTPTimeout(),
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, mail Mail) error {
+	if m.config.Host == "" {
+		return fmt.Errorf("smtp host is not configured")
+	}
+	if len(mail.To) == 0 {
+		return fmt.Errorf("mail has no recipients")
+	}
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	dialer := net.Dialer{Timeout: m.timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %w", err)
+	}
+	deadline := time.Now().Add(m.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start smtp session: %w", err)
+	}
+	defer client.Close()
+
+	if m.config.StartTLS {
+		if err := client.StartTLS(&tls.Config{
+			ServerName:         m.config.Host,
+			InsecureSkipVerify: m.config.InsecureSkipVerify,
+		}); err != nil {
+			return fmt.Errorf("smtp starttls failed: %w", err)
+		}
+	}
+	if m.config.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)); err != nil {
+			return fmt.Errorf("smtp authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.config.From); err != nil {
+		return fmt.Errorf("smtp sender rejected: %w", err)
+	}
+	for _, to := range mail.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("smtp recipient %s rejected: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data failed: %w", err)
+	}
+	if err := m.writeMessage(w, mail); err != nil {
+		return fmt.Errorf("failed to write mail: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected mail: %w", err)
+	}
+	return client.Quit()
+}
+
+// writeMessage encodes the mail as multipart/mixed with a quoted-printable
+// text body and base64 attachments, streaming attachments into w.
+func (m *smtpMailer) writeMessage(w io.Writer, mail Mail) error {
+	body := multipart.NewWriter(w)
+	fmt.Fprintf(w, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(w, "To: %s\r\n", strings.Join(mail.To, ", "))
+	fmt.Fprintf(w, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", mail.Subject))
+	fmt.Fprintf(w, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(w, "Message-ID: <%s@%s>\r\n", uuid.NewString(), m.config.Host)
+	fmt.Fprintf(w, "MIME-Version: 1.0\r\n")
+	if _, err := fmt.Fprintf(w, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", body.Boundary()); err != nil {
+		return err
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := body.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(mail.Body)); err != nil {
+		return err
+	}
+	if err := qp.Close(); err != nil {
+		return err
+	}
+
+	for _, attachment := range mail.Attachments {
+		contentType := attachment.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.FileName}))
+		part, err := body.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		lines := &lineWrapper{w: part, width: 76}
+		encoder := base64.NewEncoder(base64.StdEncoding, lines)
+		if _, err := io.Copy(encoder, attachment.Content); err != nil {
+			return fmt.Errorf("failed to attach %s: %w", attachment.FileName, err)
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(part, "\r\n"); err != nil {
+			return err
+		}
+	}
+	return body.Close()
+}
+
+// lineWrapper breaks its output into CRLF terminated lines of width
+// characters, as RFC 2045 requires for base64 bodies.
+type lineWrapper struct {
+	w      io.Writer
+	width  int
+	column int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if l.column == l.width {
+			if _, err := io.WriteString(l.w, "\r\n"); err != nil {
+				return written, err
+			}
+			l.column = 0
+		}
+		n := min(l.width-l.column, len(p))
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		l.column += n
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"cqs-kanban/config"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpStub is an in-process SMTP server that accepts every mail except to
+// the rejected recipients, recording the envelope and message of each.
+type smtpStub struct {
+	listener net.Listener
+	rejected map[string]bool
+
+	mu         sync.Mutex
+	from       string
+	recipients []string
+	data       []byte
+}
+
+func newSMTPStub(t *testing.T, rejected ...string) *smtpStub {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	stub := &smtpStub{listener: listener, rejected: make(map[string]bool)}
+	for _, recipient := range rejected {
+		stub.rejected[recipient] = true
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.handle(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *smtpStub) config() config.SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return config.SMTPConfig{Host: addr.IP.String(), Port: addr.Port, From: "reports@example.com", Timeout: 5}
+}
+
+func (s *smtpStub) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("220 stub ESMTP")
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			text.PrintfLine("250-stub")
+			text.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = smtpStubAddress(arg)
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "RCPT":
+			recipient := smtpStubAddress(arg)
+			if s.rejected[recipient] {
+				text.PrintfLine("550 no such user")
+				continue
+			}
+			s.mu.Lock()
+			s.recipients = append(s.recipients, recipient)
+			s.mu.Unlock()
+			text.PrintfLine("250 OK")
+		case "DATA":
+			text.PrintfLine("354 go ahead")
+			// Read the raw lines; the textproto dot reader would turn CRLF into LF
+			var data []byte
+			for {
+				line, err := text.R.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data = append(data, strings.TrimPrefix(line, ".")...)
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			text.PrintfLine("250 queued")
+		case "QUIT":
+			text.PrintfLine("221 bye")
+			return
+		default:
+			text.PrintfLine("250 OK")
+		}
+	}
+}
+
+// smtpStubAddress takes the address out of "FROM:<a@b> BODY=8BITMIME"
+func smtpStubAddress(arg string) string {
+	start, end := strings.Index(arg, "<"), strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return arg
+	}
+	return arg[start+1 : end]
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	stub := newSMTPStub(t)
+	mailer := NewSMTPMailer(&config.Config{SMTP: stub.config()})
+
+	attachment := bytes.Repeat([]byte("Doanh thu tháng 1;12.500.000\r\n\x00\xff"), 40)
+	err := mailer.Send(context.Background(), Mail{
+		To:      []string{"a@example.com", "b@example.com"},
+		Subject: "Báo cáo doanh thu",
+		Body:    "Xin chào,\r\nBáo cáo được đính kèm.\r\n",
+		Attachments: []MailAttachment{{
+			FileName:    "doanh thu.xlsx",
+			ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			Content:     bytes.NewReader(attachment),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	stub.mu.Lock()
+	from, recipients, data := stub.from, stub.recipients, stub.data
+	stub.mu.Unlock()
+	if from != "reports@example.com" {
+		t.Errorf("MAIL FROM = %q", from)
+	}
+	if strings.Join(recipients, ",") != "a@example.com,b@example.com" {
+		t.Errorf("RCPT TO = %q", recipients)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	if to := msg.Header.Get("To"); to != "a@example.com, b@example.com" {
+		t.Errorf("To = %q", to)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil || subject != "Báo cáo doanh thu" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("Content-Type = %q (%v)", msg.Header.Get("Content-Type"), err)
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	body, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("body part: %v", err)
+	}
+	// The reader removes the quoted-printable encoding of the body
+	text, _ := io.ReadAll(body)
+	if body.Header.Get("Content-Type") != "text/plain; charset=utf-8" || string(text) != "Xin chào,\r\nBáo cáo được đính kèm.\r\n" {
+		t.Errorf("body = %q %q", body.Header.Get("Content-Type"), text)
+	}
+
+	part, err := parts.NextPart()
+	if err != nil {
+		t.Fatalf("attachment part: %v", err)
+	}
+	if part.FileName() != "doanh thu.xlsx" {
+		t.Errorf("attachment name = %q", part.FileName())
+	}
+	if part.Header.Get("Content-Transfer-Encoding") != "base64" {
+		t.Errorf("attachment encoding = %q", part.Header.Get("Content-Transfer-Encoding"))
+	}
+	encoded, _ := io.ReadAll(part)
+	for _, line := range strings.Split(strings.TrimRight(string(encoded), "\r\n"), "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("base64 line of %d characters, want at most 76", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(encoded), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, attachment) {
+		t.Errorf("attachment does not round trip (%v)", err)
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("want two parts, next part err = %v", err)
+	}
+}
+
+func TestSMTPMailerSendRejectedRecipient(t *testing.T) {
+	stub := newSMTPStub(t, "gone@example.com")
+	mailer := NewSMTPMailer(&config.Config{SMTP: stub.config()})
+
+	err := mailer.Send(context.Background(), Mail{To: []string{"a@example.com", "gone@example.com"}, Subject: "s", Body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "gone@example.com") {
+		t.Fatalf("err = %v, want the rejected recipient named", err)
+	}
+	stub.mu.Lock()
+	defer stub.mu.Unlock()
+	if stub.data != nil {
+		t.Errorf("message was sent despite the rejected recipient")
+	}
+}
@@ -12,21 +12,27 @@ import (
 type (
 	menuService struct {
 		menuRepo repository.MenuRepo
+		scope    *departmentScope
 	}
 	MenuService interface {
 		Create(ctx context.Context, req dto.MenuCreateReq) error
-		GetMenu(ctx context.Context, req dto.MenuDetailReq) (*dto.MenuDetailRes, error)
+		GetMenu(ctx context.Context, reqCtx RequestContext, req dto.MenuDetailReq) (*dto.MenuDetailRes, error)
 		Update(ctx context.Context, id int64, req dto.MenuUpdateReq) error
 		Delete(ctx context.Context, id int64) error
-		GetAll(ctx context.Context) ([]dto.MenuDetailRes, error)
-		GetByID(ctx context.Context, id int64) (*dto.MenuDetailRes, error)
+		GetAll(ctx context.Context, reqCtx RequestContext) ([]dto.MenuDetailRes, error)
+		GetByID(ctx context.Context, reqCtx RequestContext, id int64) (*dto.MenuDetailRes, error)
 		GetItemsMenuIDs(ctx context.Context, id int64) ([]dto.MenuDetailItem, error)
 	}
 )
 
-func NewMenuService(menuRepo repository.MenuRepo) MenuService {
+func NewMenuService(menuRepo repository.MenuRepo, reportRepo repository.ReportRepo, operationRepo repository.OperationRepository, logger Logger) MenuService {
 	return &menuService{
 		menuRepo: menuRepo,
+		scope: &departmentScope{
+			reportRepo:    reportRepo,
+			operationRepo: operationRepo,
+			logger:        logger,
+		},
 	}
 }
 func (m *menuService) Create(ctx context.Context, req dto.MenuCreateReq) error {
@@ -62,11 +68,19 @@ func (m *menuService) Create(ctx context.Context, req dto.MenuCreateReq) error {
 	return m.menuRepo.Create(ctx, req)
 }
 
-func (m *menuService) GetMenu(ctx context.Context, req dto.MenuDetailReq) (*dto.MenuDetailRes, error) {
+func (m *menuService) GetMenu(ctx context.Context, reqCtx RequestContext, req dto.MenuDetailReq) (*dto.MenuDetailRes, error) {
 	var menus []models.Menu
 	var err error
 
-	if req.DepartmentID != nil && *req.DepartmentID > 0 {
+	if !reqCtx.AllDepartments {
+		if req.DepartmentID != nil && *req.DepartmentID > 0 && *req.DepartmentID != reqCtx.DepartmentID {
+			return nil, m.scope.deny(ctx, reqCtx, 0, OperationTypeView, "menu")
+		}
+		menus, err = m.scopedMenus(ctx, reqCtx)
+		if err != nil {
+			return nil, err
+		}
+	} else if req.DepartmentID != nil && *req.DepartmentID > 0 {
 		menus, err = m.menuRepo.GetByDepartment(ctx, *req.DepartmentID)
 		if err != nil {
 			return nil, fmt.Errorf("not found department id: %w", err)
@@ -130,8 +144,14 @@ func (m *menuService) Update(ctx context.Context, id int64, req dto.MenuUpdateRe
 func (m *menuService) Delete(ctx context.Context, id int64) error {
 	return m.menuRepo.Delete(ctx, id)
 }
-func (m *menuService) GetAll(ctx context.Context) ([]dto.MenuDetailRes, error) {
-	menus, err := m.menuRepo.GetAll(ctx)
+func (m *menuService) GetAll(ctx context.Context, reqCtx RequestContext) ([]dto.MenuDetailRes, error) {
+	var menus []models.Menu
+	var err error
+	if reqCtx.AllDepartments {
+		menus, err = m.menuRepo.GetAll(ctx)
+	} else {
+		menus, err = m.scopedMenus(ctx, reqCtx)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -177,7 +197,7 @@ func (m *menuService) GetAll(ctx context.Context) ([]dto.MenuDetailRes, error) {
 func (m *menuService) GetItemsMenuIDs(ctx context.Context, id int64) ([]dto.MenuDetailItem, error) {
 	return m.menuRepo.GetItemsMenuIDs(ctx, id)
 }
-func (m *menuService) GetByID(ctx context.Context, id int64) (*dto.MenuDetailRes, error) {
+func (m *menuService) GetByID(ctx context.Context, reqCtx RequestContext, id int64) (*dto.MenuDetailRes, error) {
 	menu, err := m.menuRepo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
@@ -189,6 +209,24 @@ func (m *menuService) GetByID(ctx context.Context, id int64) (*dto.MenuDetailRes
 	if err != nil {
 		return nil, err
 	}
+
+	// Menus of another department are visible only through reports shared with the caller
+	if !reqCtx.AllDepartments && menu.DepartmentID != reqCtx.DepartmentID {
+		shared, err := m.scope.sharedReportIDs(ctx, reqCtx.DepartmentID)
+		if err != nil {
+			return nil, err
+		}
+		visible := make([]dto.MenuDetailItem, 0, len(menuItems))
+		for _, item := range menuItems {
+			if shared[item.ReportID] {
+				visible = append(visible, item)
+			}
+		}
+		if len(visible) == 0 && !(menu.ParentID != 0 && shared[menu.ReportID]) {
+			return nil, m.scope.deny(ctx, reqCtx, menu.ReportID, OperationTypeView, "menu")
+		}
+		menuItems = visible
+	}
 	detail := &dto.MenuDetailRes{
 		Menu: []dto.MenuDetail{
 			{
@@ -206,3 +244,56 @@ func (m *menuService) GetByID(ctx context.Context, id int64) (*dto.MenuDetailRes
 	}
 	return detail, nil
 }
+
+// scopedMenus returns the menus of the caller's department plus the items of
+// other departments that open a report shared with it, together with their parents.
+func (m *menuService) scopedMenus(ctx context.Context, reqCtx RequestContext) ([]models.Menu, error) {
+	if reqCtx.DepartmentID <= 0 {
+		return []models.Menu{}, nil
+	}
+	menus, err := m.menuRepo.GetByDepartment(ctx, reqCtx.DepartmentID)
+	if err != nil {
+		return nil, fmt.Errorf("not found department id: %w", err)
+	}
+
+	shared, err := m.scope.sharedReportIDs(ctx, reqCtx.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(shared) == 0 {
+		return menus, nil
+	}
+	reportIDs := make([]int64, 0, len(shared))
+	for id := range shared {
+		reportIDs = append(reportIDs, id)
+	}
+
+	items, err := m.menuRepo.GetByReportIDs(ctx, reportIDs)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool, len(menus))
+	for _, menu := range menus {
+		seen[menu.ID] = true
+	}
+	var parentIDs []int64
+	for _, item := range items {
+		if seen[item.ID] || item.ParentID == 0 {
+			continue
+		}
+		seen[item.ID] = true
+		menus = append(menus, item)
+		if !seen[item.ParentID] {
+			seen[item.ParentID] = true
+			parentIDs = append(parentIDs, item.ParentID)
+		}
+	}
+	if len(parentIDs) > 0 {
+		parents, err := m.menuRepo.GetByIDs(ctx, parentIDs)
+		if err != nil {
+			return nil, err
+		}
+		menus = append(menus, parents...)
+	}
+	return menus, nil
+}
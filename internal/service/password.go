@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var (
+	ErrPasswordPolicy           = errors.New("password does not meet the password policy")
+	ErrPasswordReused           = errors.New("password was used recently")
+	ErrPasswordMismatch         = errors.New("new password and confirmation do not match")
+	ErrCurrentPasswordIncorrect = errors.New("current password is incorrect")
+	ErrPasswordExpired          = errors.New("temporary password has expired")
+	ErrExternalAccount          = errors.New("password is managed by an external provider")
+)
+
+const (
+	tempPasswordLength = 12
+
+	passwordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordLower   = "abcdefghijkmnpqrstuvwxyz"
+	passwordDigits  = "23456789"
+	passwordSpecial = "!@#$%^&*-_=+?"
+)
+
+type (
+	passwordService struct {
+		userRepo    repository.UserRepo
+		historyRepo repository.PasswordHistoryRepo
+		config      *config.Config
+		logger      Logger
+	}
+	PasswordService interface {
+		Validate(password, username string) error
+		ChangePassword(ctx context.Context, userID int64, req dto.UpdatePasswordRequest) error
+		SetPassword(ctx context.Context, userID int64, password string) error
+		ResetPassword(ctx context.Context, userID int64) (*dto.ResetPasswordResponse, error)
+		RecordHistory(ctx context.Context, userID int64, passwordHash string) error
+	}
+)
+
+func NewPasswordService(userRepo repository.UserRepo, historyRepo repository.PasswordHistoryRepo, config *config.Config, logger Logger) PasswordService {
+	return &passwordService{
+		userRepo:    userRepo,
+		historyRepo: historyRepo,
+		config:      config,
+		logger:      logger,
+	}
+}
+
+// Validate checks password against the configured policy.
+func (s *passwordService) Validate(password, username string) error {
+	policy := s.config.Security.Password
+	var problems []string
+
+	if len([]rune(password)) < policy.MinLength {
+		problems = append(problems, fmt.Sprintf("at least %d characters", policy.MinLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSpecial = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		problems = append(problems, "an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		problems = append(problems, "a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		problems = append(problems, "a digit")
+	}
+	if policy.RequireSpecial && !hasSpecial {
+		problems = append(problems, "a special character")
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: must contain %s", ErrPasswordPolicy, strings.Join(problems, ", "))
+	}
+
+	if username != "" && strings.EqualFold(password, username) {
+		return fmt.Errorf("%w: must not equal the username", ErrPasswordPolicy)
+	}
+	return nil
+}
+
+// ChangePassword is the self-service change: the current password is required.
+func (s *passwordService) ChangePassword(ctx context.Context, userID int64, req dto.UpdatePasswordRequest) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		return ErrExternalAccount
+	}
+	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
+		return ErrCurrentPasswordIncorrect
+	}
+	if req.NewPassword != req.ConfirmPassword {
+		return ErrPasswordMismatch
+	}
+
+	return s.setPassword(ctx, user, req.NewPassword, false, nil)
+}
+
+// SetPassword sets a password chosen by an administrator, subject to the policy.
+func (s *passwordService) SetPassword(ctx context.Context, userID int64, password string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return errors.New("user not found")
+	}
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		return ErrExternalAccount
+	}
+	return s.setPassword(ctx, user, password, false, nil)
+}
+
+// ResetPassword issues a temporary password that is valid for a single login
+// within the configured expiry and must be changed right after.
+func (s *passwordService) ResetPassword(ctx context.Context, userID int64) (*dto.ResetPasswordResponse, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if user.AuthSource != "" && user.AuthSource != models.AuthSourceLocal {
+		return nil, ErrExternalAccount
+	}
+
+	password, err := s.generateTempPassword()
+	if err != nil {
+		return nil, err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing password: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.config.GetTempPasswordExpiry())
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashed, true, &expiresAt); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Temporary password issued", map[string]interface{}{
+		"user_id":    user.ID,
+		"expires_at": expiresAt,
+	})
+	return &dto.ResetPasswordResponse{
+		TemporaryPassword: password,
+		ExpiresAt:         expiresAt,
+	}, nil
+}
+
+// RecordHistory stores passwordHash as the latest password of userID.
+func (s *passwordService) RecordHistory(ctx context.Context, userID int64, passwordHash string) error {
+	keep := s.config.Security.Password.HistorySize
+	if keep <= 0 {
+		return nil
+	}
+	if err := s.historyRepo.Create(ctx, userID, passwordHash); err != nil {
+		return err
+	}
+	return s.historyRepo.Prune(ctx, userID, keep)
+}
+
+func (s *passwordService) setPassword(ctx context.Context, user *models.User, password string, mustChange bool, expiresAt *time.Time) error {
+	if err := s.Validate(password, user.Username); err != nil {
+		return err
+	}
+	if err := s.checkReuse(ctx, user, password); err != nil {
+		return err
+	}
+
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("error hashing password: %w", err)
+	}
+	if err := s.userRepo.UpdatePassword(ctx, user.ID, hashed, mustChange, expiresAt); err != nil {
+		return err
+	}
+	return s.RecordHistory(ctx, user.ID, hashed)
+}
+
+func (s *passwordService) checkReuse(ctx context.Context, user *models.User, password string) error {
+	if utils.CheckPasswordHash(password, user.Password) {
+		return ErrPasswordReused
+	}
+
+	keep := s.config.Security.Password.HistorySize
+	if keep <= 0 {
+		return nil
+	}
+	history, err := s.historyRepo.GetRecent(ctx, user.ID, keep)
+	if err != nil {
+		return err
+	}
+	for _, entry := range history {
+		if utils.CheckPasswordHash(password, entry.PasswordHash) {
+			return fmt.Errorf("%w: choose one not among the last %d", ErrPasswordReused, keep)
+		}
+	}
+	return nil
+}
+
+// generateTempPassword returns a random password containing every character class
+func (s *passwordService) generateTempPassword() (string, error) {
+	length := tempPasswordLength
+	if s.config.Security.Password.MinLength > length {
+		length = s.config.Security.Password.MinLength
+	}
+
+	classes := []string{passwordUpper, passwordLower, passwordDigits, passwordSpecial}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, length)
+	for i := range password {
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// Shuffle so the guaranteed classes are not always at the front
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
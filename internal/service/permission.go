@@ -0,0 +1,258 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrEmptyRole           = errors.New("role cannot be empty")
+	ErrUnknownPermission   = errors.New("unknown permission code")
+	ErrDuplicatePermission = errors.New("role already has this permission")
+)
+
+type permissionCacheEntry struct {
+	permissions map[string]bool
+	expiresAt   time.Time
+}
+
+// PermissionCache keeps resolved permission sets per role and department.
+type PermissionCache struct {
+	mu    sync.RWMutex
+	cache map[string]permissionCacheEntry
+	ttl   time.Duration
+}
+
+func NewPermissionCache(ttl time.Duration) *PermissionCache {
+	return &PermissionCache{
+		cache: make(map[string]permissionCacheEntry),
+		ttl:   ttl,
+	}
+}
+
+func (c *PermissionCache) Get(key string) (map[string]bool, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, exists := c.cache[key]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.permissions, true
+}
+
+func (c *PermissionCache) Set(key string, permissions map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache[key] = permissionCacheEntry{permissions: permissions, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *PermissionCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cache = make(map[string]permissionCacheEntry)
+}
+
+type (
+	permissionService struct {
+		permissionRepo repository.PermissionRepo
+		cache          *PermissionCache
+		logger         Logger
+	}
+	PermissionService interface {
+		SeedDefaults(ctx context.Context) error
+		GetPermissions(ctx context.Context, role string, departmentID int64) (map[string]bool, error)
+		HasPermission(ctx context.Context, role string, departmentID int64, permission string) (bool, error)
+		ListPermissions(ctx context.Context) ([]dto.PermissionRes, error)
+		ListRolePermissions(ctx context.Context, role string) ([]dto.RolePermissionRes, error)
+		GrantRolePermission(ctx context.Context, req dto.RolePermissionCreateReq) (*dto.RolePermissionRes, error)
+		RevokeRolePermission(ctx context.Context, id int64) error
+	}
+)
+
+func NewPermissionService(permissionRepo repository.PermissionRepo, logger Logger) PermissionService {
+	return &permissionService{
+		permissionRepo: permissionRepo,
+		cache:          NewPermissionCache(CacheTTL),
+		logger:         logger,
+	}
+}
+
+// SeedDefaults registers the permission catalogue and grants the default
+// permissions to every role that has no grants yet.
+func (s *permissionService) SeedDefaults(ctx context.Context) error {
+	for code, description := range models.PermissionDescriptions {
+		if err := s.permissionRepo.UpsertPermission(ctx, &models.Permission{
+			Code:        code,
+			Description: description,
+		}); err != nil {
+			return err
+		}
+	}
+
+	for role, codes := range models.DefaultRolePermissions {
+		count, err := s.permissionRepo.CountRolePermissions(ctx, role)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		for _, code := range codes {
+			if err := s.permissionRepo.CreateRolePermission(ctx, &models.RolePermission{
+				Role:           role,
+				PermissionCode: code,
+			}); err != nil {
+				return err
+			}
+		}
+		s.logger.Info(ctx, "Seeded default role permissions", map[string]interface{}{
+			"role":  role,
+			"count": len(codes),
+		})
+	}
+
+	s.cache.Clear()
+	return nil
+}
+
+func (s *permissionService) GetPermissions(ctx context.Context, role string, departmentID int64) (map[string]bool, error) {
+	role = strings.TrimSpace(role)
+	if role == "" {
+		return map[string]bool{}, nil
+	}
+
+	key := fmt.Sprintf("%s_%d", role, departmentID)
+	if permissions, ok := s.cache.Get(key); ok {
+		return permissions, nil
+	}
+
+	codes, err := s.permissionRepo.GetPermissionCodes(ctx, role, departmentID)
+	if err != nil {
+		s.logger.Error(ctx, "Failed to resolve permissions", err, map[string]interface{}{
+			"role":          role,
+			"department_id": departmentID,
+		})
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		permissions[code] = true
+	}
+	s.cache.Set(key, permissions)
+
+	return permissions, nil
+}
+
+func (s *permissionService) HasPermission(ctx context.Context, role string, departmentID int64, permission string) (bool, error) {
+	permissions, err := s.GetPermissions(ctx, role, departmentID)
+	if err != nil {
+		return false, err
+	}
+	return permissions[models.PermissionAll] || permissions[permission], nil
+}
+
+func (s *permissionService) ListPermissions(ctx context.Context) ([]dto.PermissionRes, error) {
+	permissions, err := s.permissionRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.PermissionRes, 0, len(permissions))
+	for _, permission := range permissions {
+		res = append(res, dto.PermissionRes{
+			Code:        permission.Code,
+			Description: permission.Description,
+		})
+	}
+	return res, nil
+}
+
+func (s *permissionService) ListRolePermissions(ctx context.Context, role string) ([]dto.RolePermissionRes, error) {
+	var (
+		rolePermissions []models.RolePermission
+		err             error
+	)
+	if strings.TrimSpace(role) == "" {
+		rolePermissions, err = s.permissionRepo.GetAllRolePermissions(ctx)
+	} else {
+		rolePermissions, err = s.permissionRepo.GetRolePermissions(ctx, role)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.RolePermissionRes, 0, len(rolePermissions))
+	for _, rp := range rolePermissions {
+		res = append(res, toRolePermissionRes(&rp))
+	}
+	return res, nil
+}
+
+func (s *permissionService) GrantRolePermission(ctx context.Context, req dto.RolePermissionCreateReq) (*dto.RolePermissionRes, error) {
+	role := strings.TrimSpace(req.Role)
+	if role == "" {
+		return nil, ErrEmptyRole
+	}
+	if _, known := models.PermissionDescriptions[req.PermissionCode]; !known {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, req.PermissionCode)
+	}
+
+	existing, err := s.permissionRepo.GetRolePermissions(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	for _, rp := range existing {
+		if rp.PermissionCode == req.PermissionCode && rp.DepartmentID == req.DepartmentID {
+			return nil, ErrDuplicatePermission
+		}
+	}
+
+	rolePermission := &models.RolePermission{
+		Role:           role,
+		PermissionCode: req.PermissionCode,
+		DepartmentID:   req.DepartmentID,
+	}
+	if err := s.permissionRepo.CreateRolePermission(ctx, rolePermission); err != nil {
+		return nil, err
+	}
+	s.cache.Clear()
+
+	s.logger.Info(ctx, "Role permission granted", map[string]interface{}{
+		"role":          role,
+		"permission":    req.PermissionCode,
+		"department_id": req.DepartmentID,
+	})
+
+	res := toRolePermissionRes(rolePermission)
+	return &res, nil
+}
+
+func (s *permissionService) RevokeRolePermission(ctx context.Context, id int64) error {
+	if err := s.permissionRepo.DeleteRolePermission(ctx, id); err != nil {
+		return err
+	}
+	s.cache.Clear()
+
+	s.logger.Info(ctx, "Role permission revoked", map[string]interface{}{
+		"id": id,
+	})
+	return nil
+}
+
+func toRolePermissionRes(rp *models.RolePermission) dto.RolePermissionRes {
+	return dto.RolePermissionRes{
+		ID:             rp.ID,
+		Role:           rp.Role,
+		PermissionCode: rp.PermissionCode,
+		DepartmentID:   rp.DepartmentID,
+		CreatedAt:      rp.CreatedAt,
+	}
+}
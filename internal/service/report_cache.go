@@ -0,0 +1,138 @@
+package service
+
+import (
+	"cqs-kanban/internal/dto"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxReportCacheTTL bounds the per-report cache TTL
+	MaxReportCacheTTL = 24 * time.Hour
+	// MaxCachedReportRows keeps large results out of the cache
+	MaxCachedReportRows = 10000
+	// MaxReportCacheEntries bounds the cached results kept per report
+	MaxReportCacheEntries = 100
+)
+
+var ErrInvalidCacheTTL = errors.New("invalid cache ttl")
+
+type reportCacheEntry struct {
+	data      *dto.BaseERP
+	cachedAt  time.Time
+	expiresAt time.Time
+}
+
+// ReportCache keeps ERP query results per report, keyed by the normalized query input
+type ReportCache struct {
+	mu      sync.Mutex
+	entries map[int64]map[string]*reportCacheEntry
+}
+
+func NewReportCache() *ReportCache {
+	return &ReportCache{
+		entries: make(map[int64]map[string]*reportCacheEntry),
+	}
+}
+
+func (c *ReportCache) Get(reportID int64, key string) (*dto.BaseERP, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[reportID][key]
+	if !exists {
+		return nil, time.Time{}, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries[reportID], key)
+		return nil, time.Time{}, false
+	}
+	return entry.data, entry.cachedAt, true
+}
+
+func (c *ReportCache) Set(reportID int64, key string, data *dto.BaseERP, ttl time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entries, exists := c.entries[reportID]
+	if !exists {
+		entries = make(map[string]*reportCacheEntry)
+		c.entries[reportID] = entries
+	}
+	if len(entries) >= MaxReportCacheEntries {
+		c.evict(entries, now)
+	}
+	entries[key] = &reportCacheEntry{
+		data:      data,
+		cachedAt:  now,
+		expiresAt: now.Add(ttl),
+	}
+	return now
+}
+
+// Invalidate drops every cached result of a report
+func (c *ReportCache) Invalidate(reportID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, reportID)
+}
+
+// evict drops expired entries, or the oldest one when none has expired
+func (c *ReportCache) evict(entries map[string]*reportCacheEntry, now time.Time) {
+	var oldestKey string
+	var oldest time.Time
+	for key, entry := range entries {
+		if now.After(entry.expiresAt) {
+			delete(entries, key)
+			continue
+		}
+		if oldestKey == "" || entry.cachedAt.Before(oldest) {
+			oldestKey, oldest = key, entry.cachedAt
+		}
+	}
+	if len(entries) >= MaxReportCacheEntries {
+		delete(entries, oldestKey)
+	}
+}
+
+// reportCacheKey hashes everything that shapes a report result except the
+// query itself, which is covered by invalidating the report on update.
+func reportCacheKey(input dto.BaseERPReq) (string, error) {
+	payload, err := json.Marshal(struct {
+		FromDate time.Time
+		ToDate   time.Time
+		Params   map[string]any
+		GroupBy  []string
+		Sort     string
+		SortDesc bool
+		Filters  []dto.BaseERPFilter
+		Offset   int
+		Limit    int
+	}{
+		FromDate: input.FromDate.UTC(),
+		ToDate:   input.ToDate.UTC(),
+		Params:   input.Params,
+		GroupBy:  input.GroupBy,
+		Sort:     input.Sort,
+		SortDesc: input.SortDesc,
+		Filters:  input.Filters,
+		Offset:   input.Offset,
+		Limit:    input.Limit,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to build cache key: %w", err)
+	}
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func validateCacheTTL(seconds int) error {
+	if seconds < 0 || time.Duration(seconds)*time.Second > MaxReportCacheTTL {
+		return fmt.Errorf("%w: must be between 0 and %d seconds", ErrInvalidCacheTTL, int(MaxReportCacheTTL.Seconds()))
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+var ErrInvalidColumnDefinition = errors.New("invalid report column definition")
+
+const (
+	// maxReportGroupLevels keeps detail rows within Excel's seven outline levels
+	maxReportGroupLevels    = 7
+	maxReportColumnDecimals = 10
+	// maxReportColumnWidth is Excel's widest column, in characters
+	maxReportColumnWidth        = 255
+	maxReportColumnNumberFormat = 100
+)
+
+// validateColumnDefinitions checks report column definitions before they are saved
+func validateColumnDefinitions(columns []*dto.ReportColumn) error {
+	groupLevels := make(map[int]string)
+	for _, column := range columns {
+		if column == nil {
+			return fmt.Errorf("%w: empty column", ErrInvalidColumnDefinition)
+		}
+		if column.Type == "" {
+			column.Type = models.ReportColumnString
+		}
+		switch column.Type {
+		case models.ReportColumnString, models.ReportColumnInt, models.ReportColumnDecimal, models.ReportColumnDate,
+			models.ReportColumnBool, models.ReportColumnCurrency, models.ReportColumnPercent:
+		default:
+			return fmt.Errorf("%w: %s has unknown type %q", ErrInvalidColumnDefinition, column.Code, column.Type)
+		}
+		if column.Decimals != nil && (*column.Decimals < 0 || *column.Decimals > maxReportColumnDecimals) {
+			return fmt.Errorf("%w: %s decimals must be between 0 and %d", ErrInvalidColumnDefinition, column.Code, maxReportColumnDecimals)
+		}
+		if column.Width < 0 || column.Width > maxReportColumnWidth {
+			return fmt.Errorf("%w: %s width must be between 0 and %d", ErrInvalidColumnDefinition, column.Code, maxReportColumnWidth)
+		}
+		column.Align = strings.ToLower(strings.TrimSpace(column.Align))
+		switch column.Align {
+		case "", models.ReportAlignLeft, models.ReportAlignCenter, models.ReportAlignRight:
+		default:
+			return fmt.Errorf("%w: %s has unknown alignment %q", ErrInvalidColumnDefinition, column.Code, column.Align)
+		}
+		column.NumberFormat = strings.TrimSpace(column.NumberFormat)
+		if len(column.NumberFormat) > maxReportColumnNumberFormat {
+			return fmt.Errorf("%w: %s number format is longer than %d characters", ErrInvalidColumnDefinition, column.Code, maxReportColumnNumberFormat)
+		}
+		if err := validateColumnGrouping(column, groupLevels); err != nil {
+			return err
+		}
+	}
+	// Group levels run from 1 without gaps
+	for level := 1; level <= len(groupLevels); level++ {
+		if _, ok := groupLevels[level]; !ok {
+			return fmt.Errorf("%w: group levels must run from 1 to %d", ErrInvalidColumnDefinition, len(groupLevels))
+		}
+	}
+	return nil
+}
+
+// validateColumnGrouping checks the group level and aggregate of a column and
+// records its group level in groupLevels
+func validateColumnGrouping(column *dto.ReportColumn, groupLevels map[int]string) error {
+	if column.GroupLevel < 0 || column.GroupLevel > maxReportGroupLevels {
+		return fmt.Errorf("%w: %s group level must be between 0 and %d", ErrInvalidColumnDefinition, column.Code, maxReportGroupLevels)
+	}
+	if column.GroupLevel > 0 {
+		if other, ok := groupLevels[column.GroupLevel]; ok {
+			return fmt.Errorf("%w: %s and %s have the same group level", ErrInvalidColumnDefinition, other, column.Code)
+		}
+		groupLevels[column.GroupLevel] = column.Code
+	}
+
+	column.Aggregate = strings.ToLower(strings.TrimSpace(column.Aggregate))
+	if column.Aggregate == "" {
+		return nil
+	}
+	if column.GroupLevel > 0 {
+		return fmt.Errorf("%w: %s cannot be both grouped and aggregated", ErrInvalidColumnDefinition, column.Code)
+	}
+	numeric := false
+	switch column.Type {
+	case models.ReportColumnInt, models.ReportColumnDecimal, models.ReportColumnCurrency, models.ReportColumnPercent:
+		numeric = true
+	}
+	switch column.Aggregate {
+	case models.ReportAggregateCount:
+	case models.ReportAggregateSum, models.ReportAggregateAvg:
+		if !numeric {
+			return fmt.Errorf("%w: %s must be numeric to use %s", ErrInvalidColumnDefinition, column.Code, column.Aggregate)
+		}
+	case models.ReportAggregateMin, models.ReportAggregateMax:
+		if !numeric && column.Type != models.ReportColumnDate {
+			return fmt.Errorf("%w: %s must be numeric or a date to use %s", ErrInvalidColumnDefinition, column.Code, column.Aggregate)
+		}
+	default:
+		return fmt.Errorf("%w: %s has unknown aggregate %q", ErrInvalidColumnDefinition, column.Code, column.Aggregate)
+	}
+	return nil
+}
+
+func toReportColumnDTO(col models.ReportColumn) *dto.ReportColumn {
+	return &dto.ReportColumn{
+		ID:           col.ID,
+		ReportID:     col.ReportID,
+		Title:        col.Title,
+		Code:         col.Code,
+		Type:         col.Type,
+		Num:          col.Num,
+		Decimals:     col.Decimals,
+		Width:        col.Width,
+		Align:        col.Align,
+		Hidden:       col.Hidden,
+		NumberFormat: col.NumberFormat,
+		GroupLevel:   col.GroupLevel,
+		Aggregate:    col.Aggregate,
+	}
+}
+
+// formatReportRes applies the column definitions to a report result for JSON:
+// hidden columns are left out, numbers are rounded to the column's decimals
+// and dates are written as YYYY-MM-DD. Reports with group-by columns get
+// their grouped rows instead of Data; the whole result is grouped, and with a
+// page given only that page of the grouped rows is returned. Rows are copied,
+// since the result may be shared with the report cache.
+func formatReportRes(res *dto.ReportRes, page, pageSize int) {
+	columns := repository.VisibleColumns(res.Columns)
+	exportColumns := make([]utils.ExportColumn, len(columns))
+	for i, col := range columns {
+		exportColumns[i] = repository.ReportExportColumn(col)
+	}
+	format := func(row map[string]any) map[string]any {
+		formatted := maps.Clone(row)
+		for _, col := range res.Columns {
+			if col.Hidden {
+				delete(formatted, col.Code)
+			}
+		}
+		for j, col := range columns {
+			if value, ok := row[col.Code]; ok {
+				formatted[col.Code] = jsonValue(exportColumns[j], value)
+			}
+		}
+		return formatted
+	}
+
+	if groupBy, aggregates := repository.ReportGroups(res.Columns); len(groupBy) > 0 {
+		grouped := utils.GroupRows(res.Data, groupBy, aggregates)
+		if page > 0 {
+			total := len(grouped)
+			res.Pagination = &dto.ReportPagination{
+				Page:       page,
+				PageSize:   pageSize,
+				TotalCount: int64(total),
+				TotalPages: (total + pageSize - 1) / pageSize,
+			}
+			start := min((page-1)*pageSize, total)
+			grouped = grouped[start:min(start+pageSize, total)]
+		}
+		res.Rows = make([]dto.ReportRow, len(grouped))
+		for i, row := range grouped {
+			res.Rows[i] = dto.ReportRow{Type: string(row.Kind), Level: row.Level, Count: row.Count, Values: format(row.Values)}
+		}
+		res.Data = nil
+	}
+	if res.Data != nil {
+		data := make([]map[string]any, len(res.Data))
+		for i, row := range res.Data {
+			data[i] = format(row)
+		}
+		res.Data = data
+	}
+	res.Columns = columns
+}
+
+func jsonValue(column utils.ExportColumn, value any) any {
+	switch {
+	case column.Format.IsNumeric():
+		if number, ok := utils.NumberValue(value); ok {
+			digits := column.FractionDigits()
+			// Percent values are fractions; their decimals apply to the percentage
+			if column.Format == utils.FormatPercent {
+				digits += 2
+			}
+			return utils.RoundNumber(number, digits)
+		}
+	case column.Format == utils.FormatDate:
+		if date, ok := utils.DateValue(value); ok {
+			return date.Format("2006-01-02")
+		}
+	}
+	return value
+}
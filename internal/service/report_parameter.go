@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidReportParam     = errors.New("invalid report parameter")
+	ErrInvalidParamDefinition = errors.New("invalid report parameter definition")
+	ErrReportParamNotFound    = errors.New("report parameter not found")
+)
+
+const reportParamDateLayout = "2006-01-02"
+
+var reportParamNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedReportParams are always bound from the report date range
+var reservedReportParams = map[string]bool{
+	"fromdate": true,
+	"todate":   true,
+}
+
+// validateParamDefinitions checks report parameter definitions before they are saved
+func validateParamDefinitions(params []*dto.ReportParameter) error {
+	seen := make(map[string]bool, len(params))
+	for _, param := range params {
+		if param == nil {
+			return fmt.Errorf("%w: empty parameter", ErrInvalidParamDefinition)
+		}
+		param.Name = strings.TrimSpace(param.Name)
+		key := strings.ToLower(param.Name)
+		if !reportParamNamePattern.MatchString(param.Name) {
+			return fmt.Errorf("%w: name %q must be a SQL identifier", ErrInvalidParamDefinition, param.Name)
+		}
+		// Names starting with "__" are bound by the repository for paging and filters
+		if reservedReportParams[key] || strings.HasPrefix(key, "__") {
+			return fmt.Errorf("%w: name %q is reserved", ErrInvalidParamDefinition, param.Name)
+		}
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate name %q", ErrInvalidParamDefinition, param.Name)
+		}
+		seen[key] = true
+
+		if param.Type == "" {
+			param.Type = models.ReportParamString
+		}
+		switch param.Type {
+		case models.ReportParamString, models.ReportParamInt, models.ReportParamDecimal, models.ReportParamDate, models.ReportParamBool:
+		default:
+			return fmt.Errorf("%w: %s has unknown type %q", ErrInvalidParamDefinition, param.Name, param.Type)
+		}
+		if param.LookupSource != "" {
+			if !repository.IsLookupSource(param.LookupSource) {
+				return fmt.Errorf("%w: %s has unknown lookup source %q", ErrInvalidParamDefinition, param.Name, param.LookupSource)
+			}
+			if param.Type != models.ReportParamString {
+				return fmt.Errorf("%w: %s must be a string to use a lookup source", ErrInvalidParamDefinition, param.Name)
+			}
+			if len(param.AllowedValues) > 0 {
+				return fmt.Errorf("%w: %s cannot have both allowed values and a lookup source", ErrInvalidParamDefinition, param.Name)
+			}
+		}
+		for _, value := range param.AllowedValues {
+			if strings.Contains(value, ",") {
+				return fmt.Errorf("%w: %s allowed value %q cannot contain a comma", ErrInvalidParamDefinition, param.Name, value)
+			}
+			if _, err := parseReportParamValue(param.Type, value); err != nil {
+				return fmt.Errorf("%w: %s allowed value %q: %v", ErrInvalidParamDefinition, param.Name, value, err)
+			}
+		}
+		for _, value := range splitParamValues(param.DefaultValue, param.Multiple) {
+			if _, err := parseReportParamValue(param.Type, value); err != nil {
+				return fmt.Errorf("%w: %s default %q: %v", ErrInvalidParamDefinition, param.Name, value, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveReportParams validates the query parameters against the report's
+// definitions and returns them as named SQL arguments. Optional parameters
+// without a value are bound as NULL; multiple parameters bind a list.
+func (s *reportService) resolveReportParams(ctx context.Context, defs []models.ReportParameter, input map[string][]string) (map[string]any, error) {
+	lowered := make(map[string][]string, len(input))
+	for name, values := range input {
+		lowered[strings.ToLower(name)] = append(lowered[strings.ToLower(name)], values...)
+	}
+
+	args := make(map[string]any, len(defs))
+	for _, def := range defs {
+		var values []string
+		for _, raw := range lowered[strings.ToLower(def.Name)] {
+			values = append(values, splitParamValues(raw, def.Multiple)...)
+		}
+		if len(values) == 0 {
+			values = splitParamValues(def.DefaultValue, def.Multiple)
+		}
+		if len(values) == 0 && def.Required {
+			return nil, fmt.Errorf("%w: %s is required", ErrInvalidReportParam, def.Name)
+		}
+		if len(values) > 1 && !def.Multiple {
+			return nil, fmt.Errorf("%w: %s accepts a single value", ErrInvalidReportParam, def.Name)
+		}
+
+		if def.AllowedValues != "" {
+			allowed := make(map[string]bool)
+			for _, value := range strings.Split(def.AllowedValues, ",") {
+				allowed[strings.TrimSpace(value)] = true
+			}
+			for _, value := range values {
+				if !allowed[value] {
+					return nil, fmt.Errorf("%w: %s does not allow %q", ErrInvalidReportParam, def.Name, value)
+				}
+			}
+		}
+		if def.LookupSource != "" && len(values) > 0 {
+			if err := s.checkLookupValues(ctx, def, values); err != nil {
+				return nil, err
+			}
+		}
+
+		parsed := make([]any, 0, len(values))
+		for _, value := range values {
+			v, err := parseReportParamValue(def.Type, value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: %v", ErrInvalidReportParam, def.Name, err)
+			}
+			parsed = append(parsed, v)
+		}
+
+		switch {
+		case def.Multiple:
+			args[def.Name] = parsed
+		case len(parsed) == 1:
+			args[def.Name] = parsed[0]
+		default:
+			args[def.Name] = nil
+		}
+	}
+	return args, nil
+}
+
+// checkLookupValues rejects values that are not codes of the parameter's lookup source
+func (s *reportService) checkLookupValues(ctx context.Context, def models.ReportParameter, values []string) error {
+	found, err := s.copmaRepo.FilterLookupCodes(ctx, def.LookupSource, values)
+	if err != nil {
+		return fmt.Errorf("failed to check %s values: %w", def.Name, err)
+	}
+	known := make(map[string]bool, len(found))
+	for _, code := range found {
+		known[strings.ToUpper(code)] = true
+	}
+	for _, value := range values {
+		if !known[strings.ToUpper(value)] {
+			return fmt.Errorf("%w: %s %q not found in %s", ErrInvalidReportParam, def.Name, value, def.LookupSource)
+		}
+	}
+	return nil
+}
+
+// splitParamValues splits a comma-separated value for multiple parameters and drops blanks
+func splitParamValues(raw string, multiple bool) []string {
+	parts := []string{raw}
+	if multiple {
+		parts = strings.Split(raw, ",")
+	}
+	values := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// parseReportParamValue converts a raw value to the parameter type
+func parseReportParamValue(paramType, raw string) (any, error) {
+	switch paramType {
+	case models.ReportParamInt:
+		return strconv.ParseInt(raw, 10, 64)
+	case models.ReportParamDecimal:
+		return strconv.ParseFloat(raw, 64)
+	case models.ReportParamDate:
+		return time.Parse(reportParamDateLayout, raw)
+	case models.ReportParamBool:
+		return strconv.ParseBool(raw)
+	default:
+		return raw, nil
+	}
+}
+
+func toReportParameterDTO(param models.ReportParameter) *dto.ReportParameter {
+	res := &dto.ReportParameter{
+		ID:           param.ID,
+		ReportID:     param.ReportID,
+		Name:         param.Name,
+		Label:        param.Label,
+		Type:         param.Type,
+		Required:     param.Required,
+		Multiple:     param.Multiple,
+		DefaultValue: param.DefaultValue,
+		LookupSource: param.LookupSource,
+		Num:          param.Num,
+	}
+	if param.AllowedValues != "" {
+		res.AllowedValues = strings.Split(param.AllowedValues, ",")
+	}
+	return res
+}
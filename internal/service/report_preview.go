@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrReportPreviewFailed = errors.New("report query failed")
+
+const (
+	DefaultPreviewRows = 20
+	MaxPreviewRows     = 200
+)
+
+// PreviewReport runs a draft query with a row cap and suggests report columns from its result set
+func (r *reportService) PreviewReport(ctx context.Context, req dto.ReportPreviewReq) (*dto.ReportPreviewRes, error) {
+	if strings.TrimSpace(req.QueryStatement) == "" {
+		return nil, ErrEmptySQLQuery
+	}
+	maxRows := req.MaxRows
+	if maxRows <= 0 {
+		maxRows = DefaultPreviewRows
+	}
+	if maxRows > MaxPreviewRows {
+		maxRows = MaxPreviewRows
+	}
+
+	preview, err := r.runPreview(ctx, req, maxRows)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]dto.ReportPreviewColumn, len(preview.Columns))
+	for i, column := range preview.Columns {
+		columns[i] = dto.ReportPreviewColumn{
+			Name:         column.Name,
+			DatabaseType: column.DatabaseType,
+			Type:         reportColumnType(column.DatabaseType),
+		}
+	}
+	return &dto.ReportPreviewRes{
+		Columns:          columns,
+		SuggestedColumns: suggestReportColumns(preview.Columns),
+		Data:             preview.Data,
+		Truncated:        preview.Truncated,
+	}, nil
+}
+
+func (r *reportService) runPreview(ctx context.Context, req dto.ReportPreviewReq, maxRows int) (*dto.BaseERPPreview, error) {
+	if err := utils.ValidateReadOnlySQL(req.QueryStatement); err != nil {
+		return nil, err
+	}
+	if err := validateParamDefinitions(req.Parameters); err != nil {
+		return nil, err
+	}
+	dates := dto.ReportReq{FromDate: req.FromDate, ToDate: req.ToDate}
+	if err := r.normalizeDateRange(&dates); err != nil {
+		return nil, err
+	}
+	params, err := r.resolveReportParams(ctx, repository.ToParameterModels(0, req.Parameters), req.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeoutCause(ctx, r.queryTimeout(nil), ErrReportQueryTimeout)
+	defer cancel()
+	preview, err := r.baseErpRepo.PreviewBaseERP(ctx, dto.BaseERPReq{
+		SqlQuery: req.QueryStatement,
+		FromDate: *dates.FromDate,
+		ToDate:   *dates.ToDate,
+		Params:   params,
+	}, maxRows)
+	if err != nil {
+		if errors.Is(context.Cause(ctx), ErrReportQueryTimeout) {
+			return nil, ErrReportQueryTimeout
+		}
+		return nil, fmt.Errorf("%w: %v", ErrReportPreviewFailed, err)
+	}
+	return preview, nil
+}
+
+// columnWarnings runs the query for its result set columns only and reports
+// defined column codes the query does not return. Saving never fails on it.
+func (r *reportService) columnWarnings(ctx context.Context, query string, params []*dto.ReportParameter, columns []*dto.ReportColumn) []string {
+	if len(columns) == 0 || strings.TrimSpace(query) == "" {
+		return nil
+	}
+	preview, err := r.runPreview(ctx, dto.ReportPreviewReq{
+		QueryStatement: query,
+		Parameters:     params,
+	}, 0)
+	if err != nil {
+		r.logger.Warn(ctx, "Failed to verify report columns", map[string]interface{}{
+			"error": err.Error(),
+		})
+		return []string{fmt.Sprintf("could not verify columns against the query: %v", err)}
+	}
+
+	returned := make(map[string]bool, len(preview.Columns))
+	for _, column := range preview.Columns {
+		returned[column.Name] = true
+	}
+	var warnings []string
+	for _, column := range columns {
+		if column != nil && !returned[column.Code] {
+			warnings = append(warnings, fmt.Sprintf("column %q is not returned by the query", column.Code))
+		}
+	}
+	return warnings
+}
+
+func suggestReportColumns(columns []dto.BaseERPColumn) []*dto.ReportColumn {
+	res := make([]*dto.ReportColumn, len(columns))
+	for i, column := range columns {
+		res[i] = &dto.ReportColumn{
+			Title: column.Name,
+			Code:  column.Name,
+			Type:  reportColumnType(column.DatabaseType),
+			Num:   int64(i + 1),
+		}
+	}
+	return res
+}
+
+// reportColumnType maps a SQL Server type name to a report column type
+func reportColumnType(databaseType string) string {
+	switch strings.ToUpper(databaseType) {
+	case "INT", "BIGINT", "SMALLINT", "TINYINT":
+		return models.ReportColumnInt
+	case "DECIMAL", "NUMERIC", "MONEY", "SMALLMONEY", "FLOAT", "REAL":
+		return models.ReportColumnDecimal
+	case "DATE", "DATETIME", "DATETIME2", "SMALLDATETIME", "DATETIMEOFFSET":
+		return models.ReportColumnDate
+	case "BIT":
+		return models.ReportColumnBool
+	default:
+		return models.ReportColumnString
+	}
+}
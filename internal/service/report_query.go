@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxReportQueryTimeout bounds the per-report query timeout
+const MaxReportQueryTimeout = 30 * time.Minute
+
+var (
+	ErrReportQueryTimeout   = errors.New("report query timed out")
+	ErrReportQueryKilled    = errors.New("report query was cancelled by an administrator")
+	ErrReportTooManyRows    = errors.New("report returned too many rows")
+	ErrRunningQueryNotFound = errors.New("running report query not found")
+	ErrInvalidQueryTimeout  = errors.New("invalid query timeout")
+)
+
+type runningReportQuery struct {
+	info   dto.RunningReportQuery
+	cancel context.CancelCauseFunc
+}
+
+// ReportQueryTracker keeps the report queries executing on the ERP so that
+// administrators can list and cancel them.
+type ReportQueryTracker struct {
+	mu      sync.Mutex
+	queries map[string]*runningReportQuery
+}
+
+func NewReportQueryTracker() *ReportQueryTracker {
+	return &ReportQueryTracker{
+		queries: make(map[string]*runningReportQuery),
+	}
+}
+
+// Start registers a query and returns its context and a function to call when it ends
+func (t *ReportQueryTracker) Start(ctx context.Context, info dto.RunningReportQuery) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	info.ID = uuid.NewString()
+	info.StartedAt = time.Now()
+
+	t.mu.Lock()
+	t.queries[info.ID] = &runningReportQuery{info: info, cancel: cancel}
+	t.mu.Unlock()
+
+	return ctx, func() {
+		t.mu.Lock()
+		delete(t.queries, info.ID)
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+func (t *ReportQueryTracker) List() []dto.RunningReportQuery {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]dto.RunningReportQuery, 0, len(t.queries))
+	for _, query := range t.queries {
+		info := query.info
+		info.ElapsedSeconds = int64(time.Since(info.StartedAt).Seconds())
+		res = append(res, info)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].StartedAt.Before(res[j].StartedAt)
+	})
+	return res
+}
+
+// Kill cancels a running query; the driver then aborts it on the server
+func (t *ReportQueryTracker) Kill(id string) (dto.RunningReportQuery, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	query, exists := t.queries[id]
+	if !exists {
+		return dto.RunningReportQuery{}, ErrRunningQueryNotFound
+	}
+	query.cancel(ErrReportQueryKilled)
+	return query.info, nil
+}
+
+// ListRunningQueries returns the report queries currently executing on the ERP
+func (s *reportService) ListRunningQueries(ctx context.Context) []dto.RunningReportQuery {
+	return s.queries.List()
+}
+
+// KillQuery cancels a running report query
+func (s *reportService) KillQuery(ctx context.Context, id string, killedBy int64) error {
+	info, err := s.queries.Kill(id)
+	if err != nil {
+		return err
+	}
+	s.logger.Info(ctx, "Report query killed", map[string]interface{}{
+		"query_id":  id,
+		"report_id": info.ReportID,
+		"user_id":   info.UserID,
+		"killed_by": killedBy,
+	})
+	return nil
+}
+
+// runQuery executes a report query with the report's timeout and the row
+// limit, tracked so that it can be killed.
+func (s *reportService) runQuery(ctx context.Context, reqCtx RequestContext, report *models.Report, input dto.BaseERPReq) (*dto.BaseERP, error) {
+	input.MaxRows = s.maxRows
+	var data *dto.BaseERP
+	err := s.trackQuery(ctx, reqCtx, report, func(ctx context.Context) error {
+		var err error
+		data, err = s.baseErpRepo.GetBaseERP(ctx, input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// streamQuery executes a report query like runQuery but hands each row to fn
+// as it is read, so exports never hold the whole result. The timeout covers
+// the time fn takes as well.
+func (s *reportService) streamQuery(ctx context.Context, reqCtx RequestContext, report *models.Report, input dto.BaseERPReq, fn func(row map[string]any) error) error {
+	input.MaxRows = s.maxRows
+	return s.trackQuery(ctx, reqCtx, report, func(ctx context.Context) error {
+		return s.baseErpRepo.EachBaseERP(ctx, input, fn)
+	})
+}
+
+// trackQuery runs query with the report's timeout, registered with the
+// running queries, and reports timeouts, kills and the row limit as such.
+func (s *reportService) trackQuery(ctx context.Context, reqCtx RequestContext, report *models.Report, query func(ctx context.Context) error) error {
+	ctx, done := s.queries.Start(ctx, dto.RunningReportQuery{
+		ReportID:   report.ID,
+		ReportName: report.ReportName,
+		UserID:     reqCtx.UserID,
+	})
+	defer done()
+
+	ctx, cancel := context.WithTimeoutCause(ctx, s.queryTimeout(report), ErrReportQueryTimeout)
+	defer cancel()
+
+	if err := query(ctx); err != nil {
+		// A client disconnect leaves context.Canceled as the cause
+		if cause := context.Cause(ctx); errors.Is(cause, ErrReportQueryTimeout) || errors.Is(cause, ErrReportQueryKilled) {
+			return cause
+		}
+		if errors.Is(err, repository.ErrRowLimitExceeded) {
+			return fmt.Errorf("%w: more than %d rows, narrow the date range or filters", ErrReportTooManyRows, s.maxRows)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *reportService) queryTimeout(report *models.Report) time.Duration {
+	if report != nil && report.QueryTimeoutSeconds > 0 {
+		return time.Duration(report.QueryTimeoutSeconds) * time.Second
+	}
+	return s.defaultTimeout
+}
+
+func validateQueryTimeout(seconds int) error {
+	if seconds < 0 || time.Duration(seconds)*time.Second > MaxReportQueryTimeout {
+		return fmt.Errorf("%w: must be between 0 and %d seconds", ErrInvalidQueryTimeout, int(MaxReportQueryTimeout.Seconds()))
+	}
+	return nil
+}
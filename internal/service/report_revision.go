@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var ErrReportRevisionNotFound = errors.New("report revision not found")
+
+// GetRevisions lists the revisions of a report, newest first
+func (r *reportService) GetRevisions(ctx context.Context, reportID int64) ([]dto.ReportRevisionSummary, error) {
+	revisions, err := r.reportRepo.GetRevisions(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ReportRevisionSummary, len(revisions))
+	for i, revision := range revisions {
+		res[i] = dto.ReportRevisionSummary{
+			Revision:   revision.Revision,
+			ReportName: revision.ReportName,
+			Comment:    revision.Comment,
+			CreatedBy:  revision.CreatedBy,
+			CreatedAt:  revision.CreatedAt,
+		}
+	}
+	return res, nil
+}
+
+func (r *reportService) GetRevision(ctx context.Context, reportID int64, revision int) (*dto.ReportRevisionRes, error) {
+	rev, columns, params, err := r.loadRevision(ctx, reportID, revision)
+	if err != nil {
+		return nil, err
+	}
+	return toReportRevisionRes(rev, columns, params), nil
+}
+
+// DiffRevisions compares two revisions of a report
+func (r *reportService) DiffRevisions(ctx context.Context, reportID int64, from, to int) (*dto.ReportRevisionDiff, error) {
+	fromRev, fromColumns, fromParams, err := r.loadRevision(ctx, reportID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, toColumns, toParams, err := r.loadRevision(ctx, reportID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &dto.ReportRevisionDiff{
+		ReportID:   reportID,
+		From:       from,
+		To:         to,
+		Fields:     []dto.ReportFieldChange{},
+		Query:      diffLines(fromRev.QueryStatement, toRev.QueryStatement),
+		Columns:    []dto.ReportItemChange{},
+		Parameters: []dto.ReportItemChange{},
+	}
+	for _, field := range []struct {
+		name     string
+		from, to any
+	}{
+		{"report_type", fromRev.ReportType, toRev.ReportType},
+		{"report_name", fromRev.ReportName, toRev.ReportName},
+		{"department_id", fromRev.DepartmentID, toRev.DepartmentID},
+		{"cache_ttl_seconds", fromRev.CacheTTLSeconds, toRev.CacheTTLSeconds},
+		{"query_timeout_seconds", fromRev.QueryTimeoutSeconds, toRev.QueryTimeoutSeconds},
+	} {
+		if field.from != field.to {
+			res.Fields = append(res.Fields, dto.ReportFieldChange{Field: field.name, From: field.from, To: field.to})
+		}
+	}
+
+	res.Columns = diffItems(fromColumns, toColumns, func(column models.ReportColumn) string { return column.Code })
+	res.Parameters = diffItems(fromParams, toParams, func(param models.ReportParameter) string { return param.Name })
+	return res, nil
+}
+
+// RestoreRevision makes an old revision the current report definition
+func (r *reportService) RestoreRevision(ctx context.Context, reportID int64, revision int, restoredBy int64, comment string) (*dto.ReportRevisionRes, error) {
+	note := fmt.Sprintf("Restored revision %d", revision)
+	if comment = strings.TrimSpace(comment); comment != "" {
+		note += ": " + comment
+	}
+	restored, err := r.reportRepo.RestoreRevision(ctx, reportID, revision, restoredBy, note)
+	if err != nil {
+		if errors.Is(err, repository.ErrRevisionNotFound) {
+			return nil, ErrReportRevisionNotFound
+		}
+		return nil, err
+	}
+	r.cache.Invalidate(reportID)
+	r.logger.Info(ctx, "Report revision restored", map[string]interface{}{
+		"report_id":    reportID,
+		"revision":     revision,
+		"new_revision": restored.Revision,
+		"restored_by":  restoredBy,
+	})
+	return r.GetRevision(ctx, reportID, restored.Revision)
+}
+
+func (r *reportService) loadRevision(ctx context.Context, reportID int64, revision int) (*models.ReportRevision, []models.ReportColumn, []models.ReportParameter, error) {
+	rev, err := r.reportRepo.GetRevision(ctx, reportID, revision)
+	if err != nil {
+		if errors.Is(err, repository.ErrRevisionNotFound) {
+			return nil, nil, nil, ErrReportRevisionNotFound
+		}
+		return nil, nil, nil, err
+	}
+	var columns []models.ReportColumn
+	if err := json.Unmarshal([]byte(rev.Columns), &columns); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode revision columns: %w", err)
+	}
+	var params []models.ReportParameter
+	if err := json.Unmarshal([]byte(rev.Parameters), &params); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to decode revision parameters: %w", err)
+	}
+	return rev, columns, params, nil
+}
+
+func toReportRevisionRes(rev *models.ReportRevision, columns []models.ReportColumn, params []models.ReportParameter) *dto.ReportRevisionRes {
+	res := &dto.ReportRevisionRes{
+		ReportID:            rev.ReportID,
+		Revision:            rev.Revision,
+		ReportType:          rev.ReportType,
+		ReportName:          rev.ReportName,
+		DepartmentID:        rev.DepartmentID,
+		QueryStatement:      rev.QueryStatement,
+		Columns:             make([]*dto.ReportColumn, len(columns)),
+		Parameters:          make([]*dto.ReportParameter, len(params)),
+		CacheTTLSeconds:     rev.CacheTTLSeconds,
+		QueryTimeoutSeconds: rev.QueryTimeoutSeconds,
+		Comment:             rev.Comment,
+		CreatedBy:           rev.CreatedBy,
+		CreatedAt:           rev.CreatedAt,
+	}
+	for i, col := range columns {
+		res.Columns[i] = toReportColumnDTO(col)
+	}
+	for i, param := range params {
+		res.Parameters[i] = toReportParameterDTO(param)
+	}
+	return res
+}
+
+// diffItems matches items by key and reports additions, removals and changes
+func diffItems[T any](from, to []T, key func(T) string) []dto.ReportItemChange {
+	changes := []dto.ReportItemChange{}
+	old := make(map[string]T, len(from))
+	for _, item := range from {
+		old[key(item)] = item
+	}
+	seen := make(map[string]bool, len(to))
+	for _, item := range to {
+		k := key(item)
+		seen[k] = true
+		prev, exists := old[k]
+		switch {
+		case !exists:
+			changes = append(changes, dto.ReportItemChange{Key: k, Change: "added", To: item})
+		case !reflect.DeepEqual(prev, item):
+			changes = append(changes, dto.ReportItemChange{Key: k, Change: "changed", From: prev, To: item})
+		}
+	}
+	for _, item := range from {
+		if k := key(item); !seen[k] {
+			changes = append(changes, dto.ReportItemChange{Key: k, Change: "removed", From: item})
+		}
+	}
+	return changes
+}
+
+// diffLines is a line diff of two SQL texts based on their longest common subsequence
+func diffLines(from, to string) []dto.ReportDiffLine {
+	a := strings.Split(strings.ReplaceAll(from, "\r\n", "\n"), "\n")
+	b := strings.Split(strings.ReplaceAll(to, "\r\n", "\n"), "\n")
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	lines := make([]dto.ReportDiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, dto.ReportDiffLine{Op: "equal", Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, dto.ReportDiffLine{Op: "delete", Text: a[i]})
+			i++
+		default:
+			lines = append(lines, dto.ReportDiffLine{Op: "insert", Text: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, dto.ReportDiffLine{Op: "delete", Text: a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, dto.ReportDiffLine{Op: "insert", Text: b[j]})
+	}
+	return lines
+}
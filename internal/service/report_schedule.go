@@ -0,0 +1,574 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidReportSchedule = errors.New("invalid report schedule")
+	ErrReportScheduleFailed  = errors.New("report schedule run failed")
+	// ErrScheduleCreatorNotAllowed fails runs of schedules whose creator can
+	// no longer export reports
+	ErrScheduleCreatorNotAllowed = errors.New("schedule creator is not allowed to export reports")
+)
+
+const (
+	DefaultScheduleTimezone = "UTC"
+	MaxScheduleRecipients   = 50
+	DefaultScheduleRuns     = 50
+	MaxScheduleRuns         = 500
+)
+
+// Report schedule date range presets, computed in the schedule's timezone
+const (
+	PresetToday         = "today"
+	PresetYesterday     = "yesterday"
+	PresetWeekToDate    = "week_to_date"
+	PresetPreviousWeek  = "previous_week"
+	PresetMonthToDate   = "month_to_date"
+	PresetPreviousMonth = "previous_month"
+	PresetLast7Days     = "last_7_days"
+	PresetLast30Days    = "last_30_days"
+)
+
+var reportSchedulePresets = map[string]bool{
+	PresetToday:         true,
+	PresetYesterday:     true,
+	PresetWeekToDate:    true,
+	PresetPreviousWeek:  true,
+	PresetMonthToDate:   true,
+	PresetPreviousMonth: true,
+	PresetLast7Days:     true,
+	PresetLast30Days:    true,
+}
+
+type (
+	reportScheduleService struct {
+		scheduleRepo      repository.ReportScheduleRepo
+		reportService     ReportService
+		userRepo          repository.UserRepo
+		permissionService PermissionService
+		mailer            Mailer
+		logger            Logger
+	}
+	ReportScheduleService interface {
+		// Callers see and change only the schedules they created, except admins
+		List(ctx context.Context, reqCtx RequestContext) ([]dto.ReportScheduleRes, error)
+		Get(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportScheduleRes, error)
+		Create(ctx context.Context, reqCtx RequestContext, req dto.ReportScheduleReq) (*dto.ReportScheduleRes, error)
+		Update(ctx context.Context, reqCtx RequestContext, id int64, req dto.ReportScheduleReq) (*dto.ReportScheduleRes, error)
+		Delete(ctx context.Context, reqCtx RequestContext, id int64) error
+		GetRuns(ctx context.Context, reqCtx RequestContext, id int64, limit int) ([]dto.ReportScheduleRunRes, error)
+		RunNow(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportScheduleRunRes, error)
+		RunDue(ctx context.Context) (int, error)
+	}
+)
+
+func NewReportScheduleService(scheduleRepo repository.ReportScheduleRepo, reportService ReportService, userRepo repository.UserRepo, permissionService PermissionService, mailer Mailer, logger Logger) ReportScheduleService {
+	return &reportScheduleService{
+		scheduleRepo:      scheduleRepo,
+		reportService:     reportService,
+		userRepo:          userRepo,
+		permissionService: permissionService,
+		mailer:            mailer,
+		logger:            logger,
+	}
+}
+
+func (s *reportScheduleService) List(ctx context.Context, reqCtx RequestContext) ([]dto.ReportScheduleRes, error) {
+	var (
+		schedules []models.ReportSchedule
+		err       error
+	)
+	if reqCtx.Admin {
+		schedules, err = s.scheduleRepo.GetAll(ctx)
+	} else {
+		schedules, err = s.scheduleRepo.GetByCreator(ctx, reqCtx.UserID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ReportScheduleRes, 0, len(schedules))
+	for _, schedule := range schedules {
+		res = append(res, toReportScheduleRes(&schedule))
+	}
+	return res, nil
+}
+
+func (s *reportScheduleService) Get(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportScheduleRes, error) {
+	schedule, err := s.ownSchedule(ctx, reqCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	res := toReportScheduleRes(schedule)
+	return &res, nil
+}
+
+// ownSchedule gets a schedule created by the caller, or any schedule for
+// admins. Other users' schedules are reported as not found.
+func (s *reportScheduleService) ownSchedule(ctx context.Context, reqCtx RequestContext, id int64) (*models.ReportSchedule, error) {
+	schedule, err := s.scheduleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !reqCtx.Admin && schedule.CreatedBy != reqCtx.UserID {
+		return nil, repository.ErrReportScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// Create validates and stores a schedule. The creator must be able to export
+// the report; each run checks the creator's permissions and department again.
+func (s *reportScheduleService) Create(ctx context.Context, reqCtx RequestContext, req dto.ReportScheduleReq) (*dto.ReportScheduleRes, error) {
+	schedule := &models.ReportSchedule{
+		DepartmentID:   reqCtx.DepartmentID,
+		AllDepartments: reqCtx.AllDepartments,
+		CreatedBy:      reqCtx.UserID,
+	}
+	if err := s.apply(ctx, reqCtx, schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRepo.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Report schedule created", map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"report_id":   schedule.ReportID,
+		"created_by":  reqCtx.UserID,
+	})
+	res := toReportScheduleRes(schedule)
+	return &res, nil
+}
+
+func (s *reportScheduleService) Update(ctx context.Context, reqCtx RequestContext, id int64, req dto.ReportScheduleReq) (*dto.ReportScheduleRes, error) {
+	schedule, err := s.ownSchedule(ctx, reqCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(ctx, reqCtx, schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.scheduleRepo.Update(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Report schedule updated", map[string]interface{}{
+		"schedule_id": id,
+		"updated_by":  reqCtx.UserID,
+	})
+	return s.Get(ctx, reqCtx, id)
+}
+
+func (s *reportScheduleService) Delete(ctx context.Context, reqCtx RequestContext, id int64) error {
+	if _, err := s.ownSchedule(ctx, reqCtx, id); err != nil {
+		return err
+	}
+	if err := s.scheduleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.logger.Info(ctx, "Report schedule deleted", map[string]interface{}{
+		"schedule_id": id,
+		"deleted_by":  reqCtx.UserID,
+	})
+	return nil
+}
+
+func (s *reportScheduleService) GetRuns(ctx context.Context, reqCtx RequestContext, id int64, limit int) ([]dto.ReportScheduleRunRes, error) {
+	if _, err := s.ownSchedule(ctx, reqCtx, id); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultScheduleRuns
+	}
+	if limit > MaxScheduleRuns {
+		limit = MaxScheduleRuns
+	}
+	runs, err := s.scheduleRepo.GetRuns(ctx, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ReportScheduleRunRes, 0, len(runs))
+	for _, run := range runs {
+		res = append(res, toReportScheduleRunRes(&run))
+	}
+	return res, nil
+}
+
+// RunNow runs a schedule immediately, whether or not it is enabled, and
+// leaves its next scheduled run unchanged. A run that fails after it was
+// recorded is returned with its error in the run, not as an error.
+func (s *reportScheduleService) RunNow(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportScheduleRunRes, error) {
+	schedule, err := s.ownSchedule(ctx, reqCtx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info(ctx, "Report schedule run requested", map[string]interface{}{
+		"schedule_id":  id,
+		"requested_by": reqCtx.UserID,
+	})
+	run, err := s.run(ctx, schedule, true)
+	if run == nil {
+		return nil, err
+	}
+	res := toReportScheduleRunRes(run)
+	return &res, nil
+}
+
+// RunDue runs every schedule that is due and returns how many were run.
+// Runs missed while the server was down are not caught up; the schedule
+// runs once and moves on to its next time.
+func (s *reportScheduleService) RunDue(ctx context.Context) (int, error) {
+	now := time.Now()
+	schedules, err := s.scheduleRepo.GetDue(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, schedule := range schedules {
+		if ctx.Err() != nil {
+			break
+		}
+		next, err := nextScheduleRun(schedule.Cron, schedule.Timezone, now)
+		if err != nil {
+			// Stop a schedule that can no longer be parsed instead of retrying it every tick
+			s.logger.Error(ctx, "Invalid report schedule", err, map[string]interface{}{
+				"schedule_id": schedule.ID,
+			})
+			next = nil
+		}
+		claimed, err := s.scheduleRepo.Claim(ctx, schedule.ID, *schedule.NextRunAt, next)
+		if err != nil {
+			return count, err
+		}
+		if !claimed {
+			// Another instance picked it up
+			continue
+		}
+		if _, err := s.run(ctx, &schedule, false); err != nil {
+			s.logger.Warn(ctx, "Scheduled report failed", map[string]interface{}{
+				"schedule_id": schedule.ID,
+				"error":       err.Error(),
+			})
+		}
+		count++
+	}
+	return count, nil
+}
+
+// run exports the report and mails it, recording the outcome in the run
+// history. The run is returned whenever it was recorded, even on failure.
+func (s *reportScheduleService) run(ctx context.Context, schedule *models.ReportSchedule, manual bool) (*models.ReportScheduleRun, error) {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+	}
+	fromDate, toDate, err := presetDateRange(schedule.Preset, time.Now().In(loc))
+	if err != nil {
+		return nil, err
+	}
+
+	run := &models.ReportScheduleRun{
+		ScheduleID: schedule.ID,
+		ReportID:   schedule.ReportID,
+		Status:     models.ReportRunRunning,
+		Manual:     manual,
+		FromDate:   fromDate,
+		ToDate:     toDate,
+		Recipients: schedule.Recipients,
+		StartedAt:  time.Now(),
+	}
+	if err := s.scheduleRepo.CreateRun(ctx, run); err != nil {
+		return nil, err
+	}
+
+	runErr := s.deliver(ctx, schedule, run)
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Status = models.ReportRunSuccess
+	if runErr != nil {
+		run.Status = models.ReportRunFailed
+		run.Error = runErr.Error()
+	}
+	// The outcome is recorded even when ctx was cancelled by a shutdown
+	if err := s.scheduleRepo.FinishRun(context.WithoutCancel(ctx), run); err != nil {
+		s.logger.Error(ctx, "Failed to record report schedule run", err, map[string]interface{}{
+			"schedule_id": schedule.ID,
+			"run_id":      run.ID,
+		})
+	}
+
+	s.logger.Info(ctx, "Report schedule run finished", map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"run_id":      run.ID,
+		"status":      run.Status,
+		"manual":      manual,
+	})
+	if runErr != nil {
+		return run, fmt.Errorf("%w: %v", ErrReportScheduleFailed, runErr)
+	}
+	return run, nil
+}
+
+func (s *reportScheduleService) deliver(ctx context.Context, schedule *models.ReportSchedule, run *models.ReportScheduleRun) error {
+	var params map[string][]string
+	if schedule.Params != "" {
+		if err := json.Unmarshal([]byte(schedule.Params), &params); err != nil {
+			return fmt.Errorf("failed to decode schedule parameters: %w", err)
+		}
+	}
+	reqCtx, err := s.creatorContext(ctx, schedule)
+	if err != nil {
+		return err
+	}
+	file, err := s.reportService.ExportReport(ctx, reqCtx, &dto.ReportReq{
+		ReportID: schedule.ReportID,
+		FromDate: &run.FromDate,
+		ToDate:   &run.ToDate,
+		Params:   params,
+		Format:   schedule.Format,
+	}, nil)
+	if err != nil {
+		return err
+	}
+	run.FileName = file.FileName
+	content, _, err := utils.OpenTempFile(file.FilePath)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return s.mailer.Send(ctx, Mail{
+		To:      schedule.RecipientList(),
+		Subject: fmt.Sprintf("%s (%s - %s)", schedule.Name, run.FromDate.Format("2006-01-02"), run.ToDate.Format("2006-01-02")),
+		Body: fmt.Sprintf("The report %q for %s to %s is attached.\r\n\r\nThis message was sent by the report schedule %q.\r\n",
+			schedule.Name, run.FromDate.Format("2006-01-02"), run.ToDate.Format("2006-01-02"), schedule.Name),
+		Attachments: []MailAttachment{{
+			FileName:    file.FileName,
+			ContentType: reportFormatContentTypes[schedule.Format],
+			Content:     content,
+		}},
+	})
+}
+
+// creatorContext builds the access context of a run from the schedule
+// creator's current account, so a deactivated user, a revoked permission or a
+// department change takes effect on the next run.
+func (s *reportScheduleService) creatorContext(ctx context.Context, schedule *models.ReportSchedule) (RequestContext, error) {
+	return currentExportContext(ctx, s.userRepo, s.permissionService, schedule.CreatedBy, models.PermissionReportExport, ErrScheduleCreatorNotAllowed)
+}
+
+// apply validates req and copies it onto schedule, computing the next run
+func (s *reportScheduleService) apply(ctx context.Context, reqCtx RequestContext, schedule *models.ReportSchedule, req dto.ReportScheduleReq) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name cannot be empty", ErrInvalidReportSchedule)
+	}
+	if req.ReportID <= 0 {
+		return ErrInvalidReportID
+	}
+
+	timezone := strings.TrimSpace(req.Timezone)
+	if timezone == "" {
+		timezone = DefaultScheduleTimezone
+	}
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidReportSchedule, timezone)
+	}
+	cron := strings.TrimSpace(req.Cron)
+	if _, err := utils.ParseCron(cron); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+	}
+	if !reportSchedulePresets[req.Preset] {
+		return fmt.Errorf("%w: unknown preset %q", ErrInvalidReportSchedule, req.Preset)
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if format == "" {
+		format = models.ReportFormatXLSX
+	}
+	if _, ok := reportFormatContentTypes[format]; !ok {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidReportSchedule, req.Format)
+	}
+	recipients, err := parseRecipients(req.Recipients)
+	if err != nil {
+		return err
+	}
+
+	// Only parameters the report defines are accepted; their values are checked on each run
+	defs, err := s.reportService.GetParameters(ctx, reqCtx, req.ReportID)
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(defs))
+	for _, def := range defs {
+		known[strings.ToLower(def.Name)] = true
+	}
+	for name := range req.Params {
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("%w: unknown parameter %q", ErrInvalidReportParam, name)
+		}
+	}
+	params := ""
+	if len(req.Params) > 0 {
+		encoded, err := json.Marshal(req.Params)
+		if err != nil {
+			return fmt.Errorf("failed to encode schedule parameters: %w", err)
+		}
+		params = string(encoded)
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	var next *time.Time
+	if enabled {
+		if next, err = nextScheduleRun(cron, timezone, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	schedule.ReportID = req.ReportID
+	schedule.Name = name
+	schedule.Cron = cron
+	schedule.Timezone = timezone
+	schedule.Preset = req.Preset
+	schedule.Params = params
+	schedule.Format = format
+	schedule.Recipients = strings.Join(recipients, ",")
+	schedule.Enabled = enabled
+	schedule.NextRunAt = next
+	return nil
+}
+
+func parseRecipients(input []string) ([]string, error) {
+	recipients := make([]string, 0, len(input))
+	seen := make(map[string]bool, len(input))
+	for _, raw := range input {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid recipient %q", ErrInvalidReportSchedule, raw)
+		}
+		key := strings.ToLower(addr.Address)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		recipients = append(recipients, addr.Address)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: at least one recipient is required", ErrInvalidReportSchedule)
+	}
+	if len(recipients) > MaxScheduleRecipients {
+		return nil, fmt.Errorf("%w: at most %d recipients allowed", ErrInvalidReportSchedule, MaxScheduleRecipients)
+	}
+	return recipients, nil
+}
+
+// nextScheduleRun returns the first time after now that cron matches in timezone
+func nextScheduleRun(cron, timezone string, now time.Time) (*time.Time, error) {
+	schedule, err := utils.ParseCron(cron)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReportSchedule, err)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidReportSchedule, timezone)
+	}
+	next := schedule.Next(now.In(loc))
+	if next.IsZero() {
+		return nil, fmt.Errorf("%w: cron %q never matches", ErrInvalidReportSchedule, cron)
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// presetDateRange returns the dates covered by preset as seen at now. The
+// calendar days are taken in now's location but returned as the same wall
+// clock in UTC, like dates sent to the report API. The range ends one second
+// before midnight so it also works with SQL datetime, which rounds milliseconds.
+func presetDateRange(preset string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	// Weeks start on Monday
+	weekStart := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	monthStart := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var from, until time.Time
+	switch preset {
+	case PresetToday:
+		from, until = today, today.AddDate(0, 0, 1)
+	case PresetYesterday:
+		from, until = today.AddDate(0, 0, -1), today
+	case PresetWeekToDate:
+		from, until = weekStart, today.AddDate(0, 0, 1)
+	case PresetPreviousWeek:
+		from, until = weekStart.AddDate(0, 0, -7), weekStart
+	case PresetMonthToDate:
+		from, until = monthStart, today.AddDate(0, 0, 1)
+	case PresetPreviousMonth:
+		from, until = monthStart.AddDate(0, -1, 0), monthStart
+	case PresetLast7Days:
+		from, until = today.AddDate(0, 0, -7), today
+	case PresetLast30Days:
+		from, until = today.AddDate(0, 0, -30), today
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: unknown preset %q", ErrInvalidReportSchedule, preset)
+	}
+	return from, until.Add(-time.Second), nil
+}
+
+func toReportScheduleRes(schedule *models.ReportSchedule) dto.ReportScheduleRes {
+	params := map[string][]string{}
+	if schedule.Params != "" {
+		// A schedule with unreadable parameters fails on its next run and shows none here
+		_ = json.Unmarshal([]byte(schedule.Params), &params)
+	}
+	return dto.ReportScheduleRes{
+		ID:         schedule.ID,
+		ReportID:   schedule.ReportID,
+		Name:       schedule.Name,
+		Cron:       schedule.Cron,
+		Timezone:   schedule.Timezone,
+		Preset:     schedule.Preset,
+		Params:     params,
+		Format:     schedule.Format,
+		Recipients: schedule.RecipientList(),
+		Enabled:    schedule.Enabled,
+		NextRunAt:  schedule.NextRunAt,
+		LastRunAt:  schedule.LastRunAt,
+		CreatedBy:  schedule.CreatedBy,
+		CreatedAt:  schedule.CreatedAt,
+		UpdatedAt:  schedule.UpdatedAt,
+	}
+}
+
+func toReportScheduleRunRes(run *models.ReportScheduleRun) dto.ReportScheduleRunRes {
+	return dto.ReportScheduleRunRes{
+		ID:         run.ID,
+		ScheduleID: run.ScheduleID,
+		ReportID:   run.ReportID,
+		Status:     run.Status,
+		Manual:     run.Manual,
+		FromDate:   run.FromDate,
+		ToDate:     run.ToDate,
+		FileName:   run.FileName,
+		Recipients: run.RecipientList(),
+		Error:      run.Error,
+		StartedAt:  run.StartedAt,
+		FinishedAt: run.FinishedAt,
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestPresetDateRange(t *testing.T) {
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	endOf := func(year int, month time.Month, d int) time.Time {
+		return day(year, month, d).Add(24*time.Hour - time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		preset   string
+		now      time.Time
+		from, to time.Time
+	}{
+		{name: "today", preset: PresetToday, now: time.Date(2026, 1, 7, 15, 0, 0, 0, time.UTC), from: day(2026, 1, 7), to: endOf(2026, 1, 7)},
+		{name: "yesterday across a month", preset: PresetYesterday, now: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC), from: day(2026, 2, 28), to: endOf(2026, 2, 28)},
+		{name: "week to date on monday", preset: PresetWeekToDate, now: time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC), from: day(2026, 1, 5), to: endOf(2026, 1, 5)},
+		{name: "week to date on sunday", preset: PresetWeekToDate, now: time.Date(2026, 1, 4, 23, 30, 0, 0, time.UTC), from: day(2025, 12, 29), to: endOf(2026, 1, 4)},
+		{name: "previous week on monday", preset: PresetPreviousWeek, now: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), from: day(2025, 12, 29), to: endOf(2026, 1, 4)},
+		{name: "previous week on sunday", preset: PresetPreviousWeek, now: time.Date(2026, 1, 11, 12, 0, 0, 0, time.UTC), from: day(2025, 12, 29), to: endOf(2026, 1, 4)},
+		{name: "previous week across a year", preset: PresetPreviousWeek, now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC), from: day(2025, 12, 22), to: endOf(2025, 12, 28)},
+		{name: "month to date on the first", preset: PresetMonthToDate, now: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), from: day(2026, 2, 1), to: endOf(2026, 2, 1)},
+		{name: "month to date on the last day", preset: PresetMonthToDate, now: time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC), from: day(2026, 1, 1), to: endOf(2026, 1, 31)},
+		{name: "previous month across a year", preset: PresetPreviousMonth, now: time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC), from: day(2025, 12, 1), to: endOf(2025, 12, 31)},
+		{name: "previous month in a leap year", preset: PresetPreviousMonth, now: time.Date(2028, 3, 31, 6, 0, 0, 0, time.UTC), from: day(2028, 2, 1), to: endOf(2028, 2, 29)},
+		{name: "last 7 days", preset: PresetLast7Days, now: time.Date(2026, 1, 5, 6, 0, 0, 0, time.UTC), from: day(2025, 12, 29), to: endOf(2026, 1, 4)},
+		{name: "last 30 days", preset: PresetLast30Days, now: time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC), from: day(2026, 1, 30), to: endOf(2026, 2, 28)},
+		// 00:30 on March 1 in Saigon is still February 28 in UTC
+		{name: "month to date in the schedule timezone", preset: PresetMonthToDate, now: time.Date(2026, 3, 1, 0, 30, 0, 0, saigon), from: day(2026, 3, 1), to: endOf(2026, 3, 1)},
+		{name: "previous month in the schedule timezone", preset: PresetPreviousMonth, now: time.Date(2026, 3, 1, 0, 30, 0, 0, saigon), from: day(2026, 2, 1), to: endOf(2026, 2, 28)},
+		{name: "week to date in the schedule timezone", preset: PresetWeekToDate, now: time.Date(2026, 1, 5, 1, 0, 0, 0, saigon), from: day(2026, 1, 5), to: endOf(2026, 1, 5)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := presetDateRange(tt.preset, tt.now)
+			if err != nil {
+				t.Fatalf("presetDateRange: %v", err)
+			}
+			if !from.Equal(tt.from) || !to.Equal(tt.to) {
+				t.Errorf("range = %v - %v, want %v - %v", from, to, tt.from, tt.to)
+			}
+		})
+	}
+
+	if _, _, err := presetDateRange("next_week", time.Now()); !errors.Is(err, ErrInvalidReportSchedule) {
+		t.Errorf("unknown preset err = %v, want ErrInvalidReportSchedule", err)
+	}
+}
+
+type scheduleClaim struct {
+	id        int64
+	dueAt     time.Time
+	nextRunAt *time.Time
+}
+
+type fakeScheduleRepo struct {
+	repository.ReportScheduleRepo
+	due       []models.ReportSchedule
+	claimable map[int64]bool
+	claimErr  error
+	claims    []scheduleClaim
+	finished  []models.ReportScheduleRun
+}
+
+func (r *fakeScheduleRepo) GetDue(context.Context, time.Time) ([]models.ReportSchedule, error) {
+	return r.due, nil
+}
+
+func (r *fakeScheduleRepo) GetAll(context.Context) ([]models.ReportSchedule, error) {
+	return r.due, nil
+}
+
+func (r *fakeScheduleRepo) GetByCreator(_ context.Context, userID int64) ([]models.ReportSchedule, error) {
+	var schedules []models.ReportSchedule
+	for _, schedule := range r.due {
+		if schedule.CreatedBy == userID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	return schedules, nil
+}
+
+func (r *fakeScheduleRepo) GetByID(_ context.Context, id int64) (*models.ReportSchedule, error) {
+	for _, schedule := range r.due {
+		if schedule.ID == id {
+			return &schedule, nil
+		}
+	}
+	return nil, repository.ErrReportScheduleNotFound
+}
+
+func (r *fakeScheduleRepo) GetRuns(context.Context, int64, int) ([]models.ReportScheduleRun, error) {
+	return r.finished, nil
+}
+
+func (r *fakeScheduleRepo) Claim(_ context.Context, id int64, dueAt time.Time, nextRunAt *time.Time) (bool, error) {
+	r.claims = append(r.claims, scheduleClaim{id: id, dueAt: dueAt, nextRunAt: nextRunAt})
+	if r.claimErr != nil {
+		return false, r.claimErr
+	}
+	return r.claimable[id], nil
+}
+
+func (r *fakeScheduleRepo) CreateRun(_ context.Context, run *models.ReportScheduleRun) error {
+	run.ID = int64(len(r.claims))
+	return nil
+}
+
+func (r *fakeScheduleRepo) FinishRun(_ context.Context, run *models.ReportScheduleRun) error {
+	r.finished = append(r.finished, *run)
+	return nil
+}
+
+type scheduleExport struct {
+	reqCtx   RequestContext
+	reportID int64
+}
+
+// fakeExportService writes each export to a temporary file, as ExportReport does
+type fakeExportService struct {
+	ReportService
+	dir     string
+	exports []scheduleExport
+}
+
+func (s *fakeExportService) ExportReport(_ context.Context, reqCtx RequestContext, req *dto.ReportReq, _ fiber.Ctx) (*dto.ReportFileResponse, error) {
+	s.exports = append(s.exports, scheduleExport{reqCtx: reqCtx, reportID: req.ReportID})
+	path := filepath.Join(s.dir, "export-"+time.Now().Format("150405.000000000"))
+	if err := os.WriteFile(path, []byte("report data"), 0o600); err != nil {
+		return nil, err
+	}
+	return &dto.ReportFileResponse{FileName: "report.xlsx", FilePath: path}, nil
+}
+
+type fakePermissionService struct {
+	PermissionService
+	roles map[string][]string
+}
+
+func (s *fakePermissionService) GetPermissions(_ context.Context, role string, _ int64) (map[string]bool, error) {
+	permissions := make(map[string]bool)
+	for _, code := range s.roles[role] {
+		permissions[code] = true
+	}
+	return permissions, nil
+}
+
+type fakeMailer struct {
+	sent []Mail
+}
+
+func (m *fakeMailer) Send(_ context.Context, mail Mail) error {
+	for _, attachment := range mail.Attachments {
+		if _, err := io.ReadAll(attachment.Content); err != nil {
+			return err
+		}
+	}
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+type scheduleFixture struct {
+	repo    *fakeScheduleRepo
+	exports *fakeExportService
+	mailer  *fakeMailer
+	service ReportScheduleService
+}
+
+func newScheduleFixture(t *testing.T, users map[string]*models.User, schedules ...models.ReportSchedule) *scheduleFixture {
+	f := &scheduleFixture{
+		repo:    &fakeScheduleRepo{due: schedules, claimable: make(map[int64]bool)},
+		exports: &fakeExportService{dir: t.TempDir()},
+		mailer:  &fakeMailer{},
+	}
+	permissions := &fakePermissionService{roles: map[string][]string{
+		models.RoleAdmin: {models.PermissionAll},
+		models.RoleUser:  {models.PermissionReportView, models.PermissionReportExport},
+		"viewer":         {models.PermissionReportView},
+		"manager":        {models.PermissionReportExport, models.PermissionAllDepartments},
+	}}
+	f.service = NewReportScheduleService(f.repo, f.exports, &fakeUserRepo{users: users}, permissions, f.mailer, nopLogger{})
+	return f
+}
+
+func dueSchedule(id int64, cron string, dueAt time.Time) models.ReportSchedule {
+	return models.ReportSchedule{
+		ID:         id,
+		ReportID:   100 + id,
+		Name:       "Daily sales",
+		Cron:       cron,
+		Timezone:   "UTC",
+		Preset:     PresetYesterday,
+		Format:     models.ReportFormatXLSX,
+		Recipients: "a@example.com, b@example.com",
+		Enabled:    true,
+		NextRunAt:  &dueAt,
+		CreatedBy:  5,
+		// The scope at creation, which runs must not use
+		DepartmentID:   1,
+		AllDepartments: true,
+	}
+}
+
+func TestRunDueRunsClaimedSchedules(t *testing.T) {
+	dueAt := time.Now().Add(-time.Minute).Truncate(time.Minute)
+	users := map[string]*models.User{"jdoe": {ID: 5, Username: "jdoe", Role: models.RoleUser, IsActive: true, DepartmentID: 3}}
+	f := newScheduleFixture(t, users,
+		dueSchedule(1, "0 6 * * *", dueAt),
+		dueSchedule(2, "0 6 * * *", dueAt),
+		dueSchedule(3, "not a cron", dueAt),
+	)
+	// Schedule 2 was claimed by another instance
+	f.repo.claimable[1] = true
+	f.repo.claimable[3] = true
+
+	count, err := f.service.RunDue(context.Background())
+	if err != nil {
+		t.Fatalf("RunDue: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("count = %d, want 2", count)
+	}
+
+	if len(f.repo.claims) != 3 {
+		t.Fatalf("claims = %d, want one per due schedule", len(f.repo.claims))
+	}
+	for _, claim := range f.repo.claims {
+		if !claim.dueAt.Equal(dueAt) {
+			t.Errorf("schedule %d claimed at %v, want its due time %v", claim.id, claim.dueAt, dueAt)
+		}
+	}
+	if next := f.repo.claims[0].nextRunAt; next == nil || !next.After(dueAt) || next.Hour() != 6 || next.Minute() != 0 {
+		t.Errorf("next run = %v, want the next 06:00", next)
+	}
+	// An unparsable cron is claimed without a next run so it stops
+	if next := f.repo.claims[2].nextRunAt; next != nil {
+		t.Errorf("next run of the invalid schedule = %v, want nil", next)
+	}
+
+	if len(f.exports.exports) != 2 || f.exports.exports[0].reportID != 101 || f.exports.exports[1].reportID != 103 {
+		t.Fatalf("exports = %+v, want reports 101 and 103", f.exports.exports)
+	}
+	want := RequestContext{UserID: 5, DepartmentID: 3}
+	if got := f.exports.exports[0].reqCtx; got != want {
+		t.Errorf("export context = %+v, want the creator's current scope %+v", got, want)
+	}
+	if len(f.mailer.sent) != 2 || strings.Join(f.mailer.sent[0].To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("mails = %+v", f.mailer.sent)
+	}
+	for _, run := range f.repo.finished {
+		if run.Status != models.ReportRunSuccess || run.FileName != "report.xlsx" {
+			t.Errorf("run of schedule %d = %s %q", run.ScheduleID, run.Status, run.Error)
+		}
+	}
+}
+
+func TestRunDueStopsWhenClaimFails(t *testing.T) {
+	dueAt := time.Now().Add(-time.Minute)
+	f := newScheduleFixture(t, nil, dueSchedule(1, "@daily", dueAt), dueSchedule(2, "@daily", dueAt))
+	f.repo.claimErr = errors.New("database is down")
+
+	count, err := f.service.RunDue(context.Background())
+	if !errors.Is(err, f.repo.claimErr) {
+		t.Fatalf("err = %v, want the claim error", err)
+	}
+	if count != 0 || len(f.repo.claims) != 1 || len(f.exports.exports) != 0 {
+		t.Errorf("count = %d, claims = %d, exports = %d, want nothing run", count, len(f.repo.claims), len(f.exports.exports))
+	}
+}
+
+func TestRunDueChecksCreator(t *testing.T) {
+	tests := []struct {
+		name   string
+		user   *models.User
+		failed bool
+		reqCtx RequestContext
+	}{
+		{name: "inactive", user: &models.User{ID: 5, Username: "jdoe", Role: models.RoleUser}, failed: true},
+		{name: "export revoked", user: &models.User{ID: 5, Username: "jdoe", Role: "viewer", IsActive: true}, failed: true},
+		{name: "deleted", failed: true},
+		{name: "moved department", user: &models.User{ID: 5, Username: "jdoe", Role: models.RoleUser, IsActive: true, DepartmentID: 8}, reqCtx: RequestContext{UserID: 5, DepartmentID: 8}},
+		{name: "all departments", user: &models.User{ID: 5, Username: "jdoe", Role: "manager", IsActive: true, DepartmentID: 8}, reqCtx: RequestContext{UserID: 5, DepartmentID: 8, AllDepartments: true}},
+		{name: "admin", user: &models.User{ID: 5, Username: "jdoe", Role: models.RoleAdmin, IsActive: true, DepartmentID: 2}, reqCtx: RequestContext{UserID: 5, DepartmentID: 2, AllDepartments: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := map[string]*models.User{}
+			if tt.user != nil {
+				users[tt.user.Username] = tt.user
+			}
+			f := newScheduleFixture(t, users, dueSchedule(1, "@daily", time.Now().Add(-time.Minute)))
+			f.repo.claimable[1] = true
+
+			count, err := f.service.RunDue(context.Background())
+			if err != nil || count != 1 {
+				t.Fatalf("RunDue = %d, %v, want the run counted", count, err)
+			}
+			if len(f.repo.finished) != 1 {
+				t.Fatalf("finished runs = %d, want 1", len(f.repo.finished))
+			}
+			run := f.repo.finished[0]
+
+			if tt.failed {
+				if run.Status != models.ReportRunFailed || !strings.Contains(run.Error, ErrScheduleCreatorNotAllowed.Error()) {
+					t.Errorf("run = %s %q, want a failed run naming the creator check", run.Status, run.Error)
+				}
+				if len(f.exports.exports) != 0 || len(f.mailer.sent) != 0 {
+					t.Errorf("exports = %d, mails = %d, want none", len(f.exports.exports), len(f.mailer.sent))
+				}
+				return
+			}
+			if run.Status != models.ReportRunSuccess {
+				t.Fatalf("run = %s %q, want success", run.Status, run.Error)
+			}
+			if got := f.exports.exports[0].reqCtx; got != tt.reqCtx {
+				t.Errorf("export context = %+v, want %+v", got, tt.reqCtx)
+			}
+		})
+	}
+}
+
+func TestSchedulesAreScopedToTheirCreator(t *testing.T) {
+	dueAt := time.Now().Add(time.Hour)
+	own, other := dueSchedule(1, "@daily", dueAt), dueSchedule(2, "@daily", dueAt)
+	other.CreatedBy = 6
+	f := newScheduleFixture(t, nil, own, other)
+	ctx := context.Background()
+	owner := RequestContext{UserID: 5, DepartmentID: 1}
+
+	list, err := f.service.List(ctx, owner)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].ID != 1 {
+		t.Errorf("List = %+v, want only the caller's schedule", list)
+	}
+	if _, err := f.service.Get(ctx, owner, 1); err != nil {
+		t.Errorf("Get own schedule: %v", err)
+	}
+
+	// Other users' schedules look like they do not exist
+	if _, err := f.service.Get(ctx, owner, 2); !errors.Is(err, repository.ErrReportScheduleNotFound) {
+		t.Errorf("Get = %v, want ErrReportScheduleNotFound", err)
+	}
+	if _, err := f.service.GetRuns(ctx, owner, 2, 10); !errors.Is(err, repository.ErrReportScheduleNotFound) {
+		t.Errorf("GetRuns = %v, want ErrReportScheduleNotFound", err)
+	}
+	if _, err := f.service.RunNow(ctx, owner, 2); !errors.Is(err, repository.ErrReportScheduleNotFound) {
+		t.Errorf("RunNow = %v, want ErrReportScheduleNotFound", err)
+	}
+	if err := f.service.Delete(ctx, owner, 2); !errors.Is(err, repository.ErrReportScheduleNotFound) {
+		t.Errorf("Delete = %v, want ErrReportScheduleNotFound", err)
+	}
+	if _, err := f.service.Update(ctx, owner, 2, dto.ReportScheduleReq{}); !errors.Is(err, repository.ErrReportScheduleNotFound) {
+		t.Errorf("Update = %v, want ErrReportScheduleNotFound", err)
+	}
+	if len(f.exports.exports) != 0 {
+		t.Errorf("exported %d reports, want none", len(f.exports.exports))
+	}
+
+	admin := RequestContext{UserID: 1, Admin: true}
+	list, err = f.service.List(ctx, admin)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Errorf("admin List = %d schedules, want 2", len(list))
+	}
+	if _, err := f.service.GetRuns(ctx, admin, 2, 10); err != nil {
+		t.Errorf("admin GetRuns: %v", err)
+	}
+}
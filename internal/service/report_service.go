@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"cqs-kanban/config"
 	"cqs-kanban/internal/dto"
 	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
 	"errors"
 	"fmt"
+	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -18,6 +22,10 @@ type RequestContext struct {
 	DepartmentID  int64
 	IPAddress     string
 	OperationType int
+	// AllDepartments is set for callers allowed to see every department's data
+	AllDepartments bool
+	// Admin is set for callers granted every permission
+	Admin bool
 }
 
 const (
@@ -26,6 +34,9 @@ const (
 
 	DefaultDateRangeDays = 7
 	MaxDateRangeDays     = 365
+
+	DefaultReportPageSize = 100
+	MaxReportPageSize     = 1000
 )
 
 var (
@@ -36,6 +47,8 @@ var (
 	ErrNoColumnsFound    = errors.New("no columns found for report")
 	ErrEmptyReportName   = errors.New("report name cannot be empty")
 	ErrEmptySQLQuery     = errors.New("sql query cannot be empty")
+	ErrInvalidDepartment = errors.New("invalid department id")
+	ErrInvalidResultView = errors.New("invalid sort or filter")
 )
 
 type (
@@ -43,65 +56,132 @@ type (
 		baseErpRepo   repository.BaseERPRepository
 		reportRepo    repository.ReportRepo
 		operationRepo repository.OperationRepository
+		copmaRepo     repository.CopmaRepo
 		logger        Logger
+		scope         *departmentScope
+		cache         *ReportCache
+		queries       *ReportQueryTracker
+		// defaultTimeout and maxRows limit report query execution
+		defaultTimeout time.Duration
+		maxRows        int
+		pdfCompany     utils.PDFCompany
 	}
 	ReportService interface {
-		CreateReport(ctx context.Context, req dto.ReportCreateReq) error
-		UpdateReport(ctx context.Context, id int64, req dto.ReportUpdateModel) error
+		CreateReport(ctx context.Context, req dto.ReportCreateReq) (*dto.ReportSaveRes, error)
+		UpdateReport(ctx context.Context, id int64, req dto.ReportUpdateModel) (*dto.ReportSaveRes, error)
+		PreviewReport(ctx context.Context, req dto.ReportPreviewReq) (*dto.ReportPreviewRes, error)
 		DeleteReport(ctx context.Context, id int64) error
 		GetReport(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) (*dto.ReportRes, error)
-		GetReportByID(ctx context.Context, id int64) (*dto.ReportDetail, error)
-		GetAllReport(ctx context.Context) ([]*dto.ReportDetail, error)
+		GetReportByID(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportDetail, error)
+		GetAllReport(ctx context.Context, reqCtx RequestContext) ([]*dto.ReportDetail, error)
+		GetShares(ctx context.Context, reportID int64) ([]dto.ReportShareRes, error)
+		ShareReport(ctx context.Context, reportID int64, req dto.ReportShareReq, createdBy int64) error
+		UnshareReport(ctx context.Context, reportID int64, departmentID int64) error
+		GetParameters(ctx context.Context, reqCtx RequestContext, reportID int64) ([]*dto.ReportParameter, error)
+		GetParameterOptions(ctx context.Context, reqCtx RequestContext, reportID int64, name string) ([]dto.LookupOption, error)
 		Count(ctx context.Context) (int64, error)
+		ListRunningQueries(ctx context.Context) []dto.RunningReportQuery
+		GetRevisions(ctx context.Context, reportID int64) ([]dto.ReportRevisionSummary, error)
+		GetRevision(ctx context.Context, reportID int64, revision int) (*dto.ReportRevisionRes, error)
+		DiffRevisions(ctx context.Context, reportID int64, from, to int) (*dto.ReportRevisionDiff, error)
+		RestoreRevision(ctx context.Context, reportID int64, revision int, restoredBy int64, comment string) (*dto.ReportRevisionRes, error)
+		KillQuery(ctx context.Context, id string, killedBy int64) error
 		ExportReport(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) (*dto.ReportFileResponse, error)
 	}
 )
 
 func NewReportService(reportRepo repository.ReportRepo,
-	baseErpRepo repository.BaseERPRepository, operationRepo repository.OperationRepository, logger Logger,
+	baseErpRepo repository.BaseERPRepository, operationRepo repository.OperationRepository,
+	copmaRepo repository.CopmaRepo, logger Logger, config *config.Config,
 ) ReportService {
 	return &reportService{
 		reportRepo:    reportRepo,
 		baseErpRepo:   baseErpRepo,
 		operationRepo: operationRepo,
+		copmaRepo:     copmaRepo,
 		logger:        logger,
+		scope: &departmentScope{
+			reportRepo:    reportRepo,
+			operationRepo: operationRepo,
+			logger:        logger,
+		},
+		cache:          NewReportCache(),
+		queries:        NewReportQueryTracker(),
+		defaultTimeout: config.GetReportQueryTimeout(),
+		maxRows:        config.GetReportMaxRows(),
+		pdfCompany:     pdfCompany(config),
 	}
 }
-func (r *reportService) CreateReport(ctx context.Context, req dto.ReportCreateReq) error {
-	return r.reportRepo.CreateReport(ctx, dto.ReportCreateModel(req))
+func (r *reportService) CreateReport(ctx context.Context, req dto.ReportCreateReq) (*dto.ReportSaveRes, error) {
+	if err := validateParamDefinitions(req.Parameters); err != nil {
+		return nil, err
+	}
+	if err := validateColumnDefinitions(req.Columns); err != nil {
+		return nil, err
+	}
+	if err := validateCacheTTL(req.CacheTTLSeconds); err != nil {
+		return nil, err
+	}
+	if err := validateQueryTimeout(req.QueryTimeoutSeconds); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateReadOnlySQL(req.QueryStatement); err != nil {
+		return nil, err
+	}
+	if err := r.reportRepo.CreateReport(ctx, dto.ReportCreateModel(req)); err != nil {
+		return nil, err
+	}
+	return &dto.ReportSaveRes{
+		Warnings: r.columnWarnings(ctx, req.QueryStatement, req.Parameters, req.Columns),
+	}, nil
 }
 
-func (r *reportService) GetReportByID(ctx context.Context, id int64) (*dto.ReportDetail, error) {
+func (r *reportService) GetReportByID(ctx context.Context, reqCtx RequestContext, id int64) (*dto.ReportDetail, error) {
 	report, err := r.reportRepo.GetReportByID(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get report by id failed")
 	}
+	allowed, err := r.scope.canAccessReport(ctx, reqCtx, report)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, r.scope.deny(ctx, reqCtx, id, OperationTypeView, "report")
+	}
 	reportcolumn, err := r.reportRepo.GetColumn(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("get report column error")
 	}
 	columns := make([]*dto.ReportColumn, len(reportcolumn))
 	for i, col := range reportcolumn {
-		columns[i] = &dto.ReportColumn{
-			ID:       col.ID,
-			ReportID: col.ReportID,
-			Title:    col.Title,
-			Code:     col.Code,
-			Type:     col.Type,
-			Num:      col.Num,
-		}
+		columns[i] = toReportColumnDTO(col)
+	}
+	parameters, err := r.getParameterDTOs(ctx, id)
+	if err != nil {
+		return nil, err
 	}
 	return &dto.ReportDetail{
-		ID:             report.ID,
-		ReportType:     report.ReportType,
-		ReportName:     report.ReportName,
-		QueryStatement: report.QueryStatement,
-		DepartmentID:   report.DepartmentID,
-		Columns:        columns,
+		ID:                  report.ID,
+		ReportType:          report.ReportType,
+		ReportName:          report.ReportName,
+		QueryStatement:      report.QueryStatement,
+		DepartmentID:        report.DepartmentID,
+		Columns:             columns,
+		Parameters:          parameters,
+		CacheTTLSeconds:     report.CacheTTLSeconds,
+		QueryTimeoutSeconds: report.QueryTimeoutSeconds,
 	}, nil
 }
-func (r *reportService) GetAllReport(ctx context.Context) ([]*dto.ReportDetail, error) {
-	reports, err := r.reportRepo.GetAllReport(ctx)
+func (r *reportService) GetAllReport(ctx context.Context, reqCtx RequestContext) ([]*dto.ReportDetail, error) {
+	var (
+		reports []models.Report
+		err     error
+	)
+	if reqCtx.AllDepartments {
+		reports, err = r.reportRepo.GetAllReport(ctx)
+	} else {
+		reports, err = r.reportRepo.GetReportsForDepartment(ctx, reqCtx.DepartmentID)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("get all report failed")
 	}
@@ -113,31 +193,178 @@ func (r *reportService) GetAllReport(ctx context.Context) ([]*dto.ReportDetail,
 		}
 		columns := make([]*dto.ReportColumn, len(reportcolumn))
 		for i, col := range reportcolumn {
-			columns[i] = &dto.ReportColumn{
-				ID:       col.ID,
-				ReportID: col.ReportID,
-				Title:    col.Title,
-				Code:     col.Code,
-				Type:     col.Type,
-				Num:      col.Num,
-			}
+			columns[i] = toReportColumnDTO(col)
+		}
+		parameters, err := r.getParameterDTOs(ctx, report.ID)
+		if err != nil {
+			return nil, err
 		}
 		reportDetails = append(reportDetails, &dto.ReportDetail{
-			ID:             report.ID,
-			ReportType:     report.ReportType,
-			ReportName:     report.ReportName,
-			QueryStatement: report.QueryStatement,
-			DepartmentID:   report.DepartmentID,
-			Columns:        columns,
+			ID:                  report.ID,
+			ReportType:          report.ReportType,
+			ReportName:          report.ReportName,
+			QueryStatement:      report.QueryStatement,
+			DepartmentID:        report.DepartmentID,
+			Columns:             columns,
+			Parameters:          parameters,
+			CacheTTLSeconds:     report.CacheTTLSeconds,
+			QueryTimeoutSeconds: report.QueryTimeoutSeconds,
 		})
 	}
 	return reportDetails, nil
 }
-func (r *reportService) UpdateReport(ctx context.Context, id int64, req dto.ReportUpdateModel) error {
-	return r.reportRepo.UpdateReport(ctx, id, req)
+func (r *reportService) getParameterDTOs(ctx context.Context, reportID int64) ([]*dto.ReportParameter, error) {
+	params, err := r.reportRepo.GetParameters(ctx, reportID)
+	if err != nil {
+		return nil, fmt.Errorf("get report parameters error: %w", err)
+	}
+	res := make([]*dto.ReportParameter, 0, len(params))
+	for _, param := range params {
+		res = append(res, toReportParameterDTO(param))
+	}
+	return res, nil
+}
+
+// GetParameters lists the parameter definitions of a report the caller can access
+func (r *reportService) GetParameters(ctx context.Context, reqCtx RequestContext, reportID int64) ([]*dto.ReportParameter, error) {
+	if err := r.authorizeReport(ctx, reqCtx, reportID, OperationTypeView); err != nil {
+		return nil, err
+	}
+	return r.getParameterDTOs(ctx, reportID)
+}
+
+// GetParameterOptions lists the values a parameter accepts, from its lookup source or allowed values
+func (r *reportService) GetParameterOptions(ctx context.Context, reqCtx RequestContext, reportID int64, name string) ([]dto.LookupOption, error) {
+	if err := r.authorizeReport(ctx, reqCtx, reportID, OperationTypeView); err != nil {
+		return nil, err
+	}
+	params, err := r.reportRepo.GetParameters(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	for _, param := range params {
+		if !strings.EqualFold(param.Name, name) {
+			continue
+		}
+		if param.LookupSource != "" {
+			return r.copmaRepo.GetLookupOptions(ctx, param.LookupSource)
+		}
+		options := make([]dto.LookupOption, 0)
+		if param.AllowedValues != "" {
+			for _, value := range strings.Split(param.AllowedValues, ",") {
+				options = append(options, dto.LookupOption{Value: value, Label: value})
+			}
+		}
+		return options, nil
+	}
+	return nil, ErrReportParamNotFound
+}
+
+func (r *reportService) GetShares(ctx context.Context, reportID int64) ([]dto.ReportShareRes, error) {
+	shares, err := r.reportRepo.GetShares(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]dto.ReportShareRes, 0, len(shares))
+	for _, share := range shares {
+		res = append(res, dto.ReportShareRes{
+			ReportID:     share.ReportID,
+			DepartmentID: share.DepartmentID,
+			CreatedBy:    share.CreatedBy,
+			CreatedAt:    share.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// ShareReport gives another department access to the report
+func (r *reportService) ShareReport(ctx context.Context, reportID int64, req dto.ReportShareReq, createdBy int64) error {
+	if req.DepartmentID <= 0 {
+		return ErrInvalidDepartment
+	}
+	if _, err := r.reportRepo.GetReportByID(ctx, reportID); err != nil {
+		return ErrReportNotFound
+	}
+	shared, err := r.reportRepo.IsSharedWith(ctx, reportID, req.DepartmentID)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return nil
+	}
+	if err := r.reportRepo.CreateShare(ctx, &models.ReportShare{
+		ReportID:     reportID,
+		DepartmentID: req.DepartmentID,
+		CreatedBy:    createdBy,
+	}); err != nil {
+		return err
+	}
+
+	r.logger.Info(ctx, "Report shared", map[string]interface{}{
+		"report_id":     reportID,
+		"department_id": req.DepartmentID,
+		"created_by":    createdBy,
+	})
+	return nil
+}
+
+func (r *reportService) UnshareReport(ctx context.Context, reportID int64, departmentID int64) error {
+	if err := r.reportRepo.DeleteShare(ctx, reportID, departmentID); err != nil {
+		return err
+	}
+
+	r.logger.Info(ctx, "Report unshared", map[string]interface{}{
+		"report_id":     reportID,
+		"department_id": departmentID,
+	})
+	return nil
+}
+
+func (r *reportService) UpdateReport(ctx context.Context, id int64, req dto.ReportUpdateModel) (*dto.ReportSaveRes, error) {
+	if err := validateParamDefinitions(req.Parameters); err != nil {
+		return nil, err
+	}
+	if err := validateColumnDefinitions(req.Columns); err != nil {
+		return nil, err
+	}
+	if req.CacheTTLSeconds != nil {
+		if err := validateCacheTTL(*req.CacheTTLSeconds); err != nil {
+			return nil, err
+		}
+	}
+	if req.QueryTimeoutSeconds != nil {
+		if err := validateQueryTimeout(*req.QueryTimeoutSeconds); err != nil {
+			return nil, err
+		}
+	}
+	if req.QueryStatement != nil {
+		if err := utils.ValidateReadOnlySQL(*req.QueryStatement); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.reportRepo.UpdateReport(ctx, id, req); err != nil {
+		return nil, err
+	}
+	r.cache.Invalidate(id)
+
+	report, err := r.reportRepo.GetReportByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("get report by id failed")
+	}
+	params, err := r.getParameterDTOs(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ReportSaveRes{
+		Warnings: r.columnWarnings(ctx, report.QueryStatement, params, req.Columns),
+	}, nil
 }
 func (r *reportService) DeleteReport(ctx context.Context, id int64) error {
-	return r.reportRepo.DeleteReport(ctx, id)
+	if err := r.reportRepo.DeleteReport(ctx, id); err != nil {
+		return err
+	}
+	r.cache.Invalidate(id)
+	return nil
 }
 func (s *reportService) GetReport(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, c fiber.Ctx) (*dto.ReportRes, error) {
 	if err := s.validateReportRequest(req); err != nil {
@@ -147,6 +374,16 @@ func (s *reportService) GetReport(ctx context.Context, reqCtx RequestContext, re
 	if err := s.normalizeDateRange(req); err != nil {
 		return nil, err
 	}
+	s.normalizePaging(req)
+
+	if err := s.authorizeReport(ctx, reqCtx, req.ReportID, OperationTypeView); err != nil {
+		return nil, err
+	}
+
+	params, err := s.reportParams(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	logID, err := s.logAccess(ctx, reqCtx, req, OperationTypeView, c)
 	if err != nil {
@@ -156,13 +393,14 @@ func (s *reportService) GetReport(ctx context.Context, reqCtx RequestContext, re
 			"report_id": req.ReportID,
 		})
 	}
-	reportData, err := s.fetchReportData(ctx, req)
+	reportData, err := s.fetchReportData(ctx, reqCtx, req, params)
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "failed")
 		return nil, err
 	}
 	s.updateLogStatus(ctx, logID, "success")
 
+	formatReportRes(reportData, req.Page, req.PageSize)
 	return reportData, nil
 }
 
@@ -170,10 +408,26 @@ func (s *reportService) ExportReport(ctx context.Context, reqCtx RequestContext,
 	if err := s.validateReportRequest(req); err != nil {
 		return nil, err
 	}
+	format, options, err := exportFormat(req)
+	if err != nil {
+		return nil, err
+	}
 
 	if err := s.normalizeDateRange(req); err != nil {
 		return nil, err
 	}
+	// Exports keep sort and filters but always contain every matching row
+	req.Page, req.PageSize = 0, 0
+
+	if err := s.authorizeReport(ctx, reqCtx, req.ReportID, OperationTypeExport); err != nil {
+		return nil, err
+	}
+	reportExportProgress(ctx, 10)
+
+	params, err := s.reportParams(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 
 	logID, err := s.logAccess(ctx, reqCtx, req, OperationTypeExport, c)
 	if err != nil {
@@ -184,53 +438,88 @@ func (s *reportService) ExportReport(ctx context.Context, reqCtx RequestContext,
 		})
 	}
 
-	reportData, err := s.fetchReportData(ctx, req)
+	// Exports bypass the result cache and stream the rows into the file
+	report, columns, input, err := s.reportQuery(ctx, req, params)
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "failed")
 		return nil, err
 	}
-
-	reportMeta, _, err := s.getReportMetadata(ctx, req.ReportID)
-	if err != nil {
-		s.updateLogStatus(ctx, logID, "failed")
-		return nil, err
+	rows := func(fn func(row map[string]any) error) error {
+		if input == nil {
+			return nil
+		}
+		return s.streamQuery(ctx, reqCtx, report, *input, fn)
 	}
+	reportExportProgress(ctx, 20)
 
-	fileBytes, err := s.reportRepo.ExportReportToExcel(
-		ctx,
-		reportMeta.ReportName,
-		reportData.Columns,
-		reportData.Data,
-		*req.FromDate,
-		*req.ToDate,
-	)
+	filePath, err := s.writeExportFile(ctx, format, options, report.ReportName, columns, rows, *req.FromDate, *req.ToDate)
 	if err != nil {
 		s.updateLogStatus(ctx, logID, "failed")
-		s.logger.Error(ctx, "Failed to generate excel file", err, map[string]interface{}{
+		s.logger.Error(ctx, "Failed to generate export file", err, map[string]interface{}{
 			"report_id": req.ReportID,
+			"format":    format,
 		})
-		return nil, fmt.Errorf("failed to generate excel file: %w", err)
+		return nil, fmt.Errorf("failed to generate %s file: %w", format, err)
 	}
 
-	fileName := s.generateFileName(reportMeta.ReportName, req.FromDate, req.ToDate)
+	reportExportProgress(ctx, 90)
+
+	fileName := s.generateFileName(report.ReportName, format, req.FromDate, req.ToDate)
 
 	s.updateLogStatus(ctx, logID, "success")
 
 	return &dto.ReportFileResponse{
-		FileName:  fileName,
-		FileDetal: fileBytes,
+		ReportName:  report.ReportName,
+		FileName:    fileName,
+		FilePath:    filePath,
+		ContentType: reportFormatContentTypes[format],
+		GeneratedAt: time.Now(),
 	}, nil
 }
+
+// writeExportFile streams the report into a temporary file and returns its path
+func (s *reportService) writeExportFile(ctx context.Context, format string, options exportOptions, reportName string, columns []models.ReportColumn, rows repository.ReportRows, fromDate, toDate time.Time) (string, error) {
+	file, err := os.CreateTemp("", "report-*."+format)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	switch format {
+	case models.ReportFormatXLSX:
+		err = s.reportRepo.ExportReportToExcel(ctx, file, reportName, columns, rows, fromDate, toDate)
+	case models.ReportFormatPDF:
+		err = s.reportRepo.ExportReportToPDF(ctx, file, reportName, columns, rows, fromDate, toDate,
+			utils.PDFOptions{Landscape: options.landscape, Company: s.pdfCompany})
+	default:
+		err = s.reportRepo.ExportReportToDelimited(ctx, file, columns, rows, options.delimited)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
 func (s *reportService) Count(ctx context.Context) (int64, error) {
 	return s.reportRepo.Count(ctx)
 }
-func (s *reportService) fetchReportData(ctx context.Context, req *dto.ReportReq) (*dto.ReportRes, error) {
-	report, columns, err := s.getReportMetadata(ctx, req.ReportID)
+
+// reportParams validates the request's parameters against the report definitions
+func (s *reportService) reportParams(ctx context.Context, req *dto.ReportReq) (map[string]any, error) {
+	defs, err := s.reportRepo.GetParameters(ctx, req.ReportID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to get report parameters: %w", err)
 	}
+	return s.resolveReportParams(ctx, defs, req.Params)
+}
 
-	if strings.TrimSpace(report.QueryStatement) == "" {
+func (s *reportService) fetchReportData(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, params map[string]any) (*dto.ReportRes, error) {
+	report, columns, input, err := s.reportQuery(ctx, req, params)
+	if err != nil {
+		return nil, err
+	}
+	if input == nil {
 		return &dto.ReportRes{
 			ReportID:   req.ReportID,
 			ReportType: report.ReportType,
@@ -240,11 +529,7 @@ func (s *reportService) fetchReportData(ctx context.Context, req *dto.ReportReq)
 		}, nil
 	}
 
-	reportDetail, err := s.baseErpRepo.GetBaseERP(ctx, dto.BaseERPReq{
-		SqlQuery: report.QueryStatement,
-		FromDate: *req.FromDate,
-		ToDate:   *req.ToDate,
-	})
+	reportDetail, cachedAt, err := s.cachedBaseERP(ctx, reqCtx, report, req.Refresh, *input)
 	if err != nil {
 		s.logger.Error(ctx, "Failed to fetch ERP data", err, map[string]interface{}{
 			"report_id": req.ReportID,
@@ -252,13 +537,148 @@ func (s *reportService) fetchReportData(ctx context.Context, req *dto.ReportReq)
 		return nil, fmt.Errorf("failed to fetch report data: %w", err)
 	}
 
-	return &dto.ReportRes{
+	res := &dto.ReportRes{
 		ReportID:   req.ReportID,
 		ReportType: report.ReportType,
 		ReportName: report.ReportName,
 		Columns:    columns,
 		Data:       reportDetail.Data,
-	}, nil
+	}
+	if !cachedAt.IsZero() {
+		res.FromCache = true
+		res.CachedAt = &cachedAt
+		res.CacheAgeSeconds = int64(time.Since(cachedAt).Seconds())
+	}
+	if input.Limit > 0 {
+		res.Pagination = &dto.ReportPagination{
+			Page:       req.Page,
+			PageSize:   req.PageSize,
+			TotalCount: reportDetail.Total,
+			TotalPages: int((reportDetail.Total + int64(req.PageSize) - 1) / int64(req.PageSize)),
+		}
+	}
+	return res, nil
+}
+
+// reportQuery loads the report definition and builds its query with the
+// request's sort, filters, grouping and paging. The query is nil for reports
+// without a query statement.
+func (s *reportService) reportQuery(ctx context.Context, req *dto.ReportReq, params map[string]any) (*models.Report, []models.ReportColumn, *dto.BaseERPReq, error) {
+	report, columns, err := s.getReportMetadata(ctx, req.ReportID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if strings.TrimSpace(report.QueryStatement) == "" {
+		return report, columns, nil, nil
+	}
+
+	// Definitions saved before validation existed are checked again before they run
+	if err := utils.ValidateReadOnlySQL(report.QueryStatement); err != nil {
+		s.logger.Warn(ctx, "Refused to run unsafe report query", map[string]interface{}{
+			"report_id": req.ReportID,
+			"error":     err.Error(),
+		})
+		return nil, nil, nil, err
+	}
+
+	input := &dto.BaseERPReq{
+		SqlQuery: report.QueryStatement,
+		FromDate: *req.FromDate,
+		ToDate:   *req.ToDate,
+		Params:   params,
+	}
+	if err := s.applyResultView(req, columns, input); err != nil {
+		return nil, nil, nil, err
+	}
+	return report, columns, input, nil
+}
+
+// cachedBaseERP serves the query from the report's result cache when it has a
+// TTL. The returned time is zero unless the result came from the cache.
+func (s *reportService) cachedBaseERP(ctx context.Context, reqCtx RequestContext, report *models.Report, refresh bool, input dto.BaseERPReq) (*dto.BaseERP, time.Time, error) {
+	if report.CacheTTLSeconds <= 0 {
+		data, err := s.runQuery(ctx, reqCtx, report, input)
+		return data, time.Time{}, err
+	}
+
+	key, err := reportCacheKey(input)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !refresh {
+		if data, cachedAt, ok := s.cache.Get(report.ID, key); ok {
+			return data, cachedAt, nil
+		}
+	}
+
+	data, err := s.runQuery(ctx, reqCtx, report, input)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(data.Data) <= MaxCachedReportRows {
+		s.cache.Set(report.ID, key, data, time.Duration(report.CacheTTLSeconds)*time.Second)
+	}
+	return data, time.Time{}, nil
+}
+
+// applyResultView checks the requested sort and filters against the report
+// columns and pushes them, along with the group order and the page of
+// reports without group-by columns, into the ERP query.
+func (s *reportService) applyResultView(req *dto.ReportReq, columns []models.ReportColumn, input *dto.BaseERPReq) error {
+	byCode := make(map[string]models.ReportColumn, len(columns))
+	for _, column := range columns {
+		byCode[column.Code] = column
+	}
+
+	if req.Sort != "" {
+		code := strings.TrimPrefix(req.Sort, "-")
+		if _, ok := byCode[code]; !ok {
+			return fmt.Errorf("%w: unknown sort column %q", ErrInvalidResultView, code)
+		}
+		input.Sort = code
+		input.SortDesc = strings.HasPrefix(req.Sort, "-")
+	}
+
+	for code, raw := range req.Filters {
+		column, ok := byCode[code]
+		if !ok {
+			return fmt.Errorf("%w: unknown filter column %q", ErrInvalidResultView, code)
+		}
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		filter := dto.BaseERPFilter{Column: code, Type: column.Type, Value: raw}
+		switch column.Type {
+		case models.ReportColumnCurrency, models.ReportColumnPercent:
+			value, err := parseReportParamValue(models.ReportParamDecimal, raw)
+			if err != nil {
+				return fmt.Errorf("%w: filter %s: %v", ErrInvalidResultView, code, err)
+			}
+			filter.Value = value
+		case models.ReportColumnInt, models.ReportColumnDecimal, models.ReportColumnBool:
+			value, err := parseReportParamValue(column.Type, raw)
+			if err != nil {
+				return fmt.Errorf("%w: filter %s: %v", ErrInvalidResultView, code, err)
+			}
+			filter.Value = value
+		}
+		input.Filters = append(input.Filters, filter)
+	}
+	// Map iteration order is random; keep the generated SQL stable
+	sort.Slice(input.Filters, func(i, j int) bool {
+		return input.Filters[i].Column < input.Filters[j].Column
+	})
+
+	input.GroupBy, _ = repository.ReportGroups(columns)
+
+	// Subtotals and the grand total cover every row, so grouped reports are
+	// read whole, bounded by the row limit, and paged once they are grouped
+	if req.Page > 0 && len(input.GroupBy) == 0 {
+		input.Offset = (req.Page - 1) * req.PageSize
+		input.Limit = req.PageSize
+	}
+	return nil
 }
 
 func (s *reportService) getReportMetadata(ctx context.Context, reportID int64) (*models.Report, []models.ReportColumn, error) {
@@ -282,9 +702,28 @@ func (s *reportService) getReportMetadata(ctx context.Context, reportID int64) (
 	return report, columns, nil
 }
 
-func (s *reportService) logAccess(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, operationType int, c fiber.Ctx) (int, error) {
+// authorizeReport rejects reports outside the caller's department scope
+func (s *reportService) authorizeReport(ctx context.Context, reqCtx RequestContext, reportID int64, operationType int) error {
+	report, err := s.reportRepo.GetReport(ctx, reportID)
+	if err != nil {
+		return ErrReportNotFound
+	}
+	allowed, err := s.scope.canAccessReport(ctx, reqCtx, report)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return s.scope.deny(ctx, reqCtx, reportID, operationType, "report")
+	}
+	return nil
+}
 
-	ipAddress := c.IP()
+// logAccess records the request; c is nil for reports run by the scheduler
+func (s *reportService) logAccess(ctx context.Context, reqCtx RequestContext, req *dto.ReportReq, operationType int, c fiber.Ctx) (int, error) {
+	ipAddress := reqCtx.IPAddress
+	if c != nil {
+		ipAddress = c.IP()
+	}
 	accessLog := &models.AccessLog{
 		UserID:       reqCtx.UserID,
 		DepartmentID: reqCtx.DepartmentID,
@@ -338,14 +777,33 @@ func (s *reportService) normalizeDateRange(req *dto.ReportReq) error {
 	return nil
 }
 
-func (s *reportService) generateFileName(reportName string, fromDate, toDate *time.Time) string {
+// normalizePaging fills in the page or page size when only one is given.
+// Requests without either get every row, bounded by the report row limit.
+func (s *reportService) normalizePaging(req *dto.ReportReq) {
+	if req.Page <= 0 && req.PageSize <= 0 {
+		req.Page, req.PageSize = 0, 0
+		return
+	}
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = DefaultReportPageSize
+	}
+	if req.PageSize > MaxReportPageSize {
+		req.PageSize = MaxReportPageSize
+	}
+}
+
+func (s *reportService) generateFileName(reportName, format string, fromDate, toDate *time.Time) string {
 	safeName := strings.ReplaceAll(reportName, " ", "_")
 	safeName = strings.ReplaceAll(safeName, "/", "-")
 
-	return fmt.Sprintf("%s_%s_to_%s.xlsx",
+	return fmt.Sprintf("%s_%s_to_%s.%s",
 		safeName,
 		fromDate.Format("2006-01-02"),
 		toDate.Format("2006-01-02"),
+		format,
 	)
 }
 
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"slices"
+	"testing"
+)
+
+func TestApplyResultViewPaging(t *testing.T) {
+	flat := []models.ReportColumn{
+		{Code: "customer", Type: models.ReportColumnString},
+		{Code: "amount", Type: models.ReportColumnDecimal},
+	}
+	grouped := []models.ReportColumn{
+		{Code: "customer", Type: models.ReportColumnString, GroupLevel: 1},
+		{Code: "amount", Type: models.ReportColumnDecimal, Aggregate: models.ReportAggregateSum},
+	}
+
+	tests := []struct {
+		name       string
+		columns    []models.ReportColumn
+		page       int
+		wantOffset int
+		wantLimit  int
+	}{
+		{name: "flat page", columns: flat, page: 3, wantOffset: 100, wantLimit: 50},
+		{name: "flat without paging", columns: flat},
+		// Subtotals of a single page would leave out the rows of other pages
+		{name: "grouped page", columns: grouped, page: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &dto.ReportReq{Page: tt.page}
+			if tt.page > 0 {
+				req.PageSize = 50
+			}
+			var input dto.BaseERPReq
+			if err := (&reportService{}).applyResultView(req, tt.columns, &input); err != nil {
+				t.Fatalf("applyResultView: %v", err)
+			}
+			if input.Offset != tt.wantOffset || input.Limit != tt.wantLimit {
+				t.Errorf("offset, limit = %d, %d, want %d, %d", input.Offset, input.Limit, tt.wantOffset, tt.wantLimit)
+			}
+		})
+	}
+
+	var input dto.BaseERPReq
+	if err := (&reportService{}).applyResultView(&dto.ReportReq{Page: 1, PageSize: 50}, grouped, &input); err != nil {
+		t.Fatalf("applyResultView: %v", err)
+	}
+	if !slices.Equal(input.GroupBy, []string{"customer"}) {
+		t.Errorf("group by = %v, want [customer]", input.GroupBy)
+	}
+}
+
+func TestFormatReportResGroupedPage(t *testing.T) {
+	result := func() *dto.ReportRes {
+		return &dto.ReportRes{
+			Columns: []models.ReportColumn{
+				{Code: "customer", Type: models.ReportColumnString, GroupLevel: 1},
+				{Code: "amount", Type: models.ReportColumnDecimal, Aggregate: models.ReportAggregateSum},
+			},
+			Data: []map[string]any{
+				{"customer": "ACME", "amount": 1.0},
+				{"customer": "ACME", "amount": 2.0},
+				{"customer": "Beta", "amount": 4.0},
+			},
+		}
+	}
+	// header, 2 details, subtotal, header, detail, subtotal and total
+	res := result()
+	formatReportRes(res, 2, 3)
+
+	if res.Data != nil {
+		t.Errorf("data = %v, want nil for a grouped report", res.Data)
+	}
+	var kinds []string
+	for _, row := range res.Rows {
+		kinds = append(kinds, row.Type)
+	}
+	if want := []string{"subtotal", "header", "detail"}; !slices.Equal(kinds, want) {
+		t.Errorf("rows = %v, want %v", kinds, want)
+	}
+	want := dto.ReportPagination{Page: 2, PageSize: 3, TotalCount: 8, TotalPages: 3}
+	if res.Pagination == nil || *res.Pagination != want {
+		t.Errorf("pagination = %+v, want %+v", res.Pagination, want)
+	}
+
+	res = result()
+	formatReportRes(res, 4, 3)
+	if len(res.Rows) != 0 {
+		t.Errorf("rows past the last page = %d, want none", len(res.Rows))
+	}
+}
@@ -3,14 +3,25 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	"cqs-kanban/config"
 	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
 	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 )
 
+var ErrSaleCopi04NotFound = errors.New("COPI04 document not found")
+
 type (
 	saleCopi04Service struct {
-		repo repository.SaleCopi04
+		repo       repository.SaleCopi04
+		pdfCompany utils.PDFCompany
 	}
 
 	SaleCopi04Service interface {
@@ -19,12 +30,15 @@ type (
 		Create(ctx context.Context, input dto.SaleCopi04Create, creator string, company string) error
 		Update(ctx context.Context, id string, input dto.SaleCopi04Update, modifier string, company string) error
 		Delete(ctx context.Context, id string) error
+		// ExportPDF prints a single COPI04 document; orientation is as in dto.ReportReq
+		ExportPDF(ctx context.Context, req dto.SaleCopi04Req, orientation string) (*dto.ReportFileResponse, error)
 	}
 )
 
-func NewSaleCopi04Service(repo repository.SaleCopi04) SaleCopi04Service {
+func NewSaleCopi04Service(repo repository.SaleCopi04, config *config.Config) SaleCopi04Service {
 	return &saleCopi04Service{
-		repo: repo,
+		repo:       repo,
+		pdfCompany: pdfCompany(config),
 	}
 }
 
@@ -48,3 +62,33 @@ func (s *saleCopi04Service) Delete(ctx context.Context, id string) error {
 	return s.repo.Delete(ctx, id)
 }
 
+func (s *saleCopi04Service) ExportPDF(ctx context.Context, req dto.SaleCopi04Req, orientation string) (*dto.ReportFileResponse, error) {
+	_, options, err := exportFormat(&dto.ReportReq{Format: models.ReportFormatPDF, Orientation: orientation})
+	if err != nil {
+		return nil, err
+	}
+	doc, err := s.repo.GetCopi04(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	id := strings.TrimSpace(doc.Header.ME001)
+	if id == "" {
+		return nil, ErrSaleCopi04NotFound
+	}
+
+	var buf bytes.Buffer
+	if err := s.repo.ExportCopi04ToPDF(ctx, &buf, doc, utils.PDFOptions{
+		Title:     "COPI04 - " + id,
+		Landscape: options.landscape,
+		Company:   s.pdfCompany,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to generate pdf file: %w", err)
+	}
+	return &dto.ReportFileResponse{
+		ReportName:  "COPI04",
+		FileName:    fmt.Sprintf("COPI04_%s.pdf", strings.NewReplacer(" ", "_", "/", "-").Replace(id)),
+		FileDetal:   buf.Bytes(),
+		ContentType: reportFormatContentTypes[models.ReportFormatPDF],
+		GeneratedAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"errors"
+	"sync"
+	"time"
+)
+
+// sessionTouchInterval limits how often last_seen_at is written for a busy session
+const sessionTouchInterval = time.Minute
+
+type (
+	sessionService struct {
+		sessionRepo repository.SessionRepo
+		tokenRepo   repository.TokenRepo
+		config      *config.Config
+		logger      Logger
+
+		mu      sync.Mutex
+		touched map[string]time.Time
+	}
+	SessionService interface {
+		Start(ctx context.Context, userID int64, sessionID, ipAddress, userAgent string, expiresAt time.Time) error
+		Extend(ctx context.Context, sessionID string, expiresAt time.Time) error
+		Touch(ctx context.Context, sessionID string)
+		ListUserSessions(ctx context.Context, userID int64, currentSessionID string) ([]dto.SessionRes, error)
+		ListActive(ctx context.Context) ([]dto.SessionRes, error)
+		Revoke(ctx context.Context, id int64, ownerID int64, revokedBy int64) error
+		End(ctx context.Context, sessionID string, revokedBy int64, reason string) error
+		RevokeAllForUser(ctx context.Context, userID int64, revokedBy int64) error
+		Purge(ctx context.Context) (int64, error)
+	}
+)
+
+func NewSessionService(sessionRepo repository.SessionRepo, tokenRepo repository.TokenRepo, config *config.Config, logger Logger) SessionService {
+	return &sessionService{
+		sessionRepo: sessionRepo,
+		tokenRepo:   tokenRepo,
+		config:      config,
+		logger:      logger,
+		touched:     make(map[string]time.Time),
+	}
+}
+
+// Start records a new signed-in device; sessionID is the refresh token family
+func (s *sessionService) Start(ctx context.Context, userID int64, sessionID, ipAddress, userAgent string, expiresAt time.Time) error {
+	now := time.Now()
+	return s.sessionRepo.Create(ctx, &models.Session{
+		SessionID:  sessionID,
+		UserID:     userID,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+		IssuedAt:   now,
+		ExpiresAt:  expiresAt,
+		LastSeenAt: now,
+	})
+}
+
+// Extend moves the session expiry along with a rotated refresh token
+func (s *sessionService) Extend(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	return s.sessionRepo.Extend(ctx, sessionID, expiresAt)
+}
+
+// Touch updates last_seen_at at most once per sessionTouchInterval. It runs on
+// every authenticated request, so failures are only logged.
+func (s *sessionService) Touch(ctx context.Context, sessionID string) {
+	if sessionID == "" {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if last, ok := s.touched[sessionID]; ok && now.Sub(last) < sessionTouchInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.touched[sessionID] = now
+	s.mu.Unlock()
+
+	if err := s.sessionRepo.Touch(ctx, sessionID, now); err != nil {
+		s.logger.Error(ctx, "Failed to update session last seen", err, map[string]interface{}{
+			"session_id": sessionID,
+		})
+	}
+}
+
+func (s *sessionService) ListUserSessions(ctx context.Context, userID int64, currentSessionID string) ([]dto.SessionRes, error) {
+	if userID <= 0 {
+		return nil, ErrInvalidUserID
+	}
+	sessions, err := s.sessionRepo.GetActiveByUser(ctx, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.SessionRes, 0, len(sessions))
+	for _, session := range sessions {
+		item := toSessionRes(&session)
+		item.Current = currentSessionID != "" && session.SessionID == currentSessionID
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+func (s *sessionService) ListActive(ctx context.Context) ([]dto.SessionRes, error) {
+	sessions, err := s.sessionRepo.GetActive(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]dto.SessionRes, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, toSessionRes(&session))
+	}
+	return res, nil
+}
+
+// Revoke signs out one session. A non-zero ownerID restricts it to that
+// user's own sessions; admins pass 0.
+func (s *sessionService) Revoke(ctx context.Context, id int64, ownerID int64, revokedBy int64) error {
+	session, err := s.sessionRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if ownerID != 0 && session.UserID != ownerID {
+		return repository.ErrSessionNotFound
+	}
+	if session.RevokedAt != nil {
+		return nil
+	}
+
+	reason := "session revoked by user"
+	if ownerID == 0 {
+		reason = "session revoked by admin"
+	}
+	return s.End(ctx, session.SessionID, revokedBy, reason)
+}
+
+// End revokes the session's refresh tokens and every access token carrying its id
+func (s *sessionService) End(ctx context.Context, sessionID string, revokedBy int64, reason string) error {
+	if sessionID == "" {
+		return nil
+	}
+
+	session, err := s.sessionRepo.GetBySessionID(ctx, sessionID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSessionNotFound) {
+			// Tokens issued before sessions were tracked
+			return s.tokenRepo.RevokeRefreshFamily(ctx, sessionID)
+		}
+		return err
+	}
+
+	if err := s.tokenRepo.RevokeRefreshFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	now := time.Now()
+	if err := s.tokenRepo.Revoke(ctx, &models.RevokedToken{
+		Scope:     models.RevocationScopeSession,
+		SessionID: sessionID,
+		UserID:    session.UserID,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		RevokedAt: now,
+		ExpiresAt: now.Add(s.config.GetJWTExpiry()),
+	}); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.Revoke(ctx, sessionID, revokedBy); err != nil && !errors.Is(err, repository.ErrSessionNotFound) {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.touched, sessionID)
+	s.mu.Unlock()
+
+	s.logger.Info(ctx, "Session ended", map[string]interface{}{
+		"session_id": session.ID,
+		"user_id":    session.UserID,
+		"revoked_by": revokedBy,
+		"reason":     reason,
+	})
+	return nil
+}
+
+// RevokeAllForUser marks every session of userID as revoked; the tokens
+// themselves are revoked by the caller with a user-wide revocation.
+func (s *sessionService) RevokeAllForUser(ctx context.Context, userID int64, revokedBy int64) error {
+	return s.sessionRepo.RevokeByUser(ctx, userID, revokedBy)
+}
+
+// Purge deletes expired sessions and forgets stale last-seen marks
+func (s *sessionService) Purge(ctx context.Context) (int64, error) {
+	now := time.Now()
+	s.mu.Lock()
+	for sessionID, last := range s.touched {
+		if now.Sub(last) > sessionTouchInterval {
+			delete(s.touched, sessionID)
+		}
+	}
+	s.mu.Unlock()
+
+	return s.sessionRepo.DeleteExpired(ctx, now)
+}
+
+func toSessionRes(session *models.Session) dto.SessionRes {
+	res := dto.SessionRes{
+		ID:         session.ID,
+		UserID:     session.UserID,
+		IPAddress:  session.IPAddress,
+		UserAgent:  session.UserAgent,
+		IssuedAt:   session.IssuedAt,
+		ExpiresAt:  session.ExpiresAt,
+		LastSeenAt: session.LastSeenAt,
+		RevokedAt:  session.RevokedAt,
+	}
+	if session.User != nil {
+		res.Username = session.User.Username
+	}
+	return res
+}
@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"cqs-kanban/config"
+	"cqs-kanban/internal/dto"
+	"cqs-kanban/internal/models"
+	"cqs-kanban/internal/repository"
+	"cqs-kanban/internal/utils"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTwoFactorNotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorRequired       = errors.New("two-factor authentication is mandatory for this role")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidChallenge        = errors.New("invalid or expired login challenge")
+)
+
+const (
+	challengeTokenBytes  = 32
+	defaultRecoveryCodes = 10
+	defaultMaxAttempts   = 5
+)
+
+type (
+	twoFactorService struct {
+		twoFactorRepo repository.TwoFactorRepo
+		config        *config.Config
+		logger        Logger
+	}
+	TwoFactorService interface {
+		Status(ctx context.Context, userID int64, role string) (*dto.TwoFactorStatusResponse, error)
+		Enroll(ctx context.Context, userID int64, username string) (*dto.TwoFactorEnrollResponse, error)
+		Confirm(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error)
+		Disable(ctx context.Context, userID int64, role string, code string) error
+		Reset(ctx context.Context, userID int64) error
+		RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error)
+		IsEnabled(ctx context.Context, userID int64) (bool, error)
+		IsRequired(role string) bool
+		CreateChallenge(ctx context.Context, userID int64, ipAddress, userAgent string) (string, time.Time, error)
+		ChallengeUser(ctx context.Context, token string) (int64, error)
+		ConsumeChallenge(ctx context.Context, token, code string) (int64, error)
+		PurgeExpiredChallenges(ctx context.Context) (int64, error)
+	}
+)
+
+func NewTwoFactorService(twoFactorRepo repository.TwoFactorRepo, config *config.Config, logger Logger) TwoFactorService {
+	return &twoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		config:        config,
+		logger:        logger,
+	}
+}
+
+func (s *twoFactorService) Status(ctx context.Context, userID int64, role string) (*dto.TwoFactorStatusResponse, error) {
+	enabled, err := s.IsEnabled(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	res := &dto.TwoFactorStatusResponse{
+		Enabled:  enabled,
+		Required: s.IsRequired(role),
+	}
+	if enabled {
+		if res.RecoveryCodesRemaining, err = s.twoFactorRepo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// Enroll creates a new pending secret; it replaces any earlier unconfirmed one.
+func (s *twoFactorService) Enroll(ctx context.Context, userID int64, username string) (*dto.TwoFactorEnrollResponse, error) {
+	totp, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil && !errors.Is(err, repository.ErrTOTPNotFound) {
+		return nil, err
+	}
+	if totp != nil && totp.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if totp == nil {
+		totp = &models.UserTOTP{UserID: userID}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	totp.Secret = secret
+	totp.LastUsedStep = 0
+	if err := s.twoFactorRepo.SaveTOTP(ctx, totp); err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.issuer(), username, secret),
+	}, nil
+}
+
+// Confirm enables 2FA once the user proves the authenticator works and
+// returns the recovery codes, which are shown only this once.
+func (s *twoFactorService) Confirm(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error) {
+	totp, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			return nil, ErrTwoFactorNotEnabled
+		}
+		return nil, err
+	}
+	if totp.Enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	now := time.Now()
+	totp.Enabled = true
+	totp.ConfirmedAt = &now
+	totp.LastUsedStep = step
+	if err := s.twoFactorRepo.SaveTOTP(ctx, totp); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "Two-factor authentication enabled", map[string]interface{}{
+		"user_id": userID,
+	})
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+func (s *twoFactorService) Disable(ctx context.Context, userID int64, role string, code string) error {
+	if s.IsRequired(role) {
+		return ErrTwoFactorRequired
+	}
+	if err := s.verifyCode(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.Reset(ctx, userID)
+}
+
+// Reset removes the secret and recovery codes without asking for a code;
+// administrators use it when a user lost their device.
+func (s *twoFactorService) Reset(ctx context.Context, userID int64) error {
+	if err := s.twoFactorRepo.DeleteTOTP(ctx, userID); err != nil {
+		return err
+	}
+	s.logger.Info(ctx, "Two-factor authentication disabled", map[string]interface{}{
+		"user_id": userID,
+	})
+	return nil
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID int64, code string) (*dto.RecoveryCodesResponse, error) {
+	if err := s.verifyCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userID)
+}
+
+func (s *twoFactorService) IsEnabled(ctx context.Context, userID int64) (bool, error) {
+	totp, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.Enabled, nil
+}
+
+func (s *twoFactorService) IsRequired(role string) bool {
+	for _, required := range s.config.Security.TwoFactor.RequiredRoles {
+		if strings.EqualFold(required, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateChallenge issues the token that Login returns instead of access tokens.
+func (s *twoFactorService) CreateChallenge(ctx context.Context, userID int64, ipAddress, userAgent string) (string, time.Time, error) {
+	token, err := utils.GenerateRandomToken(challengeTokenBytes)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate login challenge: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.config.GetTwoFactorChallengeExpiry())
+	if err := s.twoFactorRepo.CreateChallenge(ctx, &models.LoginChallenge{
+		UserID:    userID,
+		TokenHash: utils.HashToken(token),
+		IPAddress: ipAddress,
+		UserAgent: userAgent,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// ChallengeUser returns the user of a live challenge without using it up, so
+// the caller can check the user before a code is tried.
+func (s *twoFactorService) ChallengeUser(ctx context.Context, token string) (int64, error) {
+	challenge, err := s.liveChallenge(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+	return challenge.UserID, nil
+}
+
+// ConsumeChallenge checks code for the challenge and returns its user. A
+// challenge is single use and dies after too many wrong codes.
+func (s *twoFactorService) ConsumeChallenge(ctx context.Context, token, code string) (int64, error) {
+	challenge, err := s.liveChallenge(ctx, token)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.verifyCode(ctx, challenge.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if incErr := s.twoFactorRepo.IncrementChallengeAttempts(ctx, challenge.ID); incErr != nil {
+				return 0, incErr
+			}
+		}
+		return challenge.UserID, err
+	}
+
+	used, err := s.twoFactorRepo.UseChallenge(ctx, challenge.ID)
+	if err != nil {
+		return 0, err
+	}
+	if !used {
+		return 0, ErrInvalidChallenge
+	}
+	return challenge.UserID, nil
+}
+
+// liveChallenge looks up a challenge that is unused, unexpired and has tries left
+func (s *twoFactorService) liveChallenge(ctx context.Context, token string) (*models.LoginChallenge, error) {
+	challenge, err := s.twoFactorRepo.GetChallengeByHash(ctx, utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, repository.ErrChallengeNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+		return nil, err
+	}
+	if challenge.UsedAt != nil || time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= s.maxAttempts() {
+		return nil, ErrInvalidChallenge
+	}
+	return challenge, nil
+}
+
+func (s *twoFactorService) PurgeExpiredChallenges(ctx context.Context) (int64, error) {
+	return s.twoFactorRepo.DeleteExpiredChallenges(ctx, time.Now())
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code
+func (s *twoFactorService) verifyCode(ctx context.Context, userID int64, code string) error {
+	totp, err := s.twoFactorRepo.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTOTPNotFound) {
+			return ErrTwoFactorNotEnabled
+		}
+		return err
+	}
+	if !totp.Enabled {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now()); ok {
+		fresh, err := s.twoFactorRepo.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	used, err := s.twoFactorRepo.UseRecoveryCode(ctx, userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	s.logger.Warn(ctx, "Recovery code used", map[string]interface{}{
+		"user_id": userID,
+	})
+	return nil
+}
+
+func (s *twoFactorService) newRecoveryCodes(ctx context.Context, userID int64) (*dto.RecoveryCodesResponse, error) {
+	count := s.config.Security.TwoFactor.RecoveryCodes
+	if count <= 0 {
+		count = defaultRecoveryCodes
+	}
+
+	codes := make([]string, 0, count)
+	hashes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		random, err := utils.GenerateTOTPSecret()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		code := strings.ToLower(random[:5] + "-" + random[5:10])
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return &dto.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func (s *twoFactorService) issuer() string {
+	if s.config.Security.TwoFactor.Issuer != "" {
+		return s.config.Security.TwoFactor.Issuer
+	}
+	return s.config.Server.Name
+}
+
+func (s *twoFactorService) maxAttempts() int {
+	if s.config.Security.TwoFactor.MaxAttempts > 0 {
+		return s.config.Security.TwoFactor.MaxAttempts
+	}
+	return defaultMaxAttempts
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -4,14 +4,12 @@ import (
 	"context"
 	"cqs-kanban/internal/dto"
 	"cqs-kanban/internal/repository"
-	"cqs-kanban/internal/utils"
-	"errors"
-	"fmt"
 )
 
 type (
 	userService struct {
-		userRepo repository.UserRepo
+		userRepo        repository.UserRepo
+		passwordService PasswordService
 	}
 	UserService interface {
 		Create(ctx context.Context, req dto.UserCreateReq) error
@@ -25,14 +23,25 @@ type (
 	}
 )
 
-func NewUserService(userRepo repository.UserRepo) UserService {
+func NewUserService(userRepo repository.UserRepo, passwordService PasswordService) UserService {
 	return &userService{
-		userRepo: userRepo,
+		userRepo:        userRepo,
+		passwordService: passwordService,
 	}
 }
 
 func (u *userService) Create(ctx context.Context, req dto.UserCreateReq) error {
-	return u.userRepo.Create(ctx, req)
+	if err := u.passwordService.Validate(req.Password, req.Username); err != nil {
+		return err
+	}
+	if err := u.userRepo.Create(ctx, req); err != nil {
+		return err
+	}
+	user, err := u.userRepo.GetByUsername(ctx, req.Username)
+	if err != nil {
+		return err
+	}
+	return u.passwordService.RecordHistory(ctx, user.ID, user.Password)
 }
 func (u *userService) GetByID(ctx context.Context, req dto.UserDetailReq) (*dto.UserDetailRes, error) {
 	return u.userRepo.GetUser(ctx, req)
@@ -52,24 +61,21 @@ func (u *userService) GetByUsername(ctx context.Context, username string) (*dto.
 	}, nil
 }
 func (u *userService) Update(ctx context.Context, id int, req dto.UserUpdateReq) error {
-	return u.userRepo.Update(ctx, int64(id), req)
-}
-func (u *userService) UpdatePassword(ctx context.Context, id int64, req dto.UpdatePasswordRequest) error {
-	user, err := u.userRepo.GetByID(ctx, id)
-	if err != nil {
-		return errors.New("user not found")
-	}
-	if !utils.CheckPasswordHash(req.CurrentPassword, user.Password) {
-		return errors.New("current password is incorrect")
-	}
-	if req.NewPassword != req.ConfirmPassword {
-		return errors.New("new password cannot be the same as the current password")
+	// Passwords go through the policy and history checks
+	password := req.Password
+	req.Password = nil
+	if password == nil || req.Username != nil || req.FullName != nil || req.Email != nil || req.DepartmentID != nil || req.Role != nil {
+		if err := u.userRepo.Update(ctx, int64(id), req); err != nil {
+			return err
+		}
 	}
-	hashedPassword, err := utils.HashPassword(req.NewPassword)
-	if err != nil {
-		return fmt.Errorf("error hashing password: %w", err)
+	if password != nil {
+		return u.passwordService.SetPassword(ctx, int64(id), *password)
 	}
-	return u.userRepo.UpdatePassword(ctx, id, hashedPassword)
+	return nil
+}
+func (u *userService) UpdatePassword(ctx context.Context, id int64, req dto.UpdatePasswordRequest) error {
+	return u.passwordService.ChangePassword(ctx, id, req)
 }
 func (u *userService) Delete(ctx context.Context, id int) error {
 	return u.userRepo.Delete(ctx, int64(id))
@@ -88,6 +94,7 @@ func (u *userService) GetAll(ctx context.Context) ([]dto.UserDetailRes, error) {
 			FullName:     user.FullName,
 			Email:        user.Email,
 			DepartmentID: user.DepartmentID,
+			Role:         user.Role,
 			CreatedAt:    user.CreatedAt.Format("2006-01-02"),
 			UpdatedAt:    user.UpdatedAt.Format("2006-01-02"),
 		})
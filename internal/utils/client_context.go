@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// clientCheckInterval is how often ClientContext checks the connection
+const clientCheckInterval = time.Second
+
+// ClientContext returns a context that is cancelled when the client of the
+// request disconnects or the server shuts down. fasthttp does not report
+// disconnects itself, so the connection is checked periodically. cancel must
+// be called before the handler returns.
+func ClientContext(c fiber.Ctx) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(c.RequestCtx())
+	conn := c.RequestCtx().Conn()
+	go func() {
+		ticker := time.NewTicker(clientCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if connClosed(conn) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+
+package utils
+
+import "net"
+
+// connClosed cannot detect disconnects on this platform
+func connClosed(conn net.Conn) bool {
+	return false
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd
+
+package utils
+
+import (
+	"net"
+	"syscall"
+)
+
+// connClosed peeks at the socket without consuming data; a zero-byte read
+// means the peer closed the connection.
+func connClosed(conn net.Conn) bool {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return false
+	}
+	closed := false
+	buf := make([]byte, 1)
+	_ = raw.Read(func(fd uintptr) bool {
+		n, _, err := syscall.Recvfrom(int(fd), buf, syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		closed = n == 0 && err == nil
+		return true
+	})
+	return closed
+}
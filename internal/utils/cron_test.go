@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 8-18 * * 1-5"},
+		{expr: "0,30 9 1,15 1-12/3 *"},
+		{expr: "5/10 * * * *"},
+		{expr: "0 0 * * 7"},
+		{expr: "@daily"},
+		{expr: " @HOURLY "},
+		{expr: "", wantErr: true},
+		{expr: "* * * *", wantErr: true},
+		{expr: "* * * * * *", wantErr: true},
+		{expr: "60 * * * *", wantErr: true},
+		{expr: "* 24 * * *", wantErr: true},
+		{expr: "* * 0 * *", wantErr: true},
+		{expr: "* * * 13 *", wantErr: true},
+		{expr: "* * * * 8", wantErr: true},
+		{expr: "*/0 * * * *", wantErr: true},
+		{expr: "5-1 * * * *", wantErr: true},
+		{expr: "a * * * *", wantErr: true},
+		{expr: "1,,2 * * * *", wantErr: true},
+		{expr: "@yearly", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := ParseCron(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseCron(%q) error = %v, want error %v", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	at := func(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{name: "step", expr: "*/15 * * * *", from: at(time.UTC, 2026, 1, 5, 10, 7), want: at(time.UTC, 2026, 1, 5, 10, 15)},
+		{name: "strictly after", expr: "30 8 * * *", from: at(time.UTC, 2026, 1, 5, 8, 30), want: at(time.UTC, 2026, 1, 6, 8, 30)},
+		{name: "seconds are dropped", expr: "* * * * *", from: at(time.UTC, 2026, 1, 5, 8, 30).Add(59 * time.Second), want: at(time.UTC, 2026, 1, 5, 8, 31)},
+		{name: "weekdays skip the weekend", expr: "0 9 * * 1-5", from: at(time.UTC, 2026, 1, 9, 9, 0), want: at(time.UTC, 2026, 1, 12, 9, 0)},
+		{name: "sunday as 7", expr: "0 0 * * 7", from: at(time.UTC, 2026, 2, 2, 0, 0), want: at(time.UTC, 2026, 2, 8, 0, 0)},
+		{name: "month rollover", expr: "@monthly", from: at(time.UTC, 2026, 1, 31, 23, 59), want: at(time.UTC, 2026, 2, 1, 0, 0)},
+		{name: "year rollover", expr: "0 6 * * *", from: at(time.UTC, 2026, 12, 31, 7, 0), want: at(time.UTC, 2027, 1, 1, 6, 0)},
+		{name: "leap day", expr: "0 0 29 2 *", from: at(time.UTC, 2026, 3, 1, 0, 0), want: at(time.UTC, 2028, 2, 29, 0, 0)},
+		{name: "day of month or week", expr: "0 12 1 * 0", from: at(time.UTC, 2026, 2, 2, 0, 0), want: at(time.UTC, 2026, 2, 8, 12, 0)},
+		{name: "day of month and any week day", expr: "0 12 1 * *", from: at(time.UTC, 2026, 2, 2, 0, 0), want: at(time.UTC, 2026, 3, 1, 12, 0)},
+		{name: "location", expr: "0 9 * * *", from: at(time.UTC, 2026, 1, 5, 1, 0).In(saigon), want: at(saigon, 2026, 1, 5, 9, 0)},
+		{name: "never", expr: "0 0 31 2 *", from: at(time.UTC, 2026, 1, 1, 0, 0), want: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatalf("ParseCron(%q): %v", tt.expr, err)
+			}
+			got := schedule.Next(tt.from)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+			if !got.IsZero() && got.Location() != tt.from.Location() {
+				t.Errorf("Next(%v) location = %v, want %v", tt.from, got.Location(), tt.from.Location())
+			}
+		})
+	}
+}
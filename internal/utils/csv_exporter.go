@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// utf8BOM lets Excel on Windows detect UTF-8 text
+const utf8BOM = "\ufeff"
+
+type DelimitedOptions struct {
+	Delimiter rune
+	// DecimalSeparator replaces the "." of decimal values; defaults to "."
+	DecimalSeparator string
+	BOM              bool
+}
+
+// WriteDelimitedTable writes table as delimited text to w, one line for the
+// column titles and one per row. The title is not written. Each row is
+// encoded as table.Rows yields it and not kept, so the memory used is that of
+// the row source.
+func WriteDelimitedTable(w io.Writer, table ExportTable, options DelimitedOptions) error {
+	if len(table.Columns) == 0 {
+		return fmt.Errorf("delimited table has no columns")
+	}
+	if options.BOM {
+		if _, err := io.WriteString(w, utf8BOM); err != nil {
+			return fmt.Errorf("error writing byte order mark: %w", err)
+		}
+	}
+	writer := csv.NewWriter(w)
+	if options.Delimiter != 0 {
+		writer.Comma = options.Delimiter
+	}
+
+	record := make([]string, len(table.Columns))
+	for i, column := range table.Columns {
+		record[i] = column.Title
+	}
+	if err := writer.Write(record); err != nil {
+		return fmt.Errorf("error writing headers: %w", err)
+	}
+
+	line := 0
+	err := table.Rows(func(row ExportRow) error {
+		line++
+		for j, column := range table.Columns {
+			record[j] = ""
+			if j < len(row.Values) {
+				record[j] = delimitedValue(row.Values[j], column.Format, options.DecimalSeparator)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("error writing row %d: %w", line, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error writing delimited file: %w", err)
+	}
+	return nil
+}
+
+// delimitedValue formats a value as text. Numbers are written without
+// grouping, and numeric text keeps the precision it came with.
+func delimitedValue(value any, format ColumnFormat, decimalSeparator string) string {
+	var text string
+	numeric := false
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		text = v
+		if format.IsNumeric() {
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			numeric = err == nil
+		}
+	case []byte:
+		text = string(v)
+	case float64:
+		text, numeric = strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		text, numeric = strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case time.Time:
+		if format == FormatDate {
+			return v.Format("2006-01-02")
+		}
+		return v.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(v)
+	}
+	if numeric && decimalSeparator != "" && decimalSeparator != "." {
+		text = strings.Replace(strings.TrimSpace(text), ".", decimalSeparator, 1)
+	}
+	return text
+}
@@ -2,179 +2,226 @@ package utils
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"io"
 	"strings"
-	"time"
 
 	"github.com/xuri/excelize/v2"
 )
 
-// ExportToExcel exports data to Excel file
-func ExportToExcel(data []map[string]interface{}, headers []string, title string) (string, error) {
-	// Create a new Excel file
+const (
+	excelSheet       = "Sheet1"
+	excelColumnWidth = 15
+	// Title on row 1, headers on row 3, data from row 4
+	excelHeaderRow = 3
+	excelDataRow   = 4
+)
+
+var excelBorder = []excelize.Border{
+	{Type: "left", Color: "000000", Style: 1},
+	{Type: "top", Color: "000000", Style: 1},
+	{Type: "bottom", Color: "000000", Style: 1},
+	{Type: "right", Color: "000000", Style: 1},
+}
+
+// WriteExcelTable writes table as an xlsx workbook to w. Rows go through
+// excelize's StreamWriter, which keeps memory flat by spilling to a temporary
+// file, and the workbook is zipped straight into w. Cells are styled by column
+// and numbers and dates kept as text are written as real numbers and dates.
+// Rows of a grouped table are outlined by group, with header, subtotal and
+// total rows in bold.
+func WriteExcelTable(w io.Writer, table ExportTable) error {
+	if len(table.Columns) == 0 {
+		return fmt.Errorf("excel table has no columns")
+	}
 	f := excelize.NewFile()
 	defer f.Close()
 
-	// Get the default sheet
-	sheetName := "Sheet1"
-
-	// Set title
-	f.SetCellValue(sheetName, "A1", title)
-
-	// Set title style
-	titleStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Size:  16,
-			Bold:  true,
-			Color: "1F497D",
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-	})
+	sw, err := f.NewStreamWriter(excelSheet)
 	if err != nil {
-		return "", fmt.Errorf("error creating title style: %w", err)
-	}
-
-	// Apply title style and merge cells for title
-	f.SetCellStyle(sheetName, "A1", fmt.Sprintf("%c1", rune('A'+len(headers)-1)), titleStyle)
-	f.MergeCell(sheetName, "A1", fmt.Sprintf("%c1", rune('A'+len(headers)-1)))
-
-	// Set headers
-	headerStyle, err := f.NewStyle(&excelize.Style{
-		Font: &excelize.Font{
-			Bold:  true,
-			Color: "FFFFFF",
-		},
-		Fill: excelize.Fill{
-			Type:    "pattern",
-			Color:   []string{"4472C4"},
-			Pattern: 1,
-		},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "center",
-			Vertical:   "center",
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("error creating header style: %w", err)
-	}
-
-	// Write headers
-	for i, header := range headers {
-		cellPos := fmt.Sprintf("%c3", rune('A'+i))
-		f.SetCellValue(sheetName, cellPos, header)
-	}
-
-	// Apply header style
-	headerRange := fmt.Sprintf("A3:%c3", rune('A'+len(headers)-1))
-	f.SetCellStyle(sheetName, headerRange, headerRange, headerStyle)
-
-	// Data cell styles
-	dataStyle, err := f.NewStyle(&excelize.Style{
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Alignment: &excelize.Alignment{
-			Vertical: "center",
-		},
-	})
-	if err != nil {
-		return "", fmt.Errorf("error creating data style: %w", err)
-	}
-
-	// Number format style
-	numberStyle, err := f.NewStyle(&excelize.Style{
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Alignment: &excelize.Alignment{
-			Horizontal: "right",
-			Vertical:   "center",
-		},
-		NumFmt: 3, // #,##0 format
-	})
+		return fmt.Errorf("error creating stream writer: %w", err)
+	}
+	styles, err := newExcelStyles(f)
 	if err != nil {
-		return "", fmt.Errorf("error creating number style: %w", err)
+		return err
+	}
+	for i, column := range table.Columns {
+		width := float64(column.Width)
+		if width <= 0 {
+			width = excelColumnWidth
+		}
+		if err := sw.SetColWidth(i+1, i+1, width); err != nil {
+			return fmt.Errorf("error setting column width: %w", err)
+		}
 	}
 
-	// Write data
-	for i, item := range data {
-		row := i + 4 // Data starts from row 4
+	// Title, merged across the table
+	if err := sw.SetRow("A1", []any{excelize.Cell{StyleID: styles.title, Value: table.Title}}, excelize.RowOpts{Height: 30}); err != nil {
+		return fmt.Errorf("error writing title: %w", err)
+	}
+	if len(table.Columns) > 1 {
+		lastCell, _ := excelize.CoordinatesToCellName(len(table.Columns), 1)
+		if err := sw.MergeCell("A1", lastCell); err != nil {
+			return fmt.Errorf("error merging title: %w", err)
+		}
+	}
 
-		for j, header := range headers {
-			cellPos := fmt.Sprintf("%c%d", rune('A'+j), row)
-			f.SetCellValue(sheetName, cellPos, item[header])
+	cells := make([]any, len(table.Columns))
+	for i, column := range table.Columns {
+		cells[i] = excelize.Cell{StyleID: styles.header, Value: column.Title}
+	}
+	headerCell, _ := excelize.CoordinatesToCellName(1, excelHeaderRow)
+	if err := sw.SetRow(headerCell, cells, excelize.RowOpts{Height: 25}); err != nil {
+		return fmt.Errorf("error writing headers: %w", err)
+	}
 
-			// Apply style based on data type
-			switch header {
-			case "Mã sản phẩm", "Tên sản phẩm", "Đơn vị":
-				f.SetCellStyle(sheetName, cellPos, cellPos, dataStyle)
-			default:
-				f.SetCellStyle(sheetName, cellPos, cellPos, numberStyle)
+	columnStyles := make([]int, len(table.Columns))
+	summaryColumns := make([]ExportColumn, len(table.Columns))
+	summaryStyles := make([]int, len(table.Columns))
+	for i, column := range table.Columns {
+		if columnStyles[i], err = styles.forColumn(f, column, false); err != nil {
+			return err
+		}
+		summaryColumns[i] = column
+		if column.SummaryCount {
+			summaryColumns[i] = ExportColumn{Title: column.Title, Format: FormatInteger, Align: column.Align}
+		}
+		if summaryStyles[i], err = styles.forColumn(f, summaryColumns[i], true); err != nil {
+			return err
+		}
+	}
+	rowNum := excelDataRow
+	err = table.Rows(func(row ExportRow) error {
+		rowColumns, rowStyles := table.Columns, columnStyles
+		if row.Kind.IsSummary() {
+			rowColumns, rowStyles = summaryColumns, summaryStyles
+		}
+		for j, column := range rowColumns {
+			var value any
+			if j < len(row.Values) {
+				value = excelValue(column.Format, row.Values[j])
 			}
+			cells[j] = excelize.Cell{StyleID: rowStyles[j], Value: value}
 		}
+		cell, _ := excelize.CoordinatesToCellName(1, rowNum)
+		// Outline levels start below the grand total: detail rows of a single
+		// group level are at level 1, its headers and subtotals at level 0
+		if err := sw.SetRow(cell, cells, excelize.RowOpts{OutlineLevel: max(row.Level-1, 0)}); err != nil {
+			return fmt.Errorf("error writing row %d: %w", rowNum-excelDataRow+1, err)
+		}
+		rowNum++
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	// Set column width
-	for i := range headers {
-		colName := string(rune('A' + i))
-		f.SetColWidth(sheetName, colName, colName, 15)
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("error flushing excel rows: %w", err)
 	}
-
-	// Set row height
-	f.SetRowHeight(sheetName, 1, 30)
-	f.SetRowHeight(sheetName, 3, 25)
-
-	// Generate timestamp for filename
-	timestamp := time.Now().Format("20060102_150405")
-
-	// Create downloads directory if it doesn't exist
-	downloadPath := "public/downloads"
-	if err := os.MkdirAll(downloadPath, 0755); err != nil {
-		return "", fmt.Errorf("error creating downloads directory: %w", err)
+	if err := f.Write(w); err != nil {
+		return fmt.Errorf("error writing excel file: %w", err)
 	}
+	return nil
+}
 
-	// Create sanitized filename
-	safeTitlePart := sanitizeFilename(title)
-	if len(safeTitlePart) > 30 {
-		safeTitlePart = safeTitlePart[:30]
+// excelValue converts numbers and dates kept as text for numeric and date
+// columns, so that Excel formats, sorts and sums them
+func excelValue(format ColumnFormat, value any) any {
+	switch {
+	case format.IsNumeric():
+		if number, ok := NumberValue(value); ok {
+			return number
+		}
+	case format == FormatDate:
+		if date, ok := DateValue(value); ok {
+			return date
+		}
 	}
+	return value
+}
 
-	// Complete filename
-	filename := fmt.Sprintf("%s_%s.xlsx", safeTitlePart, timestamp)
-	filePath := filepath.Join(downloadPath, filename)
-
-	// Save the file
-	if err := f.SaveAs(filePath); err != nil {
-		return "", fmt.Errorf("error saving Excel file: %w", err)
+// excelNumberFormat is the number format code of a column; text columns have none
+func excelNumberFormat(column ExportColumn) string {
+	if column.NumberFormat != "" {
+		return column.NumberFormat
+	}
+	fraction := ""
+	if digits := column.FractionDigits(); digits > 0 {
+		fraction = "." + strings.Repeat("0", digits)
 	}
+	switch column.Format {
+	case FormatInteger, FormatDecimal:
+		return "#,##0" + fraction
+	case FormatCurrency:
+		return "#,##0" + fraction + ";[Red]-#,##0" + fraction
+	case FormatPercent:
+		return "0" + fraction + "%"
+	case FormatDate:
+		return "yyyy-mm-dd"
+	default:
+		return ""
+	}
+}
 
-	return filePath, nil
+type excelStyles struct {
+	title, header int
+	// columns holds the data styles created so far by number format, alignment and weight
+	columns map[excelColumnStyle]int
 }
 
-// sanitizeFilename removes invalid characters from filename
-func sanitizeFilename(name string) string {
-	name = filepath.Clean(name)
-	invalidChars := []rune{'<', '>', ':', '"', '/', '\\', '|', '?', '*'}
+type excelColumnStyle struct {
+	numberFormat, align string
+	bold                bool
+}
 
-	for _, char := range invalidChars {
-		name = strings.ReplaceAll(name, string(char), "_")
+// forColumn returns the style of the column's cells; bold is used for header,
+// subtotal and total rows
+func (s *excelStyles) forColumn(f *excelize.File, column ExportColumn, bold bool) (int, error) {
+	numberFormat, align := excelNumberFormat(column), column.Alignment()
+	key := excelColumnStyle{numberFormat: numberFormat, align: align, bold: bold}
+	if style, ok := s.columns[key]; ok {
+		return style, nil
+	}
+	style := &excelize.Style{
+		Border:    excelBorder,
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	}
+	if bold {
+		style.Font = &excelize.Font{Bold: true}
+		style.Fill = excelize.Fill{Type: "pattern", Color: []string{"D9E1F2"}, Pattern: 1}
+	}
+	if align != AlignLeft {
+		style.Alignment.Horizontal = align
 	}
+	if numberFormat != "" {
+		style.CustomNumFmt = &numberFormat
+	}
+	id, err := f.NewStyle(style)
+	if err != nil {
+		return 0, fmt.Errorf("error creating style for column %q: %w", column.Title, err)
+	}
+	s.columns[key] = id
+	return id, nil
+}
 
-	return name
+func newExcelStyles(f *excelize.File) (*excelStyles, error) {
+	var (
+		styles = excelStyles{columns: make(map[excelColumnStyle]int)}
+		err    error
+	)
+	if styles.title, err = f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Size: 16, Bold: true, Color: "1F497D"},
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	}); err != nil {
+		return nil, fmt.Errorf("error creating title style: %w", err)
+	}
+	if styles.header, err = f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill:      excelize.Fill{Type: "pattern", Color: []string{"4472C4"}, Pattern: 1},
+		Border:    excelBorder,
+		Alignment: &excelize.Alignment{Horizontal: "center", Vertical: "center"},
+	}); err != nil {
+		return nil, fmt.Errorf("error creating header style: %w", err)
+	}
+	return &styles, nil
 }
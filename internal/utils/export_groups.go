@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// Aggregate functions computed for the subtotals and grand total of a column
+type Aggregate string
+
+const (
+	AggregateSum   Aggregate = "sum"
+	AggregateCount Aggregate = "count"
+	AggregateAvg   Aggregate = "avg"
+	AggregateMin   Aggregate = "min"
+	AggregateMax   Aggregate = "max"
+)
+
+// RowKind is the kind of a row of a grouped result
+type RowKind string
+
+const (
+	RowDetail   RowKind = "detail"
+	RowHeader   RowKind = "header"
+	RowSubtotal RowKind = "subtotal"
+	RowTotal    RowKind = "total"
+)
+
+// IsSummary reports whether rows of the kind summarize a group or the table
+func (k RowKind) IsSummary() bool {
+	return k == RowHeader || k == RowSubtotal || k == RowTotal
+}
+
+// GroupAggregate aggregates the values of column Code. Values of date columns
+// are compared as dates, others as numbers.
+type GroupAggregate struct {
+	Code      string
+	Aggregate Aggregate
+	Format    ColumnFormat
+}
+
+// GroupedRow is a row of a grouped result. Level is 1 for the header and
+// subtotal of the outermost group, one more for each group inside it, and
+// one below the innermost group for detail rows; the grand total is level 0.
+// Count is the number of detail rows of a header, subtotal or total.
+type GroupedRow struct {
+	Kind   RowKind
+	Level  int
+	Count  int
+	Values map[string]any
+}
+
+// GroupRows groups consecutive rows with equal values of the groupBy columns,
+// outermost first, so rows must come sorted by them. Each group opens with a
+// header row holding the group values and closes with a subtotal row holding
+// them along with the aggregates; a grand total row ends the result. Detail
+// rows are the rows of data, not copies.
+func GroupRows(data []map[string]any, groupBy []string, aggregates []GroupAggregate) []GroupedRow {
+	res := make([]GroupedRow, 0, len(data)+2*len(groupBy)+1)
+	// Headers get their count once their subtotal is known
+	headers := make([]int, 0, len(groupBy))
+	grouper := NewGrouper(groupBy, aggregates, func(row GroupedRow) error {
+		switch row.Kind {
+		case RowHeader:
+			headers = append(headers, len(res))
+		case RowSubtotal:
+			res[headers[len(headers)-1]].Count = row.Count
+			headers = headers[:len(headers)-1]
+		}
+		res = append(res, row)
+		return nil
+	})
+	for _, row := range data {
+		grouper.Add(row)
+	}
+	grouper.Close()
+	return res
+}
+
+// Grouper groups rows as they are added, like GroupRows, and passes each
+// grouped row to emit as soon as it is complete, so that rows can be grouped
+// while they are read. Headers are emitted before their group's rows, so
+// their Count is not set.
+type Grouper struct {
+	groupBy    []string
+	aggregates []GroupAggregate
+	emit       func(row GroupedRow) error
+	open       []openGroup
+	total      *groupTotals
+}
+
+type openGroup struct {
+	key    string
+	values map[string]any
+	totals *groupTotals
+}
+
+func NewGrouper(groupBy []string, aggregates []GroupAggregate, emit func(row GroupedRow) error) *Grouper {
+	return &Grouper{
+		groupBy:    groupBy,
+		aggregates: aggregates,
+		emit:       emit,
+		open:       make([]openGroup, 0, len(groupBy)),
+		total:      newGroupTotals(aggregates),
+	}
+}
+
+// Add adds the next row, closing the groups it does not belong to and opening
+// its own. It returns the first error of emit.
+func (g *Grouper) Add(row map[string]any) error {
+	level := 0
+	for level < len(g.open) && g.open[level].key == groupKey(row[g.groupBy[level]]) {
+		level++
+	}
+	if err := g.closeGroups(level); err != nil {
+		return err
+	}
+	for ; level < len(g.groupBy); level++ {
+		values := make(map[string]any, level+1)
+		for _, code := range g.groupBy[:level+1] {
+			values[code] = row[code]
+		}
+		g.open = append(g.open, openGroup{key: groupKey(row[g.groupBy[level]]), values: values, totals: newGroupTotals(g.aggregates)})
+		if err := g.emit(GroupedRow{Kind: RowHeader, Level: level + 1, Values: values}); err != nil {
+			return err
+		}
+	}
+	for _, group := range g.open {
+		group.totals.add(row)
+	}
+	g.total.add(row)
+	return g.emit(GroupedRow{Kind: RowDetail, Level: len(g.groupBy) + 1, Values: row})
+}
+
+// Close closes the open groups and emits the grand total
+func (g *Grouper) Close() error {
+	if err := g.closeGroups(0); err != nil {
+		return err
+	}
+	values := make(map[string]any, len(g.aggregates))
+	g.total.results(values)
+	return g.emit(GroupedRow{Kind: RowTotal, Count: g.total.rows, Values: values})
+}
+
+// closeGroups emits the subtotals of the open groups below level, innermost first
+func (g *Grouper) closeGroups(level int) error {
+	for len(g.open) > level {
+		group := g.open[len(g.open)-1]
+		g.open = g.open[:len(g.open)-1]
+		values := maps.Clone(group.values)
+		group.totals.results(values)
+		if err := g.emit(GroupedRow{Kind: RowSubtotal, Level: len(g.open) + 1, Count: group.totals.rows, Values: values}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupKey compares group values by their text, as []byte values are not
+// comparable. Text is compared the way SQL Server's default case-insensitive,
+// accent-sensitive collations compare it, ignoring case and trailing spaces,
+// so that values the database sorted together such as 'ACME' and 'acme '
+// form one group. The group shows the value of its first row.
+func groupKey(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "\x00"
+	case string:
+		return textGroupKey(v)
+	case []byte:
+		return textGroupKey(string(v))
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func textGroupKey(text string) string {
+	return strings.ToLower(strings.TrimRight(text, " "))
+}
+
+type groupTotals struct {
+	aggregates []GroupAggregate
+	rows       int
+	// per aggregate: values counted, their sum, and the least and greatest value
+	counts   []int
+	sums     []float64
+	min, max []any
+}
+
+func newGroupTotals(aggregates []GroupAggregate) *groupTotals {
+	return &groupTotals{
+		aggregates: aggregates,
+		counts:     make([]int, len(aggregates)),
+		sums:       make([]float64, len(aggregates)),
+		min:        make([]any, len(aggregates)),
+		max:        make([]any, len(aggregates)),
+	}
+}
+
+func (t *groupTotals) add(row map[string]any) {
+	t.rows++
+	for i, aggregate := range t.aggregates {
+		value := row[aggregate.Code]
+		if value == nil {
+			continue
+		}
+		if aggregate.Aggregate == AggregateCount {
+			t.counts[i]++
+			continue
+		}
+		var comparable any
+		if aggregate.Format == FormatDate {
+			date, ok := DateValue(value)
+			if !ok {
+				continue
+			}
+			comparable = date
+		} else {
+			number, ok := NumberValue(value)
+			if !ok {
+				continue
+			}
+			comparable = number
+			t.sums[i] += number
+		}
+		t.counts[i]++
+		if t.min[i] == nil || less(comparable, t.min[i]) {
+			t.min[i] = comparable
+		}
+		if t.max[i] == nil || less(t.max[i], comparable) {
+			t.max[i] = comparable
+		}
+	}
+}
+
+// results sets the value of each aggregate in values; aggregates of columns
+// without values are nil, except counts and sums, which are 0
+func (t *groupTotals) results(values map[string]any) {
+	for i, aggregate := range t.aggregates {
+		switch aggregate.Aggregate {
+		case AggregateSum:
+			values[aggregate.Code] = t.sums[i]
+		case AggregateCount:
+			values[aggregate.Code] = t.counts[i]
+		case AggregateAvg:
+			if t.counts[i] > 0 {
+				values[aggregate.Code] = t.sums[i] / float64(t.counts[i])
+			} else {
+				values[aggregate.Code] = nil
+			}
+		case AggregateMin:
+			values[aggregate.Code] = t.min[i]
+		case AggregateMax:
+			values[aggregate.Code] = t.max[i]
+		}
+	}
+}
+
+// less orders two numbers or two times; values of different kinds are unordered
+func less(a, b any) bool {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		return ok && a < b
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Before(b)
+	default:
+		return false
+	}
+}
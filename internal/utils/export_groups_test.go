@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGroupRows(t *testing.T) {
+	date := func(day int) time.Time { return time.Date(2026, 1, day, 0, 0, 0, 0, time.UTC) }
+	data := []map[string]any{
+		{"region": "North", "customer": "ACME", "amount": 10.0, "qty": int64(1), "shipped": date(5)},
+		{"region": "North", "customer": "acme ", "amount": "20", "qty": nil, "shipped": "20260103"},
+		{"region": "North", "customer": "Beta", "amount": nil, "qty": nil, "shipped": nil},
+		{"region": "South", "customer": "Beta", "amount": []byte("5.5"), "qty": int64(4), "shipped": "not a date"},
+	}
+	aggregates := []GroupAggregate{
+		{Code: "amount", Aggregate: AggregateSum},
+		{Code: "qty", Aggregate: AggregateAvg},
+		{Code: "customer", Aggregate: AggregateCount},
+		{Code: "shipped", Aggregate: AggregateMin, Format: FormatDate},
+	}
+	maxShipped := []GroupAggregate{{Code: "shipped", Aggregate: AggregateMax, Format: FormatDate}}
+
+	got := GroupRows(data, []string{"region", "customer"}, aggregates)
+	want := []GroupedRow{
+		{Kind: RowHeader, Level: 1, Count: 3, Values: map[string]any{"region": "North"}},
+		// 'ACME' and 'acme ' sort together under a case-insensitive collation
+		{Kind: RowHeader, Level: 2, Count: 2, Values: map[string]any{"region": "North", "customer": "ACME"}},
+		{Kind: RowDetail, Level: 3, Values: data[0]},
+		{Kind: RowDetail, Level: 3, Values: data[1]},
+		{Kind: RowSubtotal, Level: 2, Count: 2, Values: map[string]any{"region": "North", "customer": 2, "amount": 30.0, "qty": 1.0, "shipped": date(3)}},
+		{Kind: RowHeader, Level: 2, Count: 1, Values: map[string]any{"region": "North", "customer": "Beta"}},
+		{Kind: RowDetail, Level: 3, Values: data[2]},
+		// Without values sums and counts are 0 and the other aggregates nil
+		{Kind: RowSubtotal, Level: 2, Count: 1, Values: map[string]any{"region": "North", "customer": 1, "amount": 0.0, "qty": nil, "shipped": nil}},
+		{Kind: RowSubtotal, Level: 1, Count: 3, Values: map[string]any{"region": "North", "customer": 3, "amount": 30.0, "qty": 1.0, "shipped": date(3)}},
+		{Kind: RowHeader, Level: 1, Count: 1, Values: map[string]any{"region": "South"}},
+		{Kind: RowHeader, Level: 2, Count: 1, Values: map[string]any{"region": "South", "customer": "Beta"}},
+		{Kind: RowDetail, Level: 3, Values: data[3]},
+		{Kind: RowSubtotal, Level: 2, Count: 1, Values: map[string]any{"region": "South", "customer": 1, "amount": 5.5, "qty": 4.0, "shipped": nil}},
+		{Kind: RowSubtotal, Level: 1, Count: 1, Values: map[string]any{"region": "South", "customer": 1, "amount": 5.5, "qty": 4.0, "shipped": nil}},
+		{Kind: RowTotal, Count: 4, Values: map[string]any{"customer": 4, "amount": 35.5, "qty": 2.5, "shipped": date(3)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range max(len(got), len(want)) {
+			var g, w any
+			if i < len(got) {
+				g = got[i]
+			}
+			if i < len(want) {
+				w = want[i]
+			}
+			if !reflect.DeepEqual(g, w) {
+				t.Errorf("row %d: got %+v, want %+v", i, g, w)
+			}
+		}
+	}
+
+	grouped := GroupRows(data, []string{"region"}, maxShipped)
+	if total := grouped[len(grouped)-1]; total.Kind != RowTotal || total.Values["shipped"] != date(5) {
+		t.Errorf("max date total = %+v, want %v", total, date(5))
+	}
+}
+
+func TestGroupRowsEmpty(t *testing.T) {
+	got := GroupRows(nil, []string{"region"}, []GroupAggregate{{Code: "amount", Aggregate: AggregateAvg}})
+	want := []GroupedRow{{Kind: RowTotal, Values: map[string]any{"amount": nil}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupRows(nil) = %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupRowsNilGroupValue(t *testing.T) {
+	data := []map[string]any{
+		{"region": nil, "amount": 1.0},
+		{"region": nil, "amount": 2.0},
+		{"region": "", "amount": 4.0},
+	}
+	got := GroupRows(data, []string{"region"}, []GroupAggregate{{Code: "amount", Aggregate: AggregateSum}})
+	var subtotals []float64
+	for _, row := range got {
+		if row.Kind == RowSubtotal {
+			subtotals = append(subtotals, row.Values["amount"].(float64))
+		}
+	}
+	// NULL and the empty string are different groups
+	if !reflect.DeepEqual(subtotals, []float64{3, 4}) {
+		t.Errorf("subtotals = %v, want [3 4]", subtotals)
+	}
+}
+
+func TestGrouperStopsAtEmitError(t *testing.T) {
+	stop := errors.New("stop")
+	var emitted []RowKind
+	grouper := NewGrouper([]string{"region"}, nil, func(row GroupedRow) error {
+		emitted = append(emitted, row.Kind)
+		if row.Kind == RowDetail {
+			return stop
+		}
+		return nil
+	})
+	if err := grouper.Add(map[string]any{"region": "North"}); !errors.Is(err, stop) {
+		t.Fatalf("Add = %v, want the emit error", err)
+	}
+	if !reflect.DeepEqual(emitted, []RowKind{RowHeader, RowDetail}) {
+		t.Errorf("emitted = %v", emitted)
+	}
+}
+
+func TestGrouperEmitsHeadersWithoutCount(t *testing.T) {
+	var rows []GroupedRow
+	grouper := NewGrouper([]string{"region"}, nil, func(row GroupedRow) error {
+		rows = append(rows, row)
+		return nil
+	})
+	for _, region := range []string{"North", "North", "South"} {
+		if err := grouper.Add(map[string]any{"region": region}); err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+	}
+	if err := grouper.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var kinds []RowKind
+	for _, row := range rows {
+		kinds = append(kinds, row.Kind)
+		if row.Kind == RowHeader && row.Count != 0 {
+			t.Errorf("header %v has count %d before its group is read", row.Values, row.Count)
+		}
+	}
+	want := []RowKind{RowHeader, RowDetail, RowDetail, RowSubtotal, RowHeader, RowDetail, RowSubtotal, RowTotal}
+	if !reflect.DeepEqual(kinds, want) {
+		t.Errorf("kinds = %v, want %v", kinds, want)
+	}
+	if rows[3].Count != 2 || rows[6].Count != 1 || rows[7].Count != 3 {
+		t.Errorf("counts = %d %d %d, want 2 1 3", rows[3].Count, rows[6].Count, rows[7].Count)
+	}
+}
+
+func TestGroupKey(t *testing.T) {
+	tests := []struct {
+		a, b  any
+		equal bool
+	}{
+		{a: "ACME", b: "acme  ", equal: true},
+		{a: "ACME", b: []byte("Acme"), equal: true},
+		{a: " ACME", b: "ACME", equal: false},
+		{a: "Đơn", b: "đơn", equal: true},
+		{a: "Don", b: "Đơn", equal: false},
+		{a: nil, b: "", equal: false},
+		{a: int64(1), b: 1.0, equal: true},
+		{a: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), b: time.Date(2026, 1, 2, 0, 0, 0, 1, time.UTC), equal: false},
+	}
+	for _, tt := range tests {
+		if got := groupKey(tt.a) == groupKey(tt.b); got != tt.equal {
+			t.Errorf("groupKey(%#v) == groupKey(%#v) is %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnFormat selects how the values of an exported column are written
+type ColumnFormat int
+
+const (
+	FormatText ColumnFormat = iota
+	FormatInteger
+	FormatDecimal
+	FormatDate
+	FormatCurrency
+	// FormatPercent values are fractions; 0.15 is written as 15%
+	FormatPercent
+)
+
+// IsNumeric reports whether the values of the format are numbers
+func (f ColumnFormat) IsNumeric() bool {
+	switch f {
+	case FormatInteger, FormatDecimal, FormatCurrency, FormatPercent:
+		return true
+	default:
+		return false
+	}
+}
+
+// Column alignments
+const (
+	AlignLeft   = "left"
+	AlignCenter = "center"
+	AlignRight  = "right"
+)
+
+type ExportColumn struct {
+	Title  string
+	Format ColumnFormat
+	// Decimals overrides the number of fraction digits of the format
+	Decimals *int
+	// Width is the column width in characters; 0 leaves it to the writer
+	Width int
+	// Align is AlignLeft, AlignCenter or AlignRight; empty aligns by format
+	Align string
+	// NumberFormat is an Excel number format code used instead of the one
+	// derived from Format and Decimals
+	NumberFormat string
+	// SummaryCount marks columns whose header, subtotal and total rows hold
+	// counts rather than values of the column's format
+	SummaryCount bool
+}
+
+// FractionDigits is the number of decimals the column's numbers are shown
+// with: Decimals when set, otherwise 2 for decimal, currency and percent
+// columns and 0 for the rest. Percent digits apply to the percentage.
+func (c ExportColumn) FractionDigits() int {
+	if c.Decimals != nil {
+		return *c.Decimals
+	}
+	switch c.Format {
+	case FormatDecimal, FormatCurrency, FormatPercent:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Alignment is the column's alignment, defaulting to right for numbers,
+// center for dates and left for text
+func (c ExportColumn) Alignment() string {
+	if c.Align != "" {
+		return c.Align
+	}
+	switch {
+	case c.Format.IsNumeric():
+		return AlignRight
+	case c.Format == FormatDate:
+		return AlignCenter
+	default:
+		return AlignLeft
+	}
+}
+
+// ExportRow is a row of an ExportTable with its values in column order. Kind
+// and Level are those of a GroupedRow; rows of tables without groups leave
+// them empty.
+type ExportRow struct {
+	Values []any
+	Kind   RowKind
+	Level  int
+}
+
+// ExportTable is a titled table written by the export writers. Rows calls fn
+// with each row in order and returns the first error of fn or of reading the
+// rows; Values may be reused once fn returns. Writers call Rows once, so the
+// rows can come straight from a database cursor.
+type ExportTable struct {
+	Title   string
+	Columns []ExportColumn
+	Rows    func(fn func(row ExportRow) error) error
+}
+
+// NumberValue returns value as a number. DECIMAL values, which the driver
+// returns as text, are parsed.
+func NumberValue(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil
+	case []byte:
+		number, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// RoundNumber rounds number to the given number of fraction digits
+func RoundNumber(number float64, digits int) float64 {
+	scale := math.Pow10(digits)
+	return math.Round(number*scale) / scale
+}
+
+// dateLayouts are the text forms of dates found in ERP tables, which often
+// keep dates as YYYYMMDD strings
+var dateLayouts = []string{
+	"2006-01-02",
+	"20060102",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02",
+	"02/01/2006",
+}
+
+// DateValue returns value as a time, parsing dates kept as text
+func DateValue(value any) (time.Time, bool) {
+	var text string
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero()
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return time.Time{}, false
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, text); err == nil {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,404 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// DejaVu covers Vietnamese, which the standard PDF fonts do not
+var (
+	//go:embed fonts/DejaVuSansCondensed.ttf
+	pdfFontRegular []byte
+	//go:embed fonts/DejaVuSansCondensed-Bold.ttf
+	pdfFontBold []byte
+)
+
+const (
+	pdfFont       = "DejaVu"
+	pdfMargin     = 10.0
+	pdfFooter     = 10.0
+	pdfFontSize   = 8.0
+	pdfLineHeight = 4.0
+	pdfPadding    = 0.8
+	// pdfSampleRows is how many rows WritePDFTable reads to size its columns
+	pdfSampleRows = 200
+)
+
+// PDFCompany is printed in the title block of every page
+type PDFCompany struct {
+	Name    string
+	Address string
+	Phone   string
+}
+
+type PDFOptions struct {
+	Title string
+	// Landscape selects landscape A4 pages; portrait otherwise
+	Landscape bool
+	Company   PDFCompany
+}
+
+// PDFField is a label and value printed by PDFDocument.Fields
+type PDFField struct {
+	Label string
+	Value any
+}
+
+// PDFDocument lays out headings and tables on printable A4 pages. Each page
+// starts with the company title block and, while a table is open, the table's
+// header row; pages are numbered in the footer. Rows are never split across
+// pages.
+type PDFDocument struct {
+	pdf         *fpdf.Fpdf
+	options     PDFOptions
+	generatedAt time.Time
+	// The open table, nil between tables
+	columns []ExportColumn
+	widths  []float64
+}
+
+type pdfCell struct {
+	text  string
+	width float64
+	align string
+	bold  bool
+	fill  bool
+}
+
+func NewPDFDocument(options PDFOptions) *PDFDocument {
+	orientation := "P"
+	if options.Landscape {
+		orientation = "L"
+	}
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", pdfFontRegular)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", pdfFontBold)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.AliasNbPages("{nb}")
+	pdf.SetTitle(options.Title, true)
+	pdf.SetDrawColor(120, 120, 120)
+
+	d := &PDFDocument{pdf: pdf, options: options, generatedAt: time.Now()}
+	pdf.SetHeaderFunc(d.header)
+	pdf.SetFooterFunc(d.footer)
+	pdf.AddPage()
+	return d
+}
+
+func (d *PDFDocument) header() {
+	pdf := d.pdf
+	pageWidth, _ := pdf.GetPageSize()
+	width := pageWidth - 2*pdfMargin
+	company := d.options.Company
+
+	pdf.SetXY(pdfMargin, pdfMargin)
+	pdf.SetFont(pdfFont, "B", 11)
+	pdf.CellFormat(width/2, 5, company.Name, "", 0, "L", false, 0, "")
+	pdf.SetFont(pdfFont, "B", 12)
+	pdf.CellFormat(width/2, 5, d.options.Title, "", 1, "R", false, 0, "")
+
+	pdf.SetFont(pdfFont, "", 8)
+	contact := company.Address
+	if company.Phone != "" {
+		contact = strings.TrimPrefix(contact+" - ĐT: "+company.Phone, " - ")
+	}
+	pdf.CellFormat(width/2, 4, contact, "", 0, "L", false, 0, "")
+	pdf.CellFormat(width/2, 4, "Ngày in: "+d.generatedAt.Format("2006-01-02 15:04"), "", 1, "R", false, 0, "")
+
+	y := pdf.GetY() + 1.5
+	pdf.Line(pdfMargin, y, pageWidth-pdfMargin, y)
+	pdf.SetXY(pdfMargin, y+3)
+
+	if d.columns != nil {
+		d.headerRow()
+	}
+}
+
+func (d *PDFDocument) footer() {
+	pdf := d.pdf
+	pdf.SetY(-pdfFooter)
+	pdf.SetFont(pdfFont, "", 8)
+	pdf.CellFormat(0, 5, fmt.Sprintf("Trang %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+}
+
+// Heading prints a full-width banner, starting a new page when fewer than
+// a few rows would fit below it
+func (d *PDFDocument) Heading(text string) {
+	d.ensureSpace(6 + 3*(pdfLineHeight+2*pdfPadding))
+	pdf := d.pdf
+	pageWidth, _ := pdf.GetPageSize()
+	pdf.SetFont(pdfFont, "B", 10)
+	pdf.SetFillColor(68, 114, 196)
+	pdf.SetTextColor(255, 255, 255)
+	pdf.CellFormat(pageWidth-2*pdfMargin, 6, text, "1", 1, "L", true, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// Caption prints a line of bold text above a table
+func (d *PDFDocument) Caption(text string) {
+	d.ensureSpace(5 + 3*(pdfLineHeight+2*pdfPadding))
+	d.pdf.SetFont(pdfFont, "B", 9)
+	d.pdf.CellFormat(0, 5, text, "", 1, "L", false, 0, "")
+}
+
+// Space leaves a blank gap of height mm
+func (d *PDFDocument) Space(height float64) {
+	d.pdf.SetY(d.pdf.GetY() + height)
+}
+
+// Table opens a table and prints its header row. weights are the relative
+// column widths; nil gives every column the same width.
+func (d *PDFDocument) Table(columns []ExportColumn, weights []float64) {
+	pageWidth, _ := d.pdf.GetPageSize()
+	d.columns = columns
+	d.widths = pdfWidths(len(columns), weights, pageWidth-2*pdfMargin)
+	d.headerRow()
+}
+
+// EndTable closes the open table, so later pages no longer repeat its header
+func (d *PDFDocument) EndTable() {
+	d.columns, d.widths = nil, nil
+}
+
+// Row prints a row of the open table, with values in column order
+func (d *PDFDocument) Row(values []any) {
+	cells := make([]pdfCell, len(d.columns))
+	for i, column := range d.columns {
+		var value any
+		if i < len(values) {
+			value = values[i]
+		}
+		cells[i] = pdfCell{text: pdfText(value, column), width: d.widths[i], align: pdfAlign(column)}
+	}
+	d.row(cells)
+}
+
+// Fields prints labelled values, two to a line
+func (d *PDFDocument) Fields(fields []PDFField) {
+	pageWidth, _ := d.pdf.GetPageSize()
+	width := (pageWidth - 2*pdfMargin) / 2
+	for i := 0; i < len(fields); i += 2 {
+		var cells []pdfCell
+		for _, field := range fields[i:min(i+2, len(fields))] {
+			cells = append(cells,
+				pdfCell{text: field.Label, width: width * 0.35, align: "L", bold: true, fill: true},
+				pdfCell{text: pdfText(field.Value, ExportColumn{}), width: width * 0.65, align: "L"})
+		}
+		d.row(cells)
+	}
+}
+
+func (d *PDFDocument) headerRow() {
+	cells := make([]pdfCell, len(d.columns))
+	for i, column := range d.columns {
+		cells[i] = pdfCell{text: column.Title, width: d.widths[i], align: "C", bold: true, fill: true}
+	}
+	d.row(cells)
+}
+
+// row prints cells side by side, wrapping their text; the row is as tall as
+// its longest cell and moves to a new page when it does not fit
+func (d *PDFDocument) row(cells []pdfCell) {
+	pdf := d.pdf
+	lines := make([][]string, len(cells))
+	count := 1
+	for i, cell := range cells {
+		pdf.SetFont(pdfFont, pdfStyle(cell.bold), pdfFontSize)
+		if cell.text != "" {
+			lines[i] = pdf.SplitText(cell.text, cell.width)
+		}
+		count = max(count, len(lines[i]))
+	}
+	height := float64(count)*pdfLineHeight + 2*pdfPadding
+	d.ensureSpace(height)
+
+	pdf.SetFillColor(217, 225, 242)
+	x, y := pdfMargin, pdf.GetY()
+	for i, cell := range cells {
+		style := "D"
+		if cell.fill {
+			style = "FD"
+		}
+		pdf.Rect(x, y, cell.width, height, style)
+		pdf.SetFont(pdfFont, pdfStyle(cell.bold), pdfFontSize)
+		for j, line := range lines[i] {
+			pdf.SetXY(x, y+pdfPadding+float64(j)*pdfLineHeight)
+			pdf.CellFormat(cell.width, pdfLineHeight, line, "", 0, cell.align, false, 0, "")
+		}
+		x += cell.width
+	}
+	pdf.SetXY(pdfMargin, y+height)
+}
+
+func (d *PDFDocument) ensureSpace(height float64) {
+	_, pageHeight := d.pdf.GetPageSize()
+	if d.pdf.GetY()+height > pageHeight-pdfFooter-2 {
+		d.pdf.AddPage()
+	}
+}
+
+// Write finishes the document and writes it to w
+func (d *PDFDocument) Write(w io.Writer) error {
+	if err := d.pdf.Output(w); err != nil {
+		return fmt.Errorf("error writing pdf file: %w", err)
+	}
+	return nil
+}
+
+// WritePDFTable writes table as a PDF document to w. Column widths follow the
+// length of the titles and of the first rows.
+func WritePDFTable(w io.Writer, table ExportTable, options PDFOptions) error {
+	if len(table.Columns) == 0 {
+		return fmt.Errorf("pdf table has no columns")
+	}
+	if options.Title == "" {
+		options.Title = table.Title
+	}
+	// Titles may wrap over two lines, but not inside a word
+	weights := make([]float64, len(table.Columns))
+	for i, column := range table.Columns {
+		weights[i] = float64(utf8.RuneCountInString(column.Title)) / 2
+		for _, word := range strings.Fields(column.Title) {
+			weights[i] = max(weights[i], float64(utf8.RuneCountInString(word)))
+		}
+	}
+
+	// The first rows are held back until the column widths are known
+	var d *PDFDocument
+	sample := make([][]any, 0, pdfSampleRows)
+	start := func() {
+		for _, values := range sample {
+			for j, column := range table.Columns {
+				if j < len(values) {
+					weights[j] = max(weights[j], float64(utf8.RuneCountInString(pdfText(values[j], column))))
+				}
+			}
+		}
+		for i, column := range table.Columns {
+			weights[i] = min(max(weights[i], 4), 40)
+			if column.Width > 0 {
+				weights[i] = float64(column.Width)
+			}
+		}
+		d = NewPDFDocument(options)
+		d.Table(table.Columns, weights)
+		for _, values := range sample {
+			d.Row(values)
+		}
+		sample = nil
+	}
+	err := table.Rows(func(row ExportRow) error {
+		if d == nil {
+			if len(sample) < pdfSampleRows {
+				sample = append(sample, slices.Clone(row.Values))
+				return nil
+			}
+			start()
+		}
+		d.Row(row.Values)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if d == nil {
+		start()
+	}
+	d.EndTable()
+	return d.Write(w)
+}
+
+func pdfWidths(count int, weights []float64, total float64) []float64 {
+	widths := make([]float64, count)
+	sum := 0.0
+	for i := range widths {
+		widths[i] = 1
+		if i < len(weights) && weights[i] > 0 {
+			widths[i] = weights[i]
+		}
+		sum += widths[i]
+	}
+	for i := range widths {
+		widths[i] = widths[i] / sum * total
+	}
+	return widths
+}
+
+func pdfStyle(bold bool) string {
+	if bold {
+		return "B"
+	}
+	return ""
+}
+
+func pdfAlign(column ExportColumn) string {
+	switch column.Alignment() {
+	case AlignRight:
+		return "R"
+	case AlignCenter:
+		return "C"
+	default:
+		return "L"
+	}
+}
+
+// pdfText formats a value for printing: numbers with thousands separators and
+// the column's decimals, percentages with a % sign, and dates without the time.
+func pdfText(value any, column ExportColumn) string {
+	if column.Format.IsNumeric() {
+		if number, ok := NumberValue(value); ok {
+			if column.Format == FormatPercent {
+				return groupThousands(strconv.FormatFloat(number*100, 'f', column.FractionDigits(), 64)) + "%"
+			}
+			return groupThousands(strconv.FormatFloat(number, 'f', column.FractionDigits(), 64))
+		}
+	}
+	if column.Format == FormatDate {
+		if date, ok := DateValue(value); ok {
+			return date.Format("2006-01-02")
+		}
+	}
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case []byte:
+		return strings.TrimSpace(string(v))
+	case time.Time:
+		return v.Format("2006-01-02 15:04")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// groupThousands inserts "," between groups of three digits of a formatted number
+func groupThousands(number string) string {
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	integer, fraction, hasFraction := strings.Cut(number, ".")
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	if hasFraction {
+		b.WriteByte('.')
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
@@ -51,6 +51,15 @@ func CreatedResponse(c fiber.Ctx, message string, data interface{}) error {
 	})
 }
 
+// AcceptedResponse sends accepted response (202) for work that continues in the background
+func AcceptedResponse(c fiber.Ctx, message string, data interface{}) error {
+	return c.Status(fiber.StatusAccepted).JSON(APIResponse{
+		Success: true,
+		Message: message,
+		Data:    data,
+	})
+}
+
 // NoContentResponse sends no content response (204)
 func NoContentResponse(c fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var ErrUnsafeSQL = errors.New("query is not a read-only SELECT")
+
+// SQLValidationError points at the token that made a query unsafe.
+// Line and Column are 1-based.
+type SQLValidationError struct {
+	Token  string
+	Line   int
+	Column int
+	Reason string
+}
+
+func (e *SQLValidationError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s at line %d, column %d", e.Reason, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s: %q at line %d, column %d", e.Reason, e.Token, e.Line, e.Column)
+}
+
+func (e *SQLValidationError) Unwrap() error {
+	return ErrUnsafeSQL
+}
+
+// forbiddenSQLKeywords can change data, schema, permissions or server state,
+// or reach outside the ERP database.
+var forbiddenSQLKeywords = map[string]bool{
+	"INSERT": true, "UPDATE": true, "DELETE": true, "MERGE": true, "TRUNCATE": true,
+	"EXEC": true, "EXECUTE": true, "CREATE": true, "ALTER": true, "DROP": true,
+	"GRANT": true, "REVOKE": true, "DENY": true, "BACKUP": true, "RESTORE": true,
+	"DBCC": true, "SHUTDOWN": true, "KILL": true, "USE": true, "GO": true,
+	"INTO": true, "DECLARE": true, "SET": true, "BULK": true, "WAITFOR": true,
+	"RECONFIGURE": true, "OPENROWSET": true, "OPENQUERY": true, "OPENDATASOURCE": true,
+	"OPENXML": true, "BEGIN": true, "COMMIT": true, "ROLLBACK": true,
+}
+
+type sqlToken struct {
+	text   string
+	line   int
+	column int
+	// offset is the rune index of the token in the query
+	offset int
+}
+
+// ValidateReadOnlySQL accepts a single SELECT statement, optionally preceded by
+// a WITH clause. Keywords inside string literals, quoted identifiers and
+// comments are ignored. The returned error is a *SQLValidationError.
+func ValidateReadOnlySQL(query string) error {
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return &SQLValidationError{Line: 1, Column: 1, Reason: "query is empty"}
+	}
+
+	first := firstWord(tokens)
+	if first == nil || (!strings.EqualFold(first.text, "SELECT") && !strings.EqualFold(first.text, "WITH")) {
+		tok := tokens[0]
+		if first != nil {
+			tok = *first
+		}
+		return &SQLValidationError{Token: tok.text, Line: tok.line, Column: tok.column, Reason: "query must start with SELECT or WITH"}
+	}
+
+	for i, tok := range tokens {
+		if tok.text == ";" {
+			// A trailing semicolon is fine; anything after it is a second statement
+			for _, rest := range tokens[i+1:] {
+				if rest.text != ";" {
+					return &SQLValidationError{Token: rest.text, Line: rest.line, Column: rest.column, Reason: "multiple statements are not allowed"}
+				}
+			}
+			break
+		}
+
+		word := strings.ToUpper(tok.text)
+		if forbiddenSQLKeywords[word] {
+			return &SQLValidationError{Token: tok.text, Line: tok.line, Column: tok.column, Reason: "forbidden keyword"}
+		}
+		if strings.HasPrefix(word, "SP_") || strings.HasPrefix(word, "XP_") {
+			return &SQLValidationError{Token: tok.text, Line: tok.line, Column: tok.column, Reason: "stored procedures are not allowed"}
+		}
+	}
+	return nil
+}
+
+func firstWord(tokens []sqlToken) *sqlToken {
+	for i := range tokens {
+		if tokens[i].text != "(" {
+			return &tokens[i]
+		}
+	}
+	return nil
+}
+
+// tokenizeSQL returns the words and punctuation of a T-SQL text, skipping
+// whitespace, comments, string literals and quoted identifiers.
+func tokenizeSQL(query string) ([]sqlToken, error) {
+	runes := []rune(query)
+	var tokens []sqlToken
+	line, column, pos := 1, 1, 0
+
+	advance := func(n int) {
+		pos += n
+		for k := 0; k < n; k++ {
+			if runes[0] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+			runes = runes[1:]
+		}
+	}
+
+	for len(runes) > 0 {
+		r := runes[0]
+		startLine, startColumn, start := line, column, pos
+		switch {
+		case unicode.IsSpace(r):
+			advance(1)
+		case r == '-' && len(runes) > 1 && runes[1] == '-':
+			for len(runes) > 0 && runes[0] != '\n' {
+				advance(1)
+			}
+		case r == '/' && len(runes) > 1 && runes[1] == '*':
+			// T-SQL block comments nest
+			depth := 0
+			for {
+				if len(runes) == 0 {
+					return nil, &SQLValidationError{Token: "/*", Line: startLine, Column: startColumn, Reason: "unterminated comment"}
+				}
+				if runes[0] == '/' && len(runes) > 1 && runes[1] == '*' {
+					depth++
+					advance(2)
+					continue
+				}
+				if runes[0] == '*' && len(runes) > 1 && runes[1] == '/' {
+					depth--
+					advance(2)
+					if depth == 0 {
+						break
+					}
+					continue
+				}
+				advance(1)
+			}
+		case r == '\'' || ((r == 'N' || r == 'n') && len(runes) > 1 && runes[1] == '\''):
+			if r != '\'' {
+				advance(1)
+			}
+			if err := skipQuoted(&runes, advance, '\'', startLine, startColumn, "unterminated string literal"); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{text: "'…'", line: startLine, column: startColumn, offset: start})
+		case r == '"':
+			if err := skipQuoted(&runes, advance, '"', startLine, startColumn, "unterminated quoted identifier"); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{text: `"…"`, line: startLine, column: startColumn, offset: start})
+		case r == '[':
+			if err := skipQuoted(&runes, advance, ']', startLine, startColumn, "unterminated quoted identifier"); err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, sqlToken{text: "[…]", line: startLine, column: startColumn, offset: start})
+		case isSQLWordRune(r):
+			n := 0
+			for n < len(runes) && isSQLWordRune(runes[n]) {
+				n++
+			}
+			tokens = append(tokens, sqlToken{text: string(runes[:n]), line: startLine, column: startColumn, offset: start})
+			advance(n)
+		default:
+			tokens = append(tokens, sqlToken{text: string(r), line: startLine, column: startColumn, offset: start})
+			advance(1)
+		}
+	}
+	return tokens, nil
+}
+
+// skipQuoted consumes a quoted section whose closing quote is escaped by doubling it
+func skipQuoted(runes *[]rune, advance func(int), closing rune, line, column int, reason string) error {
+	advance(1)
+	for {
+		if len(*runes) == 0 {
+			return &SQLValidationError{Line: line, Column: column, Reason: reason}
+		}
+		if (*runes)[0] == closing {
+			if len(*runes) > 1 && (*runes)[1] == closing {
+				advance(2)
+				continue
+			}
+			advance(1)
+			return nil
+		}
+		advance(1)
+	}
+}
+
+func isSQLWordRune(r rune) bool {
+	return r == '_' || r == '@' || r == '#' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
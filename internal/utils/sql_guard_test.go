@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestValidateReadOnlySQLForbiddenKeywords(t *testing.T) {
+	keywords := make([]string, 0, len(forbiddenSQLKeywords))
+	for keyword := range forbiddenSQLKeywords {
+		keywords = append(keywords, keyword)
+	}
+	sort.Strings(keywords)
+
+	for _, keyword := range keywords {
+		t.Run(keyword, func(t *testing.T) {
+			// The keyword sits at line 2, column 3, in lower case
+			query := "SELECT a FROM t\n  " + strings.ToLower(keyword) + " x"
+			assertSQLValidationError(t, query, SQLValidationError{Token: strings.ToLower(keyword), Line: 2, Column: 3, Reason: "forbidden keyword"})
+		})
+	}
+}
+
+func TestValidateReadOnlySQLRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  SQLValidationError
+	}{
+		{
+			name:  "select into temp table",
+			query: "SELECT a, b INTO #tmp FROM t",
+			want:  SQLValidationError{Token: "INTO", Line: 1, Column: 13, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "waitfor",
+			query: "SELECT 1;\nWAITFOR DELAY '00:00:10'",
+			want:  SQLValidationError{Token: "WAITFOR", Line: 2, Column: 1, Reason: "multiple statements are not allowed"},
+		},
+		{
+			name:  "waitfor without separator",
+			query: "SELECT 1 WAITFOR DELAY '00:00:10'",
+			want:  SQLValidationError{Token: "WAITFOR", Line: 1, Column: 10, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "openrowset",
+			query: "SELECT * FROM OPENROWSET('SQLNCLI', 'Server=x;', 'SELECT 1') AS r",
+			want:  SQLValidationError{Token: "OPENROWSET", Line: 1, Column: 15, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "exec",
+			query: "EXEC sp_who",
+			want:  SQLValidationError{Token: "EXEC", Line: 1, Column: 1, Reason: "query must start with SELECT or WITH"},
+		},
+		{
+			name:  "exec inside select",
+			query: "SELECT 1 exec('DROP TABLE t')",
+			want:  SQLValidationError{Token: "exec", Line: 1, Column: 10, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "stored procedure",
+			query: "SELECT * FROM master..xp_dirtree",
+			want:  SQLValidationError{Token: "xp_dirtree", Line: 1, Column: 23, Reason: "stored procedures are not allowed"},
+		},
+		{
+			name:  "second statement",
+			query: "SELECT a FROM t; SELECT b FROM u",
+			want:  SQLValidationError{Token: "SELECT", Line: 1, Column: 18, Reason: "multiple statements are not allowed"},
+		},
+		{
+			name:  "statement after several semicolons",
+			query: "SELECT a FROM t;;\n\tDELETE FROM t",
+			want:  SQLValidationError{Token: "DELETE", Line: 2, Column: 2, Reason: "multiple statements are not allowed"},
+		},
+		{
+			name:  "cte feeding a delete",
+			query: "WITH a AS (SELECT id FROM t) DELETE FROM a",
+			want:  SQLValidationError{Token: "DELETE", Line: 1, Column: 30, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "keyword after a comment",
+			query: "SELECT a /* note */ FROM t -- trailing\nUNION SELECT b INTO x FROM u",
+			want:  SQLValidationError{Token: "INTO", Line: 2, Column: 16, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "keyword after a string",
+			query: "SELECT N'Đơn hàng', 'it''s' UPDATE t SET a = 1",
+			want:  SQLValidationError{Token: "UPDATE", Line: 1, Column: 29, Reason: "forbidden keyword"},
+		},
+		{
+			name:  "not a select",
+			query: "  -- comment\n  update t set a = 1",
+			want:  SQLValidationError{Token: "update", Line: 2, Column: 3, Reason: "query must start with SELECT or WITH"},
+		},
+		{
+			name:  "empty",
+			query: " -- only a comment\n",
+			want:  SQLValidationError{Line: 1, Column: 1, Reason: "query is empty"},
+		},
+		{
+			name:  "unterminated string",
+			query: "SELECT 'abc",
+			want:  SQLValidationError{Line: 1, Column: 8, Reason: "unterminated string literal"},
+		},
+		{
+			name:  "unterminated comment",
+			query: "SELECT 1 /* /* nested */ DELETE",
+			want:  SQLValidationError{Token: "/*", Line: 1, Column: 10, Reason: "unterminated comment"},
+		},
+		{
+			name:  "unterminated identifier",
+			query: "SELECT [a FROM t",
+			want:  SQLValidationError{Line: 1, Column: 8, Reason: "unterminated quoted identifier"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertSQLValidationError(t, tt.query, tt.want)
+		})
+	}
+}
+
+func TestValidateReadOnlySQLAccepts(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "select", query: "SELECT a, b FROM t WHERE c = @from_date"},
+		{name: "trailing semicolon", query: "SELECT a FROM t;"},
+		{name: "trailing semicolons and comment", query: "SELECT a FROM t; ;\n-- done"},
+		{name: "parenthesized", query: "(SELECT a FROM t) UNION ALL (SELECT b FROM u)"},
+		{name: "cte", query: "WITH a AS (SELECT id FROM t), b AS (SELECT id FROM a) SELECT * FROM b"},
+		{name: "line comment", query: "SELECT a -- DELETE FROM t; DROP TABLE t\nFROM t"},
+		{name: "block comment", query: "SELECT a /* INTO #tmp; EXEC sp_who */ FROM t"},
+		{name: "nested block comment", query: "SELECT a /* outer /* UPDATE */ still comment; DROP */ FROM t"},
+		{name: "string", query: "SELECT 'DELETE FROM t; EXEC xp_cmdshell' AS a"},
+		{name: "string with doubled quote", query: "SELECT 'it''s; DROP TABLE t' AS a"},
+		{name: "unicode string", query: "SELECT N'WAITFOR DELAY; INSERT' AS a"},
+		{name: "bracketed identifiers", query: "SELECT [Update], [Into], [set]]x] FROM [Delete]"},
+		{name: "quoted identifiers", query: `SELECT "Grant" FROM "Drop"`},
+		{name: "keyword prefixes", query: "SELECT updated_at, insert_date, settings, executed FROM t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateReadOnlySQL(tt.query); err != nil {
+				t.Errorf("ValidateReadOnlySQL(%q) = %v, want nil", tt.query, err)
+			}
+		})
+	}
+}
+
+func assertSQLValidationError(t *testing.T, query string, want SQLValidationError) {
+	t.Helper()
+	err := ValidateReadOnlySQL(query)
+	if !errors.Is(err, ErrUnsafeSQL) {
+		t.Fatalf("ValidateReadOnlySQL(%q) = %v, want ErrUnsafeSQL", query, err)
+	}
+	var got *SQLValidationError
+	if !errors.As(err, &got) {
+		t.Fatalf("ValidateReadOnlySQL(%q) = %T, want *SQLValidationError", query, err)
+	}
+	if *got != want {
+		t.Errorf("ValidateReadOnlySQL(%q) = %+v, want %+v", query, *got, want)
+	}
+}
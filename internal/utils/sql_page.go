@@ -0,0 +1,108 @@
+package utils
+
+import "strings"
+
+// SplitReportSQL splits a read-only report query into its WITH clause (empty
+// when there is none) and the final SELECT, so the SELECT can be wrapped as a
+// derived table. Trailing semicolons are dropped, and a top-level ORDER BY
+// without TOP or OFFSET gets "OFFSET 0 ROWS", which SQL Server requires for an
+// ordered derived table. Bodies with a query hint or FOR XML/JSON cannot be
+// wrapped and are rejected. The body may end in a line comment, so callers
+// wrapping it must start a new line after it.
+func SplitReportSQL(query string) (with string, body string, err error) {
+	if err := ValidateReadOnlySQL(query); err != nil {
+		return "", "", err
+	}
+	tokens, err := tokenizeSQL(query)
+	if err != nil {
+		return "", "", err
+	}
+	runes := []rune(query)
+
+	end := len(runes)
+	for i, tok := range tokens {
+		if tok.text == ";" {
+			end = tok.offset
+			tokens = tokens[:i]
+			break
+		}
+	}
+
+	start := 0
+	if first := firstWord(tokens); strings.EqualFold(first.text, "WITH") {
+		start = cteEnd(tokens)
+		if start < 0 {
+			return "", "", &SQLValidationError{Token: first.text, Line: first.line, Column: first.column, Reason: "WITH clause has no final SELECT"}
+		}
+		with = strings.TrimSpace(string(runes[:tokens[start].offset]))
+		tokens = tokens[start:]
+		start = tokens[0].offset
+	}
+	body = strings.TrimSpace(string(runes[start:end]))
+
+	var ordered, limited bool
+	depth := 0
+	for i, tok := range tokens {
+		switch {
+		case tok.text == "(":
+			depth++
+		case tok.text == ")":
+			depth--
+		case depth > 0:
+		case strings.EqualFold(tok.text, "TOP"), strings.EqualFold(tok.text, "OFFSET"):
+			limited = true
+		case strings.EqualFold(tok.text, "ORDER") && i+1 < len(tokens) && strings.EqualFold(tokens[i+1].text, "BY"):
+			ordered = true
+		case i+1 < len(tokens) && isOuterClause(tok.text, tokens[i+1].text):
+			return "", "", &SQLValidationError{Token: tok.text, Line: tok.line, Column: tok.column, Reason: "query hints and FOR XML/JSON cannot be used in a derived table"}
+		}
+	}
+	if ordered && !limited {
+		body += "\nOFFSET 0 ROWS"
+	}
+	return with, body, nil
+}
+
+// isOuterClause reports whether word and next open a clause that SQL Server
+// only allows on the outermost query: a query hint or FOR XML, JSON or BROWSE.
+// FOR SYSTEM_TIME of temporal tables is allowed.
+func isOuterClause(word, next string) bool {
+	switch strings.ToUpper(word) {
+	case "OPTION":
+		return next == "("
+	case "FOR":
+		switch strings.ToUpper(next) {
+		case "XML", "JSON", "BROWSE":
+			return true
+		}
+	}
+	return false
+}
+
+// cteEnd returns the index of the first token after the common table
+// expressions of a WITH clause, or -1 when the clause never ends.
+func cteEnd(tokens []sqlToken) int {
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth != 0 || i+1 >= len(tokens) {
+				continue
+			}
+			// A column list is followed by AS and a definition by a comma
+			next := tokens[i+1].text
+			if next != "," && !strings.EqualFold(next, "AS") {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// QuoteSQLIdentifier quotes a column name with brackets
+func QuoteSQLIdentifier(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSplitReportSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		wantWith string
+		wantBody string
+	}{
+		{
+			name:     "select",
+			query:    "SELECT a FROM t",
+			wantBody: "SELECT a FROM t",
+		},
+		{
+			name:     "trailing semicolon",
+			query:    "SELECT a FROM t;\n",
+			wantBody: "SELECT a FROM t",
+		},
+		{
+			name:     "cte",
+			query:    "WITH a AS (SELECT id FROM t) SELECT * FROM a",
+			wantWith: "WITH a AS (SELECT id FROM t)",
+			wantBody: "SELECT * FROM a",
+		},
+		{
+			name:     "ctes with column lists and nested parentheses",
+			query:    "WITH a (id, total) AS (SELECT id, SUM((x + 1) * (y - 2)) FROM t GROUP BY id),\nb AS (SELECT id FROM (SELECT id FROM a) AS s)\nSELECT * FROM b",
+			wantWith: "WITH a (id, total) AS (SELECT id, SUM((x + 1) * (y - 2)) FROM t GROUP BY id),\nb AS (SELECT id FROM (SELECT id FROM a) AS s)",
+			wantBody: "SELECT * FROM b",
+		},
+		{
+			name:     "cte with parentheses and select in strings and comments",
+			query:    "WITH a AS (SELECT ')' AS p, N'(SELECT' AS q -- ) SELECT\n/* ) SELECT ( */ FROM [t)]) SELECT * FROM a",
+			wantWith: "WITH a AS (SELECT ')' AS p, N'(SELECT' AS q -- ) SELECT\n/* ) SELECT ( */ FROM [t)])",
+			wantBody: "SELECT * FROM a",
+		},
+		{
+			name:     "order by gets an offset",
+			query:    "SELECT a FROM t ORDER BY a DESC;",
+			wantBody: "SELECT a FROM t ORDER BY a DESC\nOFFSET 0 ROWS",
+		},
+		{
+			name:     "order by after a line comment",
+			query:    "SELECT a FROM t ORDER BY a -- newest first",
+			wantBody: "SELECT a FROM t ORDER BY a -- newest first\nOFFSET 0 ROWS",
+		},
+		{
+			name:     "order by with top",
+			query:    "SELECT TOP 10 a FROM t ORDER BY a",
+			wantBody: "SELECT TOP 10 a FROM t ORDER BY a",
+		},
+		{
+			name:     "order by with offset",
+			query:    "SELECT a FROM t ORDER BY a OFFSET 5 ROWS",
+			wantBody: "SELECT a FROM t ORDER BY a OFFSET 5 ROWS",
+		},
+		{
+			name:     "order by only in a subquery",
+			query:    "SELECT a FROM (SELECT TOP 5 a FROM t ORDER BY a) AS s",
+			wantBody: "SELECT a FROM (SELECT TOP 5 a FROM t ORDER BY a) AS s",
+		},
+		{
+			name:     "order by in a cte",
+			query:    "WITH a AS (SELECT TOP 5 id FROM t ORDER BY id) SELECT * FROM a ORDER BY id",
+			wantWith: "WITH a AS (SELECT TOP 5 id FROM t ORDER BY id)",
+			wantBody: "SELECT * FROM a ORDER BY id\nOFFSET 0 ROWS",
+		},
+		{
+			name:     "temporal table",
+			query:    "SELECT a FROM t FOR SYSTEM_TIME AS OF '2026-01-01'",
+			wantBody: "SELECT a FROM t FOR SYSTEM_TIME AS OF '2026-01-01'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			with, body, err := SplitReportSQL(tt.query)
+			if err != nil {
+				t.Fatalf("SplitReportSQL: %v", err)
+			}
+			if with != tt.wantWith || body != tt.wantBody {
+				t.Errorf("SplitReportSQL(%q) =\n%q\n%q\nwant\n%q\n%q", tt.query, with, body, tt.wantWith, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSplitReportSQLRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  SQLValidationError
+	}{
+		{
+			name:  "query hint after order by",
+			query: "SELECT a FROM t ORDER BY a OPTION (RECOMPILE)",
+			want:  SQLValidationError{Token: "OPTION", Line: 1, Column: 28, Reason: "query hints and FOR XML/JSON cannot be used in a derived table"},
+		},
+		{
+			name:  "for json",
+			query: "SELECT a FROM t ORDER BY a\nFOR JSON PATH",
+			want:  SQLValidationError{Token: "FOR", Line: 2, Column: 1, Reason: "query hints and FOR XML/JSON cannot be used in a derived table"},
+		},
+		{
+			name:  "for xml in the body of a cte",
+			query: "WITH a AS (SELECT 1 AS x) SELECT x FROM a for xml auto",
+			want:  SQLValidationError{Token: "for", Line: 1, Column: 43, Reason: "query hints and FOR XML/JSON cannot be used in a derived table"},
+		},
+		{
+			name:  "cte without a final select",
+			query: "WITH a AS (SELECT 1 AS x)",
+			want:  SQLValidationError{Token: "WITH", Line: 1, Column: 1, Reason: "WITH clause has no final SELECT"},
+		},
+		{
+			name:  "unsafe query",
+			query: "SELECT a INTO #t FROM t",
+			want:  SQLValidationError{Token: "INTO", Line: 1, Column: 10, Reason: "forbidden keyword"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := SplitReportSQL(tt.query)
+			var got *SQLValidationError
+			if !errors.As(err, &got) {
+				t.Fatalf("SplitReportSQL(%q) error = %v, want *SQLValidationError", tt.query, err)
+			}
+			if *got != tt.want {
+				t.Errorf("SplitReportSQL(%q) = %+v, want %+v", tt.query, *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// tempFileReader deletes its file once closed
+type tempFileReader struct {
+	*os.File
+}
+
+func (r *tempFileReader) Close() error {
+	err := r.File.Close()
+	if removeErr := os.Remove(r.Name()); removeErr != nil && err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// OpenTempFile opens a generated file for a single read and returns its
+// size. The file is deleted when the reader is closed.
+func OpenTempFile(path string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error opening file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("error reading file size: %w", err)
+	}
+	return &tempFileReader{File: file}, info.Size(), nil
+}